@@ -0,0 +1,108 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package unikmer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// countKmers writes canonical k-mers of the sequence to w in sorted order,
+// just like "unikmer count -K -s".
+func countKmers(w *io.PipeWriter, seq []byte, k int) {
+	sw, err := NewSortingWriter(w, k, UNIK_CANONICAL, 1<<20)
+	if err != nil {
+		w.CloseWithError(err)
+		return
+	}
+	var kcode KmerCode
+	for i := 0; i+k <= len(seq); i++ {
+		kcode, err = NewKmerCode(seq[i : i+k])
+		if err != nil {
+			w.CloseWithError(err)
+			return
+		}
+		if err = sw.Write(kcode.Canonical()); err != nil {
+			w.CloseWithError(err)
+			return
+		}
+	}
+	w.CloseWithError(sw.Close())
+}
+
+// This example builds a count→sort→inter pipeline in-process,
+// binary files are streamed via io.Pipe without temporary files.
+func ExampleIntersect() {
+	k := 5
+	seqs := [][]byte{
+		[]byte("ACGTACGTTTGCAAC"),
+		[]byte("GGACGTACGTTTGGG"),
+	}
+
+	readers := make([]*Reader, len(seqs))
+	for i, seq := range seqs {
+		pr, pw := io.Pipe()
+		go countKmers(pw, seq, k)
+
+		reader, err := NewReader(pr)
+		if err != nil {
+			panic(err)
+		}
+		readers[i] = reader
+	}
+
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, k, UNIK_CANONICAL|UNIK_SORTED)
+	if err != nil {
+		panic(err)
+	}
+	n, err := Intersect(readers, writer, nil)
+	if err != nil {
+		panic(err)
+	}
+	if err = writer.Flush(); err != nil {
+		panic(err)
+	}
+	fmt.Printf("%d shared k-mers\n", n)
+
+	reader, err := NewReader(&buf)
+	if err != nil {
+		panic(err)
+	}
+	for {
+		kcode, err := reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			panic(err)
+		}
+		fmt.Println(kcode)
+	}
+
+	// Output:
+	// 5 shared k-mers
+	// AAACG
+	// AACGT
+	// ACGTA
+	// CAAAC
+	// CGTAC
+}
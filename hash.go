@@ -0,0 +1,44 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+// Hash64 returns a 64-bit hash value of a code, using the finalizer of
+// MurmurHash3. It's a bijection, so no collisions happen, and the code
+// can be recovered with Unhash64. Hash values are uniformly distributed,
+// which are used for sampling and sketching k-mers.
+func Hash64(code uint64) uint64 {
+	code ^= code >> 33
+	code *= 0xff51afd7ed558ccd
+	code ^= code >> 33
+	code *= 0xc4ceb9fe1a85ec53
+	code ^= code >> 33
+	return code
+}
+
+// Unhash64 is the inverse function of Hash64.
+func Unhash64(hash uint64) uint64 {
+	hash ^= hash >> 33
+	hash *= 0x9cb4b2f8129337db // modular multiplicative inverse of 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+	hash *= 0x4f74430c22a54005 // modular multiplicative inverse of 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	return hash
+}
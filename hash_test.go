@@ -0,0 +1,39 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import "testing"
+
+func TestHash64(t *testing.T) {
+	seen := make(map[uint64]struct{}, 10000)
+	var code, hash uint64
+	for i := uint64(0); i < 10000; i++ {
+		code = i * 0x9E3779B97F4A7C15
+		hash = Hash64(code)
+		if Unhash64(hash) != code {
+			t.Errorf("Unhash64(Hash64(%d)) != %d", code, code)
+		}
+		seen[hash] = struct{}{}
+	}
+	if len(seen) != 10000 {
+		t.Errorf("hash collisions found")
+	}
+}
@@ -43,7 +43,11 @@ func (codes KmerCodeSlice) Less(i, j int) bool {
 // 	return code >> 2, code & 3, code >> (uint(k-1) << 1) & 3, code & ((1 << (uint(k-1) << 1)) - 1)
 // }
 
-// CodeSlice is a slice of Kmer code (uint64), for sorting
+// CodeSlice is a slice of Kmer code (uint64), for sorting.
+// Codes are sorted by their raw uint64 values, which is a stable total order,
+// i.e., the lexicographic order of k-mers with the same K.
+// Codes are NOT canonicalized before comparison, use CanonicalCodeSlice
+// for sorting non-canonical codes in canonical order.
 type CodeSlice []uint64
 
 // Len return length of the slice
@@ -61,6 +65,45 @@ func (codes CodeSlice) Less(i, j int) bool {
 	return codes[i] < codes[j]
 }
 
+// CanonicalCodeSlice is a slice of codes of k-mers with the same K, for sorting
+// non-canonical codes in the order of their canonical codes in one pass.
+// Codes with the same canonical code (i.e., a k-mer and its reverse complement)
+// are ordered by their raw values, so the order is still a total order.
+//
+// Call Canonicalize after sorting to replace codes with canonical ones,
+// the result is identical to sorting canonicalized codes with CodeSlice.
+type CanonicalCodeSlice struct {
+	Codes []uint64
+	K     int
+}
+
+// Len return length of the slice
+func (codes CanonicalCodeSlice) Len() int {
+	return len(codes.Codes)
+}
+
+// Swap swaps two elements
+func (codes CanonicalCodeSlice) Swap(i, j int) {
+	codes.Codes[i], codes.Codes[j] = codes.Codes[j], codes.Codes[i]
+}
+
+// Less compares canonical codes of two codes, and then the raw codes.
+func (codes CanonicalCodeSlice) Less(i, j int) bool {
+	a := Canonical(codes.Codes[i], codes.K)
+	b := Canonical(codes.Codes[j], codes.K)
+	if a == b {
+		return codes.Codes[i] < codes.Codes[j]
+	}
+	return a < b
+}
+
+// Canonicalize replaces all codes with their canonical codes.
+func (codes CanonicalCodeSlice) Canonicalize() {
+	for i, code := range codes.Codes {
+		codes.Codes[i] = Canonical(code, codes.K)
+	}
+}
+
 // CodeTaxid is the code-taxid pair
 type CodeTaxid struct {
 	Code uint64
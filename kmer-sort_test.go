@@ -0,0 +1,61 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// TestCanonicalCodeSlice tests sorting non-canonical codes in canonical order
+func TestCanonicalCodeSlice(t *testing.T) {
+	for _, k := range []int{1, 5, 21, 31, 32} {
+		codes := make([]uint64, 1000)
+		for i := range codes {
+			codes[i] = rand.Uint64() & MaxCode[k]
+		}
+
+		// canonicalize, and then sort
+		codes0 := make([]uint64, len(codes))
+		for i, code := range codes {
+			codes0[i] = Canonical(code, k)
+		}
+		sort.Sort(CodeSlice(codes0))
+
+		// sort with canonical order, and then canonicalize
+		codes1 := CanonicalCodeSlice{Codes: codes, K: k}
+		sort.Sort(codes1)
+		for i := 1; i < len(codes); i++ {
+			if codes1.Less(i, i-1) {
+				t.Errorf("k=%d: codes not sorted at %d", k, i)
+			}
+		}
+		codes1.Canonicalize()
+
+		for i := range codes0 {
+			if codes0[i] != codes1.Codes[i] {
+				t.Errorf("k=%d: #%d: %d != %d", k, i, codes1.Codes[i], codes0[i])
+				break
+			}
+		}
+	}
+}
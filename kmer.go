@@ -23,6 +23,7 @@ package unikmer
 import (
 	"bytes"
 	"errors"
+	"math/bits"
 )
 
 // ErrIllegalBase means that base beyond IUPAC symbols are  detected.
@@ -187,12 +188,7 @@ func Reverse(code uint64, k int) (c uint64) {
 	if k <= 0 || k > 32 {
 		panic(ErrKOverflow)
 	}
-	for i := 0; i < k; i++ {
-		c <<= 2
-		c |= code & 3
-		code >>= 2
-	}
-	return
+	return reverse2bits(code) >> uint(64-k<<1)
 }
 
 // Complement returns code of complement sequence.
@@ -200,11 +196,8 @@ func Complement(code uint64, k int) (c uint64) {
 	if k <= 0 || k > 32 {
 		panic(ErrKOverflow)
 	}
-	for i := 0; i < k; i++ {
-		c |= (code&3 ^ 3) << uint(i<<1)
-		code >>= 2
-	}
-	return
+	// A (00) <-> T (11), C (01) <-> G (10)
+	return (code ^ MaxCode[k]) & MaxCode[k]
 }
 
 // RevComp returns code of reverse complement sequence.
@@ -212,12 +205,24 @@ func RevComp(code uint64, k int) (c uint64) {
 	if k <= 0 || k > 32 {
 		panic(ErrKOverflow)
 	}
-	for i := 0; i < k; i++ {
-		c <<= 2
-		c |= code&3 ^ 3
-		code >>= 2
+	return reverse2bits(^code) >> uint(64-k<<1)
+}
+
+// reverse2bits reverses the order of all 2-bit groups (bases) of a uint64.
+func reverse2bits(c uint64) uint64 {
+	c = c>>2&0x3333333333333333 | c&0x3333333333333333<<2
+	c = c>>4&0x0f0f0f0f0f0f0f0f | c&0x0f0f0f0f0f0f0f0f<<4
+	return bits.ReverseBytes64(c)
+}
+
+// Canonical returns code of the canonical k-mer,
+// i.e., the smaller one of the k-mer and its reverse complement.
+func Canonical(code uint64, k int) uint64 {
+	rc := RevComp(code, k)
+	if rc < code {
+		return rc
 	}
-	return
+	return code
 }
 
 // bit2base is for mapping bit to base.
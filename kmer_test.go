@@ -175,6 +175,43 @@ func TestRevComp(t *testing.T) {
 	}
 }
 
+// TestRevCompProperties compares bit-trick implementations of Reverse,
+// Complement and RevComp with base-by-base ones, and checks canonical k-mers.
+func TestRevCompProperties(t *testing.T) {
+	var rev, comp, revcomp, code uint64
+	var k, i int
+	for _, mer := range randomMers {
+		k = len(mer)
+		code, _ = Encode(mer)
+
+		rev, comp, revcomp = 0, 0, 0
+		for i = k - 1; i >= 0; i-- {
+			rev = rev<<2 | base2bit[mer[i]]
+			revcomp = revcomp<<2 | (base2bit[mer[i]] ^ 3)
+		}
+		for i = 0; i < k; i++ {
+			comp = comp<<2 | (base2bit[mer[i]] ^ 3)
+		}
+		if Reverse(code, k) != rev || Complement(code, k) != comp || RevComp(code, k) != revcomp {
+			t.Errorf("%s: unexpected Reverse/Complement/RevComp: %d/%d/%d", mer,
+				Reverse(code, k), Complement(code, k), RevComp(code, k))
+		}
+		if RevComp(RevComp(code, k), k) != code {
+			t.Errorf("%s: RevComp(RevComp(x)) != x", mer)
+		}
+
+		kcode := KmerCode{code, k}
+		s, rc := kcode.String(), kcode.RevComp().String()
+		expected := s
+		if rc < s {
+			expected = rc
+		}
+		if kcode.Canonical().String() != expected {
+			t.Errorf("%s: unexpected canonical k-mer: %s", mer, kcode.Canonical())
+		}
+	}
+}
+
 // BenchmarkEncode tests speed of Encode()
 func BenchmarkEncodeK32(b *testing.B) {
 	var code uint64
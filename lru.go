@@ -0,0 +1,87 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package unikmer
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache is a concurrency-safe LRU cache of LCA query results,
+// with a limited number of entries.
+type lruCache struct {
+	sync.Mutex
+	size int
+	ll   *list.List
+	m    map[uint64]*list.Element
+}
+
+type lruEntry struct {
+	key   uint64
+	value uint32
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size: size,
+		ll:   list.New(),
+		m:    make(map[uint64]*list.Element, size),
+	}
+}
+
+// Get returns the cached value of a key, and marks it as recently used.
+func (c *lruCache) Get(key uint64) (uint32, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.m[key]
+	if !ok {
+		return 0, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*lruEntry).value, true
+}
+
+// Add adds a key-value pair, and evicts the least recently used one
+// if the cache is full.
+func (c *lruCache) Add(key uint64, value uint32) {
+	c.Lock()
+	defer c.Unlock()
+
+	if e, ok := c.m[key]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*lruEntry).value = value
+		return
+	}
+
+	if c.ll.Len() >= c.size {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.m, e.Value.(*lruEntry).key)
+	}
+	c.m[key] = c.ll.PushFront(&lruEntry{key: key, value: value})
+}
+
+// Len returns the number of cached entries.
+func (c *lruCache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.ll.Len()
+}
@@ -0,0 +1,219 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+)
+
+// ErrInvalidMinHashSize means the size of MinHash sketch is not positive.
+var ErrInvalidMinHashSize = errors.New("unikmer: size of MinHash sketch should be positive")
+
+// ErrInvalidMinHashFile means the data is not a MinHash sketch.
+var ErrInvalidMinHashFile = errors.New("unikmer: invalid MinHash sketch file")
+
+// ErrMinHashMismatch means sketches of different K or 'canonical' flags are compared.
+var ErrMinHashMismatch = errors.New("unikmer: K or 'canonical' flags of MinHash sketches not consistent")
+
+// MinHashMagic is the magic number of serialized MinHash sketches.
+var MinHashMagic = [8]byte{'.', 'u', 'n', 'i', 'k', 'm', 'h', 's'}
+
+// MinHash is a bottom-k MinHash sketch, i.e., the Size smallest
+// Hash64 values of distinct codes, like Mash. Sketches of big sets
+// are small and cheap to compare, see Jaccard.
+type MinHash struct {
+	K         int
+	Canonical bool
+	Size      int
+
+	h    uint64MaxHeap
+	seen map[uint64]struct{} // values in the heap
+}
+
+// NewMinHash creates a MinHash sketch of the given size
+// for k-mers of size k.
+func NewMinHash(k int, canonical bool, size int) (*MinHash, error) {
+	if k < 1 || k > 32 {
+		return nil, ErrKOverflow
+	}
+	if size <= 0 {
+		return nil, ErrInvalidMinHashSize
+	}
+	return &MinHash{
+		K:         k,
+		Canonical: canonical,
+		Size:      size,
+		h:         make(uint64MaxHeap, 0, size),
+		seen:      make(map[uint64]struct{}, size),
+	}, nil
+}
+
+// Push adds a code to the sketch.
+func (mh *MinHash) Push(code uint64) {
+	mh.pushHash(Hash64(code))
+}
+
+func (mh *MinHash) pushHash(v uint64) {
+	if len(mh.h) == mh.Size && v >= mh.h[0] {
+		return
+	}
+	if _, ok := mh.seen[v]; ok {
+		return
+	}
+	mh.seen[v] = struct{}{}
+	if len(mh.h) < mh.Size {
+		heap.Push(&mh.h, v)
+		return
+	}
+	delete(mh.seen, mh.h[0])
+	mh.h[0] = v
+	heap.Fix(&mh.h, 0)
+}
+
+// Values returns hash values in the sketch in ascending order.
+func (mh *MinHash) Values() []uint64 {
+	values := make([]uint64, len(mh.h))
+	copy(values, mh.h)
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return values
+}
+
+// Jaccard estimates the Jaccard index of the two sets, and returns
+// the number of shared hash values among the s smallest ones of the union
+// of the two sketches, where s is the smaller size of the two.
+func (mh *MinHash) Jaccard(other *MinHash) (jaccard float64, shared int, total int, err error) {
+	if mh.K != other.K || mh.Canonical != other.Canonical {
+		return 0, 0, 0, ErrMinHashMismatch
+	}
+	s := mh.Size
+	if other.Size < s {
+		s = other.Size
+	}
+	a, b := mh.Values(), other.Values()
+	var i, j int
+	for total < s && (i < len(a) || j < len(b)) {
+		switch {
+		case j == len(b) || (i < len(a) && a[i] < b[j]):
+			i++
+		case i == len(a) || a[i] > b[j]:
+			j++
+		default:
+			shared++
+			i++
+			j++
+		}
+		total++
+	}
+	if total == 0 {
+		return 0, 0, 0, nil
+	}
+	return float64(shared) / float64(total), shared, total, nil
+}
+
+// MashDistance converts a Jaccard index to the Mash distance,
+// which estimates the mutation rate between sequences with k-mer size k.
+// 1 is returned for a Jaccard index of 0.
+func MashDistance(jaccard float64, k int) float64 {
+	if jaccard <= 0 {
+		return 1
+	}
+	if jaccard >= 1 {
+		return 0
+	}
+	return -math.Log(2*jaccard/(1+jaccard)) / float64(k)
+}
+
+// WriteTo serializes the sketch, including the magic number, K,
+// 'canonical' flag, size, the number of values and the values.
+func (mh *MinHash) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var canonical uint8
+	if mh.Canonical {
+		canonical = 1
+	}
+	values := mh.Values()
+	data := []interface{}{MinHashMagic, uint8(mh.K), canonical, uint32(mh.Size), uint32(len(values)), values}
+	for _, d := range data {
+		if err := binary.Write(bw, be, d); err != nil {
+			return 0, err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+	return int64(8 + 1 + 1 + 4 + 4 + len(values)<<3), nil
+}
+
+// ReadMinHash reads a sketch serialized by MinHash.WriteTo.
+func ReadMinHash(r io.Reader) (*MinHash, error) {
+	br := bufio.NewReader(r)
+	var magic [8]byte
+	var k, canonical uint8
+	var size, n uint32
+	for _, d := range []interface{}{&magic, &k, &canonical, &size, &n} {
+		if err := binary.Read(br, be, d); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, ErrInvalidMinHashFile
+			}
+			return nil, err
+		}
+	}
+	if magic != MinHashMagic || n > size {
+		return nil, ErrInvalidMinHashFile
+	}
+	mh, err := NewMinHash(int(k), canonical > 0, int(size))
+	if err != nil {
+		return nil, ErrInvalidMinHashFile
+	}
+	values := make([]uint64, n)
+	if err = binary.Read(br, be, values); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrInvalidMinHashFile
+		}
+		return nil, err
+	}
+	for _, v := range values {
+		mh.pushHash(v)
+	}
+	return mh, nil
+}
+
+type uint64MaxHeap []uint64
+
+func (h uint64MaxHeap) Len() int           { return len(h) }
+func (h uint64MaxHeap) Less(i, j int) bool { return h[i] > h[j] }
+func (h uint64MaxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *uint64MaxHeap) Push(x interface{}) { *h = append(*h, x.(uint64)) }
+
+func (h *uint64MaxHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,94 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMinHash(t *testing.T) {
+	size := 1000
+	a, err := NewMinHash(21, true, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewMinHash(21, true, size)
+	for i := 0; i < 20000; i++ {
+		a.Push(uint64(i))
+		a.Push(uint64(i)) // duplicates
+		b.Push(uint64(i + 10000))
+	}
+
+	values := a.Values()
+	if len(values) != size {
+		t.Errorf("unexpected number of values: %d", len(values))
+	}
+	for i := 1; i < len(values); i++ {
+		if values[i-1] >= values[i] {
+			t.Fatalf("values not sorted or duplicated")
+		}
+	}
+
+	j, _, total, err := a.Jaccard(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != size || math.Abs(j-1.0/3) > 0.05 {
+		t.Errorf("bad estimate of Jaccard index: %f, %d", j, total)
+	}
+	if j, _, _, _ = a.Jaccard(a); j != 1 || MashDistance(j, 21) != 0 {
+		t.Errorf("Jaccard index 1 and distance 0 expected for the same sketch: %f", j)
+	}
+	if MashDistance(0, 21) != 1 {
+		t.Errorf("distance 1 expected for Jaccard index of 0")
+	}
+
+	// serialization
+	var buf bytes.Buffer
+	n, err := a.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("unexpected number of written bytes: %d != %d", n, buf.Len())
+	}
+	c, err := ReadMinHash(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.K != 21 || !c.Canonical || c.Size != size || !reflect.DeepEqual(c.Values(), values) {
+		t.Errorf("sketch mismatch after serialization")
+	}
+	if _, err = ReadMinHash(bytes.NewReader([]byte("not a sketch"))); err != ErrInvalidMinHashFile {
+		t.Errorf("ErrInvalidMinHashFile expected, got: %v", err)
+	}
+
+	d, _ := NewMinHash(23, true, size)
+	if _, _, _, err = a.Jaccard(d); err != ErrMinHashMismatch {
+		t.Errorf("ErrMinHashMismatch expected, got: %v", err)
+	}
+	if _, err = NewMinHash(21, true, 0); err != ErrInvalidMinHashSize {
+		t.Errorf("ErrInvalidMinHashSize expected, got: %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package unikmer
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrBatchLengthMismatch means lengths of codes and taxids are not equal.
+var ErrBatchLengthMismatch = errors.New("unikmer: lengths of codes and taxids not equal")
+
+// batchRecords is the maximum number of records decoded or encoded
+// in one call of reading from or writing to the underlying stream.
+const batchRecords = 4096
+
+// fixedWidthCodes returns the width of records if they contain codes only
+// and are of fixed width, i.e., unsorted files without taxids, strands
+// and counts. 0 is returned otherwise.
+func fixedWidthCodes(sorted, compact, includeTaxid, includeStrand, includeCount bool, bufsize int) int {
+	if sorted || includeTaxid || includeStrand || includeCount {
+		return 0
+	}
+	if compact {
+		return bufsize
+	}
+	return 8
+}
+
+// ReadN reads codes into the caller-provided slice, and returns the number
+// of read codes. Less than len(codes) codes are returned with a nil error
+// at the end of data, and io.EOF is returned when no codes remain.
+// For unsorted files with codes only, records are decoded in bulk,
+// which is much faster than calling ReadCode repeatedly.
+func (reader *Reader) ReadN(codes []uint64) (n int, err error) {
+	if len(codes) == 0 {
+		return 0, nil
+	}
+
+	width := fixedWidthCodes(reader.sorted, reader.compact, reader.includeTaxid,
+		reader.includeStrand, reader.includeCount, reader.bufsize)
+	if width == 0 {
+		for n < len(codes) {
+			codes[n], err = reader.ReadCode()
+			if err != nil {
+				if err == io.EOF && n > 0 {
+					return n, nil
+				}
+				return n, err
+			}
+			n++
+		}
+		return n, nil
+	}
+
+	var m, i, j int
+	var code uint64
+	var buf []byte
+	for n < len(codes) {
+		m = len(codes) - n
+		if m > batchRecords {
+			m = batchRecords
+		}
+		if len(reader.bufBatch) < m*width {
+			reader.bufBatch = make([]byte, batchRecords*width)
+		}
+		buf = reader.bufBatch[:m*width]
+
+		m, err = io.ReadFull(reader.r, buf)
+		if m%width != 0 {
+			return n, ErrBrokenFile
+		}
+		if width == 8 {
+			for i = 0; i < m; i += 8 {
+				codes[n] = be.Uint64(buf[i : i+8])
+				n++
+			}
+		} else {
+			for i = 0; i < m; i += width {
+				code = 0
+				for j = i; j < i+width; j++ {
+					code = code<<8 | uint64(buf[j])
+				}
+				codes[n] = code
+				n++
+			}
+		}
+		if m > 0 {
+			reader.justReadACode = true
+		}
+
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				if n > 0 {
+					return n, nil
+				}
+				return 0, io.EOF
+			}
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// ReadNWithTaxid reads codes and taxids into the caller-provided slices
+// of the same length, and returns the number of read records.
+// The global taxid is returned if UNIK_INCLUDETAXID is off.
+// See ReadN for details of returned values.
+func (reader *Reader) ReadNWithTaxid(codes []uint64, taxids []uint32) (n int, err error) {
+	if len(codes) != len(taxids) {
+		return 0, ErrBatchLengthMismatch
+	}
+	if !reader.includeTaxid {
+		n, err = reader.ReadN(codes)
+		for i := 0; i < n; i++ {
+			taxids[i] = reader.globalTaxid
+		}
+		return n, err
+	}
+
+	for n < len(codes) {
+		codes[n], taxids[n], err = reader.ReadCodeWithTaxid()
+		if err != nil {
+			if err == io.EOF && n > 0 {
+				return n, nil
+			}
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// WriteBatch writes codes in bulk. For unsorted files with codes only,
+// records are encoded into a buffer and written in one call,
+// which is much faster than calling WriteCode repeatedly.
+func (writer *Writer) WriteBatch(codes []uint64) (err error) {
+	if len(codes) == 0 {
+		return nil
+	}
+
+	width := fixedWidthCodes(writer.sorted, writer.compact, writer.includeTaxid,
+		writer.includeStrand, writer.includeCount, writer.bufsize)
+	if width == 0 {
+		for _, code := range codes {
+			if err = writer.WriteCode(code); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// lazily write header
+	if !writer.wroteHeader {
+		err = writer.WriteHeader()
+		if err != nil {
+			return err
+		}
+	}
+
+	var m, i, j int
+	var code uint64
+	var buf []byte
+	for len(codes) > 0 {
+		m = len(codes)
+		if m > batchRecords {
+			m = batchRecords
+		}
+		if len(writer.bufBatch) < m*width {
+			writer.bufBatch = make([]byte, batchRecords*width)
+		}
+		buf = writer.bufBatch[:m*width]
+
+		if width == 8 {
+			for i, code = range codes[:m] {
+				be.PutUint64(buf[i<<3:], code)
+			}
+		} else {
+			for i, code = range codes[:m] {
+				for j = (i+1)*width - 1; j >= i*width; j-- {
+					buf[j] = byte(code)
+					code >>= 8
+				}
+			}
+		}
+		if _, err = writer.w.Write(buf); err != nil {
+			return err
+		}
+		codes = codes[m:]
+	}
+	writer.justWrittenACode = true
+	return nil
+}
+
+// WriteBatchWithTaxid writes codes and their taxids in bulk, the two slices
+// should be of the same length. If UNIK_INCLUDETAXID is off,
+// taxids will not be written.
+func (writer *Writer) WriteBatchWithTaxid(codes []uint64, taxids []uint32) (err error) {
+	if len(codes) != len(taxids) {
+		return ErrBatchLengthMismatch
+	}
+	if !writer.includeTaxid {
+		return writer.WriteBatch(codes)
+	}
+
+	for i, code := range codes {
+		if err = writer.WriteCode(code); err != nil {
+			return err
+		}
+		if err = writer.WriteTaxid(taxids[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package unikmer
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func genCodes(k int, num int, sorted bool) []uint64 {
+	codes := make([]uint64, num)
+	for i := range codes {
+		codes[i] = rand.Uint64() & MaxCode[k]
+	}
+	if sorted {
+		sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	}
+	return codes
+}
+
+func TestBatch(t *testing.T) {
+	for _, k := range []int{5, 21, 32} {
+		for _, flag := range []uint32{0, UNIK_COMPACT, UNIK_SORTED, UNIK_INCLUDETAXID,
+			UNIK_COMPACT | UNIK_INCLUDECOUNT, UNIK_SORTED | UNIK_INCLUDETAXID, UNIK_SENTINEL | UNIK_COMPACT} {
+			codes := genCodes(k, 10001, flag&UNIK_SORTED > 0)
+			taxids := make([]uint32, len(codes))
+			for i := range taxids {
+				taxids[i] = uint32(i + 1)
+			}
+
+			var buf bytes.Buffer
+			writer, err := NewWriter(&buf, k, flag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// in batches of different sizes
+			for _, r := range [][2]int{{0, 1}, {1, 5000}, {5000, 5000}, {5000, 10001}} {
+				if err = writer.WriteBatchWithTaxid(codes[r[0]:r[1]], taxids[r[0]:r[1]]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = writer.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			reader, err := NewReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			codes2 := make([]uint64, 0, len(codes))
+			taxids2 := make([]uint32, 0, len(codes))
+			_codes := make([]uint64, 4097)
+			_taxids := make([]uint32, 4097)
+			var n int
+			for {
+				n, err = reader.ReadNWithTaxid(_codes, _taxids)
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					t.Fatal(err)
+				}
+				codes2 = append(codes2, _codes[:n]...)
+				taxids2 = append(taxids2, _taxids[:n]...)
+			}
+
+			if !reflect.DeepEqual(codes, codes2) {
+				t.Errorf("k=%d, flag=%d: codes mismatch, %d written, %d read", k, flag, len(codes), len(codes2))
+			}
+			if flag&UNIK_INCLUDETAXID > 0 && !reflect.DeepEqual(taxids, taxids2) {
+				t.Errorf("k=%d, flag=%d: taxids mismatch", k, flag)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	writer, _ := NewWriter(&buf, 21, 0)
+	if err := writer.WriteBatchWithTaxid([]uint64{1}, nil); err != ErrBatchLengthMismatch {
+		t.Errorf("ErrBatchLengthMismatch expected, got: %v", err)
+	}
+}
+
+func benchmarkBatchData(b *testing.B, flag uint32) ([]uint64, []byte) {
+	codes := genCodes(31, 1<<20, flag&UNIK_SORTED > 0)
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, 31, flag)
+	if err != nil {
+		b.Fatal(err)
+	}
+	writer.WriteBatch(codes)
+	if err = writer.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	return codes, buf.Bytes()
+}
+
+// BenchmarkReadCode tests speed of reading codes one by one.
+func BenchmarkReadCode(b *testing.B) {
+	_, data := benchmarkBatchData(b, 0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader, _ := NewReader(bytes.NewReader(data))
+		for {
+			if _, err := reader.ReadCode(); err != nil {
+				break
+			}
+		}
+	}
+}
+
+// BenchmarkReadN tests speed of reading codes in bulk.
+func BenchmarkReadN(b *testing.B) {
+	_, data := benchmarkBatchData(b, 0)
+	codes := make([]uint64, 1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader, _ := NewReader(bytes.NewReader(data))
+		for {
+			if _, err := reader.ReadN(codes); err != nil {
+				break
+			}
+		}
+	}
+}
+
+// BenchmarkWriteCode tests speed of writing codes one by one.
+func BenchmarkWriteCode(b *testing.B) {
+	codes, data := benchmarkBatchData(b, 0)
+	var buf bytes.Buffer
+	buf.Grow(len(data))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		writer, _ := NewWriter(&buf, 31, 0)
+		for _, code := range codes {
+			writer.WriteCode(code)
+		}
+		writer.Flush()
+	}
+}
+
+// BenchmarkWriteBatch tests speed of writing codes in bulk.
+func BenchmarkWriteBatch(b *testing.B) {
+	codes, data := benchmarkBatchData(b, 0)
+	var buf bytes.Buffer
+	buf.Grow(len(data))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		writer, _ := NewWriter(&buf, 31, 0)
+		writer.WriteBatch(codes)
+		writer.Flush()
+	}
+}
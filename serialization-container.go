@@ -0,0 +1,278 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ContainerMagic is the magic number of container file.
+var ContainerMagic = [8]byte{'.', 'u', 'n', 'i', 'k', 'c', 'o', 'n'}
+
+// ErrSegmentNotFound means no segment with the given name in a container.
+var ErrSegmentNotFound = errors.New("unikmer: segment not found")
+
+// ErrDuplicatedSegment means a segment name is used twice in a container.
+var ErrDuplicatedSegment = errors.New("unikmer: duplicated segment name")
+
+// ErrContainerWriterClosed means ContainerWriter is already closed.
+var ErrContainerWriterClosed = errors.New("unikmer: ContainerWriter already closed")
+
+// A container file stores many named segments, i.e., complete .unik data,
+// to avoid wasting inodes with lots of tiny files.
+//
+//	magic (8 bytes) + main version (1 byte) + minor version (1 byte)
+//	segment 1 + segment 2 + ...
+//	index: number of segments (uvarint),
+//	       and (name length, name, offset, size) (uvarint, bytes, uvarint, uvarint) for each segment
+//	offset of index (8 bytes) + magic (8 bytes)
+//
+// The index is at the end, so segments are written in a streaming way,
+// while reading needs random access.
+
+type containerEntry struct {
+	name   string
+	offset int64
+	size   int64
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// ContainerWriter writes many named segments into one file.
+type ContainerWriter struct {
+	w *countingWriter
+
+	entries []containerEntry
+	names   map[string]struct{}
+
+	cur      *Writer
+	curName  string
+	curStart int64
+
+	closed bool
+}
+
+// NewContainerWriter creates a ContainerWriter.
+func NewContainerWriter(w io.Writer) (*ContainerWriter, error) {
+	cw := &ContainerWriter{w: &countingWriter{w: w}, names: make(map[string]struct{}, 1024)}
+	err := binary.Write(cw.w, be, ContainerMagic)
+	if err != nil {
+		return nil, err
+	}
+	err = binary.Write(cw.w, be, [2]uint8{MainVersion, MinorVersion})
+	if err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// NewSegment finishes the previous segment, and returns a Writer
+// for a new segment. The Writer should not be used after calling
+// NewSegment again or Close, and it needs not to be flushed.
+func (cw *ContainerWriter) NewSegment(name string, k int, flag uint32) (*Writer, error) {
+	if cw.closed {
+		return nil, ErrContainerWriterClosed
+	}
+	if _, ok := cw.names[name]; ok {
+		return nil, ErrDuplicatedSegment
+	}
+	err := cw.finishSegment()
+	if err != nil {
+		return nil, err
+	}
+
+	writer, err := NewWriter(cw.w, k, flag)
+	if err != nil {
+		return nil, err
+	}
+	cw.names[name] = struct{}{}
+	cw.cur, cw.curName, cw.curStart = writer, name, cw.w.n
+	return writer, nil
+}
+
+func (cw *ContainerWriter) finishSegment() error {
+	if cw.cur == nil {
+		return nil
+	}
+	err := cw.cur.Flush()
+	if err != nil {
+		return err
+	}
+	cw.entries = append(cw.entries, containerEntry{name: cw.curName, offset: cw.curStart, size: cw.w.n - cw.curStart})
+	cw.cur = nil
+	return nil
+}
+
+// Close finishes the last segment and writes the index.
+// It does not close the underlying io.Writer.
+func (cw *ContainerWriter) Close() error {
+	if cw.closed {
+		return ErrContainerWriterClosed
+	}
+	cw.closed = true
+	err := cw.finishSegment()
+	if err != nil {
+		return err
+	}
+
+	indexOffset := cw.w.n
+	buf := make([]byte, 0, 1024)
+	var tmp [binary.MaxVarintLen64]byte
+	putUvarint := func(v uint64) {
+		n := binary.PutUvarint(tmp[:], v)
+		buf = append(buf, tmp[:n]...)
+	}
+	putUvarint(uint64(len(cw.entries)))
+	for _, e := range cw.entries {
+		putUvarint(uint64(len(e.name)))
+		buf = append(buf, e.name...)
+		putUvarint(uint64(e.offset))
+		putUvarint(uint64(e.size))
+
+		if len(buf) >= 1<<16 {
+			if _, err = cw.w.Write(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+	if _, err = cw.w.Write(buf); err != nil {
+		return err
+	}
+
+	err = binary.Write(cw.w, be, uint64(indexOffset))
+	if err != nil {
+		return err
+	}
+	return binary.Write(cw.w, be, ContainerMagic)
+}
+
+// ContainerReader reads segments from a container file.
+type ContainerReader struct {
+	r       io.ReaderAt
+	entries []containerEntry
+	index   map[string]int
+
+	MainVersion  uint8
+	MinorVersion uint8
+}
+
+// NewContainerReader reads the index of a container file of the given size.
+func NewContainerReader(r io.ReaderAt, size int64) (*ContainerReader, error) {
+	var head [10]byte
+	var tail [16]byte
+	if size < int64(len(head)+len(tail)) {
+		return nil, ErrInvalidFileFormat
+	}
+	if _, err := r.ReadAt(head[:], 0); err != nil {
+		return nil, err
+	}
+	if _, err := r.ReadAt(tail[:], size-16); err != nil {
+		return nil, err
+	}
+	var magic [8]byte
+	copy(magic[:], head[:8])
+	if magic != ContainerMagic {
+		return nil, ErrInvalidFileFormat
+	}
+	copy(magic[:], tail[8:])
+	if magic != ContainerMagic {
+		return nil, ErrBrokenFile
+	}
+	if head[8] != MainVersion {
+		return nil, fmt.Errorf("unikmer: container format compatibility error, please recreate with newest version")
+	}
+
+	indexOffset := int64(be.Uint64(tail[:8]))
+	if indexOffset < int64(len(head)) || indexOffset > size-16 {
+		return nil, ErrBrokenFile
+	}
+	indexSize := uint64(size - 16 - indexOffset)
+	br := bufio.NewReader(io.NewSectionReader(r, indexOffset, int64(indexSize)))
+
+	// values below are read from the file, they are checked against
+	// the index size before allocating memory, in case of broken files.
+	n, err := binary.ReadUvarint(br)
+	if err != nil || n > indexSize/3 { // an entry takes 3 bytes at least
+		return nil, ErrBrokenFile
+	}
+	cr := &ContainerReader{
+		r:            r,
+		entries:      make([]containerEntry, 0, n),
+		index:        make(map[string]int, n),
+		MainVersion:  head[8],
+		MinorVersion: head[9],
+	}
+	var l, offset, s uint64
+	for i := uint64(0); i < n; i++ {
+		if l, err = binary.ReadUvarint(br); err != nil || l > indexSize {
+			return nil, ErrBrokenFile
+		}
+		name := make([]byte, l)
+		if _, err = io.ReadFull(br, name); err != nil {
+			return nil, ErrBrokenFile
+		}
+		if offset, err = binary.ReadUvarint(br); err != nil {
+			return nil, ErrBrokenFile
+		}
+		if s, err = binary.ReadUvarint(br); err != nil {
+			return nil, ErrBrokenFile
+		}
+		if offset < uint64(len(head)) || offset > uint64(indexOffset) || s > uint64(indexOffset)-offset {
+			return nil, ErrBrokenFile
+		}
+		cr.index[string(name)] = len(cr.entries)
+		cr.entries = append(cr.entries, containerEntry{name: string(name), offset: int64(offset), size: int64(s)})
+	}
+	return cr, nil
+}
+
+// Segments returns names of all segments in the order of writing.
+func (cr *ContainerReader) Segments() []string {
+	names := make([]string, len(cr.entries))
+	for i, e := range cr.entries {
+		names[i] = e.name
+	}
+	return names
+}
+
+// Open returns a Reader of a segment.
+func (cr *ContainerReader) Open(name string) (*Reader, error) {
+	i, ok := cr.index[name]
+	if !ok {
+		return nil, ErrSegmentNotFound
+	}
+	e := cr.entries[i]
+	return NewReader(bufio.NewReader(io.NewSectionReader(cr.r, e.offset, e.size)))
+}
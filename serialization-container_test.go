@@ -0,0 +1,151 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestContainer(t *testing.T) {
+	var buf bytes.Buffer
+	cw, err := NewContainerWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := 100
+	for i := 0; i < n; i++ {
+		flag := uint32(0)
+		if i%2 == 0 {
+			flag = UNIK_SORTED
+		}
+		w, err := cw.NewSegment(fmt.Sprintf("gene%d", i), 11+i%21, flag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < i; j++ {
+			if err = w.WriteCode(uint64(j)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, err = cw.NewSegment("gene0", 21, 0); err != ErrDuplicatedSegment {
+		t.Errorf("ErrDuplicatedSegment expected, got: %v", err)
+	}
+	if err = cw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cw.NewSegment("gene", 21, 0); err != ErrContainerWriterClosed {
+		t.Errorf("ErrContainerWriterClosed expected, got: %v", err)
+	}
+
+	data := buf.Bytes()
+	cr, err := NewContainerReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := cr.Segments()
+	if len(names) != n {
+		t.Fatalf("number of segments mismatch: %d != %d", len(names), n)
+	}
+
+	// in reverse order to check random access
+	for i := n - 1; i >= 0; i-- {
+		if names[i] != fmt.Sprintf("gene%d", i) {
+			t.Errorf("segment name mismatch: %s", names[i])
+		}
+		r, err := cr.Open(names[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.K != 11+i%21 || r.IsSorted() != (i%2 == 0) {
+			t.Errorf("%s: header mismatch: %s", names[i], r.Header)
+		}
+		var m int
+		for {
+			code, err := r.ReadCode()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatalf("%s: %s", names[i], err)
+			}
+			if code != uint64(m) {
+				t.Errorf("%s: code mismatch: %d != %d", names[i], code, m)
+			}
+			m++
+		}
+		if m != i {
+			t.Errorf("%s: number mismatch: %d != %d", names[i], m, i)
+		}
+	}
+
+	if _, err = cr.Open("gene"); err != ErrSegmentNotFound {
+		t.Errorf("ErrSegmentNotFound expected, got: %v", err)
+	}
+
+	if _, err = NewContainerReader(bytes.NewReader(data[:len(data)-1]), int64(len(data)-1)); err == nil {
+		t.Errorf("error expected for truncated data")
+	}
+}
+
+func TestContainerBrokenIndex(t *testing.T) {
+	// a container with 10 bytes of data and a hand-made index
+	container := func(index ...uint64) []byte {
+		var buf bytes.Buffer
+		buf.Write(ContainerMagic[:])
+		buf.Write([]byte{MainVersion, MinorVersion})
+		buf.Write(make([]byte, 10))
+		indexOffset := buf.Len()
+		var tmp [binary.MaxVarintLen64]byte
+		for _, v := range index {
+			buf.Write(tmp[:binary.PutUvarint(tmp[:], v)])
+		}
+		binary.Write(&buf, be, uint64(indexOffset))
+		buf.Write(ContainerMagic[:])
+		return buf.Bytes()
+	}
+
+	data := container(1, 0, 10, 10)
+	if _, err := NewContainerReader(bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("valid index: %s", err)
+	}
+
+	for _, index := range [][]uint64{
+		{math.MaxUint64},               // huge number of segments
+		{1 << 40, 0, 10, 10},           // more segments than the index could hold
+		{1, math.MaxUint64},            // huge name length
+		{1, 0, 10, math.MaxUint64 - 5}, // offset+size overflows
+		{1, 0, math.MaxUint64, 10},     // huge offset
+		{1, 0, 0, 10},                  // offset in the header
+		{1, 0, 10, 11},                 // segment overlaps the index
+	} {
+		data = container(index...)
+		if _, err := NewContainerReader(bytes.NewReader(data), int64(len(data))); err != ErrBrokenFile {
+			t.Errorf("index %v: ErrBrokenFile expected, got: %v", index, err)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"sort"
+)
+
+// ErrNotIndexable means the binary file is not sorted, or with UNIK_SENTINEL.
+var ErrNotIndexable = errors.New("unikmer: only sorted binary file without UNIK_SENTINEL can be indexed")
+
+// ErrInvalidIndexInterval means the interval of index is not positive.
+var ErrInvalidIndexInterval = errors.New("unikmer: interval of index should be positive")
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (n int, err error) {
+	n, err = cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// indexEntry records the position and the decoder state in a sorted file,
+// from where reading could start.
+type indexEntry struct {
+	pos    int64  // position in the file
+	offset uint64 // previous code, the base of delta encoding
+	code   uint64 // the code at pos
+	idx    int64  // index of the code
+}
+
+// IndexedReader holds a sparse index of an uncompressed sorted binary file,
+// which can be split into code ranges to read in parallel.
+type IndexedReader struct {
+	Header
+
+	r          io.ReaderAt
+	size       int64
+	headerSize int64
+
+	entries []indexEntry
+	number  int64
+}
+
+// Range is a range of codes in a sorted binary file, created by
+// IndexedReader.Partitions.
+type Range struct {
+	Start  uint64 // the first code
+	Number int64  // number of codes
+
+	begin, end int64
+	offset     uint64
+}
+
+// NewIndexedReader scans an uncompressed sorted binary file of the given size,
+// and records positions of every interval codes.
+// For files with UNIK_INDEXED, the stored index is used instead of scanning.
+func NewIndexedReader(r io.ReaderAt, size int64, interval int) (*IndexedReader, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidIndexInterval
+	}
+	cr := &countingReader{r: bufio.NewReaderSize(io.NewSectionReader(r, 0, size), 1<<16)}
+	reader, err := newReader(cr, false)
+	if err != nil {
+		return nil, err
+	}
+	if !reader.IsSorted() || reader.HasSentinel() {
+		return nil, ErrNotIndexable
+	}
+
+	ir := &IndexedReader{Header: reader.Header, r: r, size: size, headerSize: cr.n}
+	if reader.IsIndexed() { // use the stored index
+		ir.entries, ir.number, _, err = readIndex(r, size)
+		if err != nil {
+			return nil, err
+		}
+		return ir, nil
+	}
+	ir.entries = make([]indexEntry, 0, 1024)
+
+	var code uint64
+	var n, last int64
+	var entry indexEntry
+	var record bool
+	for {
+		// codes are stored in pairs, only positions between pairs are OK.
+		record = !reader.hasPrev && (n == 0 || n-last >= int64(interval))
+		if record {
+			entry = indexEntry{pos: cr.n, offset: reader.offset, idx: n}
+		}
+
+		code, _, err = reader.ReadCodeWithTaxid()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		if record {
+			entry.code = code
+			ir.entries = append(ir.entries, entry)
+			last = n
+		}
+		n++
+	}
+	ir.number = n
+	return ir, nil
+}
+
+// Number returns the number of codes.
+func (ir *IndexedReader) Number() int64 {
+	return ir.number
+}
+
+// Partitions splits codes into at most n ranges with roughly equal
+// numbers of codes, according to the index.
+func (ir *IndexedReader) Partitions(n int) []Range {
+	if n <= 0 || len(ir.entries) == 0 {
+		return nil
+	}
+
+	ranges := make([]Range, 0, n)
+	var a, b int
+	var target int64
+	for i := 1; i <= n && a < len(ir.entries); i++ {
+		target = ir.number * int64(i) / int64(n)
+		for b = a + 1; b < len(ir.entries) && ir.entries[b].idx < target; b++ {
+		}
+		if i == n {
+			b = len(ir.entries)
+		}
+		ranges = append(ranges, ir.newRange(a, b))
+		a = b
+	}
+	return ranges
+}
+
+// newRange creates a Range covering entries[a:b].
+func (ir *IndexedReader) newRange(a, b int) Range {
+	rg := Range{
+		Start:  ir.entries[a].code,
+		begin:  ir.entries[a].pos,
+		offset: ir.entries[a].offset,
+	}
+	if b < len(ir.entries) {
+		rg.end = ir.entries[b].pos
+		rg.Number = ir.entries[b].idx - ir.entries[a].idx
+	} else {
+		rg.end = ir.size
+		rg.Number = ir.number - ir.entries[a].idx
+	}
+	return rg
+}
+
+// At returns a Reader reading codes in a range, safe for concurrent use.
+func (ir *IndexedReader) At(rg Range) (*Reader, error) {
+	reader, err := newReader(bufio.NewReader(io.MultiReader(
+		io.NewSectionReader(ir.r, 0, ir.headerSize),
+		io.NewSectionReader(ir.r, rg.begin, rg.end-rg.begin))), false)
+	if err != nil {
+		return nil, err
+	}
+	reader.offset = rg.offset
+	return reader, nil
+}
+
+// ErrNotIndexed means the binary file is not sorted with UNIK_INDEXED.
+var ErrNotIndexed = errors.New("unikmer: Seek only supported for sorted binary file with UNIK_INDEXED")
+
+// ErrBrokenIndex means the index stored in the binary file is broken.
+var ErrBrokenIndex = errors.New("unikmer: broken index")
+
+// ErrUnknownSize means the size of an io.ReaderAt is unknown.
+var ErrUnknownSize = errors.New("unikmer: size of io.ReaderAt unknown, it should have a method Size or Stat")
+
+// ctrlByteEndOfData marks the end of data in sorted files with UNIK_INDEXED.
+// Control bytes of code pairs are < 64, and 128 is for the last single code,
+// so 64 is never used by data.
+const ctrlByteEndOfData = 64
+
+// indexEntrySize is the number of bytes of an index entry in files.
+const indexEntrySize = 32
+
+// NewWriterWithIndex creates a Writer for sorted binary file, which records
+// positions of every interval codes, and writes the index after data
+// in Flush, with flag UNIK_INDEXED. It could be read by any Reader,
+// and Reader.Seek is supported for the Reader created by NewReaderAt.
+// The file should not be compressed for random access.
+func NewWriterWithIndex(w io.Writer, k int, flag uint32, interval int) (*Writer, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidIndexInterval
+	}
+	if flag&UNIK_SORTED == 0 || flag&UNIK_SENTINEL > 0 {
+		return nil, ErrNotIndexable
+	}
+	writer, err := NewWriter(w, k, flag)
+	if err != nil {
+		return nil, err
+	}
+	writer.Flag |= UNIK_INDEXED
+	writer.indexInterval = interval
+	writer.index = make([]indexEntry, 0, 1024)
+	return writer, nil
+}
+
+// addIndexEntry records the position of a code to write, if it's
+// the first one of a pair of codes.
+func (writer *Writer) addIndexEntry(code uint64) {
+	// codes are stored in pairs, only positions between pairs are OK.
+	if !writer.hasPrev &&
+		(writer.nCodes == 0 || writer.nCodes-writer.lastIndexed >= int64(writer.indexInterval)) {
+		writer.index = append(writer.index, indexEntry{
+			pos: writer.cw.n, offset: writer.offset, code: code, idx: writer.nCodes})
+		writer.lastIndexed = writer.nCodes
+	}
+	writer.nCodes++
+}
+
+// writeIndex writes the end-of-data mark, the index, and the position
+// of the mark in the last 8 bytes.
+//
+//	ctrlByteEndOfData (1 byte)
+//	number of codes (8 bytes)
+//	number of entries (8 bytes)
+//	entries: position, offset, code, index of code (8 bytes each)
+//	position of ctrlByteEndOfData (8 bytes)
+func (writer *Writer) writeIndex() (err error) {
+	dataEnd := writer.cw.n
+
+	buf := make([]byte, indexEntrySize)
+	buf[0] = ctrlByteEndOfData
+	if _, err = writer.w.Write(buf[:1]); err != nil {
+		return err
+	}
+	be.PutUint64(buf[0:8], uint64(writer.nCodes))
+	be.PutUint64(buf[8:16], uint64(len(writer.index)))
+	if _, err = writer.w.Write(buf[:16]); err != nil {
+		return err
+	}
+	for _, e := range writer.index {
+		be.PutUint64(buf[0:8], uint64(e.pos))
+		be.PutUint64(buf[8:16], e.offset)
+		be.PutUint64(buf[16:24], e.code)
+		be.PutUint64(buf[24:32], uint64(e.idx))
+		if _, err = writer.w.Write(buf); err != nil {
+			return err
+		}
+	}
+	be.PutUint64(buf[0:8], uint64(dataEnd))
+	if _, err = writer.w.Write(buf[:8]); err != nil {
+		return err
+	}
+
+	writer.wroteIndex = true
+	return nil
+}
+
+// readIndex reads the index of a sorted binary file with UNIK_INDEXED,
+// and returns index entries, number of codes and the end of data.
+func readIndex(r io.ReaderAt, size int64) ([]indexEntry, int64, int64, error) {
+	buf := make([]byte, indexEntrySize)
+	if size < 8+17 {
+		return nil, 0, 0, ErrBrokenIndex
+	}
+	if _, err := r.ReadAt(buf[:8], size-8); err != nil {
+		return nil, 0, 0, err
+	}
+	dataEnd := int64(be.Uint64(buf[:8]))
+	if dataEnd < int64(HeaderSize) || dataEnd > size-8-17 {
+		return nil, 0, 0, ErrBrokenIndex
+	}
+
+	br := bufio.NewReader(io.NewSectionReader(r, dataEnd, size-8-dataEnd))
+	if _, err := io.ReadFull(br, buf[:17]); err != nil {
+		return nil, 0, 0, err
+	}
+	if buf[0] != ctrlByteEndOfData {
+		return nil, 0, 0, ErrBrokenIndex
+	}
+	number := int64(be.Uint64(buf[1:9]))
+	n := be.Uint64(buf[9:17])
+	if n > uint64(size-8-17-dataEnd)/indexEntrySize {
+		return nil, 0, 0, ErrBrokenIndex
+	}
+
+	entries := make([]indexEntry, n)
+	for i := range entries {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, 0, 0, err
+		}
+		entries[i] = indexEntry{
+			pos:    int64(be.Uint64(buf[0:8])),
+			offset: be.Uint64(buf[8:16]),
+			code:   be.Uint64(buf[16:24]),
+			idx:    int64(be.Uint64(buf[24:32])),
+		}
+	}
+	return entries, number, dataEnd, nil
+}
+
+// readerAtSize returns the size of an io.ReaderAt, which should have a
+// method Size (e.g., *bytes.Reader and *io.SectionReader) or Stat (*os.File).
+func readerAtSize(r io.ReaderAt) (int64, error) {
+	switch v := r.(type) {
+	case interface{ Size() int64 }:
+		return v.Size(), nil
+	case interface{ Stat() (os.FileInfo, error) }:
+		info, err := v.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+	return 0, ErrUnknownSize
+}
+
+// Seek positions the Reader at the first code >= the given one,
+// so the next read returns it, or io.EOF if no such code.
+// Only Readers of sorted files with UNIK_INDEXED created by NewReaderAt
+// are supported. The Reader could be repositioned by Seek any times.
+func (reader *Reader) Seek(code uint64) error {
+	if reader.ra == nil {
+		return ErrNoReaderAt
+	}
+	if !reader.indexed {
+		return ErrNotIndexed
+	}
+
+	pos, offset, second := reader.dataEnd, uint64(0), false
+	if len(reader.index) > 0 {
+		// the last entry with code < the target, as duplicates of the target
+		// might start before an entry with code == the target
+		i := sort.Search(len(reader.index), func(i int) bool { return reader.index[i].code >= code }) - 1
+		if i < 0 {
+			i = 0
+		}
+		var err error
+		pos, offset, second, err = reader.locate(reader.index[i], code)
+		if err != nil {
+			return err
+		}
+	}
+
+	reader.r = bufio.NewReader(io.NewSectionReader(reader.ra, pos, reader.dataEnd-pos))
+	reader.offset = offset
+	reader.hasPrev = false
+	reader.hasPrevTaxid = false
+	reader.justReadACode = false
+	reader.lastRecord = false
+	reader.eod = false
+
+	if second { // skip the first code of the pair
+		if _, _, err := reader.ReadCodeWithTaxid(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// locate scans codes from an index entry, and returns the position and
+// the offset of the pair containing the first code >= the given one,
+// and whether it's the second code of the pair.
+func (reader *Reader) locate(e indexEntry, code uint64) (int64, uint64, bool, error) {
+	cr := &countingReader{r: io.NewSectionReader(reader.ra, e.pos, reader.dataEnd-e.pos)}
+	r, err := newReader(io.MultiReader(io.NewSectionReader(reader.ra, 0, reader.dataStart), cr), false)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	r.offset = e.offset
+
+	var pos int64
+	var offset, c uint64
+	var second bool
+	for {
+		second = r.hasPrev
+		if !second {
+			pos, offset = e.pos+cr.n, r.offset
+		}
+		c, _, err = r.ReadCodeWithTaxid()
+		if err != nil {
+			if err == io.EOF {
+				return reader.dataEnd, 0, false, nil
+			}
+			return 0, 0, false, err
+		}
+		if c >= code {
+			return pos, offset, second, nil
+		}
+	}
+}
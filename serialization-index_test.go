@@ -0,0 +1,238 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestIndexedReader(t *testing.T) {
+	for _, flag := range []uint32{UNIK_SORTED, UNIK_SORTED | UNIK_INCLUDETAXID} {
+		for _, n := range []int{0, 1, 2, 999, 10000} {
+			for _, withIndex := range []bool{false, true} {
+				var buf bytes.Buffer
+				var w *Writer
+				var err error
+				if withIndex { // using the stored index
+					w, err = NewWriterWithIndex(&buf, 31, flag, 100)
+				} else {
+					w, err = NewWriter(&buf, 31, flag)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < n; i++ {
+					if err = w.WriteCodeWithTaxid(uint64(i*7+i%3), uint32(i+1)); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err = w.Flush(); err != nil {
+					t.Fatal(err)
+				}
+				data := buf.Bytes()
+
+				ir, err := NewIndexedReader(bytes.NewReader(data), int64(len(data)), 100)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ir.Number() != int64(n) {
+					t.Errorf("number mismatch: %d != %d", ir.Number(), n)
+				}
+
+				for _, p := range []int{1, 3, 8, 1000} {
+					ranges := ir.Partitions(p)
+					if len(ranges) > p {
+						t.Errorf("too many ranges: %d > %d", len(ranges), p)
+					}
+
+					results := make([][]CodeTaxid, len(ranges))
+					var wg sync.WaitGroup
+					for i, rg := range ranges {
+						wg.Add(1)
+						go func(i int, rg Range) {
+							defer wg.Done()
+							reader, err := ir.At(rg)
+							if err != nil {
+								t.Error(err)
+								return
+							}
+							for {
+								code, taxid, err := reader.ReadCodeWithTaxid()
+								if err != nil {
+									if err != io.EOF {
+										t.Error(err)
+									}
+									break
+								}
+								results[i] = append(results[i], CodeTaxid{Code: code, Taxid: taxid})
+							}
+							if int64(len(results[i])) != rg.Number {
+								t.Errorf("number of codes in range %d mismatch: %d != %d", i, len(results[i]), rg.Number)
+							}
+							if len(results[i]) > 0 && results[i][0].Code != rg.Start {
+								t.Errorf("start of range %d mismatch: %d != %d", i, results[i][0].Code, rg.Start)
+							}
+						}(i, rg)
+					}
+					wg.Wait()
+
+					var j int
+					for _, result := range results {
+						for _, ct := range result {
+							if ct.Code != uint64(j*7+j%3) || (flag&UNIK_INCLUDETAXID > 0 && ct.Taxid != uint32(j+1)) {
+								t.Fatalf("flag %d, n %d, p %d: unexpected record %d: %v", flag, n, p, j, ct)
+							}
+							j++
+						}
+					}
+					if j != n {
+						t.Errorf("flag %d, n %d, p %d: number mismatch: %d != %d", flag, n, p, j, n)
+					}
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, 31, 0)
+	w.WriteCode(1)
+	w.Flush()
+	if _, err := NewIndexedReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()), 100); err != ErrNotIndexable {
+		t.Errorf("ErrNotIndexable expected, got: %v", err)
+	}
+}
+
+func TestSeek(t *testing.T) {
+	code := func(i int) uint64 { return uint64(i*7 + i%3) }
+	for _, flag := range []uint32{UNIK_SORTED, UNIK_SORTED | UNIK_INCLUDETAXID, UNIK_SORTED | UNIK_INCLUDESTRAND, UNIK_SORTED | UNIK_INCLUDECOUNT} {
+		for _, n := range []int{0, 1, 2, 3, 999, 1000} {
+			var buf bytes.Buffer
+			w, err := NewWriterWithIndex(&buf, 31, flag, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < n; i++ {
+				if err = w.WriteCodeWithTaxid(code(i), uint32(i+1)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+
+			// sequential reading stops at the end of data
+			reader, err := NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var m int
+			for {
+				if _, _, err = reader.ReadCodeWithTaxid(); err != nil {
+					if err != io.EOF {
+						t.Fatal(err)
+					}
+					break
+				}
+				m++
+			}
+			if m != n {
+				t.Errorf("flag %d, n %d: number mismatch: %d != %d", flag, n, m, n)
+			}
+
+			reader, err = NewReaderAt(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, target := range []uint64{0, 1, 8, 9, 100, 1000, 3500, 6992, 6993, 6994, 7000, 1 << 40} {
+				if err = reader.Seek(target); err != nil {
+					t.Fatal(err)
+				}
+				j := 0
+				for j < n && code(j) < target {
+					j++
+				}
+				for ; ; j++ {
+					c, taxid, err := reader.ReadCodeWithTaxid()
+					if err != nil {
+						if err != io.EOF {
+							t.Fatal(err)
+						}
+						break
+					}
+					if j >= n || c != code(j) || (flag&UNIK_INCLUDETAXID > 0 && taxid != uint32(j+1)) {
+						t.Fatalf("flag %d, n %d, seek %d: unexpected record %d: %d, %d", flag, n, target, j, c, taxid)
+					}
+				}
+				if j != n {
+					t.Errorf("flag %d, n %d, seek %d: stopped at %d", flag, n, target, j)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, 31, UNIK_SORTED)
+	w.WriteCode(1)
+	w.Flush()
+	if _, err := NewReaderAt(bytes.NewReader(buf.Bytes())); err != ErrNotFixedWidth {
+		t.Errorf("ErrNotFixedWidth expected, got: %v", err)
+	}
+	if _, err := NewWriterWithIndex(&buf, 31, 0, 10); err != ErrNotIndexable {
+		t.Errorf("ErrNotIndexable expected, got: %v", err)
+	}
+}
+
+func TestSeekDuplicates(t *testing.T) {
+	// duplicates of 8 span two index entries
+	codes := []uint64{1, 5, 8, 8, 8, 8, 8, 100}
+	var buf bytes.Buffer
+	w, err := NewWriterWithIndex(&buf, 31, UNIK_SORTED, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range codes {
+		w.WriteCode(code)
+	}
+	if err = w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewReaderAt(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = reader.Seek(8); err != nil {
+		t.Fatal(err)
+	}
+	for j := 2; j < len(codes); j++ {
+		c, err := reader.ReadCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != codes[j] {
+			t.Fatalf("unexpected record %d: %d != %d", j, c, codes[j])
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+)
+
+// ErrNotFixedWidth means records of the binary file are not of fixed width,
+// i.e., the file is sorted or with UNIK_SENTINEL.
+var ErrNotFixedWidth = errors.New("unikmer: random access only supported for unsorted binary file without UNIK_SENTINEL")
+
+// ErrNoReaderAt means the Reader is not created by NewReaderAt.
+var ErrNoReaderAt = errors.New("unikmer: random access only supported for Reader created by NewReaderAt")
+
+// NewReaderAt returns a Reader from an io.ReaderAt, which supports
+// reading records by index with ReadAt, besides reading sequentially.
+// Only unsorted binary files, of which records are of fixed width,
+// are supported for ReadAt. Sorted files with UNIK_INDEXED are also
+// supported, for seeking codes with Seek, where r should have
+// a method Size or Stat to locate the index.
+func NewReaderAt(r io.ReaderAt) (*Reader, error) {
+	sr := io.NewSectionReader(r, 0, math.MaxInt64)
+	cr := &countingReader{r: sr}
+	reader, err := newReader(cr, false)
+	if err != nil {
+		return nil, err
+	}
+	if reader.IsIndexed() && reader.IsSorted() {
+		size, err := readerAtSize(r)
+		if err != nil {
+			return nil, err
+		}
+		reader.index, _, reader.dataEnd, err = readIndex(r, size)
+		if err != nil {
+			return nil, err
+		}
+	} else if reader.IsSorted() || reader.HasSentinel() {
+		return nil, ErrNotFixedWidth
+	}
+
+	reader.ra = r
+	reader.dataStart = cr.n
+	reader.r = bufio.NewReader(sr) // sr is right after the header
+
+	reader.recordSize = reader.RecordSize()
+	return reader, nil
+}
+
+// ReadAt reads the code and taxid of the record with the given index
+// (0-based). It does not affect sequential reading, and is safe for
+// concurrent use. io.EOF is returned if the index is out of range.
+func (reader *Reader) ReadAt(index int64) (CodeTaxid, error) {
+	if reader.ra == nil {
+		return CodeTaxid{}, ErrNoReaderAt
+	}
+	if reader.recordSize <= 0 {
+		return CodeTaxid{}, ErrNotFixedWidth
+	}
+	if index < 0 {
+		return CodeTaxid{}, io.EOF
+	}
+
+	var buf [24]byte
+	record := buf[:reader.recordSize]
+	n, err := reader.ra.ReadAt(record, reader.dataStart+index*int64(reader.recordSize))
+	if n < len(record) { // err is not nil
+		if n > 0 && err == io.EOF {
+			return CodeTaxid{}, ErrBrokenFile
+		}
+		return CodeTaxid{}, err
+	}
+
+	var tmp [8]byte
+	var ct CodeTaxid
+
+	n = 8
+	if reader.compact {
+		n = reader.bufsize
+	}
+	copy(tmp[8-n:], record[:n])
+	ct.Code = be.Uint64(tmp[:])
+	record = record[n:]
+
+	if reader.includeStrand {
+		record = record[1:]
+	}
+	if reader.includeCount {
+		record = record[4:]
+	}
+
+	if reader.includeTaxid {
+		tmp = [8]byte{}
+		copy(tmp[8-len(record):], record)
+		ct.Taxid = uint32(be.Uint64(tmp[:]))
+	} else {
+		ct.Taxid = reader.globalTaxid
+	}
+	return ct, nil
+}
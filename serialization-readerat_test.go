@@ -0,0 +1,129 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestReaderAt(t *testing.T) {
+	n := 10000
+	flags := []uint32{0, UNIK_COMPACT, UNIK_INCLUDETAXID, UNIK_COMPACT | UNIK_INCLUDETAXID,
+		UNIK_COMPACT | UNIK_INCLUDETAXID | UNIK_INCLUDESTRAND,
+		UNIK_COMPACT | UNIK_INCLUDETAXID | UNIK_INCLUDESTRAND | UNIK_INCLUDECOUNT}
+	for _, flag := range flags {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, 21, flag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.SetMaxTaxid(1 << 20)
+		if flag&UNIK_INCLUDETAXID == 0 {
+			w.SetGlobalTaxid(9606)
+		}
+		for i := 0; i < n; i++ {
+			if err = w.WriteCodeWithTaxid(uint64(i*11), uint32(i+1)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+
+		reader, err := NewReaderAt(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		taxid := func(i int) uint32 {
+			if flag&UNIK_INCLUDETAXID == 0 {
+				return 9606
+			}
+			return uint32(i + 1)
+		}
+
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(seed int64) {
+				defer wg.Done()
+				rnd := rand.New(rand.NewSource(seed))
+				for j := 0; j < 1000; j++ {
+					i := rnd.Intn(n)
+					ct, err := reader.ReadAt(int64(i))
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if ct.Code != uint64(i*11) || ct.Taxid != taxid(i) {
+						t.Errorf("flag %d: unexpected record %d: %v", flag, i, ct)
+						return
+					}
+				}
+			}(int64(g))
+		}
+		wg.Wait()
+
+		if _, err = reader.ReadAt(int64(n)); err != io.EOF {
+			t.Errorf("flag %d: io.EOF expected, got: %v", flag, err)
+		}
+
+		// sequential reading still works
+		var i int
+		for {
+			code, tid, err := reader.ReadCodeWithTaxid()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatal(err)
+			}
+			if code != uint64(i*11) || tid != taxid(i) {
+				t.Errorf("flag %d: unexpected record %d: %d, %d", flag, i, code, tid)
+			}
+			i++
+		}
+		if i != n {
+			t.Errorf("flag %d: number mismatch: %d != %d", flag, i, n)
+		}
+
+		reader, err = NewReaderAt(bytes.NewReader(data[:len(data)-1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = reader.ReadAt(int64(n - 1)); err != ErrBrokenFile {
+			t.Errorf("flag %d: ErrBrokenFile expected, got: %v", flag, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, 21, UNIK_SORTED)
+	w.WriteCode(1)
+	w.Flush()
+	if _, err := NewReaderAt(bytes.NewReader(buf.Bytes())); err != ErrNotFixedWidth {
+		t.Errorf("ErrNotFixedWidth expected, got: %v", err)
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bufio"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ErrSortingWriterClosed means SortingWriter is already closed.
+var ErrSortingWriterClosed = errors.New("unikmer: SortingWriter already closed")
+
+// ErrSortingWriterPayload means flags UNIK_INCLUDESTRAND and UNIK_INCLUDECOUNT
+// are not supported by SortingWriter, which only sorts codes and taxids.
+var ErrSortingWriterPayload = errors.New("unikmer: SortingWriter does not support UNIK_INCLUDESTRAND or UNIK_INCLUDECOUNT")
+
+// SortingWriter accepts codes in any order, and writes them sorted.
+// Codes are buffered in RAM, and sorted runs are spilled into temporary
+// files when the buffer is full. All runs are k-way merged on Close,
+// i.e., it's an external sort baked in the writer.
+// Duplicated codes are kept.
+type SortingWriter struct {
+	writer *Writer
+
+	// TmpDir is the directory for spilled runs, default: os.TempDir().
+	TmpDir string
+	tmpDir string
+
+	maxInMem     int
+	includeTaxid bool
+	codes        []uint64
+	pairs        []CodeTaxid
+
+	runs   []string
+	number int64
+	closed bool
+}
+
+// NewSortingWriter creates a SortingWriter,
+// maxInMem is the maximum number of codes buffered in RAM.
+// Flag UNIK_SORTED is always switched on, and UNIK_COMPACT is ignored.
+// ErrSortingWriterPayload is returned for UNIK_INCLUDESTRAND and
+// UNIK_INCLUDECOUNT, as strands and counts would be lost.
+func NewSortingWriter(w io.Writer, k int, flag uint32, maxInMem int) (*SortingWriter, error) {
+	if maxInMem <= 0 {
+		return nil, fmt.Errorf("unikmer: maxInMem should be positive: %d", maxInMem)
+	}
+	if flag&(UNIK_INCLUDESTRAND|UNIK_INCLUDECOUNT) > 0 {
+		return nil, ErrSortingWriterPayload
+	}
+	flag |= UNIK_SORTED
+	writer, err := NewWriter(w, k, flag)
+	if err != nil {
+		return nil, err
+	}
+
+	sw := &SortingWriter{writer: writer, maxInMem: maxInMem, TmpDir: os.TempDir()}
+	if flag&UNIK_INCLUDETAXID > 0 {
+		sw.includeTaxid = true
+		sw.pairs = make([]CodeTaxid, 0, minInt(maxInMem, 1<<20))
+	} else {
+		sw.codes = make([]uint64, 0, minInt(maxInMem, 1<<20))
+	}
+	return sw, nil
+}
+
+// SetGlobalTaxid sets the global taxid
+func (sw *SortingWriter) SetGlobalTaxid(taxid uint32) error {
+	return sw.writer.SetGlobalTaxid(taxid)
+}
+
+// SetMaxTaxid set the maxtaxid
+func (sw *SortingWriter) SetMaxTaxid(taxid uint32) error {
+	return sw.writer.SetMaxTaxid(taxid)
+}
+
+// SetDescription sets the description in header.
+func (sw *SortingWriter) SetDescription(desc []byte) error {
+	if len(desc) > descMaxLen {
+		return ErrDescTooLong
+	}
+	sw.writer.Description = desc
+	return nil
+}
+
+// Write writes one KmerCode.
+func (sw *SortingWriter) Write(kcode KmerCode) error {
+	if sw.writer.K != kcode.K {
+		return ErrKMismatch
+	}
+	return sw.WriteCodeWithTaxid(kcode.Code, 0)
+}
+
+// WriteCode writes one code.
+func (sw *SortingWriter) WriteCode(code uint64) error {
+	return sw.WriteCodeWithTaxid(code, 0)
+}
+
+// WriteCodeWithTaxid writes a code and its taxid.
+// If UNIK_INCLUDETAXID is off, taxid will not be written.
+func (sw *SortingWriter) WriteCodeWithTaxid(code uint64, taxid uint32) error {
+	if sw.closed {
+		return ErrSortingWriterClosed
+	}
+	sw.number++
+	if sw.includeTaxid {
+		sw.pairs = append(sw.pairs, CodeTaxid{Code: code, Taxid: taxid})
+		if len(sw.pairs) >= sw.maxInMem {
+			return sw.spill()
+		}
+		return nil
+	}
+	sw.codes = append(sw.codes, code)
+	if len(sw.codes) >= sw.maxInMem {
+		return sw.spill()
+	}
+	return nil
+}
+
+// spill sorts buffered codes and writes them to a temporary file.
+func (sw *SortingWriter) spill() (err error) {
+	if sw.tmpDir == "" {
+		sw.tmpDir, err = ioutil.TempDir(sw.TmpDir, "unikmer-sort")
+		if err != nil {
+			return err
+		}
+	}
+	file := filepath.Join(sw.tmpDir, fmt.Sprintf("run_%03d%s", len(sw.runs)+1, ".unik"))
+
+	fh, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	outfh := bufio.NewWriter(fh)
+
+	writer, err := NewWriter(outfh, sw.writer.K, sw.writer.Flag&^UNIK_COMPACT)
+	if err != nil {
+		return err
+	}
+	writer.SetMaxTaxid(sw.writer.maxTaxid)
+
+	err = sw.writeBuffered(writer)
+	if err != nil {
+		return err
+	}
+	err = outfh.Flush()
+	if err != nil {
+		return err
+	}
+
+	sw.runs = append(sw.runs, file)
+	return nil
+}
+
+// writeBuffered sorts buffered codes, writes them and resets the buffer.
+func (sw *SortingWriter) writeBuffered(writer *Writer) (err error) {
+	if sw.includeTaxid {
+		sort.Sort(CodeTaxidSlice(sw.pairs))
+		writer.Number = int64(len(sw.pairs))
+		for _, p := range sw.pairs {
+			if err = writer.WriteCodeWithTaxid(p.Code, p.Taxid); err != nil {
+				return err
+			}
+		}
+		sw.pairs = sw.pairs[:0]
+	} else {
+		sort.Sort(CodeSlice(sw.codes))
+		writer.Number = int64(len(sw.codes))
+		for _, code := range sw.codes {
+			if err = writer.WriteCode(code); err != nil {
+				return err
+			}
+		}
+		sw.codes = sw.codes[:0]
+	}
+	return writer.Flush()
+}
+
+// Close sorts and writes all codes, and removes temporary files.
+// Note that the underlying io.Writer is not closed.
+func (sw *SortingWriter) Close() (err error) {
+	if sw.closed {
+		return ErrSortingWriterClosed
+	}
+	sw.closed = true
+
+	if len(sw.runs) == 0 { // all in RAM
+		return sw.writeBuffered(sw.writer)
+	}
+
+	defer os.RemoveAll(sw.tmpDir)
+
+	if len(sw.codes) > 0 || len(sw.pairs) > 0 {
+		if err = sw.spill(); err != nil {
+			return err
+		}
+	}
+
+	// k-way merge
+	readers := make([]*Reader, len(sw.runs))
+	for i, file := range sw.runs {
+		fh, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+
+		readers[i], err = NewReader(bufio.NewReader(fh))
+		if err != nil {
+			return err
+		}
+	}
+
+	h := make(runHeap, 0, len(readers))
+	var code uint64
+	var taxid uint32
+	for i, reader := range readers {
+		code, taxid, err = reader.ReadCodeWithTaxid()
+		if err != nil {
+			if err == io.EOF {
+				continue
+			}
+			return err
+		}
+		h = append(h, runEntry{idx: i, code: code, taxid: taxid})
+	}
+	heap.Init(&h)
+
+	writer := sw.writer
+	writer.Number = sw.number
+	var e runEntry
+	for len(h) > 0 {
+		e = h[0]
+		if err = writer.WriteCodeWithTaxid(e.code, e.taxid); err != nil {
+			return err
+		}
+
+		code, taxid, err = readers[e.idx].ReadCodeWithTaxid()
+		if err != nil {
+			if err == io.EOF {
+				heap.Pop(&h)
+				continue
+			}
+			return err
+		}
+		h[0].code, h[0].taxid = code, taxid
+		heap.Fix(&h, 0)
+	}
+
+	return writer.Flush()
+}
+
+type runEntry struct {
+	idx   int // run index
+	code  uint64
+	taxid uint32
+	count uint32
+}
+
+type runHeap []runEntry
+
+func (h runHeap) Len() int { return len(h) }
+
+// Less breaks ties with run indexes, so equal codes are popped in order of runs.
+func (h runHeap) Less(i, j int) bool {
+	return h[i].code < h[j].code || (h[i].code == h[j].code && h[i].idx < h[j].idx)
+}
+
+func (h runHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(runEntry)) }
+
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
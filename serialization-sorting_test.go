@@ -0,0 +1,112 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// TestSortingWriter tests writing codes in random order with SortingWriter
+func TestSortingWriter(t *testing.T) {
+	k := 21
+	n := 10001
+	for _, includeTaxid := range []bool{false, true} {
+		for _, maxInMem := range []int{n * 2, 1000, 7} {
+			codes := make([]CodeTaxid, n)
+			for i := range codes {
+				codes[i] = CodeTaxid{Code: rand.Uint64() & MaxCode[k], Taxid: uint32(i + 1)}
+			}
+			dup := codes[0].Code
+			codes[1].Code = dup // duplicated
+
+			var flag uint32
+			if includeTaxid {
+				flag |= UNIK_INCLUDETAXID
+			}
+
+			buf := new(bytes.Buffer)
+			sw, err := NewSortingWriter(buf, k, flag, maxInMem)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sw.TmpDir = t.TempDir()
+			for _, ct := range codes {
+				if err = sw.WriteCodeWithTaxid(ct.Code, ct.Taxid); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = sw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err = sw.WriteCode(1); err != ErrSortingWriterClosed {
+				t.Errorf("writing after closing should fail")
+			}
+
+			reader, err := NewReader(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reader.IsSorted() {
+				t.Errorf("output should be sorted")
+			}
+			if reader.Number != int64(n) {
+				t.Errorf("number in header: %d != %d", reader.Number, n)
+			}
+
+			sort.Stable(CodeTaxidSlice(codes))
+			var i int
+			var code uint64
+			var taxid uint32
+			for {
+				code, taxid, err = reader.ReadCodeWithTaxid()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					t.Fatal(err)
+				}
+				if i >= n || code != codes[i].Code {
+					t.Fatalf("taxid: %v, maxInMem: %d: unexpected code #%d: %d", includeTaxid, maxInMem, i, code)
+				}
+				if includeTaxid && code != dup && taxid != codes[i].Taxid {
+					t.Fatalf("taxid: %v, maxInMem: %d: unexpected taxid #%d: %d", includeTaxid, maxInMem, i, taxid)
+				}
+				i++
+			}
+			if i != n {
+				t.Errorf("taxid: %v, maxInMem: %d: %d codes expected, %d read", includeTaxid, maxInMem, n, i)
+			}
+		}
+	}
+}
+
+// TestSortingWriterPayload tests that flags of payloads not sorted are rejected.
+func TestSortingWriterPayload(t *testing.T) {
+	for _, flag := range []uint32{UNIK_INCLUDESTRAND, UNIK_INCLUDECOUNT, UNIK_INCLUDETAXID | UNIK_INCLUDECOUNT} {
+		if _, err := NewSortingWriter(new(bytes.Buffer), 21, flag, 1000); err != ErrSortingWriterPayload {
+			t.Errorf("flag %d: ErrSortingWriterPayload expected, got: %v", flag, err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// FileInfo holds header metadata of a binary file, see Stat.
+type FileInfo struct {
+	Header
+}
+
+// Stat reads the header of a binary file and returns its metadata,
+// without reading k-mers. Gzip- and zstd-compressed files are supported.
+// It's much cheaper than NewReader for checking K and flags of files.
+func Stat(file string) (*FileInfo, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	br := bufio.NewReader(fh)
+	magic, _ := br.Peek(4) // leave errors of short files to StatReader
+
+	var r io.Reader = br
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("unikmer: fail to read gzipped file %s: %s", file, err)
+		}
+		defer gr.Close()
+		r = gr
+	case bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("unikmer: fail to read zstd-compressed file %s: %s", file, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	return StatReader(r)
+}
+
+// StatReader reads the header from an io.Reader and returns the metadata.
+// Only the header is consumed.
+func StatReader(r io.Reader) (*FileInfo, error) {
+	reader, err := newReader(r, false)
+	if err != nil {
+		return nil, err
+	}
+	return &FileInfo{Header: reader.Header}, nil
+}
+
+// IsSorted tells if the k-mers in file sorted
+func (info *FileInfo) IsSorted() bool {
+	return info.Flag&UNIK_SORTED > 0
+}
+
+// IsCanonical tells if the only canonical k-mers stored
+func (info *FileInfo) IsCanonical() bool {
+	return info.Flag&UNIK_CANONICAL > 0
+}
+
+// IsCompact tells if the k-mers are stored in a compact format
+func (info *FileInfo) IsCompact() bool {
+	return info.Flag&UNIK_COMPACT > 0
+}
+
+// IsIncludeStrand tells if every k-mer is followed by its strand
+func (info *FileInfo) IsIncludeStrand() bool {
+	return info.Flag&UNIK_INCLUDESTRAND > 0
+}
+
+// IsIncludeCount tells if every k-mer is followed by its count
+func (info *FileInfo) IsIncludeCount() bool {
+	return info.Flag&UNIK_INCLUDECOUNT > 0
+}
+
+// IsIndexed tells if the data are followed by a sparse index
+func (info *FileInfo) IsIndexed() bool {
+	return info.Flag&UNIK_INDEXED > 0
+}
+
+// IsIncludeTaxid tells if every k-mer is followed by its taxid
+func (info *FileInfo) IsIncludeTaxid() bool {
+	return info.Flag&UNIK_INCLUDETAXID > 0
+}
+
+// HasSentinel tells if the data are ended with a sentinel
+func (info *FileInfo) HasSentinel() bool {
+	return info.Flag&UNIK_SENTINEL > 0
+}
+
+// HasGlobalTaxid means the file has a global taxid
+func (info *FileInfo) HasGlobalTaxid() bool {
+	return info.globalTaxid > 0
+}
+
+// HasTaxidInfo means the binary file contains global taxid or taxids for all k-mers
+func (info *FileInfo) HasTaxidInfo() bool {
+	return info.IsIncludeTaxid() || info.HasGlobalTaxid()
+}
+
+// GetGlobalTaxid returns the global taxid
+func (info *FileInfo) GetGlobalTaxid() uint32 {
+	return info.globalTaxid
+}
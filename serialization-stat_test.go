@@ -0,0 +1,107 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestStat(t *testing.T) {
+	dir := t.TempDir()
+	mode := uint32(UNIK_SORTED | UNIK_CANONICAL | UNIK_INCLUDETAXID)
+
+	write := func(file string, wrap func(io.Writer) io.WriteCloser) {
+		fh, err := os.Create(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fh.Close()
+
+		bw := bufio.NewWriter(fh)
+		defer bw.Flush()
+
+		w := wrap(bw)
+		defer w.Close()
+
+		writer, err := NewWriter(w, 21, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Number = 3
+		for i := 1; i <= 3; i++ {
+			if err = writer.WriteCodeWithTaxid(uint64(i), uint32(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = writer.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := map[string]func(io.Writer) io.WriteCloser{
+		"plain.unik": func(w io.Writer) io.WriteCloser { return nopWriteCloser{w} },
+		"gzip.unik":  func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"zstd.unik": func(w io.Writer) io.WriteCloser {
+			zw, err := zstd.NewWriter(w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return zw
+		},
+	}
+
+	for name, wrap := range files {
+		file := filepath.Join(dir, name)
+		write(file, wrap)
+
+		info, err := Stat(file)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if info.K != 21 || info.Number != 3 || info.MainVersion != MainVersion {
+			t.Errorf("%s: unexpected header: K %d, number %d, version %d.%d",
+				name, info.K, info.Number, info.MainVersion, info.MinorVersion)
+		}
+		if !info.IsSorted() || !info.IsCanonical() || !info.IsIncludeTaxid() || !info.HasTaxidInfo() {
+			t.Errorf("%s: unexpected flags: %d", name, info.Flag)
+		}
+		if info.IsCompact() || info.HasGlobalTaxid() || info.IsIncludeStrand() {
+			t.Errorf("%s: unexpected flags: %d", name, info.Flag)
+		}
+	}
+
+	if _, err := Stat(filepath.Join(dir, "not-existed.unik")); err == nil {
+		t.Errorf("error expected for missing file")
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
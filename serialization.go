@@ -21,10 +21,13 @@
 package unikmer
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 )
 
 // MainVersion is the main version number.
@@ -60,6 +63,37 @@ var ErrCallReadWriteTaxid = errors.New("unikmer: can not call ReadTaxid/WriteTax
 // ErrInvalidTaxid means zero given for a taxid
 var ErrInvalidTaxid = errors.New("unikmer: invalid taxid, 0 not allowed")
 
+// ErrCallReadWriteStrand means flag UNIK_INCLUDESTRAND is off, but you call ReadCodeWithStrand/WriteCodeWithStrand
+var ErrCallReadWriteStrand = errors.New("unikmer: can not call ReadCodeWithStrand/WriteCodeWithStrand when flag UNIK_INCLUDESTRAND is off")
+
+// ErrCallLateBufferSize means SetBufferSize should be called before writing anything
+var ErrCallLateBufferSize = errors.New("unikmer: SetBufferSize should be called before writing anything")
+
+// ErrNoSentinel means flag UNIK_SENTINEL is off, but you call NextSegment
+var ErrNoSentinel = errors.New("unikmer: can not call NextSegment when flag UNIK_SENTINEL is off")
+
+// ErrCanonicalMismatch means sampled k-mers contradict the flag UNIK_CANONICAL,
+// see StrictOpen.
+var ErrCanonicalMismatch = errors.New("unikmer: k-mers contradict the 'canonical' flag, the file might be mislabeled")
+
+// StrictOpen makes NewReader sample the first strictOpenSamples k-mers
+// and check whether they contradict the flag UNIK_CANONICAL,
+// i.e., non-canonical k-mers found in a file flagged canonical,
+// or all sampled k-mers being canonical in an unsorted file not flagged
+// canonical (the first k-mers of sorted files are biased to be canonical).
+// ErrCanonicalMismatch is returned in these cases, unless
+// CanonicalMismatchHandler is set.
+// Files with UNIK_SENTINEL are not checked.
+var StrictOpen bool
+
+// CanonicalMismatchHandler, if not nil, is called by NewReader with the
+// Reader when StrictOpen finds sampled k-mers contradicting the flag
+// UNIK_CANONICAL, and the returned error is returned by NewReader,
+// e.g., a handler only logging a warning returns nil.
+var CanonicalMismatchHandler func(reader *Reader) error
+
+var strictOpenSamples = 32
+
 var be = binary.BigEndian
 
 var descMaxLen = 128
@@ -86,8 +120,60 @@ const (
 	UNIK_SORTED // when sorted, the serialization structure is very different
 	// UNIK_INCLUDETAXID means a k-mer are followed it's LCA taxid
 	UNIK_INCLUDETAXID
+	// UNIK_SENTINEL means data are framed into blocks and ended with a sentinel,
+	// so the end of data can be detected, and multiple files concatenated
+	// at the byte level can be read one by one with Reader.NextSegment.
+	UNIK_SENTINEL
+	// UNIK_INCLUDESTRAND means a k-mer are followed by a byte storing its strand,
+	// i.e., whether it came from the reverse complement strand before
+	// canonicalization. In sorted mode, strands of two k-mers share a byte.
+	UNIK_INCLUDESTRAND
+	// UNIK_INDEXED means data of a sorted file are followed by a sparse index
+	// of codes and their positions, for Reader.Seek. See NewWriterWithIndex.
+	UNIK_INDEXED
+	// UNIK_INCLUDECOUNT means a k-mer are followed by its count (4 bytes),
+	// after the strand if UNIK_INCLUDESTRAND is on.
+	// In sorted mode, counts of two k-mers are stored together.
+	UNIK_INCLUDECOUNT
 )
 
+// HeaderSize is the number of bytes of the header of binary files.
+const HeaderSize = 190
+
+// RecordSize returns the number of bytes of a k-mer record in binary files
+// with the given flag, including the strand, the count and the taxid (4 bytes at most),
+// so the size of an uncompressed file is HeaderSize + RecordSize * number.
+// -1 is returned for sorted files or files with UNIK_SENTINEL,
+// of which records are not of fixed width.
+func RecordSize(flag uint32, k int) int {
+	return recordSize(flag, k, 4)
+}
+
+func recordSize(flag uint32, k int, taxidByteLen int) int {
+	if flag&UNIK_SORTED > 0 || flag&UNIK_SENTINEL > 0 {
+		return -1
+	}
+	compact := flag&UNIK_COMPACT > 0
+	size := 8
+	if compact {
+		size = (k + 3) / 4
+	}
+	if flag&UNIK_INCLUDESTRAND > 0 {
+		size++
+	}
+	if flag&UNIK_INCLUDECOUNT > 0 {
+		size += 4
+	}
+	if flag&UNIK_INCLUDETAXID > 0 {
+		if compact {
+			size += taxidByteLen
+		} else {
+			size += 4
+		}
+	}
+	return size
+}
+
 func (h Header) String() string {
 	return fmt.Sprintf("unikmer binary k-mer data file v%d.%d with K=%d and Flag=%d",
 		h.MainVersion, h.MinorVersion, h.K, h.Flag)
@@ -98,6 +184,9 @@ type Reader struct {
 	Header
 	r io.Reader
 
+	raw      io.Reader    // the underlying reader
+	blockRdr *blockReader // for UNIK_SENTINEL
+
 	buf []byte
 
 	compact bool // saving KmerCode in variable-length byte array.
@@ -116,18 +205,117 @@ type Reader struct {
 	hasPrevTaxid  bool
 	justReadACode bool
 	lastRecord    bool
+
+	includeStrand bool
+	bufStrand     []byte
+	strand        bool // strand of the last read code
+	prevStrand    bool // strand of the buffered code
+
+	includeCount bool
+	bufCount     []byte
+	count        uint32 // count of the last read code
+	prevCount    uint32 // count of the buffered code
+
+	// for random access, see NewReaderAt
+	ra         io.ReaderAt
+	dataStart  int64
+	recordSize int
+
+	bufBatch []byte // for ReadN
+
+	// for UNIK_INDEXED
+	indexed bool
+	eod     bool         // end of data
+	index   []indexEntry // for Seek, see NewReaderAt
+	dataEnd int64
 }
 
 // NewReader returns a Reader.
 func NewReader(r io.Reader) (reader *Reader, err error) {
-	reader = &Reader{r: r}
+	return newReader(r, StrictOpen)
+}
+
+func newReader(r io.Reader, checkCanonical bool) (reader *Reader, err error) {
+	reader = &Reader{r: r, raw: r}
 	err = reader.readHeader()
 	if err != nil {
 		return nil, err
 	}
+	if checkCanonical && !reader.HasSentinel() {
+		err = reader.checkCanonical()
+		if err == ErrCanonicalMismatch && CanonicalMismatchHandler != nil {
+			err = CanonicalMismatchHandler(reader)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
 	return reader, nil
 }
 
+// checkCanonical samples the first strictOpenSamples k-mers and checks
+// whether they contradict the flag UNIK_CANONICAL. Consumed data are
+// pushed back and the state of the Reader is restored.
+func (reader *Reader) checkCanonical() error {
+	saved := *reader
+	var buf bytes.Buffer
+	reader.r = io.TeeReader(saved.r, &buf)
+
+	canonical := reader.IsCanonical()
+	var code uint64
+	var err error
+	var n, nCanonical int
+	for n < strictOpenSamples {
+		code, _, err = reader.ReadCodeWithTaxid()
+		if err != nil { // leave errors to following reading
+			break
+		}
+		n++
+		if Canonical(code, reader.K) == code {
+			nCanonical++
+		}
+	}
+
+	*reader = saved
+	reader.r = io.MultiReader(&buf, saved.r)
+	reader.raw = reader.r
+
+	if canonical && nCanonical < n {
+		return ErrCanonicalMismatch
+	}
+	if !canonical && !reader.IsSorted() && n == strictOpenSamples && nCanonical == n {
+		return ErrCanonicalMismatch
+	}
+	return nil
+}
+
+// RecordSize returns the number of bytes of a k-mer record,
+// -1 for sorted files or files with UNIK_SENTINEL.
+func (reader *Reader) RecordSize() int {
+	return recordSize(reader.Flag, reader.K, reader.taxidByteLen)
+}
+
+// HasSentinel tells if the data are ended with a sentinel
+func (reader *Reader) HasSentinel() bool {
+	return reader.Flag&UNIK_SENTINEL > 0
+}
+
+// NextSegment skips remaining data of current segment, and reads header of
+// the next segment, for reading a stream of concatenated binary files
+// written with flag UNIK_SENTINEL. io.EOF is returned if no segments left.
+func (reader *Reader) NextSegment() error {
+	if !reader.HasSentinel() {
+		return ErrNoSentinel
+	}
+	_, err := io.Copy(ioutil.Discard, reader.blockRdr)
+	if err != nil {
+		return err
+	}
+
+	*reader = Reader{r: reader.raw, raw: reader.raw}
+	return reader.readHeader()
+}
+
 // IsSorted tells if the k-mers in file sorted
 func (reader *Reader) IsSorted() bool {
 	return reader.Flag&UNIK_SORTED > 0
@@ -143,6 +331,21 @@ func (reader *Reader) IsCompact() bool {
 	return reader.Flag&UNIK_COMPACT > 0
 }
 
+// IsIncludeStrand tells if every k-mer is followed by its strand
+func (reader *Reader) IsIncludeStrand() bool {
+	return reader.Flag&UNIK_INCLUDESTRAND > 0
+}
+
+// IsIncludeCount tells if every k-mer is followed by its count
+func (reader *Reader) IsIncludeCount() bool {
+	return reader.Flag&UNIK_INCLUDECOUNT > 0
+}
+
+// IsIndexed tells if the data are followed by a sparse index
+func (reader *Reader) IsIndexed() bool {
+	return reader.Flag&UNIK_INDEXED > 0
+}
+
 // IsIncludeTaxid tells if every k-mer is followed by its taxid
 func (reader *Reader) IsIncludeTaxid() bool {
 	return reader.Flag&UNIK_INCLUDETAXID > 0
@@ -222,6 +425,17 @@ func (reader *Reader) readHeader() (err error) {
 		reader.includeTaxid = true
 		reader.bufTaxid = make([]byte, 4)
 	}
+	if reader.IsIncludeStrand() {
+		reader.includeStrand = true
+		reader.bufStrand = make([]byte, 1)
+	}
+	if reader.IsIncludeCount() {
+		reader.includeCount = true
+		reader.bufCount = make([]byte, 8)
+	}
+	if reader.IsIndexed() {
+		reader.indexed = true
+	}
 
 	// number
 	err = binary.Read(r, be, &reader.Number)
@@ -262,6 +476,16 @@ func (reader *Reader) readHeader() (err error) {
 		return err
 	}
 
+	if reader.HasSentinel() {
+		// reading block lengths needs an io.ByteReader, the buffered reader
+		// is also used for reading following segments.
+		if _, ok := reader.raw.(io.ByteReader); !ok {
+			reader.raw = bufio.NewReader(reader.raw)
+		}
+		reader.blockRdr = &blockReader{r: reader.raw.(byteReader)}
+		reader.r = reader.blockRdr
+	}
+
 	return nil
 }
 
@@ -349,17 +573,102 @@ func (reader *Reader) ReadTaxid() (taxid uint32, err error) {
 	return be.Uint32(reader.bufTaxid), nil
 }
 
+// ReadCodeWithStrand reads a code, and whether it came from
+// the reverse complement strand.
+func (reader *Reader) ReadCodeWithStrand() (code uint64, rc bool, err error) {
+	if !reader.includeStrand {
+		return 0, false, ErrCallReadWriteStrand
+	}
+	code, err = reader.ReadCode()
+	if err != nil {
+		return 0, false, err
+	}
+	return code, reader.strand, nil
+}
+
+// ReadCodeWithCount reads a code and its count.
+// The count is always 1 if UNIK_INCLUDECOUNT is off.
+func (reader *Reader) ReadCodeWithCount() (code uint64, count uint32, err error) {
+	code, err = reader.ReadCode()
+	if err != nil {
+		return 0, 0, err
+	}
+	if !reader.includeCount {
+		return code, 1, nil
+	}
+	return code, reader.count, nil
+}
+
+// ReadRecord reads a code along with all its payloads, i.e., the strand,
+// the count and the taxid, so records can be copied to a Writer of the
+// same flag with WriteRecord without losing any information.
+// The strand is false if UNIK_INCLUDESTRAND is off, the count is 1 if
+// UNIK_INCLUDECOUNT is off, and the taxid is the global one if
+// UNIK_INCLUDETAXID is off.
+func (reader *Reader) ReadRecord() (code uint64, rc bool, count uint32, taxid uint32, err error) {
+	code, err = reader.ReadCode()
+	if err != nil {
+		return 0, false, 0, 0, err
+	}
+	if reader.includeStrand {
+		rc = reader.strand
+	}
+	count = 1
+	if reader.includeCount {
+		count = reader.count
+	}
+	if reader.includeTaxid {
+		taxid, err = reader.ReadTaxid()
+		if err != nil {
+			return 0, false, 0, 0, err
+		}
+	} else {
+		taxid = reader.globalTaxid
+	}
+	return code, rc, count, taxid, nil
+}
+
+// readCounts reads n (1 or 2) counts into reader.bufCount.
+func (reader *Reader) readCounts(n int) error {
+	_, err := io.ReadFull(reader.r, reader.bufCount[:n<<2])
+	if err != nil {
+		if err == io.EOF {
+			return ErrBrokenFile
+		}
+		return err
+	}
+	return nil
+}
+
+// readStrand reads a strand byte.
+func (reader *Reader) readStrand() (byte, error) {
+	_, err := io.ReadFull(reader.r, reader.bufStrand)
+	if err != nil {
+		if err == io.EOF {
+			return 0, ErrBrokenFile
+		}
+		return 0, err
+	}
+	return reader.bufStrand[0], nil
+}
+
 // ReadCode reads one code.
 func (reader *Reader) ReadCode() (uint64, error) {
 	var err error
+	var b byte
 	if reader.sorted {
 		if reader.hasPrev {
 			c := reader.prev
 			// reader.prev = 0
 			reader.hasPrev = false
 			reader.justReadACode = true
+			reader.strand = reader.prevStrand
+			reader.count = reader.prevCount
 			return c, nil
 		}
+		if reader.eod {
+			return 0, io.EOF
+		}
 
 		buf2 := reader.buf2
 		r := reader.r
@@ -372,17 +681,38 @@ func (reader *Reader) ReadCode() (uint64, error) {
 		}
 
 		ctrlByte := buf2[0]
+		if reader.indexed && ctrlByte == ctrlByteEndOfData { // followed by the index
+			reader.eod = true
+			return 0, io.EOF
+		}
 		if ctrlByte&128 > 0 { // last one
 			nReaded, err = io.ReadFull(r, buf2[0:8])
 			if err != nil {
 				return 0, err
 			}
+			if reader.includeStrand {
+				b, err = reader.readStrand()
+				if err != nil {
+					return 0, err
+				}
+				reader.strand = b&1 > 0
+			}
+			if reader.includeCount {
+				err = reader.readCounts(1)
+				if err != nil {
+					return 0, err
+				}
+				reader.count = be.Uint32(reader.bufCount)
+			}
 			reader.lastRecord = true
 			reader.justReadACode = true
 			return be.Uint64(buf2[0:8]), nil
 		}
 
 		// parse control byte
+		if int(ctrlByte) >= len(ctrlByte2ByteLengths) {
+			return 0, ErrBrokenFile
+		}
 		encodedBytes := ctrlByte2ByteLengths[ctrlByte]
 		nEncodedBytes := int(encodedBytes[0] + encodedBytes[1])
 
@@ -400,6 +730,23 @@ func (reader *Reader) ReadCode() (uint64, error) {
 			return 0, ErrBrokenFile
 		}
 
+		if reader.includeStrand { // strands of the two codes
+			b, err = reader.readStrand()
+			if err != nil {
+				return 0, err
+			}
+			reader.strand = b&1 > 0
+			reader.prevStrand = b&2 > 0
+		}
+		if reader.includeCount { // counts of the two codes
+			err = reader.readCounts(2)
+			if err != nil {
+				return 0, err
+			}
+			reader.count = be.Uint32(reader.bufCount)
+			reader.prevCount = be.Uint32(reader.bufCount[4:])
+		}
+
 		code := decodedVals[0] + reader.offset
 		reader.prev = code + decodedVals[1]
 		reader.hasPrev = true
@@ -417,6 +764,21 @@ func (reader *Reader) ReadCode() (uint64, error) {
 		return 0, err
 	}
 
+	if reader.includeStrand {
+		b, err = reader.readStrand()
+		if err != nil {
+			return 0, err
+		}
+		reader.strand = b&1 > 0
+	}
+	if reader.includeCount {
+		err = reader.readCounts(1)
+		if err != nil {
+			return 0, err
+		}
+		reader.count = be.Uint32(reader.bufCount)
+	}
+
 	reader.justReadACode = true
 	return be.Uint64(reader.buf), nil
 }
@@ -427,6 +789,9 @@ type Writer struct {
 	w           io.Writer
 	wroteHeader bool
 
+	blockWtr *blockWriter // for UNIK_SENTINEL
+	bw       *bufio.Writer
+
 	buf []byte
 
 	// saving KmerCode in compact fixlength byte array.
@@ -450,13 +815,36 @@ type Writer struct {
 	taxidByteLen     int
 	prevTaxid        uint32 // buffered taxid
 	hasPrevTaxid     bool
+
+	// for strand
+	includeStrand bool
+	bufStrand     []byte
+	prevStrand    bool // strand of buffered code
+
+	// for count
+	includeCount bool
+	bufCount     []byte
+	prevCount    uint32 // count of buffered code
+
+	bufBatch []byte // for WriteBatch
+
+	// for UNIK_INDEXED, see NewWriterWithIndex
+	indexInterval int
+	index         []indexEntry
+	cw            *countingWriter
+	nCodes        int64
+	lastIndexed   int64
+	wroteIndex    bool
 }
 
 // NewWriter creates a Writer.
+// ErrKOverflow is returned for k out of range of [1, 32].
+// UNIK_INDEXED in flag is ignored, please use NewWriterWithIndex.
 func NewWriter(w io.Writer, k int, flag uint32) (*Writer, error) {
-	if k == 0 || k > 32 {
+	if k < 1 || k > 32 {
 		return nil, ErrKOverflow
 	}
+	flag &^= UNIK_INDEXED // e.g., flag copied from a Reader
 
 	writer := &Writer{
 		Header: Header{MainVersion: MainVersion, MinorVersion: MinorVersion, K: k, Flag: flag, Number: -1},
@@ -477,15 +865,34 @@ func NewWriter(w io.Writer, k int, flag uint32) (*Writer, error) {
 		writer.includeTaxid = true
 		writer.bufTaxid = make([]byte, 4)
 	}
+	if writer.Flag&UNIK_INCLUDESTRAND > 0 {
+		writer.includeStrand = true
+		writer.bufStrand = make([]byte, 1)
+	}
+	if writer.Flag&UNIK_INCLUDECOUNT > 0 {
+		writer.includeCount = true
+		writer.bufCount = make([]byte, 8)
+	}
 
 	return writer, nil
 }
 
-// WriteHeader writes file header
+// HeaderWritten returns whether the header has been written.
+func (writer *Writer) HeaderWritten() bool {
+	return writer.wroteHeader
+}
+
+// WriteHeader writes file header. It's not necessary to call it manually,
+// as the header is written lazily before the first code, or in Flush
+// if nothing is written. It's a no-op if the header is already written.
 func (writer *Writer) WriteHeader() (err error) {
 	if writer.wroteHeader {
 		return nil
 	}
+	if writer.indexInterval > 0 { // positions are counted from the beginning
+		writer.cw = &countingWriter{w: writer.w}
+		writer.w = writer.cw
+	}
 	w := writer.w
 
 	// 8 bytes magic number
@@ -556,6 +963,15 @@ func (writer *Writer) WriteHeader() (err error) {
 
 	// header has 192 bytes
 
+	if writer.Flag&UNIK_SENTINEL > 0 {
+		size := blockSize
+		if writer.bw != nil && writer.bw.Size() < size {
+			size = writer.bw.Size()
+		}
+		writer.blockWtr = &blockWriter{w: writer.w, buf: make([]byte, 0, size)}
+		writer.w = writer.blockWtr
+	}
+
 	writer.wroteHeader = true
 	return nil
 }
@@ -591,12 +1007,13 @@ func (writer *Writer) WriteKmer(mer []byte) error {
 func (writer *Writer) WriteKmerWithTaxid(mer []byte, taxid uint32) error {
 	err := writer.WriteKmer(mer)
 	if err != nil {
-		return nil
+		return err
 	}
 	return writer.WriteTaxid(taxid)
 }
 
 // Write writes one KmerCode.
+// ErrKMismatch is returned if K of the KmerCode differs from that of the writer.
 func (writer *Writer) Write(kcode KmerCode) (err error) {
 	if writer.K != kcode.K {
 		return ErrKMismatch
@@ -609,7 +1026,7 @@ func (writer *Writer) Write(kcode KmerCode) (err error) {
 func (writer *Writer) WriteWithTaxid(kcode KmerCode, taxid uint32) (err error) {
 	err = writer.Write(kcode)
 	if err != nil {
-		return nil
+		return err
 	}
 	return writer.WriteTaxid(taxid)
 }
@@ -619,7 +1036,7 @@ func (writer *Writer) WriteWithTaxid(kcode KmerCode, taxid uint32) (err error) {
 func (writer *Writer) WriteCodeWithTaxid(code uint64, taxid uint32) (err error) {
 	err = writer.WriteCode(code)
 	if err != nil {
-		return nil
+		return err
 	}
 	if !writer.includeTaxid { // if no taxid, just return.
 		return nil
@@ -627,6 +1044,24 @@ func (writer *Writer) WriteCodeWithTaxid(code uint64, taxid uint32) (err error)
 	return writer.WriteTaxid(taxid)
 }
 
+// WriteKmerCodeWithTaxid writes one KmerCode and its taxid.
+// Different from WriteWithTaxid, ErrKMismatch is returned if K of the
+// KmerCode differs from that of the writer, and ErrCallReadWriteTaxid
+// is returned if UNIK_INCLUDETAXID is off.
+func (writer *Writer) WriteKmerCodeWithTaxid(kcode KmerCode, taxid uint32) (err error) {
+	if !writer.includeTaxid {
+		return ErrCallReadWriteTaxid
+	}
+	if writer.K != kcode.K {
+		return ErrKMismatch
+	}
+	err = writer.WriteCode(kcode.Code)
+	if err != nil {
+		return err
+	}
+	return writer.WriteTaxid(taxid)
+}
+
 // WriteTaxid appends taxid to the code
 func (writer *Writer) WriteTaxid(taxid uint32) (err error) {
 	if !writer.includeTaxid {
@@ -648,8 +1083,10 @@ func (writer *Writer) WriteTaxid(taxid uint32) (err error) {
 		_, err = writer.w.Write(writer.bufTaxid[4-writer.taxidByteLen:])
 		// fmt.Printf("write taxid: %d, %d\n", writer.prevTaxid, writer.bufTaxid[4-writer.taxidByteLen:])
 
-		be.PutUint32(writer.bufTaxid, taxid)
-		_, err = writer.w.Write(writer.bufTaxid[4-writer.taxidByteLen:])
+		if err == nil {
+			be.PutUint32(writer.bufTaxid, taxid)
+			_, err = writer.w.Write(writer.bufTaxid[4-writer.taxidByteLen:])
+		}
 		writer.hasPrevTaxid = false
 	} else if writer.compact {
 		be.PutUint32(writer.bufTaxid, taxid)
@@ -658,25 +1095,84 @@ func (writer *Writer) WriteTaxid(taxid uint32) (err error) {
 		be.PutUint32(writer.bufTaxid, taxid)
 		_, err = writer.w.Write(writer.bufTaxid)
 	}
+	if err != nil {
+		return err
+	}
 
 	writer.justWrittenACode = false
 	return nil
 }
 
-// WriteCode writes one code
+// WriteCodeWithStrand writes a code, and whether it came from
+// the reverse complement strand.
+func (writer *Writer) WriteCodeWithStrand(code uint64, rc bool) (err error) {
+	if !writer.includeStrand {
+		return ErrCallReadWriteStrand
+	}
+	return writer.writeCode(code, rc, 1)
+}
+
+// WriteCodeWithCount writes a code and its count.
+// If UNIK_INCLUDECOUNT is off, count will not be written.
+func (writer *Writer) WriteCodeWithCount(code uint64, count uint32) (err error) {
+	return writer.writeCode(code, false, count)
+}
+
+// WriteRecord writes a code along with all its payloads, i.e., the strand,
+// the count and the taxid, see ReadRecord. Payloads are not written if
+// the corresponding flags are off.
+func (writer *Writer) WriteRecord(code uint64, rc bool, count uint32, taxid uint32) (err error) {
+	err = writer.writeCode(code, rc, count)
+	if err != nil {
+		return err
+	}
+	if !writer.includeTaxid {
+		return nil
+	}
+	return writer.WriteTaxid(taxid)
+}
+
+// WriteCode writes one code.
+// The code is assumed to be of the writer's K, and no check is performed,
+// use Write for KmerCodes of uncertain K.
+// If UNIK_INCLUDESTRAND is on, the strand is recorded as the forward one.
+// If UNIK_INCLUDECOUNT is on, the count is recorded as 1.
 func (writer *Writer) WriteCode(code uint64) (err error) {
+	return writer.writeCode(code, false, 1)
+}
+
+func (writer *Writer) writeStrand(b byte) (err error) {
+	writer.bufStrand[0] = b
+	_, err = writer.w.Write(writer.bufStrand)
+	return err
+}
+
+// writeCounts writes one or two counts.
+func (writer *Writer) writeCounts(counts ...uint32) (err error) {
+	for i, c := range counts {
+		be.PutUint32(writer.bufCount[i<<2:], c)
+	}
+	_, err = writer.w.Write(writer.bufCount[:len(counts)<<2])
+	return err
+}
+
+func (writer *Writer) writeCode(code uint64, rc bool, count uint32) (err error) {
 	// lazily write header
 	if !writer.wroteHeader {
 		err = writer.WriteHeader()
 		if err != nil {
 			return err
 		}
-		writer.wroteHeader = true
 	}
 
 	if writer.sorted {
+		if writer.indexInterval > 0 {
+			writer.addIndexEntry(code)
+		}
 		if !writer.hasPrev { // write it later
 			writer.prev = code
+			writer.prevStrand = rc
+			writer.prevCount = count
 			writer.hasPrev = true
 			writer.justWrittenACode = true
 			return nil
@@ -687,6 +1183,12 @@ func (writer *Writer) WriteCode(code uint64) (err error) {
 		writer.buf3[0] = writer.ctrlByte
 		copy(writer.buf3[1:writer.nEncodedByte+1], writer.buf2[0:writer.nEncodedByte])
 		_, err = writer.w.Write(writer.buf3[0 : writer.nEncodedByte+1])
+		if err == nil && writer.includeStrand { // strands of the two codes
+			err = writer.writeStrand(strandByte(writer.prevStrand) | strandByte(rc)<<1)
+		}
+		if err == nil && writer.includeCount { // counts of the two codes
+			err = writer.writeCounts(writer.prevCount, count)
+		}
 
 		writer.offset = code
 		// writer.prev = 0
@@ -698,6 +1200,12 @@ func (writer *Writer) WriteCode(code uint64) (err error) {
 		be.PutUint64(writer.buf, code)
 		_, err = writer.w.Write(writer.buf)
 	}
+	if err == nil && !writer.sorted && writer.includeStrand {
+		err = writer.writeStrand(strandByte(rc))
+	}
+	if err == nil && !writer.sorted && writer.includeCount {
+		err = writer.writeCounts(count)
+	}
 
 	if err != nil {
 		return err
@@ -706,12 +1214,61 @@ func (writer *Writer) WriteCode(code uint64) (err error) {
 	return nil
 }
 
+func strandByte(rc bool) byte {
+	if rc {
+		return 1
+	}
+	return 0
+}
+
+// SetBufferSize makes the Writer buffer at most size bytes before
+// writing to the underlying io.Writer, so a slow consumer blocks writing
+// instead of data piling up in RAM. It should be called before writing
+// anything. Note that Flush should be called to write the buffered data.
+func (writer *Writer) SetBufferSize(size int) error {
+	if writer.wroteHeader {
+		return ErrCallLateBufferSize
+	}
+	if size <= 0 {
+		return fmt.Errorf("unikmer: buffer size should be positive: %d", size)
+	}
+	writer.bw = bufio.NewWriterSize(writer.w, size)
+	writer.w = writer.bw
+	return nil
+}
+
 // Flush write the last k-mer
 func (writer *Writer) Flush() (err error) {
 	if !writer.wroteHeader {
 		writer.Number = 0
-		writer.WriteHeader()
+		err = writer.WriteHeader()
+		if err != nil {
+			return err
+		}
+	}
+	err = writer.writeLastRecord()
+	if err != nil {
+		return err
+	}
+	if writer.indexInterval > 0 && !writer.wroteIndex {
+		err = writer.writeIndex()
+		if err != nil {
+			return err
+		}
+	}
+	if writer.blockWtr != nil {
+		err = writer.blockWtr.finish()
+		if err != nil {
+			return err
+		}
 	}
+	if writer.bw != nil {
+		return writer.bw.Flush()
+	}
+	return nil
+}
+
+func (writer *Writer) writeLastRecord() (err error) {
 	if !writer.sorted || !writer.hasPrev {
 		return nil
 	}
@@ -725,6 +1282,18 @@ func (writer *Writer) Flush() (err error) {
 	if err != nil {
 		return err
 	}
+	if writer.includeStrand {
+		err = writer.writeStrand(strandByte(writer.prevStrand))
+		if err != nil {
+			return err
+		}
+	}
+	if writer.includeCount {
+		err = writer.writeCounts(writer.prevCount)
+		if err != nil {
+			return err
+		}
+	}
 	if writer.includeTaxid && writer.hasPrevTaxid { // last taxid
 		err = binary.Write(writer.w, be, writer.prevTaxid)
 		if err != nil {
@@ -736,3 +1305,101 @@ func (writer *Writer) Flush() (err error) {
 	writer.hasPrevTaxid = false
 	return nil
 }
+
+// blockSize is the maximum size of a data block for UNIK_SENTINEL.
+const blockSize = 1 << 16
+
+// blockWriter frames data into blocks, each block is preceded by its length
+// in uvarint, and a zero length is the sentinel marking the end of data.
+type blockWriter struct {
+	w        io.Writer
+	buf      []byte
+	varint   [binary.MaxVarintLen64]byte
+	finished bool
+}
+
+func (bw *blockWriter) Write(p []byte) (n int, err error) {
+	var m int
+	for len(p) > 0 {
+		m = cap(bw.buf) - len(bw.buf)
+		if m > len(p) {
+			m = len(p)
+		}
+		bw.buf = append(bw.buf, p[:m]...)
+		p = p[m:]
+		n += m
+		if len(bw.buf) == cap(bw.buf) {
+			if err = bw.writeBlock(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (bw *blockWriter) writeBlock() error {
+	l := binary.PutUvarint(bw.varint[:], uint64(len(bw.buf)))
+	_, err := bw.w.Write(bw.varint[:l])
+	if err != nil {
+		return err
+	}
+	_, err = bw.w.Write(bw.buf)
+	bw.buf = bw.buf[:0]
+	return err
+}
+
+// finish writes buffered data and the sentinel.
+func (bw *blockWriter) finish() error {
+	if bw.finished {
+		return nil
+	}
+	bw.finished = true
+	if len(bw.buf) > 0 {
+		if err := bw.writeBlock(); err != nil {
+			return err
+		}
+	}
+	_, err := bw.w.Write([]byte{0})
+	return err
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// blockReader reads data framed by blockWriter,
+// io.EOF is returned when the sentinel is met.
+type blockReader struct {
+	r    byteReader
+	left uint64
+	done bool
+}
+
+func (br *blockReader) Read(p []byte) (n int, err error) {
+	if br.done {
+		return 0, io.EOF
+	}
+	if br.left == 0 {
+		br.left, err = binary.ReadUvarint(br.r)
+		if err != nil {
+			if err == io.EOF {
+				return 0, ErrBrokenFile
+			}
+			return 0, err
+		}
+		if br.left == 0 { // sentinel
+			br.done = true
+			return 0, io.EOF
+		}
+	}
+	if uint64(len(p)) > br.left {
+		p = p[:br.left]
+	}
+	n, err = br.r.Read(p)
+	br.left -= uint64(n)
+	if err == io.EOF && br.left > 0 {
+		err = ErrBrokenFile
+	}
+	return n, err
+}
@@ -23,12 +23,14 @@ package unikmer
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"os"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/shenwei356/util/byteutil"
 )
@@ -151,3 +153,671 @@ func read(file string) ([][]byte, error) {
 
 	return mers, nil
 }
+
+func TestNewWriterKRange(t *testing.T) {
+	var buf bytes.Buffer
+	for _, k := range []int{-1, 0, 33, 64} {
+		if _, err := NewWriter(&buf, k, 0); err != ErrKOverflow {
+			t.Errorf("k=%d: ErrKOverflow expected, got: %v", k, err)
+		}
+	}
+	for _, k := range []int{1, 31, 32} {
+		if _, err := NewWriter(&buf, k, 0); err != nil {
+			t.Errorf("k=%d: unexpected error: %s", k, err)
+		}
+	}
+}
+
+// TestSetBufferSize checks that a slow consumer blocks the Writer.
+func TestWriteHeader(t *testing.T) {
+	// empty output: the same bytes with or without calling WriteHeader
+	var buf1, buf2 bytes.Buffer
+	w1, _ := NewWriter(&buf1, 21, UNIK_SORTED)
+	if err := w1.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	w2, _ := NewWriter(&buf2, 21, UNIK_SORTED)
+	if w2.HeaderWritten() {
+		t.Errorf("header should not be written before writing anything")
+	}
+	w2.Number = 0
+	for i := 0; i < 2; i++ {
+		if err := w2.WriteHeader(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !w2.HeaderWritten() {
+		t.Errorf("header should be written")
+	}
+	if err := w2.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Errorf("different outputs for empty writers: %d != %d bytes", buf1.Len(), buf2.Len())
+	}
+
+	// header is written only once
+	var buf3 bytes.Buffer
+	w3, _ := NewWriter(&buf3, 21, 0)
+	w3.WriteHeader()
+	w3.WriteCode(1)
+	w3.WriteHeader()
+	w3.WriteCode(2)
+	if err := w3.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(&buf3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []uint64{1, 2} {
+		code, err := r.ReadCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != expected {
+			t.Errorf("unexpected code: %d != %d", code, expected)
+		}
+	}
+	if _, err = r.ReadCode(); err != io.EOF {
+		t.Errorf("io.EOF expected, got: %v", err)
+	}
+}
+
+func TestSetBufferSize(t *testing.T) {
+	pr, pw := io.Pipe()
+
+	// throttled consumer
+	done := make(chan int)
+	go func() {
+		var n int
+		buf := make([]byte, 100)
+		for {
+			m, err := pr.Read(buf)
+			n += m
+			if err != nil {
+				break
+			}
+			time.Sleep(time.Microsecond)
+		}
+		done <- n
+	}()
+
+	// the pipe blocks until data are read, so written bytes are consumed ones
+	cw := &countingWriter{w: pw}
+	w, err := NewWriter(cw, 31, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bufSize := 1024
+	if err = w.SetBufferSize(bufSize); err != nil {
+		t.Fatal(err)
+	}
+
+	var hbuf bytes.Buffer
+	hw, _ := NewWriter(&hbuf, 31, 0)
+	hw.Flush()
+	headerSize := hbuf.Len()
+
+	n := 10000
+	for i := 0; i < n; i++ {
+		if err = w.WriteCode(uint64(i)); err != nil {
+			t.Fatal(err)
+		}
+		if headerSize+(i+1)*8-int(cw.n) > bufSize {
+			t.Fatalf("too many bytes buffered: %d", headerSize+(i+1)*8-int(cw.n))
+		}
+	}
+	if err = w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	pw.Close()
+
+	if m := <-done; m != headerSize+n*8 {
+		t.Errorf("bytes mismatch: %d != %d", m, headerSize+n*8)
+	}
+
+	if err = w.SetBufferSize(bufSize); err != ErrCallLateBufferSize {
+		t.Errorf("ErrCallLateBufferSize expected, got: %v", err)
+	}
+}
+
+func TestStrand(t *testing.T) {
+	for _, flag := range []uint32{0, UNIK_COMPACT, UNIK_SORTED, UNIK_INCLUDETAXID, UNIK_SORTED | UNIK_INCLUDETAXID} {
+		for _, n := range []int{0, 1, 2, 1001} {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, 21, flag|UNIK_INCLUDESTRAND)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < n; i++ {
+				if err = w.WriteCodeWithStrand(uint64(i*3), i%3 == 1); err != nil {
+					t.Fatal(err)
+				}
+				if flag&UNIK_INCLUDETAXID > 0 {
+					if err = w.WriteTaxid(uint32(i + 1)); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if err = w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := NewReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !r.IsIncludeStrand() {
+				t.Errorf("flag %d: UNIK_INCLUDESTRAND expected", flag)
+			}
+			var i int
+			for {
+				code, rc, err := r.ReadCodeWithStrand()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					t.Fatalf("flag %d, n %d: %s", flag, n, err)
+				}
+				if code != uint64(i*3) || rc != (i%3 == 1) {
+					t.Errorf("flag %d, n %d: unexpected record %d: %d, %v", flag, n, i, code, rc)
+				}
+				if flag&UNIK_INCLUDETAXID > 0 {
+					taxid, err := r.ReadTaxid()
+					if err != nil {
+						t.Fatal(err)
+					}
+					if taxid != uint32(i+1) {
+						t.Errorf("flag %d, n %d: unexpected taxid of record %d: %d", flag, n, i, taxid)
+					}
+				}
+				i++
+			}
+			if i != n {
+				t.Errorf("flag %d: number mismatch: %d != %d", flag, i, n)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, 21, 0)
+	if err := w.WriteCodeWithStrand(1, true); err != ErrCallReadWriteStrand {
+		t.Errorf("ErrCallReadWriteStrand expected, got: %v", err)
+	}
+}
+
+func TestWriteKmerCodeWithTaxid(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, 5, UNIK_INCLUDETAXID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kcodes := []KmerCode{KmerCode{Code: 3, K: 5}, KmerCode{Code: 100, K: 5}}
+	for i, kcode := range kcodes {
+		if err = w.WriteKmerCodeWithTaxid(kcode, uint32(i+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.WriteKmerCodeWithTaxid(KmerCode{Code: 3, K: 6}, 1); err != ErrKMismatch {
+		t.Errorf("ErrKMismatch expected, got: %v", err)
+	}
+	if err = w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, kcode := range kcodes {
+		code, taxid, err := r.ReadCodeWithTaxid()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != kcode.Code || taxid != uint32(i+1) {
+			t.Errorf("unexpected record %d: %d, %d", i, code, taxid)
+		}
+	}
+	if _, _, err = r.ReadCodeWithTaxid(); err != io.EOF {
+		t.Errorf("io.EOF expected, got: %v", err)
+	}
+
+	buf.Reset()
+	w, _ = NewWriter(&buf, 5, 0)
+	if err = w.WriteKmerCodeWithTaxid(kcodes[0], 1); err != ErrCallReadWriteTaxid {
+		t.Errorf("ErrCallReadWriteTaxid expected, got: %v", err)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	payloads := []uint32{0, UNIK_INCLUDESTRAND, UNIK_INCLUDECOUNT, UNIK_INCLUDETAXID,
+		UNIK_INCLUDESTRAND | UNIK_INCLUDECOUNT | UNIK_INCLUDETAXID}
+	for _, base := range []uint32{0, UNIK_COMPACT, UNIK_SORTED, UNIK_SENTINEL} {
+		for _, payload := range payloads {
+			flag := base | payload
+			for _, n := range []int{0, 1, 2, 1001} {
+				var buf bytes.Buffer
+				w, err := NewWriter(&buf, 21, flag)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < n; i++ {
+					if err = w.WriteRecord(uint64(i*3), i%3 == 1, uint32(i*7+1), uint32(i+1)); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err = w.Flush(); err != nil {
+					t.Fatal(err)
+				}
+
+				r, err := NewReader(&buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var i int
+				for {
+					code, rc, count, taxid, err := r.ReadRecord()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						t.Fatalf("flag %d, n %d: %s", flag, n, err)
+					}
+					_rc := flag&UNIK_INCLUDESTRAND > 0 && i%3 == 1
+					_count := uint32(1)
+					if flag&UNIK_INCLUDECOUNT > 0 {
+						_count = uint32(i*7 + 1)
+					}
+					var _taxid uint32
+					if flag&UNIK_INCLUDETAXID > 0 {
+						_taxid = uint32(i + 1)
+					}
+					if code != uint64(i*3) || rc != _rc || count != _count || taxid != _taxid {
+						t.Errorf("flag %d, n %d: unexpected record %d: %d, %v, %d, %d",
+							flag, n, i, code, rc, count, taxid)
+					}
+					i++
+				}
+				if i != n {
+					t.Errorf("flag %d: number mismatch: %d != %d", flag, i, n)
+				}
+			}
+		}
+	}
+}
+
+func TestWriteKMismatch(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, 5, UNIK_INCLUDETAXID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kcode := KmerCode{Code: 3, K: 6}
+	if err = w.Write(kcode); err != ErrKMismatch {
+		t.Errorf("Write: ErrKMismatch expected, got: %v", err)
+	}
+	if err = w.WriteWithTaxid(kcode, 1); err != ErrKMismatch {
+		t.Errorf("WriteWithTaxid: ErrKMismatch expected, got: %v", err)
+	}
+	if err = w.WriteKmerWithTaxid([]byte("ACGTAC"), 1); err != ErrKMismatch {
+		t.Errorf("WriteKmerWithTaxid: ErrKMismatch expected, got: %v", err)
+	}
+}
+
+// failingWriter fails after writing n bytes.
+type failingWriter struct {
+	n int
+}
+
+var errFailingWriter = errors.New("write error")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errFailingWriter
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestWriteCodeWithTaxidError(t *testing.T) {
+	for _, flag := range []uint32{UNIK_INCLUDETAXID, UNIK_COMPACT | UNIK_INCLUDETAXID, UNIK_SORTED | UNIK_INCLUDETAXID} {
+		for _, n := range []int{0, HeaderSize, HeaderSize + 10} { // in header, code and taxid
+			w, err := NewWriter(&failingWriter{n: n}, 21, flag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 100; i++ {
+				if err = w.WriteCodeWithTaxid(uint64(i), uint32(i+1)); err != nil {
+					break
+				}
+			}
+			if err != errFailingWriter {
+				t.Errorf("flag %d, fail after %d bytes: errFailingWriter expected, got: %v", flag, n, err)
+			}
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	for _, flag := range []uint32{0, UNIK_COMPACT, UNIK_SORTED, UNIK_SENTINEL,
+		UNIK_INCLUDETAXID, UNIK_SORTED | UNIK_INCLUDETAXID, UNIK_SORTED | UNIK_INCLUDESTRAND} {
+		for _, n := range []int{0, 1, 2, 1001} {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, 21, flag|UNIK_INCLUDECOUNT)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < n; i++ {
+				if err = w.WriteCodeWithCount(uint64(i*3), uint32(i*7+1)); err != nil {
+					t.Fatal(err)
+				}
+				if flag&UNIK_INCLUDETAXID > 0 {
+					if err = w.WriteTaxid(uint32(i + 1)); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if err = w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := NewReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !r.IsIncludeCount() {
+				t.Errorf("flag %d: UNIK_INCLUDECOUNT expected", flag)
+			}
+			var i int
+			for {
+				code, count, err := r.ReadCodeWithCount()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					t.Fatalf("flag %d, n %d: %s", flag, n, err)
+				}
+				if code != uint64(i*3) || count != uint32(i*7+1) {
+					t.Errorf("flag %d, n %d: unexpected record %d: %d, %d", flag, n, i, code, count)
+				}
+				if flag&UNIK_INCLUDETAXID > 0 {
+					taxid, err := r.ReadTaxid()
+					if err != nil {
+						t.Fatal(err)
+					}
+					if taxid != uint32(i+1) {
+						t.Errorf("flag %d, n %d: unexpected taxid of record %d: %d", flag, n, i, taxid)
+					}
+				}
+				i++
+			}
+			if i != n {
+				t.Errorf("flag %d: number mismatch: %d != %d", flag, i, n)
+			}
+		}
+	}
+
+	// counts are 1 for files without UNIK_INCLUDECOUNT
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, 21, UNIK_SORTED)
+	for i := 0; i < 3; i++ {
+		w.WriteCodeWithCount(uint64(i), 5)
+	}
+	w.Flush()
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		_, count, err := r.ReadCodeWithCount()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("count 1 expected for file without UNIK_INCLUDECOUNT, got: %d", count)
+		}
+	}
+}
+
+func TestStrictOpen(t *testing.T) {
+	StrictOpen = true
+	defer func() { StrictOpen = false }()
+
+	k := 21
+	write := func(flag uint32, codes []uint64) *bytes.Buffer {
+		if flag&UNIK_SORTED > 0 {
+			sort.Sort(CodeSlice(codes))
+		}
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, k, flag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, code := range codes {
+			if err = w.WriteCodeWithTaxid(code, uint32(i+1)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		return &buf
+	}
+
+	// canonical and non-canonical codes
+	var canonicals, mixed []uint64
+	var code uint64
+	mask := uint64(1)<<uint(k*2) - 1
+	for i := uint64(1); len(canonicals) < 100; i++ {
+		code = (i * 0x9E3779B97F4A7C15) & mask
+		if Canonical(code, k) == code {
+			canonicals = append(canonicals, code)
+		}
+		mixed = append(mixed, code)
+	}
+
+	for _, flag := range []uint32{0, UNIK_COMPACT, UNIK_SORTED, UNIK_INCLUDETAXID, UNIK_SORTED | UNIK_INCLUDETAXID} {
+		// consistent files, all records should still be read after checking
+		for _, c := range []struct {
+			flag  uint32
+			codes []uint64
+		}{
+			{flag | UNIK_CANONICAL, canonicals},
+			{flag, mixed},
+			{flag, canonicals[:strictOpenSamples-1]}, // too few to judge
+		} {
+			r, err := NewReader(write(c.flag, c.codes))
+			if err != nil {
+				t.Fatalf("flag %d: %s", c.flag, err)
+			}
+			var i int
+			for {
+				code, taxid, err := r.ReadCodeWithTaxid()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					t.Fatalf("flag %d: %s", c.flag, err)
+				}
+				if code != c.codes[i] {
+					t.Errorf("flag %d: unexpected code of record %d: %d != %d", c.flag, i, code, c.codes[i])
+				}
+				if flag&UNIK_INCLUDETAXID > 0 && taxid != uint32(i+1) {
+					t.Errorf("flag %d: unexpected taxid of record %d: %d", c.flag, i, taxid)
+				}
+				i++
+			}
+			if i != len(c.codes) {
+				t.Errorf("flag %d: number mismatch: %d != %d", c.flag, i, len(c.codes))
+			}
+		}
+
+		// mislabeled files
+		if _, err := NewReader(write(flag|UNIK_CANONICAL, mixed)); err != ErrCanonicalMismatch {
+			t.Errorf("flag %d: ErrCanonicalMismatch expected for non-canonical k-mers, got: %v", flag, err)
+		}
+		if flag&UNIK_SORTED > 0 { // not checked for sorted files
+			continue
+		}
+		if _, err := NewReader(write(flag, canonicals)); err != ErrCanonicalMismatch {
+			t.Errorf("flag %d: ErrCanonicalMismatch expected for canonical k-mers, got: %v", flag, err)
+		}
+	}
+
+	// mismatches could be only warned by a handler
+	var nMismatches int
+	CanonicalMismatchHandler = func(reader *Reader) error {
+		nMismatches++
+		return nil
+	}
+	defer func() { CanonicalMismatchHandler = nil }()
+	r, err := NewReader(write(UNIK_CANONICAL, mixed))
+	if err != nil {
+		t.Fatalf("no error expected with CanonicalMismatchHandler, got: %v", err)
+	}
+	if nMismatches != 1 {
+		t.Errorf("CanonicalMismatchHandler should be called once, %d times called", nMismatches)
+	}
+	var n int
+	for {
+		if _, err = r.ReadCode(); err != nil {
+			if err != io.EOF {
+				t.Fatal(err)
+			}
+			break
+		}
+		n++
+	}
+	if n != len(mixed) {
+		t.Errorf("number mismatch: %d != %d", n, len(mixed))
+	}
+}
+
+// TestNextSegment tests reading concatenated data written with UNIK_SENTINEL
+func TestNextSegment(t *testing.T) {
+	flags := []uint32{0, UNIK_COMPACT, UNIK_SORTED, UNIK_INCLUDETAXID}
+	ns := []int{0, 1, 20000, 3}
+	ks := []int{21, 5, 31, 11}
+
+	var buf bytes.Buffer
+	for i, flag := range flags {
+		w, err := NewWriter(&buf, ks[i], flag|UNIK_SENTINEL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < ns[i]; j++ {
+			code := uint64(j)
+			if flag&UNIK_INCLUDETAXID > 0 {
+				err = w.WriteCodeWithTaxid(code, uint32(j+1))
+			} else {
+				err = w.WriteCode(code)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range flags {
+		if i > 0 {
+			if err = r.NextSegment(); err != nil {
+				t.Fatalf("segment %d: %s", i, err)
+			}
+		}
+		if r.K != ks[i] {
+			t.Errorf("segment %d: K mismatch: %d != %d", i, r.K, ks[i])
+		}
+		if i == 1 { // skip reading, NextSegment should discard the data
+			continue
+		}
+		var n int
+		for {
+			var code uint64
+			var taxid uint32
+			if r.IsIncludeTaxid() {
+				code, taxid, err = r.ReadCodeWithTaxid()
+			} else {
+				code, err = r.ReadCode()
+			}
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatalf("segment %d: %s", i, err)
+			}
+			if code != uint64(n) || (r.IsIncludeTaxid() && taxid != uint32(n+1)) {
+				t.Errorf("segment %d: unexpected record %d: %d %d", i, n, code, taxid)
+			}
+			n++
+		}
+		if n != ns[i] {
+			t.Errorf("segment %d: number mismatch: %d != %d", i, n, ns[i])
+		}
+	}
+	if err = r.NextSegment(); err != io.EOF {
+		t.Errorf("io.EOF expected after the last segment, got: %v", err)
+	}
+}
+
+func TestRecordSize(t *testing.T) {
+	k := 21
+	n := 100
+	for _, flag := range []uint32{
+		0,
+		UNIK_COMPACT,
+		UNIK_INCLUDETAXID,
+		UNIK_COMPACT | UNIK_INCLUDETAXID,
+		UNIK_INCLUDESTRAND,
+		UNIK_COMPACT | UNIK_INCLUDETAXID | UNIK_INCLUDESTRAND,
+		UNIK_INCLUDECOUNT,
+		UNIK_COMPACT | UNIK_INCLUDETAXID | UNIK_INCLUDESTRAND | UNIK_INCLUDECOUNT,
+	} {
+		for _, maxTaxid := range []uint32{0, 1000} {
+			var buf bytes.Buffer
+			writer, err := NewWriter(&buf, k, flag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if maxTaxid > 0 {
+				writer.SetMaxTaxid(maxTaxid)
+			}
+			for i := 0; i < n; i++ {
+				writer.WriteCodeWithTaxid(uint64(i), 9)
+			}
+			if err = writer.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			size := buf.Len()
+
+			reader, err := NewReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if HeaderSize+n*reader.RecordSize() != size {
+				t.Errorf("flag %d, max taxid %d: unexpected record size: %d, file size: %d",
+					flag, maxTaxid, reader.RecordSize(), size)
+			}
+			if maxTaxid == 0 && RecordSize(flag, k) != reader.RecordSize() {
+				t.Errorf("flag %d: record size %d != %d", flag, RecordSize(flag, k), reader.RecordSize())
+			}
+		}
+	}
+
+	if RecordSize(UNIK_SORTED, k) != -1 || RecordSize(UNIK_SENTINEL, k) != -1 {
+		t.Errorf("-1 expected for records of variable width")
+	}
+}
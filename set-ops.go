@@ -0,0 +1,510 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package unikmer
+
+import (
+	"container/heap"
+	"errors"
+	"io"
+	"math"
+)
+
+// ErrNotSorted means the binary file is not sorted.
+var ErrNotSorted = errors.New("unikmer: sorted binary file needed")
+
+// ErrCanonicalInconsistent means 'canonical' flags of binary files are not consistent.
+var ErrCanonicalInconsistent = errors.New("unikmer: 'canonical' flags not consistent")
+
+// ErrNoCount means flag UNIK_INCLUDECOUNT is off.
+var ErrNoCount = errors.New("unikmer: counts not found, flag UNIK_INCLUDECOUNT needed")
+
+// checkSortedReaders checks if all readers are sorted and compatible.
+func checkSortedReaders(readers []*Reader) error {
+	for _, reader := range readers {
+		if !reader.IsSorted() {
+			return ErrNotSorted
+		}
+		if reader.K != readers[0].K {
+			return ErrKMismatch
+		}
+		if reader.IsCanonical() != readers[0].IsCanonical() {
+			return ErrCanonicalInconsistent
+		}
+	}
+	return nil
+}
+
+// IntersectFunc scans sorted readers simultaneously, and calls fn for every
+// code shared by all readers, which only needs O(N) memory for N readers.
+// Duplicated codes are reported once.
+// If taxondb is not nil, taxids of a shared code are merged with LCA,
+// otherwise the taxid in the first reader is used.
+// Readers can wrap any io.Reader, including an io.Pipe,
+// so intermediate results are not necessarily saved into files.
+func IntersectFunc(readers []*Reader, taxondb *Taxonomy, fn func(code uint64, taxid uint32) error) error {
+	if len(readers) == 0 {
+		return nil
+	}
+	if err := checkSortedReaders(readers); err != nil {
+		return err
+	}
+
+	codes := make([]uint64, len(readers))
+	taxids := make([]uint32, len(readers))
+	var err error
+	for i, reader := range readers {
+		codes[i], taxids[i], err = reader.ReadCodeWithTaxid()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+
+	var i int
+	var target uint64
+	var taxid uint32
+	var matched, eof bool
+	for {
+		// no codes smaller than the largest current one could be shared
+		target = codes[0]
+		for _, code := range codes[1:] {
+			if code > target {
+				target = code
+			}
+		}
+
+		matched = true
+		for i = range readers {
+			for codes[i] < target {
+				codes[i], taxids[i], err = readers[i].ReadCodeWithTaxid()
+				if err != nil {
+					if err == io.EOF {
+						return nil
+					}
+					return err
+				}
+			}
+			if codes[i] != target {
+				matched = false
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		// skip duplicates of the shared code in all readers
+		taxid = taxids[0]
+		for i = range readers {
+			for codes[i] == target {
+				if taxondb != nil {
+					taxid = taxondb.LCA(taxid, taxids[i])
+				}
+				codes[i], taxids[i], err = readers[i].ReadCodeWithTaxid()
+				if err != nil {
+					if err == io.EOF {
+						eof = true
+						break
+					}
+					return err
+				}
+			}
+		}
+
+		if err = fn(target, taxid); err != nil {
+			return err
+		}
+
+		if eof {
+			return nil
+		}
+	}
+}
+
+// Intersect writes codes shared by all sorted readers to the writer,
+// and returns the number of written codes. See IntersectFunc for details.
+// The writer is not flushed.
+func Intersect(readers []*Reader, writer *Writer, taxondb *Taxonomy) (int64, error) {
+	var n int64
+	err := IntersectFunc(readers, taxondb, func(code uint64, taxid uint32) error {
+		n++
+		return writer.WriteCodeWithTaxid(code, taxid)
+	})
+	return n, err
+}
+
+// UnionFunc k-way merges sorted readers, and calls fn for every distinct code
+// in ascending order, which only needs O(N) memory for N readers.
+// If taxondb is not nil, taxids of a code are merged with LCA,
+// otherwise the taxid of the first occurrence is used.
+func UnionFunc(readers []*Reader, taxondb *Taxonomy, fn func(code uint64, taxid uint32) error) error {
+	return mergeSorted(readers, taxondb, func(code uint64, taxid uint32, n int) error {
+		return fn(code, taxid)
+	})
+}
+
+// CodeMerger k-way merges sorted readers with a min-heap, and yields
+// distinct codes in ascending order, along with indexes of readers
+// containing them. It's the streaming core of union and difference
+// operations, which only needs O(N) memory for N readers.
+type CodeMerger struct {
+	readers []*Reader
+	taxondb *Taxonomy
+
+	h runHeap
+
+	// seen[i] == round means reader i contains the current code,
+	// so duplicates in a reader are reported once.
+	seen      []int
+	round     int
+	presentIn []int
+
+	err error
+}
+
+// NewCodeMerger creates a CodeMerger from sorted readers.
+// If taxondb is not nil, taxids of a code are merged with LCA,
+// otherwise the taxid in the first reader containing the code is used.
+func NewCodeMerger(readers []*Reader, taxondb *Taxonomy) (*CodeMerger, error) {
+	if err := checkSortedReaders(readers); err != nil {
+		return nil, err
+	}
+
+	m := &CodeMerger{
+		readers:   readers,
+		taxondb:   taxondb,
+		h:         make(runHeap, 0, len(readers)),
+		seen:      make([]int, len(readers)),
+		presentIn: make([]int, 0, len(readers)),
+	}
+
+	var code uint64
+	var taxid uint32
+	var err error
+	for i, reader := range readers {
+		code, taxid, err = reader.ReadCodeWithTaxid()
+		if err != nil {
+			if err == io.EOF {
+				continue
+			}
+			return nil, err
+		}
+		m.h = append(m.h, runEntry{idx: i, code: code, taxid: taxid})
+	}
+	heap.Init(&m.h)
+	return m, nil
+}
+
+// Next returns the next distinct code, its taxid, and ascending indexes of
+// readers containing it. The presentIn slice is reused by the next call.
+// io.EOF is returned after all readers are consumed.
+func (m *CodeMerger) Next() (code uint64, taxid uint32, presentIn []int, err error) {
+	if m.err != nil {
+		return 0, 0, nil, m.err
+	}
+	if len(m.h) == 0 {
+		return 0, 0, nil, io.EOF
+	}
+
+	m.round++
+	m.presentIn = m.presentIn[:0]
+	code, taxid = m.h[0].code, m.h[0].taxid
+
+	var e runEntry
+	var _code uint64
+	var _taxid uint32
+	for len(m.h) > 0 && m.h[0].code == code {
+		e = m.h[0]
+		if m.taxondb != nil {
+			taxid = m.taxondb.LCA(taxid, e.taxid)
+		}
+		if m.seen[e.idx] != m.round {
+			m.seen[e.idx] = m.round
+			m.presentIn = append(m.presentIn, e.idx)
+		}
+
+		_code, _taxid, err = m.readers[e.idx].ReadCodeWithTaxid()
+		if err != nil {
+			if err == io.EOF {
+				heap.Pop(&m.h)
+				continue
+			}
+			m.err = err
+			return 0, 0, nil, err
+		}
+		m.h[0].code, m.h[0].taxid = _code, _taxid
+		heap.Fix(&m.h, 0)
+	}
+	// entries of the same code are popped in order of reader indexes
+	return code, taxid, m.presentIn, nil
+}
+
+// mergeSorted k-way merges sorted readers, and calls fn for every distinct
+// code in ascending order, with the merged taxid and the number of readers
+// containing the code.
+func mergeSorted(readers []*Reader, taxondb *Taxonomy, fn func(code uint64, taxid uint32, n int) error) error {
+	if len(readers) == 0 {
+		return nil
+	}
+	m, err := NewCodeMerger(readers, taxondb)
+	if err != nil {
+		return err
+	}
+
+	var code uint64
+	var taxid uint32
+	var presentIn []int
+	for {
+		code, taxid, presentIn, err = m.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err = fn(code, taxid, len(presentIn)); err != nil {
+			return err
+		}
+	}
+}
+
+// Union writes distinct codes of all sorted readers to the writer,
+// and returns the number of written codes. See UnionFunc for details.
+// The writer is not flushed.
+func Union(readers []*Reader, writer *Writer, taxondb *Taxonomy) (int64, error) {
+	var n int64
+	err := UnionFunc(readers, taxondb, func(code uint64, taxid uint32) error {
+		n++
+		return writer.WriteCodeWithTaxid(code, taxid)
+	})
+	return n, err
+}
+
+// SymmetricDifferenceFunc k-way merges sorted readers, and calls fn for every
+// code present in an odd number of readers, or in exactly one reader
+// if exactlyOne is true. Codes are reported in ascending order.
+// Taxids are merged as UnionFunc does.
+func SymmetricDifferenceFunc(readers []*Reader, taxondb *Taxonomy, exactlyOne bool,
+	fn func(code uint64, taxid uint32) error) error {
+	return mergeSorted(readers, taxondb, func(code uint64, taxid uint32, n int) error {
+		if exactlyOne {
+			if n != 1 {
+				return nil
+			}
+		} else if n&1 == 0 {
+			return nil
+		}
+		return fn(code, taxid)
+	})
+}
+
+// SymmetricDifference writes the symmetric difference of all sorted readers
+// to the writer, and returns the number of written codes.
+// See SymmetricDifferenceFunc for details. The writer is not flushed.
+func SymmetricDifference(readers []*Reader, writer *Writer, taxondb *Taxonomy, exactlyOne bool) (int64, error) {
+	var n int64
+	err := SymmetricDifferenceFunc(readers, taxondb, exactlyOne, func(code uint64, taxid uint32) error {
+		n++
+		return writer.WriteCodeWithTaxid(code, taxid)
+	})
+	return n, err
+}
+
+// IntersectionUnionSize returns numbers of distinct codes shared by all
+// sorted readers and present in any of them, in a single k-way merge pass.
+// It's useful for computing similarity indexes like Jaccard index.
+func IntersectionUnionSize(readers []*Reader) (inter int64, union int64, err error) {
+	err = mergeSorted(readers, nil, func(code uint64, taxid uint32, n int) error {
+		union++
+		if n == len(readers) {
+			inter++
+		}
+		return nil
+	})
+	return inter, union, err
+}
+
+// OverlapSize compares two sorted readers in a single merge pass, and returns
+// numbers of distinct codes only in a, in both, and only in b.
+func OverlapSize(a, b *Reader) (onlyA int64, shared int64, onlyB int64, err error) {
+	if err = checkSortedReaders([]*Reader{a, b}); err != nil {
+		return
+	}
+
+	// read returns the next code different from prev, duplicates are skipped
+	read := func(r *Reader, prev uint64, first bool) (uint64, bool, error) {
+		for {
+			code, _, err := r.ReadCodeWithTaxid()
+			if err != nil {
+				if err == io.EOF {
+					return 0, true, nil
+				}
+				return 0, false, err
+			}
+			if first || code != prev {
+				return code, false, nil
+			}
+		}
+	}
+
+	var codeA, codeB uint64
+	var eofA, eofB bool
+	if codeA, eofA, err = read(a, 0, true); err != nil {
+		return
+	}
+	if codeB, eofB, err = read(b, 0, true); err != nil {
+		return
+	}
+	for !eofA && !eofB {
+		switch {
+		case codeA < codeB:
+			onlyA++
+			codeA, eofA, err = read(a, codeA, false)
+		case codeA > codeB:
+			onlyB++
+			codeB, eofB, err = read(b, codeB, false)
+		default:
+			shared++
+			if codeA, eofA, err = read(a, codeA, false); err != nil {
+				return
+			}
+			codeB, eofB, err = read(b, codeB, false)
+		}
+		if err != nil {
+			return
+		}
+	}
+	for !eofA {
+		onlyA++
+		if codeA, eofA, err = read(a, codeA, false); err != nil {
+			return
+		}
+	}
+	for !eofB {
+		onlyB++
+		if codeB, eofB, err = read(b, codeB, false); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// SumFunc k-way merges sorted readers with counts, and calls fn for every
+// distinct code in ascending order with the sum of its counts in all readers,
+// including duplicates in a reader. Sums exceeding the maximum of uint32
+// are saturated. Taxids are ignored.
+func SumFunc(readers []*Reader, fn func(code uint64, count uint32) error) error {
+	if len(readers) == 0 {
+		return nil
+	}
+	if err := checkSortedReaders(readers); err != nil {
+		return err
+	}
+	for _, reader := range readers {
+		if !reader.IsIncludeCount() {
+			return ErrNoCount
+		}
+	}
+
+	read := func(r *Reader) (code uint64, count uint32, err error) {
+		code, count, err = r.ReadCodeWithCount()
+		if err != nil {
+			return 0, 0, err
+		}
+		if r.IsIncludeTaxid() {
+			_, err = r.ReadTaxid()
+		}
+		return code, count, err
+	}
+
+	h := make(runHeap, 0, len(readers))
+	var code uint64
+	var count uint32
+	var err error
+	for i, reader := range readers {
+		code, count, err = read(reader)
+		if err != nil {
+			if err == io.EOF {
+				continue
+			}
+			return err
+		}
+		h = append(h, runEntry{idx: i, code: code, count: count})
+	}
+	heap.Init(&h)
+
+	var e runEntry
+	var first = true
+	var prev uint64
+	var sum uint64
+	for len(h) > 0 {
+		e = h[0]
+		if first {
+			prev, sum = e.code, uint64(e.count)
+			first = false
+		} else if e.code == prev {
+			sum += uint64(e.count)
+		} else {
+			if err = fn(prev, saturateCount(sum)); err != nil {
+				return err
+			}
+			prev, sum = e.code, uint64(e.count)
+		}
+
+		code, count, err = read(readers[e.idx])
+		if err != nil {
+			if err == io.EOF {
+				heap.Pop(&h)
+				continue
+			}
+			return err
+		}
+		h[0].code, h[0].count = code, count
+		heap.Fix(&h, 0)
+	}
+	if !first {
+		return fn(prev, saturateCount(sum))
+	}
+	return nil
+}
+
+func saturateCount(sum uint64) uint32 {
+	if sum > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(sum)
+}
+
+// Sum writes distinct codes of all sorted readers and the sums of their
+// counts to the writer, skipping codes with sums less than minCount,
+// and returns the number of written codes. See SumFunc for details.
+// The writer is not flushed.
+func Sum(readers []*Reader, writer *Writer, minCount uint32) (int64, error) {
+	var n int64
+	err := SumFunc(readers, func(code uint64, count uint32) error {
+		if count < minCount {
+			return nil
+		}
+		n++
+		return writer.WriteCodeWithCount(code, count)
+	})
+	return n, err
+}
@@ -0,0 +1,227 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package unikmer
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func sortedReaders(t *testing.T, files [][]uint64) []*Reader {
+	readers := make([]*Reader, len(files))
+	for i, codes := range files {
+		var buf bytes.Buffer
+		writer, err := NewWriter(&buf, 21, UNIK_SORTED)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, code := range codes {
+			writer.WriteCode(code)
+		}
+		if err = writer.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if readers[i], err = NewReader(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return readers
+}
+
+func TestSetOperations(t *testing.T) {
+	for _, c := range []struct {
+		files   [][]uint64
+		inter   []uint64
+		union   []uint64
+		symdiff []uint64 // odd number
+		one     []uint64 // exactly one
+	}{
+		{[][]uint64{{1, 2, 3}, {}}, []uint64{}, []uint64{1, 2, 3}, []uint64{1, 2, 3}, []uint64{1, 2, 3}},
+		{[][]uint64{{1, 3, 5, 7}, {2, 3, 4, 7}}, []uint64{3, 7}, []uint64{1, 2, 3, 4, 5, 7}, []uint64{1, 2, 4, 5}, []uint64{1, 2, 4, 5}},
+		{[][]uint64{{1, 3, 3, 5, 7}, {3, 5, 5}, {0, 3, 5, 9}}, []uint64{3, 5}, []uint64{0, 1, 3, 5, 7, 9}, []uint64{0, 1, 3, 5, 7, 9}, []uint64{0, 1, 7, 9}},
+		{[][]uint64{{1, 2}, {3, 4}}, []uint64{}, []uint64{1, 2, 3, 4}, []uint64{1, 2, 3, 4}, []uint64{1, 2, 3, 4}},
+		{[][]uint64{{9}, {9}, {9}}, []uint64{9}, []uint64{9}, []uint64{9}, []uint64{}},
+		{[][]uint64{{1, 9}, {9}, {2, 9}, {9}}, []uint64{9}, []uint64{1, 2, 9}, []uint64{1, 2}, []uint64{1, 2}},
+	} {
+		codes := make([]uint64, 0, 8)
+		collect := func(code uint64, taxid uint32) error {
+			codes = append(codes, code)
+			return nil
+		}
+
+		if err := IntersectFunc(sortedReaders(t, c.files), nil, collect); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(codes, c.inter) {
+			t.Errorf("files %v: intersection expected %v, got %v", c.files, c.inter, codes)
+		}
+
+		codes = codes[:0]
+		if err := UnionFunc(sortedReaders(t, c.files), nil, collect); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(codes, c.union) {
+			t.Errorf("files %v: union expected %v, got %v", c.files, c.union, codes)
+		}
+
+		codes = codes[:0]
+		if err := SymmetricDifferenceFunc(sortedReaders(t, c.files), nil, false, collect); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(codes, c.symdiff) {
+			t.Errorf("files %v: symmetric difference expected %v, got %v", c.files, c.symdiff, codes)
+		}
+
+		codes = codes[:0]
+		if err := SymmetricDifferenceFunc(sortedReaders(t, c.files), nil, true, collect); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(codes, c.one) {
+			t.Errorf("files %v: codes in exactly one file expected %v, got %v", c.files, c.one, codes)
+		}
+
+		inter, union, err := IntersectionUnionSize(sortedReaders(t, c.files))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inter != int64(len(c.inter)) || union != int64(len(c.union)) {
+			t.Errorf("files %v: sizes of intersection and union expected %d and %d, got %d and %d",
+				c.files, len(c.inter), len(c.union), inter, union)
+		}
+	}
+}
+
+func TestOverlapSize(t *testing.T) {
+	for _, c := range []struct {
+		a, b                 []uint64
+		onlyA, shared, onlyB int64
+	}{
+		{[]uint64{}, []uint64{}, 0, 0, 0},
+		{[]uint64{1, 2, 3}, []uint64{}, 3, 0, 0},
+		{[]uint64{}, []uint64{1, 1, 2}, 0, 0, 2},
+		{[]uint64{1, 3, 5, 7}, []uint64{2, 3, 4, 7}, 2, 2, 2},
+		{[]uint64{1, 3, 3, 5, 5, 9}, []uint64{3, 5, 5, 5}, 2, 2, 0},
+		{[]uint64{0, 9}, []uint64{0, 0, 8, 9, 10, 10, 11}, 0, 2, 3},
+	} {
+		readers := sortedReaders(t, [][]uint64{c.a, c.b})
+		onlyA, shared, onlyB, err := OverlapSize(readers[0], readers[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if onlyA != c.onlyA || shared != c.shared || onlyB != c.onlyB {
+			t.Errorf("%v and %v: expected %d, %d, %d, got %d, %d, %d",
+				c.a, c.b, c.onlyA, c.shared, c.onlyB, onlyA, shared, onlyB)
+		}
+	}
+}
+
+func TestSum(t *testing.T) {
+	newReader := func(codes []uint64, counts []uint32, flag uint32) *Reader {
+		var buf bytes.Buffer
+		writer, err := NewWriter(&buf, 21, UNIK_SORTED|flag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, code := range codes {
+			writer.WriteCodeWithCount(code, counts[i])
+			if flag&UNIK_INCLUDETAXID > 0 {
+				writer.WriteTaxid(9606)
+			}
+		}
+		if err = writer.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		reader, err := NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reader
+	}
+
+	readers := []*Reader{
+		newReader([]uint64{1, 3, 3, 5}, []uint32{2, 1, 1, 4}, UNIK_INCLUDECOUNT),
+		newReader([]uint64{}, []uint32{}, UNIK_INCLUDECOUNT),
+		newReader([]uint64{0, 3, 5, 9}, []uint32{1, 3, 1<<32 - 1, 7}, UNIK_INCLUDECOUNT|UNIK_INCLUDETAXID),
+	}
+	var codes []uint64
+	var counts []uint32
+	err := SumFunc(readers, func(code uint64, count uint32) error {
+		codes = append(codes, code)
+		counts = append(counts, count)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(codes, []uint64{0, 1, 3, 5, 9}) ||
+		!reflect.DeepEqual(counts, []uint32{1, 2, 5, 1<<32 - 1, 7}) {
+		t.Errorf("unexpected result: %v, %v", codes, counts)
+	}
+
+	if err = SumFunc(sortedReaders(t, [][]uint64{{1}}), nil); err != ErrNoCount {
+		t.Errorf("ErrNoCount expected, got: %v", err)
+	}
+}
+
+func TestCodeMerger(t *testing.T) {
+	files := [][]uint64{{1, 3, 3, 5, 7}, {}, {3, 5, 5}, {0, 3, 5, 9, 9}}
+	m, err := NewCodeMerger(sortedReaders(t, files), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var codes []uint64
+	var presents [][]int
+	for {
+		code, _, presentIn, err := m.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatal(err)
+		}
+		codes = append(codes, code)
+		presents = append(presents, append([]int(nil), presentIn...))
+	}
+	if !reflect.DeepEqual(codes, []uint64{0, 1, 3, 5, 7, 9}) {
+		t.Errorf("unexpected codes: %v", codes)
+	}
+	if !reflect.DeepEqual(presents, [][]int{{3}, {0}, {0, 2, 3}, {0, 2, 3}, {0}, {3}}) {
+		t.Errorf("unexpected presentIn: %v", presents)
+	}
+
+	// EOF is sticky
+	if _, _, _, err = m.Next(); err != io.EOF {
+		t.Errorf("io.EOF expected, got: %v", err)
+	}
+
+	// no readers or empty readers
+	for _, files := range [][][]uint64{{}, {{}, {}}} {
+		m, err = NewCodeMerger(sortedReaders(t, files), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, err = m.Next(); err != io.EOF {
+			t.Errorf("io.EOF expected for %v, got: %v", files, err)
+		}
+	}
+}
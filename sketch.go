@@ -0,0 +1,117 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// DefaultSketchPrecision is the default precision of Sketch,
+// using 2^14 registers (16 KB) with a standard error of about 0.8%.
+const DefaultSketchPrecision = 14
+
+// ErrInvalidPrecision means the precision of Sketch is out of range.
+var ErrInvalidPrecision = errors.New("unikmer: precision of sketch should be in range of [4, 18]")
+
+// ErrPrecisionMismatch means sketches of different precisions are merged.
+var ErrPrecisionMismatch = errors.New("unikmer: sketches of different precisions can not be merged")
+
+// Sketch is a HyperLogLog sketch for estimating the number of distinct
+// codes with fixed memory (2^precision bytes), no matter how many codes
+// are added. The standard error is about 1.04/sqrt(2^precision).
+type Sketch struct {
+	p         uint8
+	registers []uint8
+}
+
+// NewSketch creates a Sketch with the given precision in range of [4, 18].
+func NewSketch(precision int) (*Sketch, error) {
+	if precision < 4 || precision > 18 {
+		return nil, ErrInvalidPrecision
+	}
+	return &Sketch{p: uint8(precision), registers: make([]uint8, 1<<uint(precision))}, nil
+}
+
+// Precision returns the precision of the sketch.
+func (s *Sketch) Precision() int {
+	return int(s.p)
+}
+
+// Add adds a code to the sketch. Codes are hashed with Hash64.
+func (s *Sketch) Add(code uint64) {
+	h := Hash64(code)
+	idx := h >> (64 - s.p)
+	// number of leading zeros of the remaining bits plus one,
+	// the sentinel bit limits it to 64-p+1.
+	rho := uint8(bits.LeadingZeros64(h<<s.p|1<<(s.p-1))) + 1
+	if rho > s.registers[idx] {
+		s.registers[idx] = rho
+	}
+}
+
+// Merge merges another sketch into this one, after which the sketch
+// estimates the number of distinct codes in the union of the two.
+func (s *Sketch) Merge(other *Sketch) error {
+	if s.p != other.p {
+		return ErrPrecisionMismatch
+	}
+	for i, r := range other.registers {
+		if r > s.registers[i] {
+			s.registers[i] = r
+		}
+	}
+	return nil
+}
+
+// Count returns the estimated number of distinct codes.
+func (s *Sketch) Count() uint64 {
+	m := float64(len(s.registers))
+	var sum float64
+	var zeros int
+	for _, r := range s.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	var alpha float64
+	switch len(s.registers) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+	e := alpha * m * m / sum
+
+	// linear counting for small cardinalities.
+	// No correction is needed for large ones with 64-bit hash values.
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+	return uint64(e + 0.5)
+}
@@ -0,0 +1,68 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package unikmer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSketch(t *testing.T) {
+	for _, n := range []int{0, 1, 100, 10000, 1000000} {
+		s, err := NewSketch(DefaultSketchPrecision)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, _ := NewSketch(DefaultSketchPrecision)
+		b, _ := NewSketch(DefaultSketchPrecision)
+		for i := 0; i < n; i++ {
+			code := uint64(i) * 7
+			s.Add(code)
+			s.Add(code) // duplicates
+			if i&1 == 0 {
+				a.Add(code)
+			} else {
+				b.Add(code)
+			}
+		}
+
+		c := s.Count()
+		if math.Abs(float64(c)-float64(n)) > 0.03*float64(n)+1 {
+			t.Errorf("n %d: bad estimate: %d", n, c)
+		}
+
+		if err = a.Merge(b); err != nil {
+			t.Fatal(err)
+		}
+		if a.Count() != c {
+			t.Errorf("n %d: estimate of merged sketch mismatch: %d != %d", n, a.Count(), c)
+		}
+	}
+
+	if _, err := NewSketch(3); err != ErrInvalidPrecision {
+		t.Errorf("ErrInvalidPrecision expected, got: %v", err)
+	}
+	a, _ := NewSketch(10)
+	b, _ := NewSketch(12)
+	if err := a.Merge(b); err != ErrPrecisionMismatch {
+		t.Errorf("ErrPrecisionMismatch expected, got: %v", err)
+	}
+}
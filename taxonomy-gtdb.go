@@ -0,0 +1,171 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package unikmer
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/shenwei356/breader"
+)
+
+// GTDBRanks maps prefixes of names in GTDB lineages to ranks.
+// "superkingdom" is used for domains to be compatible with NCBI Taxonomy.
+var GTDBRanks = map[string]string{
+	"d": "superkingdom",
+	"p": "phylum",
+	"c": "class",
+	"o": "order",
+	"f": "family",
+	"g": "genus",
+	"s": "species",
+}
+
+// NewTaxonomyFromGTDB parses Taxonomy from a GTDB taxonomy file,
+// e.g., bac120_taxonomy.tsv, which contains two tab-delimited columns:
+// genome accession and lineage like "d__Bacteria;p__Proteobacteria;...".
+//
+// Taxids are synthesized from hash values of names with rank prefixes,
+// e.g., "s__Escherichia coli", so they are stable across GTDB releases
+// unless hash collisions happen, which are resolved by linear probing
+// in alphabetical order of names. The root is 1.
+// Names of taxids are saved in Taxonomy.Names.
+func NewTaxonomyFromGTDB(file string) (*Taxonomy, error) {
+	parseFunc := func(line string) (interface{}, bool, error) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return nil, false, nil
+		}
+		items := strings.Split(line, "\t")
+		if len(items) < 2 {
+			return nil, false, nil
+		}
+		return strings.Split(items[1], ";"), true, nil
+	}
+
+	reader, err := breader.NewBufferedReader(file, 8, 100, parseFunc)
+	if err != nil {
+		return nil, fmt.Errorf("unikmer: %s", err)
+	}
+
+	parents := make(map[string]string, 1024) // name -> parent name, "" for the root
+	var lineage []string
+	var name, parent, p string
+	var ok bool
+	var data interface{}
+	for chunk := range reader.Ch {
+		if chunk.Err != nil {
+			return nil, fmt.Errorf("unikmer: %s", chunk.Err)
+		}
+		for _, data = range chunk.Data {
+			lineage = data.([]string)
+			parent = ""
+			for _, name = range lineage {
+				name = strings.TrimSpace(name)
+				if len(name) < 3 || name[1:3] != "__" {
+					return nil, fmt.Errorf("unikmer: invalid name in GTDB lineage: %s", name)
+				}
+				if _, ok = GTDBRanks[name[:1]]; !ok {
+					return nil, fmt.Errorf("unikmer: unknown rank prefix in GTDB lineage: %s", name)
+				}
+				if len(name) == 3 { // empty name, e.g., "s__"
+					break
+				}
+				if p, ok = parents[name]; ok && p != parent {
+					return nil, fmt.Errorf("unikmer: inconsistent GTDB lineages of %s: %s and %s", name, p, parent)
+				}
+				parents[name] = parent
+				parent = name
+			}
+		}
+	}
+
+	// assign taxids
+	names := make([]string, 0, len(parents))
+	for name = range parents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var root uint32 = 1
+	name2taxid := make(map[string]uint32, len(names))
+	taxid2name := make(map[uint32]string, len(names)+1)
+	taxid2name[root] = "root"
+	var taxid, maxTaxid uint32
+	maxTaxid = root
+	for _, name = range names {
+		taxid = gtdbTaxid(name)
+		for {
+			if taxid <= root { // 0 is invalid
+				taxid = root + 1
+			}
+			if _, ok = taxid2name[taxid]; !ok {
+				break
+			}
+			taxid++
+		}
+		name2taxid[name] = taxid
+		taxid2name[taxid] = name
+		if taxid > maxTaxid {
+			maxTaxid = taxid
+		}
+	}
+
+	nodes := make(map[uint32]uint32, len(names)+1)
+	nodes[root] = root
+	taxid2rankid := make(map[uint32]uint8, len(names)+1)
+	ranks := []string{"no rank"}
+	rank2rankid := map[string]int{"no rank": 0}
+	ranksMap := map[string]interface{}{"no rank": struct{}{}}
+	taxid2rankid[root] = 0
+
+	var rank string
+	var rankid int
+	for name, parent = range parents {
+		taxid = name2taxid[name]
+		if parent == "" {
+			nodes[taxid] = root
+		} else {
+			nodes[taxid] = name2taxid[parent]
+		}
+
+		rank = GTDBRanks[name[:1]]
+		if rankid, ok = rank2rankid[rank]; !ok {
+			ranks = append(ranks, rank)
+			rankid = len(ranks) - 1
+			rank2rankid[rank] = rankid
+			ranksMap[rank] = struct{}{}
+		}
+		taxid2rankid[taxid] = uint8(rankid)
+	}
+
+	return &Taxonomy{file: file, Nodes: nodes, rootNode: root, maxTaxid: maxTaxid,
+		taxid2rankid: taxid2rankid, ranks: ranks, hasRanks: true, Ranks: ranksMap,
+		Names: taxid2name}, nil
+}
+
+// gtdbTaxid returns the FNV-1a hash value of a name.
+func gtdbTaxid(name string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return h.Sum32()
+}
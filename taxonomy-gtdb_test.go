@@ -0,0 +1,81 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package unikmer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewTaxonomyFromGTDB(t *testing.T) {
+	data := `GB_GCA_000000001.1	d__Bacteria;p__Proteobacteria;c__Gammaproteobacteria;o__Enterobacterales;f__Enterobacteriaceae;g__Escherichia;s__Escherichia coli
+GB_GCA_000000002.1	d__Bacteria;p__Proteobacteria;c__Gammaproteobacteria;o__Enterobacterales;f__Enterobacteriaceae;g__Escherichia;s__Escherichia albertii
+GB_GCA_000000003.1	d__Bacteria;p__Proteobacteria;c__Gammaproteobacteria;o__Enterobacterales;f__Enterobacteriaceae;g__Salmonella;s__Salmonella enterica
+GB_GCA_000000004.1	d__Archaea;p__Halobacteriota;c__;o__;f__;g__;s__
+`
+	file := filepath.Join(t.TempDir(), "taxonomy.tsv")
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tax, err := NewTaxonomyFromGTDB(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tax.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	taxids := make(map[string]uint32, len(tax.Names))
+	for taxid, name := range tax.Names {
+		taxids[name] = taxid
+	}
+	if len(taxids) != 13 { // 10 + 2 + root
+		t.Fatalf("unexpected number of taxa: %d", len(taxids))
+	}
+
+	ecoli := taxids["s__Escherichia coli"]
+	if ecoli != gtdbTaxid("s__Escherichia coli") {
+		t.Errorf("taxid of s__Escherichia coli is not stable: %d", ecoli)
+	}
+	if tax.Rank(ecoli) != "species" {
+		t.Errorf("unexpected rank: %s", tax.Rank(ecoli))
+	}
+	if lca := tax.LCA(ecoli, taxids["s__Escherichia albertii"]); lca != taxids["g__Escherichia"] {
+		t.Errorf("unexpected LCA: %s", tax.Names[lca])
+	}
+	if lca := tax.LCA(ecoli, taxids["s__Salmonella enterica"]); lca != taxids["f__Enterobacteriaceae"] {
+		t.Errorf("unexpected LCA: %s", tax.Names[lca])
+	}
+	if lca := tax.LCA(ecoli, taxids["p__Halobacteriota"]); lca != 1 {
+		t.Errorf("unexpected LCA: %s", tax.Names[lca])
+	}
+	if at := tax.AtRank(ecoli, "superkingdom"); at != taxids["d__Bacteria"] {
+		t.Errorf("unexpected superkingdom: %s", tax.Names[at])
+	}
+
+	if err = ioutil.WriteFile(file, []byte("a\td__Bacteria;x__Foo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewTaxonomyFromGTDB(file); err == nil {
+		t.Errorf("error expected for unknown rank prefix")
+	}
+}
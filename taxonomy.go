@@ -23,6 +23,7 @@ package unikmer
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -43,6 +44,8 @@ type Taxonomy struct {
 	ranks        []string         // rank id -> rank
 	Ranks        map[string]interface{}
 
+	Names map[uint32]string // taxid -> name, see LoadNames
+
 	hasRanks      bool
 	hasDelNodes   bool
 	hasMergeNodes bool
@@ -51,6 +54,13 @@ type Taxonomy struct {
 	// lcaCache map[uint64]uint32 // cache of lca
 	// mux      sync.Mutex
 	lcaCache sync.Map
+	lcaLRU   *lruCache // bounded cache, used instead of lcaCache if not nil
+
+	depths   map[uint32]int // cache of depths, see Depth
+	depthMux sync.Mutex
+
+	children     map[uint32][]uint32 // parent -> children, see Children
+	childrenOnce sync.Once
 
 	maxTaxid uint32
 }
@@ -244,6 +254,190 @@ func (t *Taxonomy) Rank(taxid uint32) string {
 	return "" // taxid not found int db
 }
 
+// AtRank returns the ancestor of a taxid at the given rank,
+// 0 for unknown taxid or no ancestor at the rank.
+// A taxid is returned if it's at the rank itself.
+func (t *Taxonomy) AtRank(taxid uint32, rank string) uint32 {
+	if !t.hasRanks {
+		panic(ErrRankNotLoaded)
+	}
+	var parent, newTaxid uint32
+	var ok bool
+	for taxid > 0 {
+		if t.Rank(taxid) == rank {
+			return taxid
+		}
+		parent, ok = t.Nodes[taxid]
+		if !ok {
+			if !t.hasMergeNodes {
+				return 0
+			}
+			if newTaxid, ok = t.MergeNodes[taxid]; !ok || newTaxid == taxid {
+				return 0
+			}
+			taxid = newTaxid
+			continue
+		}
+		if parent == taxid { // root
+			return 0
+		}
+		taxid = parent
+	}
+	return 0
+}
+
+// Lineage returns the lineage of a taxid, from the root to the taxid,
+// nil for unknown taxid. A merged taxid is replaced by the new one.
+func (t *Taxonomy) Lineage(taxid uint32) []uint32 {
+	child, parent, ok := t.parentOf(taxid)
+	if !ok {
+		return nil
+	}
+	lineage := make([]uint32, 0, 16)
+	for {
+		lineage = append(lineage, child)
+		if parent == child || child == t.rootNode { // root
+			break
+		}
+		if child, parent, ok = t.parentOf(parent); !ok {
+			return nil
+		}
+	}
+	for i, j := 0, len(lineage)-1; i < j; i, j = i+1, j-1 {
+		lineage[i], lineage[j] = lineage[j], lineage[i]
+	}
+	return lineage
+}
+
+// LineageRanks returns the lineage of a taxid and ranks of all nodes,
+// nil for unknown taxid. See Lineage for details.
+func (t *Taxonomy) LineageRanks(taxid uint32) ([]uint32, []string) {
+	if !t.hasRanks {
+		panic(ErrRankNotLoaded)
+	}
+	lineage := t.Lineage(taxid)
+	if lineage == nil {
+		return nil, nil
+	}
+	ranks := make([]string, len(lineage))
+	for i, taxid := range lineage {
+		ranks[i] = t.Rank(taxid)
+	}
+	return lineage, ranks
+}
+
+// IsDescendantOf returns true if taxid a is b or in the subtree of b,
+// false for unknown taxids. Merged taxids are replaced by new ones.
+func (t *Taxonomy) IsDescendantOf(a uint32, b uint32) bool {
+	var ok bool
+	if b, _, ok = t.parentOf(b); !ok {
+		return false
+	}
+	child, parent, ok := t.parentOf(a)
+	if !ok {
+		return false
+	}
+	for {
+		if child == b {
+			return true
+		}
+		if parent == child { // root
+			return false
+		}
+		if child, parent, ok = t.parentOf(parent); !ok {
+			return false
+		}
+	}
+}
+
+// Depth returns the number of edges from the root to a taxid, i.e.,
+// 0 for the root, and -1 for unknown taxid or broken lineage.
+// A merged taxid is replaced by the new one. Depths of all nodes in
+// the lineage are cached, and it's safe for concurrent use.
+func (t *Taxonomy) Depth(taxid uint32) int {
+	child, parent, ok := t.parentOf(taxid)
+	if !ok {
+		return -1
+	}
+
+	t.depthMux.Lock()
+	defer t.depthMux.Unlock()
+
+	if t.depths == nil {
+		t.depths = make(map[uint32]int, 1024)
+	}
+
+	// nodes from the taxid to the root or a cached ancestor
+	path := make([]uint32, 0, 16)
+	var depth int
+	for {
+		if d, cached := t.depths[child]; cached {
+			depth = d
+			break
+		}
+		path = append(path, child)
+		if parent == child || child == t.rootNode { // root
+			depth = -1
+			break
+		}
+		if len(path) > len(t.Nodes) { // cycle
+			return -1
+		}
+		if child, parent, ok = t.parentOf(parent); !ok {
+			return -1
+		}
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		depth++
+		t.depths[path[i]] = depth
+	}
+	return depth
+}
+
+// buildChildren builds the inverted index of Nodes,
+// children of every node are sorted in ascending order.
+func (t *Taxonomy) buildChildren() {
+	t.children = make(map[uint32][]uint32, len(t.Nodes))
+	for child, parent := range t.Nodes {
+		if child == parent { // root
+			continue
+		}
+		t.children[parent] = append(t.children[parent], child)
+	}
+	for _, children := range t.children {
+		sort.Slice(children, func(i, j int) bool { return children[i] < children[j] })
+	}
+}
+
+// Children returns direct children of a taxid in ascending order,
+// nil for unknown taxid or leaf node. A merged taxid is replaced by
+// the new one. The inverted index of Nodes is built on the first call.
+// The returned slice should not be modified.
+func (t *Taxonomy) Children(taxid uint32) []uint32 {
+	taxid, _, ok := t.parentOf(taxid)
+	if !ok {
+		return nil
+	}
+	t.childrenOnce.Do(t.buildChildren)
+	return t.children[taxid]
+}
+
+// Subtree returns the taxid and all its descendants in breadth-first order,
+// nil for unknown taxid. A merged taxid is replaced by the new one.
+func (t *Taxonomy) Subtree(taxid uint32) []uint32 {
+	taxid, _, ok := t.parentOf(taxid)
+	if !ok {
+		return nil
+	}
+	t.childrenOnce.Do(t.buildChildren)
+
+	subtree := []uint32{taxid}
+	for i := 0; i < len(subtree); i++ {
+		subtree = append(subtree, t.children[subtree[i]]...)
+	}
+	return subtree
+}
+
 // LoadMergedNodesFromNCBI loads merged nodes from  NCBI merged.dmp.
 func (t *Taxonomy) LoadMergedNodesFromNCBI(file string) error {
 	return t.LoadMergedNodes(file, 1, 3)
@@ -347,6 +541,144 @@ func (t *Taxonomy) LoadDeletedNodes(file string, column int) error {
 	return nil
 }
 
+// LoadNamesFromNCBI loads scientific names of taxids from NCBI names.dmp.
+func (t *Taxonomy) LoadNamesFromNCBI(file string) error {
+	return t.LoadNames(file, 1, 3, 7)
+}
+
+// LoadNames loads names of taxids. Only rows with the name class of
+// "scientific name" are kept if classColumn is positive,
+// otherwise all rows are used.
+func (t *Taxonomy) LoadNames(file string, taxidColumn int, nameColumn int, classColumn int) error {
+	if taxidColumn < 1 || nameColumn < 1 {
+		return ErrIllegalColumnIndex
+	}
+
+	minColumns := taxidColumn
+	if nameColumn > minColumns {
+		minColumns = nameColumn
+	}
+	if classColumn > minColumns {
+		minColumns = classColumn
+	}
+
+	taxidColumn--
+	nameColumn--
+	classColumn--
+	parseFunc := func(line string) (interface{}, bool, error) {
+		items := strings.Split(strings.TrimSpace(line), "\t")
+		if len(items) < minColumns {
+			return nil, false, nil
+		}
+		if classColumn >= 0 && items[classColumn] != "scientific name" {
+			return nil, false, nil
+		}
+		id, e := strconv.Atoi(items[taxidColumn])
+		if e != nil {
+			return nil, false, e
+		}
+		return taxidName{uint32(id), items[nameColumn]}, true, nil
+	}
+
+	m := make(map[uint32]string, 1024)
+	reader, err := breader.NewBufferedReader(file, 3, 50, parseFunc)
+	if err != nil {
+		return fmt.Errorf("unikmer: %s", err)
+	}
+
+	var p taxidName
+	var data interface{}
+	for chunk := range reader.Ch {
+		if chunk.Err != nil {
+			return fmt.Errorf("unikmer: %s", chunk.Err)
+		}
+
+		for _, data = range chunk.Data {
+			p = data.(taxidName)
+			m[p.taxid] = p.name
+		}
+	}
+	t.Names = m
+	return nil
+}
+
+type taxidName struct {
+	taxid uint32
+	name  string
+}
+
+// Name returns the name of a taxid, empty string for taxid not found
+// or names not loaded.
+func (t *Taxonomy) Name(taxid uint32) string {
+	return t.Names[taxid]
+}
+
+// Validate checks that lineages of all nodes reach a root (a node being
+// parent of itself) without cycles. An error naming the first cycle or
+// the node with a missing parent is returned, nodes are checked in
+// ascending order of taxids.
+func (t *Taxonomy) Validate() error {
+	taxids := make([]uint32, 0, len(t.Nodes))
+	for taxid := range t.Nodes {
+		taxids = append(taxids, taxid)
+	}
+	sort.Slice(taxids, func(i, j int) bool { return taxids[i] < taxids[j] })
+
+	const (
+		inPath = iota + 1
+		valid
+	)
+	state := make(map[uint32]uint8, len(t.Nodes))
+	path := make([]uint32, 0, 64)
+
+	var child, parent uint32
+	var ok bool
+	for _, taxid := range taxids {
+		if state[taxid] == valid {
+			continue
+		}
+
+		path = path[:0]
+		child = taxid
+		for {
+			if state[child] == valid {
+				break
+			}
+			if state[child] == inPath { // back to a node in the path
+				var i int
+				for i = range path {
+					if path[i] == child {
+						break
+					}
+				}
+				cycle := make([]string, 0, len(path)-i+1)
+				for _, node := range path[i:] {
+					cycle = append(cycle, strconv.Itoa(int(node)))
+				}
+				cycle = append(cycle, strconv.Itoa(int(child)))
+				return fmt.Errorf("unikmer: cycle found in taxonomy: %s", strings.Join(cycle, "->"))
+			}
+
+			state[child] = inPath
+			path = append(path, child)
+
+			parent, ok = t.Nodes[child]
+			if !ok {
+				return fmt.Errorf("unikmer: lineage of taxid %d does not reach a root: parent of %d not found", taxid, child)
+			}
+			if parent == child { // root
+				break
+			}
+			child = parent
+		}
+
+		for _, node := range path {
+			state[node] = valid
+		}
+	}
+	return nil
+}
+
 // MaxTaxid returns maximum taxid
 func (t *Taxonomy) MaxTaxid() uint32 {
 	return t.maxTaxid
@@ -360,6 +692,37 @@ func (t *Taxonomy) CacheLCA() {
 	// }
 }
 
+// CacheLCAWithLimit tells to cache at most n LCA query results,
+// the least recently used ones are evicted when the limit is reached.
+// The cache is unbounded if n <= 0, the same as CacheLCA.
+func (t *Taxonomy) CacheLCAWithLimit(n int) {
+	t.cacheLCA = true
+	if n > 0 {
+		t.lcaLRU = newLRUCache(n)
+	} else {
+		t.lcaLRU = nil
+	}
+}
+
+func (t *Taxonomy) loadLCA(query uint64) (uint32, bool) {
+	if t.lcaLRU != nil {
+		return t.lcaLRU.Get(query)
+	}
+	tmp, ok := t.lcaCache.Load(query)
+	if !ok {
+		return 0, false
+	}
+	return tmp.(uint32), true
+}
+
+func (t *Taxonomy) storeLCA(query uint64, lca uint32) {
+	if t.lcaLRU != nil {
+		t.lcaLRU.Add(query, lca)
+		return
+	}
+	t.lcaCache.Store(query, lca)
+}
+
 // LCA returns the Lowest Common Ancestor of two nodes, 0 for unknown taxid.
 func (t *Taxonomy) LCA(a uint32, b uint32) uint32 {
 	if a == 0 || b == 0 {
@@ -373,13 +736,13 @@ func (t *Taxonomy) LCA(a uint32, b uint32) uint32 {
 	var ok bool
 
 	var query uint64
-	var tmp interface{}
+	var lca uint32
 	if t.cacheLCA {
 		query = pack2uint32(a, b)
 
-		tmp, ok = t.lcaCache.Load(query)
+		lca, ok = t.loadLCA(query)
 		if ok {
-			return tmp.(uint32)
+			return lca
 		}
 	}
 
@@ -407,7 +770,7 @@ func (t *Taxonomy) LCA(a uint32, b uint32) uint32 {
 
 			if !flag {
 				if t.cacheLCA {
-					t.lcaCache.Store(query, uint32(0))
+					t.storeLCA(query, 0)
 				}
 				return 0
 			}
@@ -419,7 +782,7 @@ func (t *Taxonomy) LCA(a uint32, b uint32) uint32 {
 		}
 		if parent == b { // b is ancestor of a
 			if t.cacheLCA {
-				t.lcaCache.Store(query, b)
+				t.storeLCA(query, b)
 			}
 			return b
 		}
@@ -447,7 +810,7 @@ func (t *Taxonomy) LCA(a uint32, b uint32) uint32 {
 
 			if !flag {
 				if t.cacheLCA {
-					t.lcaCache.Store(query, uint32(0))
+					t.storeLCA(query, 0)
 				}
 				return 0
 			}
@@ -458,13 +821,13 @@ func (t *Taxonomy) LCA(a uint32, b uint32) uint32 {
 		}
 		if parent == a { // a is ancestor of b
 			if t.cacheLCA {
-				t.lcaCache.Store(query, a)
+				t.storeLCA(query, a)
 			}
 			return a
 		}
 		if _, ok = mA[parent]; ok {
 			if t.cacheLCA {
-				t.lcaCache.Store(query, parent)
+				t.storeLCA(query, parent)
 			}
 			return parent
 		}
@@ -474,6 +837,78 @@ func (t *Taxonomy) LCA(a uint32, b uint32) uint32 {
 	return t.rootNode
 }
 
+// LCAN returns the Lowest Common Ancestor of multiple nodes,
+// 0 for empty input or any unknown taxid. Merged taxids are handled as LCA does.
+// Ancestors of the first taxid are computed only once,
+// and other taxids just walk up until hitting one of them,
+// which is faster than folding LCA pairwise.
+// Results of two taxids are cached by LCA if CacheLCA is called.
+func (t *Taxonomy) LCAN(taxids []uint32) uint32 {
+	switch len(taxids) {
+	case 0:
+		return 0
+	case 2:
+		return t.LCA(taxids[0], taxids[1])
+	}
+
+	// the first taxid and its ancestors, root at the end
+	line := make([]uint32, 0, 16)
+	idx := make(map[uint32]int, 16)
+
+	child, parent, ok := t.parentOf(taxids[0])
+	if !ok {
+		return 0
+	}
+	for {
+		idx[child] = len(line)
+		line = append(line, child)
+		if parent == child { // root
+			break
+		}
+		if child, parent, ok = t.parentOf(parent); !ok {
+			return 0
+		}
+	}
+
+	var lca, i int // index of current LCA in line
+	for _, taxid := range taxids[1:] {
+		if child, parent, ok = t.parentOf(taxid); !ok {
+			return 0
+		}
+		for {
+			if i, ok = idx[child]; ok {
+				if i > lca {
+					lca = i
+				}
+				break
+			}
+			if parent == child { // another root
+				return t.rootNode
+			}
+			if child, parent, ok = t.parentOf(parent); !ok {
+				return 0
+			}
+		}
+	}
+	return line[lca]
+}
+
+// parentOf returns the taxid and its parent, a merged taxid is replaced
+// by the new one. False is returned for unknown taxid.
+func (t *Taxonomy) parentOf(taxid uint32) (uint32, uint32, bool) {
+	if parent, ok := t.Nodes[taxid]; ok {
+		return taxid, parent, true
+	}
+	if t.hasMergeNodes {
+		if newTaxid, ok := t.MergeNodes[taxid]; ok {
+			if parent, ok := t.Nodes[newTaxid]; ok {
+				return newTaxid, parent, true
+			}
+		}
+	}
+	return taxid, 0, false
+}
+
 func pack2uint32(a uint32, b uint32) uint64 {
 	if a < b {
 		return (uint64(a) << 32) | uint64(b)
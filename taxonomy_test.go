@@ -21,6 +21,9 @@
 package unikmer
 
 import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -42,3 +45,274 @@ func TestPackTwoTaxids(t *testing.T) {
 		}
 	}
 }
+
+func TestValidate(t *testing.T) {
+	type Test struct {
+		nodes map[uint32]uint32
+		err   string
+	}
+	tests := []Test{
+		Test{map[uint32]uint32{1: 1, 2: 1, 3: 2, 4: 2}, ""},
+		Test{map[uint32]uint32{1: 1, 2: 1, 3: 4, 4: 5, 5: 3}, "unikmer: cycle found in taxonomy: 3->4->5->3"},
+		Test{map[uint32]uint32{1: 1, 2: 1, 3: 2, 4: 5, 5: 5}, ""}, // two roots
+		Test{map[uint32]uint32{1: 1, 2: 1, 3: 6}, "unikmer: lineage of taxid 3 does not reach a root: parent of 6 not found"},
+		Test{map[uint32]uint32{1: 1, 2: 3, 3: 2, 4: 2}, "unikmer: cycle found in taxonomy: 2->3->2"},
+	}
+
+	for _, test := range tests {
+		tax := &Taxonomy{Nodes: test.nodes}
+		err := tax.Validate()
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		} else if err == nil || err.Error() != test.err {
+			t.Errorf("error expected: %s, got: %v", test.err, err)
+		}
+	}
+}
+
+func TestAtRank(t *testing.T) {
+	tax := &Taxonomy{
+		Nodes:         map[uint32]uint32{1: 1, 2: 1, 3: 2, 4: 3, 5: 3, 6: 2},
+		taxid2rankid:  map[uint32]uint8{1: 0, 2: 1, 3: 2, 4: 3, 5: 3, 6: 3},
+		ranks:         []string{"no rank", "family", "genus", "species"},
+		hasRanks:      true,
+		MergeNodes:    map[uint32]uint32{7: 4},
+		hasMergeNodes: true,
+	}
+	tests := []struct {
+		taxid uint32
+		rank  string
+		at    uint32
+	}{
+		{4, "species", 4},
+		{4, "genus", 3},
+		{4, "family", 2},
+		{5, "genus", 3},
+		{6, "genus", 0}, // no genus in lineage
+		{3, "species", 0},
+		{7, "genus", 3}, // merged
+		{8, "genus", 0}, // unknown
+		{0, "genus", 0},
+		{1, "order", 0},
+	}
+	for _, test := range tests {
+		if at := tax.AtRank(test.taxid, test.rank); at != test.at {
+			t.Errorf("AtRank(%d, %s): expected %d, got %d", test.taxid, test.rank, test.at, at)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != ErrRankNotLoaded {
+			t.Errorf("panic of ErrRankNotLoaded expected, got: %v", r)
+		}
+	}()
+	tax = &Taxonomy{Nodes: map[uint32]uint32{1: 1, 2: 1}}
+	tax.AtRank(2, "genus")
+}
+
+func TestLCAN(t *testing.T) {
+	tax := &Taxonomy{
+		Nodes:         map[uint32]uint32{1: 1, 2: 1, 3: 2, 4: 3, 5: 3, 6: 2, 8: 1, 9: 9},
+		MergeNodes:    map[uint32]uint32{7: 4},
+		hasMergeNodes: true,
+		rootNode:      1,
+	}
+	tests := []struct {
+		taxids []uint32
+		lca    uint32
+	}{
+		{[]uint32{}, 0},
+		{[]uint32{4}, 4},
+		{[]uint32{7}, 4}, // merged
+		{[]uint32{10}, 0},
+		{[]uint32{4, 5}, 3},
+		{[]uint32{4, 5, 4}, 3},
+		{[]uint32{4, 5, 6}, 2},
+		{[]uint32{3, 4, 5}, 3},
+		{[]uint32{4, 3, 5}, 3},
+		{[]uint32{7, 5, 5}, 3},
+		{[]uint32{4, 5, 8}, 1},
+		{[]uint32{4, 5, 10}, 0}, // unknown
+		{[]uint32{0, 4, 5}, 0},
+		{[]uint32{4, 5, 9}, 1}, // another root
+	}
+	for _, test := range tests {
+		if lca := tax.LCAN(test.taxids); lca != test.lca {
+			t.Errorf("LCAN(%v): expected %d, got %d", test.taxids, test.lca, lca)
+		}
+	}
+}
+
+func TestLineage(t *testing.T) {
+	tax := &Taxonomy{
+		Nodes:         map[uint32]uint32{1: 1, 2: 1, 3: 2, 4: 3, 5: 3, 6: 2},
+		taxid2rankid:  map[uint32]uint8{1: 0, 2: 1, 3: 2, 4: 3, 5: 3, 6: 3},
+		ranks:         []string{"no rank", "family", "genus", "species"},
+		hasRanks:      true,
+		MergeNodes:    map[uint32]uint32{7: 4},
+		hasMergeNodes: true,
+		rootNode:      1,
+	}
+	tests := []struct {
+		taxid   uint32
+		lineage []uint32
+		ranks   []string
+	}{
+		{4, []uint32{1, 2, 3, 4}, []string{"no rank", "family", "genus", "species"}},
+		{7, []uint32{1, 2, 3, 4}, []string{"no rank", "family", "genus", "species"}}, // merged
+		{6, []uint32{1, 2, 6}, []string{"no rank", "family", "species"}},
+		{1, []uint32{1}, []string{"no rank"}},
+		{8, nil, nil}, // unknown
+		{0, nil, nil},
+	}
+	for _, test := range tests {
+		if lineage := tax.Lineage(test.taxid); !reflect.DeepEqual(lineage, test.lineage) {
+			t.Errorf("Lineage(%d): expected %v, got %v", test.taxid, test.lineage, lineage)
+		}
+		lineage, ranks := tax.LineageRanks(test.taxid)
+		if !reflect.DeepEqual(lineage, test.lineage) || !reflect.DeepEqual(ranks, test.ranks) {
+			t.Errorf("LineageRanks(%d): expected %v %v, got %v %v", test.taxid, test.lineage, test.ranks, lineage, ranks)
+		}
+	}
+}
+
+func TestCacheLCAWithLimit(t *testing.T) {
+	tax := &Taxonomy{
+		Nodes:    map[uint32]uint32{1: 1, 2: 1, 3: 2, 4: 3, 5: 3, 6: 2},
+		rootNode: 1,
+	}
+	tax.CacheLCAWithLimit(2)
+
+	for i := 0; i < 2; i++ { // the second round hits the cache
+		if lca := tax.LCA(4, 5); lca != 3 {
+			t.Errorf("LCA(4, 5): expected 3, got %d", lca)
+		}
+		if lca := tax.LCA(4, 6); lca != 2 {
+			t.Errorf("LCA(4, 6): expected 2, got %d", lca)
+		}
+		if lca := tax.LCA(5, 6); lca != 2 {
+			t.Errorf("LCA(5, 6): expected 2, got %d", lca)
+		}
+	}
+	if n := tax.lcaLRU.Len(); n != 2 {
+		t.Errorf("expected 2 cached entries, got %d", n)
+	}
+	if _, ok := tax.lcaLRU.Get(pack2uint32(4, 5)); ok {
+		t.Errorf("least recently used entry should be evicted")
+	}
+	if lca, ok := tax.lcaLRU.Get(pack2uint32(6, 5)); !ok || lca != 2 {
+		t.Errorf("recently used entry should be cached")
+	}
+}
+
+func TestIsDescendantOf(t *testing.T) {
+	tax := &Taxonomy{
+		Nodes:         map[uint32]uint32{1: 1, 2: 1, 3: 2, 4: 3, 5: 3, 6: 2},
+		MergeNodes:    map[uint32]uint32{7: 4, 8: 3},
+		hasMergeNodes: true,
+		rootNode:      1,
+	}
+	tests := []struct {
+		a, b uint32
+		is   bool
+	}{
+		{4, 3, true},
+		{4, 2, true},
+		{4, 1, true},
+		{4, 4, true},
+		{3, 4, false},
+		{4, 6, false},
+		{7, 3, true}, // merged
+		{4, 8, true}, // merged
+		{4, 9, false},
+		{9, 1, false},
+		{0, 0, false},
+	}
+	for _, test := range tests {
+		if is := tax.IsDescendantOf(test.a, test.b); is != test.is {
+			t.Errorf("IsDescendantOf(%d, %d): expected %v, got %v", test.a, test.b, test.is, is)
+		}
+	}
+}
+
+func TestDepth(t *testing.T) {
+	tax := &Taxonomy{
+		Nodes:         map[uint32]uint32{1: 1, 2: 1, 3: 2, 4: 3, 5: 3, 6: 2, 8: 9},
+		MergeNodes:    map[uint32]uint32{7: 4},
+		hasMergeNodes: true,
+		rootNode:      1,
+	}
+	// repeated queries are answered from cache
+	for _, test := range []struct {
+		taxid uint32
+		depth int
+	}{
+		{4, 3}, {5, 3}, {3, 2}, {1, 0}, {6, 2}, {7, 3}, // 7 is merged into 4
+		{4, 3}, {10, -1}, {0, -1}, {8, -1}, // 8 has a broken lineage
+	} {
+		if depth := tax.Depth(test.taxid); depth != test.depth {
+			t.Errorf("Depth(%d): expected %d, got %d", test.taxid, test.depth, depth)
+		}
+	}
+}
+
+func TestChildrenSubtree(t *testing.T) {
+	tax := &Taxonomy{
+		Nodes:         map[uint32]uint32{1: 1, 2: 1, 3: 2, 4: 3, 5: 3, 6: 2},
+		MergeNodes:    map[uint32]uint32{7: 3},
+		hasMergeNodes: true,
+		rootNode:      1,
+	}
+	for _, test := range []struct {
+		taxid    uint32
+		children []uint32
+		subtree  []uint32
+	}{
+		{1, []uint32{2}, []uint32{1, 2, 3, 6, 4, 5}},
+		{2, []uint32{3, 6}, []uint32{2, 3, 6, 4, 5}},
+		{7, []uint32{4, 5}, []uint32{3, 4, 5}}, // merged
+		{4, nil, []uint32{4}},
+		{8, nil, nil}, // unknown
+	} {
+		if children := tax.Children(test.taxid); !reflect.DeepEqual(children, test.children) {
+			t.Errorf("Children(%d): expected %v, got %v", test.taxid, test.children, children)
+		}
+		if subtree := tax.Subtree(test.taxid); !reflect.DeepEqual(subtree, test.subtree) {
+			t.Errorf("Subtree(%d): expected %v, got %v", test.taxid, test.subtree, subtree)
+		}
+	}
+}
+
+func TestLoadNames(t *testing.T) {
+	data := `1	|	all	|		|	synonym	|
+1	|	root	|		|	scientific name	|
+2	|	Bacteria	|	Bacteria <bacteria>	|	scientific name	|
+2	|	eubacteria	|		|	genbank common name	|
+562	|	Escherichia coli	|		|	scientific name	|
+`
+	file := filepath.Join(t.TempDir(), "names.dmp")
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tax := &Taxonomy{Nodes: map[uint32]uint32{1: 1, 2: 1, 562: 2}}
+	if tax.Name(562) != "" {
+		t.Errorf("empty name expected before loading names")
+	}
+	if err := tax.LoadNamesFromNCBI(file); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[uint32]string{1: "root", 2: "Bacteria", 562: "Escherichia coli", 3: ""}
+	for taxid, name := range expected {
+		if tax.Name(taxid) != name {
+			t.Errorf("unexpected name of taxid %d: %q, expected: %q", taxid, tax.Name(taxid), name)
+		}
+	}
+
+	if err := tax.LoadNames(file, 0, 3, 7); err != ErrIllegalColumnIndex {
+		t.Errorf("ErrIllegalColumnIndex expected")
+	}
+}
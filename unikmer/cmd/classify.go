@@ -0,0 +1,284 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// classifyCmd represents
+var classifyCmd = &cobra.Command{
+	Use:   "classify",
+	Short: "Classify sequences by taxids of k-mers in binary files",
+	Long: `Classify sequences by taxids of k-mers in binary files
+
+All k-mers of every sequence in -f/--seq-file are searched in the binary
+files (the database) with taxids, and a taxid is assigned to the sequence
+by voting with taxids of matched k-mers:
+
+  lca:      the LCA of taxids of all matched k-mers
+  majority: the most frequent taxid, the LCA of the most frequent ones for ties
+
+Output format (tab-delimited):
+  1. seqID
+  2. length of sequence
+  3. number of k-mers in the sequence, k-mers containing bases other
+     than A, C, G, T are skipped and not counted
+  4. number of k-mers matched in the database
+  5. assigned taxid, 0 for unclassified
+
+Attention:
+  1. The 'canonical' flags of all files should be consistent.
+  2. Binary files should have taxid information.
+  3. K-mers containing bases other than A, C, G, T are skipped.
+
+Tips:
+  1. Use -m/--min-hits to avoid classifying by a few random matches.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+		seq.ValidateSeq = false
+
+		var err error
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			if len(files) == 1 && isStdin(files[0]) {
+				log.Info("no files given, reading from stdin")
+			} else {
+				log.Infof("%d input file(s) given", len(files))
+			}
+		}
+
+		checkFileSuffix(extDataFile, files...)
+
+		outFile := getFlagString(cmd, "out-file")
+		seqFile := getFlagNonEmptyString(cmd, "seq-file")
+		if isStdin(seqFile) && len(files) == 1 && isStdin(files[0]) {
+			checkError(fmt.Errorf("stdin can not be used for both binary file and sequence file"))
+		}
+		inputFiles = append(inputFiles, seqFile)
+		minHits := getFlagPositiveInt(cmd, "min-hits")
+		vote := getFlagString(cmd, "vote")
+		var voteMajority bool
+		switch vote {
+		case "lca":
+		case "majority":
+			voteMajority = true
+		default:
+			checkError(fmt.Errorf("invalid value of flag --vote: %s, available: lca, majority", vote))
+		}
+
+		// -----------------------------------------------------------------------
+
+		// load k-mers
+		taxondb := loadTaxonomy(opt, false)
+
+		m := make(map[uint64]uint32, mapInitSize)
+
+		var infh *bufio.Reader
+		var r *os.File
+		var reader *unikmer.Reader
+		var code uint64
+		var taxid, lca uint32
+		var ok bool
+		var k int = -1
+		var canonical bool
+		var nfiles = len(files)
+		for i, file := range files {
+			if opt.Verbose {
+				log.Infof("reading file (%d/%d): %s", i+1, nfiles, file)
+			}
+			func() {
+				infh, r, _, err = inStream(file)
+				checkError(err)
+				defer r.Close()
+
+				reader, err = unikmer.NewReader(infh)
+				checkError(err)
+
+				if !reader.HasTaxidInfo() {
+					checkError(fmt.Errorf("no taxids found in file: %s", file))
+				}
+
+				if k == -1 {
+					k = reader.K
+					canonical = reader.IsCanonical()
+				} else {
+					if k != reader.K {
+						checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
+					}
+					if reader.IsCanonical() != canonical {
+						checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+					}
+				}
+
+				for {
+					code, taxid, err = reader.ReadCodeWithTaxid()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+					}
+					if lca, ok = m[code]; ok {
+						m[code] = taxondb.LCA(lca, taxid)
+					} else {
+						m[code] = taxid
+					}
+				}
+			}()
+		}
+		if opt.Verbose {
+			log.Infof("%d k-mers loaded", len(m))
+		}
+
+		// -----------------------------------------------------------------------
+
+		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		var kmer []byte
+		var kcode unikmer.KmerCode
+		var nKmers, nHits int
+		counts := make(map[uint32]int, 64)
+
+		classifySeq := func(id []byte, sequence []byte) {
+			nKmers, nHits = 0, 0
+			for t := range counts {
+				delete(counts, t)
+			}
+			lca = 0
+			for i := 0; i+k <= len(sequence); i++ {
+				kmer = sequence[i : i+k]
+
+				// N and other IUPAC bases are encoded as A
+				if !onlyACGT(kmer) {
+					continue
+				}
+				nKmers++
+
+				kcode, err = unikmer.NewKmerCode(kmer)
+				checkError(err)
+
+				if canonical {
+					taxid, ok = m[kcode.Canonical().Code]
+				} else if taxid, ok = m[kcode.Code]; !ok {
+					taxid, ok = m[kcode.RevComp().Code]
+				}
+				if !ok {
+					continue
+				}
+				nHits++
+
+				if voteMajority {
+					counts[taxid]++
+				} else if lca == 0 {
+					lca = taxid
+				} else {
+					lca = taxondb.LCA(lca, taxid)
+				}
+			}
+
+			if nHits < minHits {
+				lca = 0
+			} else if voteMajority {
+				lca = majorityTaxid(counts, taxondb)
+			}
+
+			outfh.WriteString(fmt.Sprintf("%s\t%d\t%d\t%d\t%d\n", id, len(sequence), nKmers, nHits, lca))
+		}
+
+		var record *fastx.Record
+		var fastxReader *fastx.Reader
+		if opt.Verbose {
+			log.Infof("reading sequence file: %s", seqFile)
+		}
+		fastxReader, err = fastx.NewDefaultReader(seqFile)
+		checkError(err)
+		var nSeqs, nClassified int
+		for {
+			record, err = fastxReader.Read()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+				break
+			}
+
+			classifySeq(record.ID, record.Seq.Seq)
+			nSeqs++
+			if lca > 0 {
+				nClassified++
+			}
+		}
+		if opt.Verbose {
+			log.Infof("%d of %d sequences classified", nClassified, nSeqs)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(classifyCmd)
+
+	classifyCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+	classifyCmd.Flags().StringP("seq-file", "f", "", "query sequences in (gzipped) fasta/q file")
+	classifyCmd.Flags().StringP("vote", "", "lca", "voting method for assigning taxid: lca or majority")
+	classifyCmd.Flags().IntP("min-hits", "m", 1, "minimum number of matched k-mers to classify a sequence")
+}
+
+// majorityTaxid returns the most frequent taxid,
+// or the LCA of the most frequent ones for ties.
+func majorityTaxid(counts map[uint32]int, taxondb *unikmer.Taxonomy) uint32 {
+	var best uint32
+	var max int
+	for taxid, n := range counts {
+		if n > max {
+			best, max = taxid, n
+		} else if n == max {
+			best = taxondb.LCA(best, taxid)
+		}
+	}
+	return best
+}
@@ -0,0 +1,87 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/shenwei356/unikmer"
+)
+
+// TestClassifyNonACGT checks that k-mers containing bases other than ACGT
+// are skipped instead of being encoded as A.
+func TestClassifyNonACGT(t *testing.T) {
+	dir := t.TempDir()
+	k := 5
+
+	err := ioutil.WriteFile(filepath.Join(dir, "nodes.dmp"), []byte("1\t|\t1\t|\tno rank\t|\n"+
+		"10\t|\t1\t|\tgenus\t|\n"+
+		"11\t|\t10\t|\tspecies\t|\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, "db.unik")
+	outfh, gw, w, err := outStreamWithCodec(file, codecGzip, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := unikmer.NewWriter(outfh, k, unikmer.UNIK_INCLUDETAXID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kcode, err := unikmer.NewKmerCode([]byte("AAAAA"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.WriteCodeWithTaxid(kcode.Code, 11); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	outfh.Flush()
+	gw.Close()
+	w.Close()
+
+	seqFile := filepath.Join(dir, "reads.fa")
+	err = ioutil.WriteFile(seqFile, []byte(">r1\nANNNNA\n>r2\nAAAAANNNN\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outFile := filepath.Join(dir, "classify.tsv")
+	if err = executeRootCmd(t, "classify", "--data-dir", dir, "-f", seqFile, "-o", outFile, file); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "r1\t6\t0\t0\t0\n" + // N was encoded as A
+		"r2\t9\t1\t1\t11\n"
+	if result := string(data); result != expected {
+		t.Errorf("unexpected result:\n%s\nexpected:\n%s", result, expected)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/shenwei356/util/pathutil"
+	"github.com/spf13/cobra"
+)
+
+// compareDirsCmd represents
+var compareDirsCmd = &cobra.Command{
+	Use:   "compare-dirs",
+	Short: "Compare k-mers of binary files in two directories",
+	Long: `Compare k-mers of binary files in two directories
+
+Binary files in two directories are matched by basename, and for every
+pair, sizes of intersection and two set differences are reported.
+This is useful for validating database builds, e.g., per-taxon files.
+
+Output format (tab-delimited):
+  1. file, basename of the file
+  2. n1, number of unique k-mers in file of dir1
+  3. n2, number of unique k-mers in file of dir2
+  4. shared, number of k-mers in both files
+  5. only1, number of k-mers only in file of dir1
+  6. only2, number of k-mers only in file of dir2
+
+Attentions:
+  1. Taxids are ignored.
+  2. Files existing in only one directory are skipped with warnings.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		if len(args) != 2 {
+			checkError(fmt.Errorf("two directories needed"))
+		}
+		dir1, dir2 := args[0], args[1]
+
+		outFile := getFlagString(cmd, "out-file")
+		patternFile := getFlagString(cmd, "pattern")
+		reFile, err := regexp.Compile(patternFile)
+		if err != nil {
+			checkError(fmt.Errorf("fail to compile pattern of binary file: %s", patternFile))
+		}
+
+		files1 := listFilesInDir(dir1, reFile)
+		files2 := listFilesInDir(dir2, reFile)
+		if opt.Verbose {
+			log.Infof("%d and %d binary files found in %s and %s", len(files1), len(files2), dir1, dir2)
+		}
+
+		names := make([]string, 0, len(files1))
+		for name := range files1 {
+			if _, ok := files2[name]; !ok {
+				log.Warningf("file only found in %s: %s", dir1, name)
+				continue
+			}
+			names = append(names, name)
+		}
+		for name := range files2 {
+			if _, ok := files1[name]; !ok {
+				log.Warningf("file only found in %s: %s", dir2, name)
+			}
+		}
+		sort.Strings(names)
+		if opt.Verbose {
+			log.Infof("%d pairs of files to compare", len(names))
+		}
+
+		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		type cmpResult struct {
+			n1, n2, shared int
+		}
+		results := make([]cmpResult, len(names))
+
+		var wg sync.WaitGroup
+		tokens := make(chan int, opt.NumCPUs)
+		for i, name := range names {
+			wg.Add(1)
+			tokens <- 1
+			go func(i int, name string) {
+				defer func() {
+					wg.Done()
+					<-tokens
+				}()
+
+				file1, file2 := files1[name], files2[name]
+				m1, k1, canonical1, err := readCodeSet(file1)
+				checkError(err)
+				m2, k2, canonical2, err := readCodeSet(file2)
+				checkError(err)
+				if k1 != k2 {
+					checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to K (%d) of '%s'", k2, file2, k1, file1))
+				}
+				if canonical1 != canonical2 {
+					checkError(fmt.Errorf(`'canonical' flags not consistent: %s and %s, please check with "unikmer stats"`, file1, file2))
+				}
+
+				var shared int
+				for code := range m2 {
+					if _, ok := m1[code]; ok {
+						shared++
+					}
+				}
+				results[i] = cmpResult{n1: len(m1), n2: len(m2), shared: shared}
+
+				if opt.Verbose {
+					log.Infof("finished comparing: %s", name)
+				}
+			}(i, name)
+		}
+		wg.Wait()
+
+		outfh.WriteString("file\tn1\tn2\tshared\tonly1\tonly2\n")
+		for i, name := range names {
+			r := results[i]
+			outfh.WriteString(fmt.Sprintf("%s\t%d\t%d\t%d\t%d\t%d\n",
+				name, r.n1, r.n2, r.shared, r.n1-r.shared, r.n2-r.shared))
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(compareDirsCmd)
+
+	compareDirsCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+	compareDirsCmd.Flags().StringP("pattern", "p", `\.unik(\.gz)?$`, `binary file pattern (regular expression)`)
+}
+
+// listFilesInDir returns files matching the pattern in a directory,
+// with basenames as keys.
+func listFilesInDir(dir string, re *regexp.Regexp) map[string]string {
+	existed, err := pathutil.DirExists(dir)
+	if err != nil {
+		checkError(fmt.Errorf("check given dir '%s': %s", dir, err))
+	}
+	if !existed {
+		checkError(fmt.Errorf("dir not existed: %s", dir))
+	}
+
+	list, err := ioutil.ReadDir(dir)
+	if err != nil {
+		checkError(fmt.Errorf("check given directory '%s': %s", dir, err))
+	}
+
+	files := make(map[string]string, len(list))
+	var filename string
+	for _, file := range list {
+		filename = file.Name()
+		if filename[0] == '.' || file.IsDir() || !re.MatchString(filename) {
+			continue
+		}
+		files[filename] = filepath.Join(dir, filename)
+	}
+	return files
+}
+
+// readCodeSet reads all codes of a binary file into a set.
+func readCodeSet(file string) (map[uint64]struct{}, int, bool, error) {
+	infh, r, _, err := inStream(file)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer r.Close()
+
+	reader, err := unikmer.NewReader(infh)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("%s: %s", file, err)
+	}
+
+	m := make(map[uint64]struct{}, mapInitSize)
+	var code uint64
+	for {
+		code, _, err = reader.ReadCodeWithTaxid()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, 0, false, fmt.Errorf("%s: %s", file, err)
+		}
+		m[code] = struct{}{}
+	}
+	return m, reader.K, reader.IsCanonical(), nil
+}
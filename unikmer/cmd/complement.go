@@ -0,0 +1,184 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// complementCmd represents
+var complementCmd = &cobra.Command{
+	Use:   "complement",
+	Short: "K-mers in a universe but absent from a binary file",
+	Long: `K-mers in a universe but absent from a binary file
+
+K-mers present in the universe file (-u/--universe) but absent from the
+input file are outputted, with taxids of the universe file preserved.
+Both files are streamed, so the memory usage is very low.
+
+Attentions:
+  1. Both files should be sorted.
+  2. The 'canonical' flags of the two files should be consistent.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if len(files) > 1 {
+			checkError(fmt.Errorf("only one input file accepted"))
+		}
+		file := files[0]
+
+		universe := getFlagNonEmptyString(cmd, "universe")
+		if isStdin(universe) && isStdin(file) {
+			checkError(fmt.Errorf("stdin can not be used for both the universe file and the input file"))
+		}
+
+		inputFiles = append(inputFiles, universe)
+
+		checkFileSuffix(extDataFile, universe, file)
+		setProvenance(opt, cmd, []string{universe, file})
+
+		outFile := getFlagString(cmd, "out-prefix")
+		failIfEmpty := getFlagBool(cmd, "fail-if-empty")
+		var nResult int
+		defer exitIfEmpty(failIfEmpty, &nResult)
+
+		infhU, rU, _, err := inStream(universe)
+		checkError(err)
+		defer rU.Close()
+
+		readerU, err := unikmer.NewReader(infhU)
+		checkError(err)
+		if !readerU.IsSorted() {
+			checkError(fmt.Errorf("the universe file should be sorted: %s", universe))
+		}
+
+		infh, r, _, err := inStream(file)
+		checkError(err)
+		defer r.Close()
+
+		reader, err := unikmer.NewReader(infh)
+		checkError(err)
+		if !reader.IsSorted() {
+			checkError(fmt.Errorf("the input file should be sorted: %s", file))
+		}
+
+		if reader.K != readerU.K {
+			checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to K (%d) of the universe file", reader.K, file, readerU.K))
+		}
+		if reader.IsCanonical() != readerU.IsCanonical() {
+			checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+		}
+
+		hasTaxid := !opt.IgnoreTaxid && readerU.HasTaxidInfo()
+
+		if !isStdout(outFile) {
+			outFile += extDataFile
+		}
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		mode := uint32(unikmer.UNIK_SORTED)
+		if readerU.IsCanonical() {
+			mode |= unikmer.UNIK_CANONICAL
+		}
+		if hasTaxid {
+			mode |= unikmer.UNIK_INCLUDETAXID
+		}
+		writer, err := unikmer.NewWriter(outfh, readerU.K, mode)
+		checkError(err)
+		writer.Description = opt.Provenance
+		writer.SetMaxTaxid(maxUint32N(readerU.GetTaxidBytesLength())) // follow reader
+
+		var code, codeU uint64
+		var taxid uint32
+		var hasCode = true // whether there are k-mers left in the input file
+
+		code, err = reader.ReadCode()
+		if err != nil {
+			if err != io.EOF {
+				checkError(err)
+			}
+			hasCode = false
+		}
+
+		var n int
+		for {
+			codeU, taxid, err = readerU.ReadCodeWithTaxid()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+			}
+
+			for hasCode && code < codeU {
+				code, err = reader.ReadCode()
+				if err != nil {
+					if err != io.EOF {
+						checkError(err)
+					}
+					hasCode = false
+				}
+			}
+			if hasCode && code == codeU {
+				continue
+			}
+
+			writer.WriteCodeWithTaxid(codeU, taxid)
+			n++
+		}
+
+		checkError(writer.Flush())
+		nResult = n
+		if opt.Verbose {
+			log.Infof("%d k-mers saved to %s", n, outFile)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(complementCmd)
+
+	complementCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
+	complementCmd.Flags().StringP("universe", "u", "", "sorted binary file of the universe k-mers")
+	complementCmd.Flags().BoolP("fail-if-empty", "", false, helpFailIfEmpty)
+}
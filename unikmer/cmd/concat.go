@@ -38,9 +38,20 @@ var concatCmd = &cobra.Command{
 	Long: `Concatenate multiple binary files without removing duplicates
 
 Attentions:
-  1. The 'canonical' flags of all files should be consistent.
+  1. The 'canonical' flags of all files should be consistent,
+     unless -K/--canonicalize is given.
   2. Input files should ALL have or don't have taxid information.
 
+Tips:
+  1. Use -t/--tag-source to record which input file each k-mer comes from,
+     the 1-based index of the input file is stored as the taxid. Taxids of
+     input files are ignored, so don't use taxonomy-aware commands on the
+     output.
+  2. Use -K/--canonicalize to concatenate files with different 'canonical'
+     flags, all k-mers are converted to canonical ones, so the output is
+     safe for downstream set operations. -s/--sorted is not allowed for
+     non-canonical inputs then, as converting breaks the order.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		opt := getOptions(cmd)
@@ -61,14 +72,71 @@ Attentions:
 		}
 
 		checkFileSuffix(extDataFile, files...)
+		setProvenance(opt, cmd, files)
 
 		outFile := getFlagString(cmd, "out-prefix")
 		sortedKmers := getFlagBool(cmd, "sorted")
+		tagSource := getFlagBool(cmd, "tag-source")
+		canonicalize := getFlagBool(cmd, "canonicalize")
+		if tagSource && len(files) > int(maxUint32) {
+			checkError(fmt.Errorf("too many input files for -t/--tag-source: %d", len(files)))
+		}
+
+		var k int = -1
+		var canonical bool
+		var isCanonical0 bool // 'canonical' flag of the first file
+		var hasTaxid bool
+
+		// checkReader checks if the reader is consistent with previous ones
+		checkReader := func(file string, reader *unikmer.Reader) {
+			if canonicalize && !reader.IsCanonical() && sortedKmers {
+				checkError(fmt.Errorf("flag -s/--sorted not allowed for non-canonical file with -K/--canonicalize: %s", file))
+			}
+
+			if k == -1 {
+				k = reader.K
+				isCanonical0 = reader.IsCanonical()
+				canonical = isCanonical0 || canonicalize
+				hasTaxid = !opt.IgnoreTaxid && reader.HasTaxidInfo()
+				return
+			}
+			if k != reader.K {
+				checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
+			}
+			if !canonicalize && reader.IsCanonical() != canonical {
+				checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats", or use -K/--canonicalize`))
+			}
+			if !tagSource && !opt.IgnoreTaxid && reader.HasTaxidInfo() != hasTaxid {
+				if reader.HasTaxidInfo() {
+					checkError(fmt.Errorf(`taxid information not found in previous files, but found in this: %s`, file))
+				} else {
+					checkError(fmt.Errorf(`taxid information found in previous files, but missing in this: %s`, file))
+				}
+			}
+		}
+
+		// checking headers of files before writing anything, stdin is checked later
+		for _, file := range files {
+			if isStdin(file) {
+				continue
+			}
+			func() {
+				infh, r, _, err := inStream(file)
+				checkError(err)
+				defer r.Close()
+
+				reader, err := unikmer.NewReader(infh)
+				checkError(err)
+
+				checkReader(file, reader)
+			}()
+		}
+		k = -1
 
 		if !isStdout(outFile) {
 			outFile += extDataFile
 		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -85,9 +153,7 @@ Attentions:
 		var reader *unikmer.Reader
 		var code uint64
 		var taxid uint32
-		var k int = -1
-		var canonical bool
-		var hasTaxid bool
+		var toCanonical bool
 		var flag int
 		var n int64
 		var nfiles = len(files)
@@ -104,10 +170,13 @@ Attentions:
 				reader, err = unikmer.NewReader(infh)
 				checkError(err)
 
-				if k == -1 {
-					k = reader.K
-					canonical = reader.IsCanonical()
-					hasTaxid = !opt.IgnoreTaxid && reader.HasTaxidInfo()
+				checkReader(file, reader)
+				toCanonical = canonicalize && !reader.IsCanonical()
+
+				if writer == nil {
+					if tagSource && hasTaxid {
+						log.Warningf("taxids of input files are replaced with indexes of files with -t/--tag-source")
+					}
 
 					var mode uint32
 					if sortedKmers {
@@ -118,27 +187,19 @@ Attentions:
 					if canonical {
 						mode |= unikmer.UNIK_CANONICAL
 					}
-					if hasTaxid {
+					if hasTaxid || tagSource {
 						mode |= unikmer.UNIK_INCLUDETAXID
 					}
 					writer, err = unikmer.NewWriter(outfh, k, mode)
 					checkError(err)
-					writer.SetMaxTaxid(maxUint32N(reader.GetTaxidBytesLength())) // follow reader
-				} else {
-					if k != reader.K {
-						checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
-					}
-					if reader.IsCanonical() != canonical {
-						checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
-					}
-					if !opt.IgnoreTaxid && reader.HasTaxidInfo() != hasTaxid {
-						if reader.HasTaxidInfo() {
-							checkError(fmt.Errorf(`taxid information not found in previous files, but found in this: %s`, file))
-						} else {
-							checkError(fmt.Errorf(`taxid information found in previous files, but missing in this: %s`, file))
-						}
-
+					writer.Description = opt.Provenance
+					if tagSource {
+						writer.SetMaxTaxid(uint32(nfiles))
+					} else {
+						writer.SetMaxTaxid(maxUint32N(reader.GetTaxidBytesLength())) // follow reader
 					}
+				} else if canonicalize && reader.IsCanonical() != isCanonical0 {
+					log.Warningf("'canonical' flag of file '%s' not consistent with previous files, k-mers are converted to canonical ones", file)
 				}
 
 				for {
@@ -150,6 +211,12 @@ Attentions:
 						checkError(err)
 					}
 
+					if toCanonical {
+						code = unikmer.Canonical(code, k)
+					}
+					if tagSource {
+						taxid = uint32(i + 1)
+					}
 					checkError(writer.WriteCodeWithTaxid(code, taxid))
 					n++
 				}
@@ -176,4 +243,6 @@ func init() {
 
 	concatCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
 	concatCmd.Flags().BoolP("sorted", "s", false, "input k-mers are sorted")
+	concatCmd.Flags().BoolP("tag-source", "t", false, "store the 1-based index of input file as the taxid of each k-mer")
+	concatCmd.Flags().BoolP("canonicalize", "K", false, "convert all k-mers to canonical ones, allowing inputs with different 'canonical' flags")
 }
@@ -0,0 +1,133 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// containmentCmd represents
+var containmentCmd = &cobra.Command{
+	Use:   "containment",
+	Short: "Compute containment of a query binary file in reference files",
+	Long: `Compute containment of a query binary file in reference files
+
+Containment is the fraction of k-mers of the query file that appear in
+a reference file, i.e., |query ∩ ref| / |query|, which is asymmetric,
+unlike Jaccard index. Every reference file is compared with the query file
+by merging the two sorted files in streaming, so no k-mers are held in RAM.
+
+Output format (tab-delimited):
+  1. query, query file
+  2. ref, reference file
+  3. shared, number of k-mers in both files
+  4. qsize, number of k-mers in query file
+  5. containment, shared / qsize
+
+Attentions:
+  1. All input files should be sorted, you can use 'unikmer sort -u -m 100M'.
+  2. K and 'canonical' flags of all files should be consistent.
+  3. Taxids are ignored.
+  4. Reading from stdin is not supported.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		queryFile := getFlagString(cmd, "query")
+		if queryFile == "" {
+			checkError(fmt.Errorf("flag -q/--query needed"))
+		}
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			log.Infof("%d reference file(s) given", len(files))
+		}
+
+		checkFileSuffix(extDataFile, append([]string{queryFile}, files...)...)
+
+		outFile := getFlagString(cmd, "out-file")
+
+		// checking all files before computing
+		checkSortedFiles(append([]string{queryFile}, files...))
+
+		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		outfh.WriteString("query\tref\tshared\tqsize\tcontainment\n")
+		var onlyQuery, shared int64
+		var containment float64
+		for i, file := range files {
+			if opt.Verbose {
+				log.Infof("processing file (%d/%d): %s", i+1, len(files), file)
+			}
+
+			func() {
+				readers := make([]*unikmer.Reader, 2)
+				for x, _file := range []string{queryFile, file} {
+					infh, r, _, err := inStream(_file)
+					checkError(err)
+					defer r.Close()
+
+					readers[x], err = unikmer.NewReader(infh)
+					checkError(err)
+				}
+
+				onlyQuery, shared, _, err = unikmer.OverlapSize(readers[0], readers[1])
+				if err != nil {
+					checkError(fmt.Errorf("%s: %s", file, err))
+				}
+			}()
+
+			containment = 0
+			if onlyQuery+shared > 0 {
+				containment = float64(shared) / float64(onlyQuery+shared)
+			}
+			outfh.WriteString(fmt.Sprintf("%s\t%s\t%d\t%d\t%.6f\n",
+				queryFile, file, shared, onlyQuery+shared, containment))
+			outfh.Flush()
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(containmentCmd)
+
+	containmentCmd.Flags().StringP("query", "q", "", "query binary file (sorted)")
+	containmentCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+}
@@ -21,8 +21,11 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"sort"
@@ -31,15 +34,40 @@ import (
 	"github.com/shenwei356/bio/seq"
 	"github.com/shenwei356/bio/seqio/fastx"
 	"github.com/shenwei356/unikmer"
+	"github.com/shenwei356/util/pathutil"
 	"github.com/spf13/cobra"
 )
 
+// estimated memory occupation of a k-mer (and its taxid) stored in map,
+// including the slice for sorting.
+const (
+	bytesPerKmerInMap      = 48
+	bytesPerKmerTaxidInMap = 56
+)
+
 // countCmd represents
 var countCmd = &cobra.Command{
 	Use:   "count",
 	Short: "Count k-mers from FASTA/Q sequences",
 	Long: `Count k-mers from FASTA/Q sequences
 
+Attentions:
+  1. Sequences shorter than k are skipped.
+  2. For circular genomes (--circular), the first k-1 bases of each sequence
+     are appended to its end, so k-mers spanning the origin are also counted.
+  3. K-mers containing bases other than ACGT (e.g., N) are skipped.
+  4. With --with-count, occurrences of every k-mer are counted and saved
+     in the output, which can not be used along with -T/--parse-taxid
+     or -d/--repeated. All k-mers are kept in memory, --max-memory is
+     ignored.
+
+Tips:
+  1. For sorted output (-s/--sort), k-mers are kept in memory, sorted once
+     and written, which is the fastest way for small genomes. When the
+     estimated memory occupation exceeds --max-memory, k-mers are dumped
+     to sorted chunk files in --tmp-dir, which are merged at last.
+     Not available for -d/--repeated and -t/--taxid.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		opt := getOptions(cmd)
@@ -64,6 +92,22 @@ var countCmd = &cobra.Command{
 		parseTaxidRegexp := getFlagString(cmd, "parse-taxid-regexp")
 
 		repeated := getFlagBool(cmd, "repeated")
+		withCount := getFlagBool(cmd, "with-count")
+
+		maxMem, err := ParseByteSize(getFlagString(cmd, "max-memory"))
+		if err != nil {
+			checkError(fmt.Errorf("parsing byte size: %s", err))
+		}
+		tmpDir := getFlagString(cmd, "tmp-dir")
+
+		if withCount {
+			if parseTaxid {
+				checkError(fmt.Errorf("flag --with-count and -T/--parse-taxid can not given simultaneously"))
+			}
+			if repeated {
+				checkError(fmt.Errorf("flag --with-count and -d/--repeated can not given simultaneously"))
+			}
+		}
 
 		var reParseTaxid *regexp.Regexp
 		if parseTaxid {
@@ -94,13 +138,26 @@ var countCmd = &cobra.Command{
 				log.Infof("%d input file(s) given", len(files))
 			}
 		}
+		setProvenance(opt, cmd, files)
 
+		outFile0 := outFile
 		if !isStdout(outFile) {
 			outFile += extDataFile
 		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
-		checkError(err)
+
+		// k-mers exceeding the memory limit are dumped to chunk files,
+		// in which case the output file is created by mergeChunksFile.
+		var outfh *bufio.Writer
+		var gw io.WriteCloser
+		var w *os.File
+		openOutFile := func() {
+			outfh, gw, w, err = outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
+			checkError(err)
+		}
 		defer func() {
+			if outfh == nil {
+				return
+			}
 			outfh.Flush()
 			if gw != nil {
 				gw.Close()
@@ -111,7 +168,8 @@ var countCmd = &cobra.Command{
 		var mode uint32
 		var writer *unikmer.Writer
 
-		if !parseTaxid && !sortKmers {
+		if !parseTaxid && !sortKmers && !withCount {
+			openOutFile()
 			if sortKmers {
 				mode |= unikmer.UNIK_SORTED
 			} else if opt.Compact {
@@ -125,6 +183,7 @@ var countCmd = &cobra.Command{
 			}
 			writer, err = unikmer.NewWriter(outfh, k, mode)
 			checkError(err)
+			writer.Description = opt.Provenance
 			writer.SetMaxTaxid(opt.MaxTaxid)
 			if taxid > 0 {
 				checkError(writer.SetGlobalTaxid(taxid))
@@ -134,6 +193,7 @@ var countCmd = &cobra.Command{
 		var m map[uint64]struct{}
 		var taxondb *unikmer.Taxonomy
 		var mt map[uint64]uint32
+		var mc map[uint64]uint32 // counts of k-mers
 
 		// could use bloom filter
 		// a key exists means it appear once, value of true means it's appeared more than once.
@@ -142,6 +202,8 @@ var countCmd = &cobra.Command{
 		if parseTaxid {
 			mt = make(map[uint64]uint32, mapInitSize)
 			taxondb = loadTaxonomy(opt, false)
+		} else if withCount {
+			mc = make(map[uint64]uint32, mapInitSize)
 		} else {
 			m = make(map[uint64]struct{}, mapInitSize)
 		}
@@ -149,8 +211,79 @@ var countCmd = &cobra.Command{
 			marks = make(map[uint64]bool, mapInitSize)
 		}
 
+		// dumping k-mers to chunk files when exceeding the memory limit
+		limitMem := maxMem > 0 && sortKmers && !repeated && !withCount && !(taxid > 0 && !parseTaxid)
+		var maxElem int
+		if limitMem {
+			if parseTaxid {
+				maxElem = maxMem / bytesPerKmerTaxidInMap
+			} else {
+				maxElem = maxMem / bytesPerKmerInMap
+			}
+			if maxElem < 1 {
+				maxElem = 1
+			}
+
+			if isStdout(outFile0) {
+				tmpDir = filepath.Join(tmpDir, "stdout.tmp")
+			} else {
+				tmpDir = filepath.Join(tmpDir, filepath.Base(outFile0)+".tmp")
+			}
+		}
+		var chunkMode uint32 = unikmer.UNIK_SORTED
+		if canonical {
+			chunkMode |= unikmer.UNIK_CANONICAL
+		}
+		if parseTaxid {
+			chunkMode |= unikmer.UNIK_INCLUDETAXID
+		}
+		var tmpFiles []string
+		dumpChunk := func() {
+			if len(tmpFiles) == 0 {
+				if opt.Verbose {
+					log.Infof("memory limit (%s) reached, dumping k-mers to chunk files in: %s",
+						getFlagString(cmd, "max-memory"), tmpDir)
+				}
+				existed, err := pathutil.DirExists(tmpDir)
+				checkError(err)
+				if existed {
+					empty, err := pathutil.IsEmpty(tmpDir)
+					checkError(err)
+					if !empty {
+						checkError(fmt.Errorf("tmp dir not empty: %s, choose another one", tmpDir))
+					}
+				}
+				checkError(os.MkdirAll(tmpDir, 0777))
+			}
+
+			file := chunkFileName(tmpDir, len(tmpFiles)+1)
+			var _n int64
+			if parseTaxid {
+				codesTaxids := make([]unikmer.CodeTaxid, 0, len(mt))
+				for code, taxid := range mt {
+					codesTaxids = append(codesTaxids, unikmer.CodeTaxid{Code: code, Taxid: taxid})
+				}
+				sort.Sort(unikmer.CodeTaxidSlice(codesTaxids))
+				_n = dumpCodesTaxids2File(codesTaxids, taxondb, k, chunkMode, file, opt, false, false)
+				mt = make(map[uint64]uint32, mapInitSize)
+			} else {
+				codes := make([]uint64, 0, len(m))
+				for code := range m {
+					codes = append(codes, code)
+				}
+				sort.Sort(unikmer.CodeSlice(codes))
+				_n = dumpCodes2File(codes, k, chunkMode, file, opt, false, false)
+				m = make(map[uint64]struct{}, mapInitSize)
+			}
+			if opt.Verbose {
+				log.Infof("[chunk %d] %d k-mers saved to tmp file: %s", len(tmpFiles)+1, _n, file)
+			}
+			tmpFiles = append(tmpFiles, file)
+		}
+
 		var sequence, kmer, preKmer []byte
-		var originalLen, l, end, e int
+		var circSeq []byte // sequence with the first k-1 bases appended, for circular genome
+		var l int
 		var record *fastx.Record
 		var fastxReader *fastx.Reader
 		var kcode, preKcode unikmer.KmerCode
@@ -163,6 +296,8 @@ var countCmd = &cobra.Command{
 		var lca uint32
 		var mark bool
 		var nseq int64
+		var lastIllegal int // position of the last base other than ACGT
+		var nSkipped int64  // k-mers containing bases other than ACGT
 		for _, file := range files {
 			if opt.Verbose {
 				log.Infof("reading sequence file: %s", file)
@@ -210,26 +345,48 @@ var countCmd = &cobra.Command{
 						sequence = record.Seq.RevComInplace().Seq
 					}
 
-					originalLen = len(record.Seq.Seq)
 					l = len(sequence)
+					if l < k { // also for circular genome, to avoid counting a base more than once
+						if opt.Verbose && j == 0 {
+							log.Warningf("sequence shorter than k (%d < %d), skipped: %s", l, k, record.ID)
+						}
+						break
+					}
 
-					end = l - 1
-					if end < 0 {
-						end = 0
+					// k-mers spanning the origin of circular genome
+					if circular && k > 1 {
+						circSeq = append(circSeq[:0], sequence...)
+						circSeq = append(circSeq, sequence[0:k-1]...)
+						sequence = circSeq
+						l = len(sequence)
 					}
+
 					first = true
-					for i = 0; i <= end; i++ {
-						e = i + k
-						if e > originalLen {
-							if circular {
-								e = e - originalLen
-								kmer = sequence[i:]
-								kmer = append(kmer, sequence[0:e]...)
-							} else {
-								break
+					for i = 0; i+k <= l; i++ {
+						if limitMem && (len(m) >= maxElem || len(mt) >= maxElem) {
+							dumpChunk()
+						}
+
+						kmer = sequence[i : i+k]
+
+						// skip k-mers containing bases other than ACGT
+						if i == 0 {
+							lastIllegal = -1
+							for p := 0; p < k-1; p++ {
+								if !isACGT[sequence[p]] {
+									lastIllegal = p
+								}
 							}
-						} else {
-							kmer = sequence[i : i+k]
+						}
+						if !isACGT[kmer[k-1]] {
+							lastIllegal = i + k - 1
+						}
+						if lastIllegal >= i {
+							if j == 0 {
+								nSkipped++
+							}
+							first = true
+							continue
 						}
 
 						if first {
@@ -271,6 +428,13 @@ var countCmd = &cobra.Command{
 							continue
 						}
 
+						if withCount {
+							if mc[kcode.Code] < ^uint32(0) { // saturated
+								mc[kcode.Code]++
+							}
+							continue
+						}
+
 						if repeated {
 							if mark, ok = marks[kcode.Code]; !ok {
 								marks[kcode.Code] = false
@@ -299,7 +463,38 @@ var countCmd = &cobra.Command{
 			}
 		}
 
-		if sortKmers || parseTaxid {
+		if nSkipped > 0 && opt.Verbose {
+			log.Infof("%d k-mers containing bases other than ACGT skipped", nSkipped)
+		}
+
+		if len(tmpFiles) > 0 {
+			if len(m) > 0 || len(mt) > 0 {
+				dumpChunk()
+			}
+			m, mt = nil, nil // just for gc
+
+			if opt.Verbose {
+				log.Infof("merging from %d chunk files", len(tmpFiles))
+			}
+			n, _ = mergeChunksFile(opt, taxondb, tmpFiles, outFile, k, chunkMode, true, false, true)
+			if opt.Verbose {
+				log.Infof("%d unique k-mers saved to %s", n, outFile)
+				log.Infof("removing %d intermediate files", len(tmpFiles))
+			}
+			for _, file := range tmpFiles {
+				if err = os.Remove(file); err != nil {
+					checkError(fmt.Errorf("fail to remove intermediate file: %s", file))
+				}
+			}
+			if err = os.Remove(tmpDir); err != nil {
+				checkError(fmt.Errorf("fail to remove temp directory, please manually delete it: %s", tmpDir))
+			}
+			return
+		}
+
+		if sortKmers || parseTaxid || withCount {
+			openOutFile()
+
 			var mode uint32
 			if canonical {
 				mode |= unikmer.UNIK_CANONICAL
@@ -307,11 +502,15 @@ var countCmd = &cobra.Command{
 			if parseTaxid {
 				mode |= unikmer.UNIK_INCLUDETAXID
 			}
+			if withCount {
+				mode |= unikmer.UNIK_INCLUDECOUNT
+			}
 			if sortKmers {
 				mode |= unikmer.UNIK_SORTED
 			}
 			writer, err = unikmer.NewWriter(outfh, k, mode)
 			checkError(err)
+			writer.Description = opt.Provenance
 			writer.SetMaxTaxid(opt.MaxTaxid)
 			if taxid > 0 {
 				checkError(writer.SetGlobalTaxid(taxid))
@@ -319,6 +518,8 @@ var countCmd = &cobra.Command{
 
 			if parseTaxid {
 				n = int64(len(mt))
+			} else if withCount {
+				n = int64(len(mc))
 			} else {
 				n = int64(len(m))
 			}
@@ -326,12 +527,18 @@ var countCmd = &cobra.Command{
 		}
 
 		var code uint64
+		var count uint32
 		if !sortKmers {
 			if parseTaxid {
 				for code, taxid = range mt {
 					writer.WriteCodeWithTaxid(code, taxid)
 				}
 				n = int64(len(mt))
+			} else if withCount {
+				for code, count = range mc {
+					writer.WriteCodeWithCount(code, count)
+				}
+				n = int64(len(mc))
 			}
 		} else {
 			if parseTaxid {
@@ -355,6 +562,27 @@ var countCmd = &cobra.Command{
 					writer.WriteCodeWithTaxid(codeT.Code, codeT.Taxid)
 				}
 				n = int64(len(mt))
+			} else if withCount {
+				codes := make([]uint64, len(mc))
+
+				i := 0
+				for code = range mc {
+					codes[i] = code
+					i++
+				}
+
+				if opt.Verbose {
+					log.Infof("sorting %d k-mers", len(codes))
+				}
+				sort.Sort(unikmer.CodeSlice(codes))
+				if opt.Verbose {
+					log.Infof("done sorting")
+				}
+
+				for _, code := range codes {
+					writer.WriteCodeWithCount(code, mc[code])
+				}
+				n = int64(len(mc))
 			} else {
 				codes := make([]uint64, len(m))
 
@@ -391,11 +619,14 @@ func init() {
 
 	countCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
 	countCmd.Flags().IntP("kmer-len", "k", 0, "k-mer length")
-	countCmd.Flags().BoolP("circular", "", false, "circular genome")
+	countCmd.Flags().BoolP("circular", "", false, "circular genome, k-mers spanning the origin are also counted")
 	countCmd.Flags().BoolP("canonical", "K", false, "only keep the canonical k-mers")
 	countCmd.Flags().BoolP("sort", "s", false, helpSort)
 	countCmd.Flags().Uint32P("taxid", "t", 0, "taxid")
 	countCmd.Flags().BoolP("parse-taxid", "T", false, `parse taxid from FASTA/Q header`)
 	countCmd.Flags().StringP("parse-taxid-regexp", "r", "", `regular expression for passing taxid`)
 	countCmd.Flags().BoolP("repeated", "d", false, `only count duplicated k-mers, for removing singleton in FASTQ`)
+	countCmd.Flags().BoolP("with-count", "", false, `count occurrences of k-mers and save them in the output`)
+	countCmd.Flags().StringP("max-memory", "", "4G", `maximum memory for storing k-mers in sorting mode, exceeded k-mers are dumped into chunk files, supports K/M/G suffix, 0 for no limit`)
+	countCmd.Flags().StringP("tmp-dir", "", "./", `directory for intermediate files`)
 }
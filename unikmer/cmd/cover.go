@@ -0,0 +1,337 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// coverCmd represents
+var coverCmd = &cobra.Command{
+	Use:   "cover",
+	Short: "Compute coverage of a sequence by k-mers in binary files",
+	Long: `Compute coverage of a sequence by k-mers in binary files
+
+For a sequence given by -s/--seq or sequences in a file given by
+-f/--seq-file, all k-mers of the sequence are checked against k-mers
+of the binary files (the database), and the fraction of k-mers present
+is reported.
+
+Output format (tab-delimited):
+  1. seqID, "seq" for sequence given by -s/--seq
+  2. length of sequence
+  3. number of k-mers in the sequence, k-mers containing bases other
+     than A, C, G, T are skipped and not counted
+  4. number of k-mers present in the database
+  5. fraction of k-mers present
+  6. number of bases covered by present k-mers
+  7. fraction of bases covered
+
+Coverage profile (-p/--profile-file, tab-delimited):
+  1. seqID
+  2. position (1-based)
+  3. number of present k-mers covering this base
+
+Attention:
+  1. The 'canonical' flags of all files should be consistent.
+  2. Taxids are ignored.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+		seq.ValidateSeq = false
+
+		var err error
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			if len(files) == 1 && isStdin(files[0]) {
+				log.Info("no files given, reading from stdin")
+			} else {
+				log.Infof("%d input file(s) given", len(files))
+			}
+		}
+
+		checkFileSuffix(extDataFile, files...)
+
+		outFile := getFlagString(cmd, "out-file")
+		profileFile := getFlagString(cmd, "profile-file")
+		circular := getFlagBool(cmd, "circular")
+
+		query := getFlagString(cmd, "seq")
+		seqFile := getFlagString(cmd, "seq-file")
+		if (query == "") == (seqFile == "") {
+			checkError(fmt.Errorf("one and only one of flag -s/--seq and -f/--seq-file needed"))
+		}
+		if seqFile != "" && isStdin(seqFile) && len(files) == 1 && isStdin(files[0]) {
+			checkError(fmt.Errorf("stdin can not be used for both binary file and sequence file"))
+		}
+
+		// -----------------------------------------------------------------------
+
+		// load k-mers
+		m := make(map[uint64]struct{}, mapInitSize)
+
+		var infh *bufio.Reader
+		var r *os.File
+		var reader *unikmer.Reader
+		var code uint64
+		var k int = -1
+		var canonical bool
+		var nfiles = len(files)
+		for i, file := range files {
+			if opt.Verbose {
+				log.Infof("reading file (%d/%d): %s", i+1, nfiles, file)
+			}
+			func() {
+				infh, r, _, err = inStream(file)
+				checkError(err)
+				defer r.Close()
+
+				reader, err = unikmer.NewReader(infh)
+				checkError(err)
+
+				if k == -1 {
+					k = reader.K
+					canonical = reader.IsCanonical()
+				} else {
+					if k != reader.K {
+						checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
+					}
+					if reader.IsCanonical() != canonical {
+						checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+					}
+				}
+
+				for {
+					code, _, err = reader.ReadCodeWithTaxid()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+					}
+					m[code] = struct{}{}
+				}
+			}()
+		}
+		if opt.Verbose {
+			log.Infof("%d k-mers loaded", len(m))
+		}
+
+		// -----------------------------------------------------------------------
+
+		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		var outfh2 *bufio.Writer
+		if profileFile != "" {
+			var gw2 io.WriteCloser
+			var w2 *os.File
+			outfh2, gw2, w2, err = outStream(profileFile, strings.HasSuffix(strings.ToLower(profileFile), ".gz"), opt.CompressionLevel)
+			checkError(err)
+			defer func() {
+				outfh2.Flush()
+				if gw2 != nil {
+					gw2.Close()
+				}
+				w2.Close()
+			}()
+		}
+
+		var depth []int
+		var kmer, preKmer []byte
+		var originalLen, l, end, e, j int
+		var lastIllegal int // position of the last base other than ACGT
+		var kcode, preKcode unikmer.KmerCode
+		var first, ok bool
+		var nKmers, nHits, nCovered int
+
+		coverSeq := func(id []byte, sequence []byte) {
+			originalLen = len(sequence)
+			l = len(sequence)
+
+			if cap(depth) < l+1 {
+				depth = make([]int, l+1)
+			} else {
+				depth = depth[:l+1]
+				for j = range depth {
+					depth[j] = 0
+				}
+			}
+
+			nKmers, nHits = 0, 0
+			end = l - 1
+			if l < k { // too short
+				end = -1
+			}
+			first = true
+			for i := 0; i <= end; i++ {
+				e = i + k
+				if e > originalLen {
+					if circular {
+						e = e - originalLen
+						kmer = sequence[i:]
+						kmer = append(kmer, sequence[0:e]...)
+					} else {
+						break
+					}
+				} else {
+					kmer = sequence[i : i+k]
+				}
+
+				// skip k-mers containing bases other than ACGT
+				if i == 0 {
+					lastIllegal = -1
+					for p := 0; p < k-1; p++ {
+						if !isACGT[sequence[p]] {
+							lastIllegal = p
+						}
+					}
+				}
+				if !isACGT[sequence[(i+k-1)%originalLen]] {
+					lastIllegal = i + k - 1
+				}
+				if lastIllegal >= i {
+					first = true
+					continue
+				}
+
+				if first {
+					kcode, err = unikmer.NewKmerCode(kmer)
+					first = false
+				} else {
+					kcode, err = unikmer.NewKmerCodeMustFromFormerOne(kmer, preKmer, preKcode)
+				}
+				if err != nil {
+					checkError(fmt.Errorf("fail to encode '%s': %s", kmer, err))
+				}
+				preKmer, preKcode = kmer, kcode
+
+				nKmers++
+				if canonical {
+					_, ok = m[kcode.Canonical().Code]
+				} else if _, ok = m[kcode.Code]; !ok {
+					_, ok = m[kcode.RevComp().Code]
+				}
+				if !ok {
+					continue
+				}
+				nHits++
+
+				// difference array of depth
+				depth[i]++
+				if i+k <= l {
+					depth[i+k]--
+				} else { // circular
+					depth[l]--
+					depth[0]++
+					depth[i+k-l]--
+				}
+			}
+
+			nCovered = 0
+			var d int
+			for j = 0; j < l; j++ {
+				d += depth[j]
+				depth[j] = d
+				if d > 0 {
+					nCovered++
+				}
+			}
+
+			outfh.WriteString(fmt.Sprintf("%s\t%d\t%d\t%d\t%.4f\t%d\t%.4f\n",
+				id, l, nKmers, nHits, fraction(nHits, nKmers), nCovered, fraction(nCovered, l)))
+
+			if outfh2 != nil {
+				for j = 0; j < l; j++ {
+					outfh2.WriteString(fmt.Sprintf("%s\t%d\t%d\n", id, j+1, depth[j]))
+				}
+			}
+		}
+
+		if query != "" {
+			coverSeq([]byte("seq"), []byte(query))
+			return
+		}
+
+		var record *fastx.Record
+		var fastxReader *fastx.Reader
+		if opt.Verbose {
+			log.Infof("reading sequence file: %s", seqFile)
+		}
+		fastxReader, err = fastx.NewDefaultReader(seqFile)
+		checkError(err)
+		for {
+			record, err = fastxReader.Read()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+				break
+			}
+
+			if opt.Verbose {
+				log.Infof("processing sequence: %s", record.ID)
+			}
+			coverSeq(record.ID, record.Seq.Seq)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(coverCmd)
+
+	coverCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+	coverCmd.Flags().StringP("seq", "s", "", "query sequence")
+	coverCmd.Flags().StringP("seq-file", "f", "", "query sequences in (gzipped) fasta/q file")
+	coverCmd.Flags().StringP("profile-file", "p", "", `out file of per-base coverage profile ("-" for stdout, suffix .gz for gzipped out)`)
+	coverCmd.Flags().BoolP("circular", "", false, "circular sequence")
+}
+
+func fraction(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
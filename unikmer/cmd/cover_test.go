@@ -0,0 +1,88 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shenwei356/unikmer"
+)
+
+// TestCoverNonACGT checks that k-mers containing bases other than ACGT
+// are skipped instead of being encoded as A or failing.
+func TestCoverNonACGT(t *testing.T) {
+	dir := t.TempDir()
+	k := 5
+
+	file := filepath.Join(dir, "db.unik")
+	outfh, gw, w, err := outStreamWithCodec(file, codecGzip, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := unikmer.NewWriter(outfh, k, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kcode, err := unikmer.NewKmerCode([]byte("AAAAA"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.WriteCode(kcode.Code); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	outfh.Flush()
+	gw.Close()
+	w.Close()
+
+	tests := []struct {
+		seq      string
+		expected string // columns 2-5
+	}{
+		{"AAAAA", "5\t1\t1\t1.0000"},
+		{"ANNNNA", "6\t0\t0\t0.0000"},       // N was encoded as A
+		{"AAAAA-CCCCC", "11\t2\t1\t0.5000"}, // gaps were fatal
+		{"NAAAAACCCCN", "11\t5\t1\t0.2000"},
+	}
+	for _, test := range tests {
+		outFile := filepath.Join(dir, "cover.tsv")
+		if err = executeRootCmd(t, "cover", "-s", test.seq, "-o", outFile, file); err != nil {
+			t.Fatalf("%s: %s", test.seq, err)
+		}
+
+		data, err := ioutil.ReadFile(outFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		items := strings.Split(strings.TrimSpace(string(data)), "\t")
+		if len(items) < 5 {
+			t.Fatalf("%s: unexpected output: %s", test.seq, data)
+		}
+		if result := strings.Join(items[1:5], "\t"); result != test.expected {
+			t.Errorf("%s: unexpected result: %s, expected: %s", test.seq, result, test.expected)
+		}
+	}
+}
@@ -23,7 +23,6 @@ package cmd
 import (
 	"fmt"
 	"runtime"
-	"strconv"
 	"strings"
 
 	"github.com/shenwei356/breader"
@@ -37,6 +36,9 @@ var decodeCmd = &cobra.Command{
 	Short: "Decode encoded integer to k-mer text",
 	Long: `Decode encoded integer to k-mer text
 
+Codes are read from files (one code per line, stdin by default),
+or given by -q/--query. Codes in hexadecimal should have the prefix "0x".
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		opt := getOptions(cmd)
@@ -51,15 +53,20 @@ var decodeCmd = &cobra.Command{
 			checkError(fmt.Errorf("k > 32 not supported"))
 		}
 
-		if opt.Verbose {
-			log.Info("checking input files ...")
-		}
-		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
-		if opt.Verbose {
-			if len(files) == 1 && isStdin(files[0]) {
-				log.Info("no files given, reading from stdin")
-			} else {
-				log.Infof("%d input file(s) given", len(files))
+		queries := getFlagStringSlice(cmd, "query")
+
+		var files []string
+		if len(queries) == 0 || len(args) > 0 || getFlagString(cmd, "infile-list") != "" {
+			if opt.Verbose {
+				log.Info("checking input files ...")
+			}
+			files = getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+			if opt.Verbose {
+				if len(files) == 1 && isStdin(files[0]) {
+					log.Info("no files given, reading from stdin")
+				} else {
+					log.Infof("%d input file(s) given", len(files))
+				}
 			}
 		}
 
@@ -76,10 +83,36 @@ var decodeCmd = &cobra.Command{
 		var reader *breader.BufferedReader
 		var chunk breader.Chunk
 		var data interface{}
-		var line string
 		var code uint64
 		var kmer []byte
 
+		decode := func(line string) {
+			if line == "" {
+				return
+			}
+
+			code, err = parseCode(line)
+			if err != nil {
+				checkError(fmt.Errorf("encode kmer should be non-negative integer: %s", line))
+			}
+
+			if code > unikmer.MaxCode[k] {
+				checkError(fmt.Errorf("encode integer overflows for k=%d (max: %d): %d", k, unikmer.MaxCode[k], code))
+			}
+
+			kmer = unikmer.Decode(code, k)
+
+			if all {
+				outfh.WriteString(fmt.Sprintf("%d\t%s\n", code, kmer))
+			} else {
+				outfh.WriteString(fmt.Sprintf("%s\n", kmer))
+			}
+		}
+
+		for _, query := range queries {
+			decode(query)
+		}
+
 		for _, file := range files {
 			reader, err = breader.NewDefaultBufferedReader(file)
 			checkError(err)
@@ -87,33 +120,7 @@ var decodeCmd = &cobra.Command{
 			for chunk = range reader.Ch {
 				checkError(chunk.Err)
 				for _, data = range chunk.Data {
-					line = data.(string)
-					if line == "" {
-						continue
-					}
-
-					code, err = strconv.ParseUint(line, 10, 64)
-					if err != nil {
-						checkError(fmt.Errorf("encode kmer should be non-negative integer: %s", line))
-					}
-
-					if code < 0 {
-						checkError(fmt.Errorf("encode kmer should be non-negative integer: %d", code))
-					}
-					if code > unikmer.MaxCode[k] {
-						checkError(fmt.Errorf("encode integer overflows for k=%d (max: %d): %d", k, unikmer.MaxCode[k], code))
-					}
-
-					kmer = unikmer.Decode(code, k)
-					if err != nil {
-						checkError(fmt.Errorf("fail to decode '%s': %s", line, err))
-					}
-
-					if all {
-						outfh.WriteString(fmt.Sprintf("%d\t%s\n", code, kmer))
-					} else {
-						outfh.WriteString(fmt.Sprintf("%s\n", kmer))
-					}
+					decode(data.(string))
 				}
 			}
 		}
@@ -127,5 +134,6 @@ func init() {
 	decodeCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
 	decodeCmd.Flags().IntP("kmer-len", "k", 0, "k-mer length")
 	decodeCmd.Flags().BoolP("all", "a", false, `output all data: encoded integer, decoded k-mer`)
+	decodeCmd.Flags().StringSliceP("query", "q", []string{""}, `codes to decode (multiple values delimted by comma supported), files are not read if no files given`)
 
 }
@@ -0,0 +1,211 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// dedupCmd represents
+var dedupCmd = &cobra.Command{
+	Use:   "dedup",
+	Short: "Remove duplicated k-mers in a binary file",
+	Long: `Remove duplicated k-mers in a binary file
+
+For sorted input, duplicates are removed in a single streaming pass with
+little memory. Otherwise, all k-mers are loaded into RAM and sorted first.
+
+Attentions:
+  1. Only one input file is accepted, use "unikmer sort -u" for multiple files.
+  2. Taxids of duplicated k-mers are merged to their LCA, the taxonomy
+     data is needed only if the input file has taxids of k-mers.
+  3. Output file is sorted.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		outFile := getFlagString(cmd, "out-prefix")
+
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if len(files) > 1 {
+			checkError(fmt.Errorf("only one input file allowed, use \"unikmer sort -u\" for multiple files"))
+		}
+		file := files[0]
+
+		checkFileSuffix(extDataFile, file)
+		setProvenance(opt, cmd, files)
+
+		infh, r, _, err := inStream(file)
+		checkError(err)
+		defer r.Close()
+
+		reader, err := unikmer.NewReader(infh)
+		checkError(err)
+
+		k := reader.K
+		sorted := reader.IsSorted()
+		hasTaxid := !opt.IgnoreTaxid && reader.IsIncludeTaxid()
+
+		var taxondb *unikmer.Taxonomy
+		if hasTaxid {
+			taxondb = loadTaxonomy(opt, false)
+		}
+
+		var mode uint32
+		mode |= unikmer.UNIK_SORTED
+		if reader.IsCanonical() {
+			mode |= unikmer.UNIK_CANONICAL
+		}
+		if hasTaxid {
+			mode |= unikmer.UNIK_INCLUDETAXID
+		}
+
+		var code uint64
+		var taxid uint32
+		var m []uint64
+		var mt []unikmer.CodeTaxid
+
+		pr := newProgressReader(opt, file, reader)
+
+		// unsorted input: loading all k-mers and sorting them
+		if !sorted {
+			if opt.Verbose {
+				log.Infof("input file is not sorted, loading k-mers into RAM")
+			}
+			for {
+				code, taxid, err = pr.ReadCodeWithTaxid()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+				}
+
+				if hasTaxid {
+					mt = append(mt, unikmer.CodeTaxid{Code: code, Taxid: taxid})
+				} else {
+					m = append(m, code)
+				}
+			}
+			pr.done()
+
+			if opt.Verbose {
+				log.Infof("sorting %d k-mers", len(m)+len(mt))
+			}
+			if hasTaxid {
+				sort.Sort(unikmer.CodeTaxidSlice(mt))
+			} else {
+				sort.Sort(unikmer.CodeSlice(m))
+			}
+		}
+
+		if !isStdout(outFile) {
+			outFile += extDataFile
+		}
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		writer, err := unikmer.NewWriter(outfh, k, mode)
+		checkError(err)
+		writer.Description = opt.Provenance
+		writer.SetMaxTaxid(opt.MaxTaxid) // follow taxondb
+		if !opt.IgnoreTaxid && !hasTaxid && reader.HasGlobalTaxid() {
+			checkError(writer.SetGlobalTaxid(reader.GetGlobalTaxid()))
+		}
+
+		var n, nDup int64
+		var last uint64
+		var lca uint32
+		var first = true
+		// k-mers must be fed in ascending order
+		add := func(code uint64, taxid uint32) {
+			if !first && code == last {
+				nDup++
+				if hasTaxid {
+					lca = taxondb.LCA(taxid, lca)
+				}
+				return
+			}
+
+			if first {
+				first = false
+			} else { // when meeting new k-mer, output previous one
+				checkError(writer.WriteCodeWithTaxid(last, lca))
+				n++
+			}
+			last = code
+			lca = taxid
+		}
+
+		if sorted {
+			for {
+				code, taxid, err = pr.ReadCodeWithTaxid()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+				}
+				add(code, taxid)
+			}
+			pr.done()
+		} else if hasTaxid {
+			for _, codeT := range mt {
+				add(codeT.Code, codeT.Taxid)
+			}
+		} else {
+			for _, code = range m {
+				add(code, 0)
+			}
+		}
+		if !first { // do not forget the last one
+			checkError(writer.WriteCodeWithTaxid(last, lca))
+			n++
+		}
+
+		checkError(writer.Flush())
+		if opt.Verbose {
+			log.Infof("%d k-mers saved to %s, %d duplicates removed", n, outFile, nDup)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(dedupCmd)
+
+	dedupCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
+}
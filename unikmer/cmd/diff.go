@@ -24,9 +24,9 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"runtime"
-	"sort"
 	"sync"
 
 	"github.com/shenwei356/unikmer"
@@ -42,22 +42,33 @@ var diffCmd = &cobra.Command{
 Attentions:
   0. The first file should be sorted.
   1. The 'canonical' flags of all files should be consistent.
-  2. By default taxids in the 2nd and later files are ignored.
+  2. By default taxids in the 2nd and later files are ignored,
+     and taxids of k-mers in the first file are kept in output.
+     Use -I/--ignore-taxid to drop them.
   3. You can switch on flag -t/--compare-taxid , and input
      files should ALL have or don't have taxid information.
      A same k-mer found but query taxid equals to target taxid,
      or query taxid is ancester of target taxid, this k-mer remains
 
 Tips:
-  1. Increasing threads number (-j/--threads) to accelerate computation
-     when dealing with lots of files, in cost of more memory occupation.
+  1. Increasing threads number (-j/--threads) to accelerate computation.
+     K-mers of the first file are partitioned into code ranges, each
+     thread handles one range, so no extra memory is needed.
+  2. Only the first file can be stdin.
+  3. If all input files are sorted, they are streamed simultaneously
+     instead of loading k-mers of the first file, which only needs a little
+     memory, and output is sorted. Use --on-disk to make sure of this for
+     huge sets: unsorted files (except the first one) are externally
+     sorted into temporary files in --tmp-dir, with at most -m/--chunk-size
+     k-mers kept in RAM, and then streamed.
+  4. In streaming mode, the output is buffered in --max-memory to record
+     the number of k-mers in the header, which is unknown (-1) for bigger
+     outputs.
 
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		opt := getOptions(cmd)
 
-		var err error
-
 		if opt.Verbose {
 			log.Info("checking input files ...")
 		}
@@ -71,12 +82,27 @@ Tips:
 		}
 
 		checkFileSuffix(extDataFile, files...)
+		setProvenance(opt, cmd, files)
 
 		var nfiles = len(files)
 
 		outFile := getFlagString(cmd, "out-prefix")
 		sortKmers := getFlagBool(cmd, "sort")
 		compareTaxid := getFlagBool(cmd, "compare-taxid")
+		opt.Partition = getFlagPartition(cmd, "partition")
+		failIfEmpty := getFlagBool(cmd, "fail-if-empty")
+		onDisk := getFlagBool(cmd, "on-disk")
+		maxBuffered := getFlagMaxBuffered(cmd, "max-memory")
+		tmpDir := getFlagString(cmd, "tmp-dir")
+		maxElem, err := ParseByteSize(getFlagString(cmd, "chunk-size"))
+		if err != nil {
+			checkError(fmt.Errorf("parsing byte size: %s", err))
+		}
+		if onDisk && maxElem <= 0 {
+			checkError(fmt.Errorf("value of -m/--chunk-size should be positive"))
+		}
+		var nResult int
+		defer exitIfEmpty(failIfEmpty, &nResult)
 
 		threads := opt.NumCPUs
 
@@ -92,10 +118,44 @@ Tips:
 		var k int = -1
 		var canonical bool
 		var hasTaxid bool
-		var ok bool
 
 		var taxondb *unikmer.Taxonomy
 
+		if !isStdout(outFile) {
+			outFile += extDataFile
+		}
+		newWriter := func(maxTaxid uint32) (*unikmer.Writer, func()) {
+			outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
+			checkError(err)
+
+			var mode uint32
+			if sortKmers {
+				mode |= unikmer.UNIK_SORTED
+			} else if opt.Compact {
+				mode |= unikmer.UNIK_COMPACT
+			}
+			if canonical {
+				mode |= unikmer.UNIK_CANONICAL
+			}
+			if hasTaxid {
+				mode |= unikmer.UNIK_INCLUDETAXID
+			}
+
+			writer, err := unikmer.NewWriter(outfh, k, mode)
+			checkError(err)
+			writer.Description = opt.Provenance
+			writer.SetMaxTaxid(maxTaxid)
+
+			return writer, func() {
+				checkError(writer.Flush())
+				outfh.Flush()
+				if gw != nil {
+					gw.Close()
+				}
+				w.Close()
+			}
+		}
+
 		// -----------------------------------------------------------------------
 
 		file := files[0]
@@ -110,6 +170,7 @@ Tips:
 
 		reader, err = unikmer.NewReader(infh)
 		checkError(err)
+		pr := newProgressReader(opt, file, reader)
 
 		if !reader.IsSorted() { // query is sorted
 			checkError(fmt.Errorf("the first file should be sorted"))
@@ -125,13 +186,113 @@ Tips:
 				}
 				taxondb = loadTaxonomy(opt, false)
 			} else {
-				log.Warningf("not taxids found in file: %s, flag -t/--compare-taxid ignored", file)
+				if opt.IgnoreTaxid {
+					log.Warningf("flag -t/--compare-taxid ignored when -I/--ignore-taxid given")
+				} else {
+					log.Warningf("not taxids found in file: %s, flag -t/--compare-taxid ignored", file)
+				}
+				compareTaxid = false
+			}
+		}
+
+		// checking other files
+		allSorted := true
+		unsorted := make(map[string]bool)
+		for _, file := range files[1:] {
+			if isStdin(file) {
+				checkError(fmt.Errorf("stdin only allowed for the first file"))
+			}
+			func() {
+				infh, r, _, err := inStream(file)
+				checkError(err)
+				defer r.Close()
+
+				reader, err := unikmer.NewReader(infh)
+				checkError(err)
+
+				if !reader.IsSorted() {
+					unsorted[file] = true
+					if !onDisk {
+						allSorted = false
+					}
+				}
+				if k != reader.K {
+					checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
+				}
+				if reader.IsCanonical() != canonical {
+					checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+				}
+				if compareTaxid && reader.HasTaxidInfo() != hasTaxid {
+					if reader.HasTaxidInfo() {
+						checkError(fmt.Errorf(`taxid information not found in previous files, but found in this: %s`, file))
+					} else {
+						checkError(fmt.Errorf(`taxid information found in previous files, but missing in this: %s`, file))
+					}
+				}
+			}()
+		}
+
+		if allSorted {
+			// unsorted files are sorted into temporary files in advance
+			sortedFiles := make(map[string]string, len(unsorted))
+			if len(unsorted) > 0 {
+				tmpDir, err = ioutil.TempDir(tmpDir, "unikmer-diff")
+				checkError(err)
+				defer os.RemoveAll(tmpDir)
+
+				for _, file := range files[1:] {
+					if !unsorted[file] || sortedFiles[file] != "" {
+						continue
+					}
+					if opt.Verbose {
+						log.Infof("sorting unsorted file: %s", file)
+					}
+					sortedFiles[file] = chunkFileName(tmpDir, len(sortedFiles)+1)
+					checkError(sortKmerFile(file, sortedFiles[file], tmpDir, int(maxElem), compareTaxid))
+				}
+			}
+
+			if opt.Verbose {
+				log.Infof("all input files are sorted, streaming them simultaneously")
+			}
+			readers := make([]*unikmer.Reader, 0, nfiles-1)
+			for _, file := range files[1:] {
+				if file == files[0] {
+					continue
+				}
+				if unsorted[file] {
+					file = sortedFiles[file]
+				}
+				infh, r, _, err := inStream(file)
+				checkError(err)
+				defer r.Close()
+
+				reader, err := unikmer.NewReader(infh)
+				checkError(err)
+				readers = append(readers, reader)
+			}
+
+			// k-mers of the first file are streamed in order
+			sortKmers = true
+			writer, closeWriter := newWriter(maxUint32N(reader.GetTaxidBytesLength())) // follow reader
+			nw := newNumberedWriter(writer, maxBuffered)
+			nResult, err = diffSorted(opt, pr, readers, compareTaxid, taxondb, nw)
+			checkError(err)
+			checkError(nw.Close())
+			pr.done()
+			closeWriter()
+			r.Close()
+
+			if opt.Verbose {
+				log.Infof("%d k-mers saved to %s", nResult, outFile)
 			}
+			return
 		}
 
 		var n0 int
 		for {
-			code, taxid, err = reader.ReadCodeWithTaxid()
+			// difference is a subset of the first file
+			code, taxid, err = opt.Partition.readCodeWithTaxid(pr)
 			if err != nil {
 				if err == io.EOF {
 					break
@@ -144,6 +305,7 @@ Tips:
 		n0 = len(mc)
 
 		r.Close()
+		pr.done()
 
 		if opt.Verbose {
 			log.Infof("%d k-mers loaded", n0)
@@ -154,39 +316,8 @@ Tips:
 				log.Infof("exporting k-mers")
 			}
 
-			if !isStdout(outFile) {
-				outFile += extDataFile
-			}
-			outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
-			checkError(err)
-			defer func() {
-				outfh.Flush()
-				if gw != nil {
-					gw.Close()
-				}
-				w.Close()
-			}()
-
-			var mode uint32
-			if sortKmers {
-				mode |= unikmer.UNIK_SORTED
-			} else if opt.Compact {
-				mode |= unikmer.UNIK_COMPACT
-			}
-			if canonical {
-				mode |= unikmer.UNIK_CANONICAL
-			}
-			if hasTaxid {
-				mode |= unikmer.UNIK_INCLUDETAXID
-			}
-
-			writer, err := unikmer.NewWriter(outfh, k, mode)
-			checkError(err)
-			writer.SetMaxTaxid(maxUint32N(reader.GetTaxidBytesLength())) // follow reader
-
-			writer.Number = 0
-			checkError(writer.WriteHeader())
-			checkError(writer.Flush())
+			_, closeWriter := newWriter(maxUint32N(reader.GetTaxidBytesLength())) // follow reader
+			closeWriter()
 
 			if opt.Verbose {
 				log.Infof("%d k-mers saved to %s", 0, outFile)
@@ -196,413 +327,384 @@ Tips:
 
 		// -----------------------------------------------------------------------
 
-		if threads > len(files)-1 {
-			threads = len(files) - 1
+		if threads > len(mc) {
+			threads = len(mc)
 		}
-		if threads < 1 {
-			threads = 1
+		if opt.Verbose {
+			log.Infof("%d workers in position", threads)
 		}
 
-		done := make(chan int)
-
-		toStop := make(chan int, threads+2)
-		doneDone := make(chan int)
-		go func() {
-			<-toStop
-			close(done)
-			doneDone <- 1
-		}()
+		mc = diffByCodeRange(opt, mc, files[1:], files[0], threads, compareTaxid, taxondb)
 
-		// ---------------
-
-		type iFile struct {
-			i    int
-			file string
+		if len(mc) == 0 {
+			if opt.Verbose {
+				log.Infof("no set difference found")
+			}
 		}
 
-		chFile := make(chan iFile, threads)
-		doneSendFile := make(chan int)
+		// k-mers of the first file and intermediate results of workers are
+		// not needed anymore, reclaim them before the slow output.
+		reader = nil
+		runtime.GC()
 
-		maps := make(map[int]map[uint64]uint32, threads)
+		// -----------------------------------------------------------------------
 
-		mapsc := make(map[int][]unikmer.CodeTaxid, threads)
-		mapsc[0] = mc
+		// output
 
-		if threads > 1 {
-			// clone maps
-			if opt.Verbose {
-				log.Infof("cloning data for parallization")
-			}
-			var wg sync.WaitGroup
-			type iMap struct {
-				i  int
-				m  map[uint64]uint32
-				mc []unikmer.CodeTaxid
-			}
-			ch := make(chan iMap, threads)
-			doneClone := make(chan int)
-			go func() {
-				for ptr := range ch {
-					mapsc[ptr.i] = ptr.mc
-				}
-				doneClone <- 1
-			}()
-			for i := 1; i < threads; i++ {
-				wg.Add(1)
-				go func(i int) {
-					mc1 := make([]unikmer.CodeTaxid, len(mc))
-					for i, ct := range mc {
-						mc1[i] = ct
-					}
-					ch <- iMap{i: i, mc: mc1}
-					wg.Done()
-				}(i)
-			}
-			wg.Wait()
-			close(ch)
-			<-doneClone
-			if opt.Verbose {
-				log.Infof("done cloning data")
-			}
+		if opt.Verbose {
+			log.Infof("exporting k-mers")
 		}
 
-		// -----------------------------------------------------------------------
+		writer, closeWriter := newWriter(opt.MaxTaxid)
+
+		// k-mers are still sorted
+		writer.Number = int64(len(mc))
+		nResult = len(mc)
+
+		for _, ct := range mc {
+			writer.WriteCodeWithTaxid(ct.Code, ct.Taxid)
+		}
+		closeWriter()
 		if opt.Verbose {
-			log.Infof("%d workers in position", threads)
+			log.Infof("%d k-mers saved to %s", len(mc), outFile)
 		}
+	},
+}
 
-		hasDiff := true
-		var wgWorkers sync.WaitGroup
-		for i := 0; i < threads; i++ { // workers
-			wgWorkers.Add(1)
+func init() {
+	RootCmd.AddCommand(diffCmd)
 
-			go func(i int) {
-				defer func() {
-					if opt.Verbose {
-						log.Infof("worker %02d: finished with %d k-mers", i, len(maps[i]))
-					}
-					wgWorkers.Done()
-				}()
+	diffCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
+	diffCmd.Flags().BoolP("sort", "s", false, helpSort)
+	diffCmd.Flags().BoolP("compare-taxid", "t", false, `take taxid into consideration. type unikmer "diff -h" for detail`)
+	diffCmd.Flags().StringP("partition", "", "", helpPartition)
+	diffCmd.Flags().BoolP("fail-if-empty", "", false, helpFailIfEmpty)
+	diffCmd.Flags().BoolP("on-disk", "", false, `make sure all files are streamed instead of loading k-mers of the first file into RAM, unsorted files are sorted into temporary files first`)
+	diffCmd.Flags().StringP("chunk-size", "m", "10M", `maximum number of k-mers kept in RAM when sorting unsorted files for --on-disk, supports K/M/G suffix`)
+	diffCmd.Flags().StringP("tmp-dir", "", "./", `directory for intermediate files of --on-disk`)
+	diffCmd.Flags().StringP("max-memory", "", "100M", helpMaxMemoryNumber)
+}
 
-				if opt.Verbose {
-					log.Infof("worker %02d: started", i)
-				}
+// diffByCodeRange computes set difference between k-mers of the first file
+// (mc, sorted) and other files.
+//
+// The code space is partitioned into ranges according to k-mers of the first
+// file, each worker owns k-mers in one range, and streams all other files
+// but only touches codes in its range. So no data is cloned, and memory
+// of each worker is bounded to len(mc)/threads. For sorted files, a worker
+// can stop reading once codes exceed its range.
+//
+// Returned k-mers remain sorted.
+func diffByCodeRange(opt *Options, mc []unikmer.CodeTaxid, files []string, file0 string,
+	threads int, compareTaxid bool, taxondb *unikmer.Taxonomy) []unikmer.CodeTaxid {
+	if len(mc) == 0 {
+		return mc
+	}
+	if threads < 1 {
+		threads = 1
+	}
+
+	parts := make([][]unikmer.CodeTaxid, threads)
+	size := (len(mc) + threads - 1) / threads
+
+	var wg sync.WaitGroup
+	var start, end int
+	for i := 0; i < threads; i++ {
+		start = i * size
+		end = start + size
+		if end > len(mc) {
+			end = len(mc)
+		}
+		if start >= end {
+			break
+		}
 
-				var code uint64
-				var qtaxid, taxid uint32
-				var ifile iFile
-				var file string
-				var infh *bufio.Reader
-				var r *os.File
-				var reader *unikmer.Reader
-				var ok bool
-				var sorted bool
-				var m1 map[uint64]uint32
-				mc1 := mapsc[i]
-				for {
-					ifile, ok = <-chFile
-					if !ok {
-						return
-					}
-					file = ifile.file
+		wg.Add(1)
+		go func(i int, part []unikmer.CodeTaxid) {
+			defer wg.Done()
+			parts[i] = diffCodeRange(opt, i, part, files, file0, compareTaxid, taxondb)
+		}(i, mc[start:end])
+	}
+	wg.Wait()
+
+	if len(parts) == 1 {
+		return parts[0]
+	}
+
+	var n int
+	for _, part := range parts {
+		n += len(part)
+	}
+	mc2 := make([]unikmer.CodeTaxid, 0, n)
+	for i, part := range parts {
+		mc2 = append(mc2, part...)
+		parts[i] = nil // just for gc
+	}
+	return mc2
+}
 
-					select {
-					case <-done:
-						return
-					default:
-					}
+// diffCodeRange removes k-mers appearing in files from part,
+// which is sorted and covers a range of [part[0].Code, part[len(part)-1].Code].
+func diffCodeRange(opt *Options, worker int, part []unikmer.CodeTaxid, files []string, file0 string,
+	compareTaxid bool, taxondb *unikmer.Taxonomy) []unikmer.CodeTaxid {
+	if opt.Verbose {
+		log.Infof("worker %02d: started with %d k-mers", worker, len(part))
+	}
+
+	var infh *bufio.Reader
+	var r *os.File
+	var reader *unikmer.Reader
+	var err error
+	var code, qCode, lo, hi uint64
+	var taxid, qtaxid uint32
+	var ok bool
+	var ii int
+	var m map[uint64]uint32
+
+	for i, file := range files {
+		if file == file0 {
+			continue
+		}
+		if len(part) == 0 {
+			break
+		}
+		lo, hi = part[0].Code, part[len(part)-1].Code
 
-					if opt.Verbose {
-						log.Infof("worker %02d: starting processing file (%d/%d): %s", i, ifile.i+1, nfiles, file)
-					}
+		infh, r, _, err = inStream(file)
+		checkError(err)
 
-					infh, r, _, err = inStream(file)
-					checkError(err)
+		reader, err = unikmer.NewReader(infh)
+		checkError(err)
 
-					reader, err = unikmer.NewReader(infh)
-					checkError(err)
+		part2 := make([]unikmer.CodeTaxid, 0, len(part))
 
-					if k != reader.K {
-						checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
-					}
-					if reader.IsCanonical() != canonical {
-						checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
-					}
-					if compareTaxid && reader.HasTaxidInfo() != hasTaxid {
-						if reader.HasTaxidInfo() {
-							checkError(fmt.Errorf(`taxid information not found in previous files, but found in this: %s`, file))
-						} else {
-							checkError(fmt.Errorf(`taxid information found in previous files, but missing in this: %s`, file))
-						}
+		if reader.IsSorted() {
+			ii = 0
+			qCode, qtaxid = part[ii].Code, part[ii].Taxid
+			for {
+				code, taxid, err = reader.ReadCodeWithTaxid()
+				if err != nil {
+					if err == io.EOF {
+						break
 					}
+					checkError(err)
+				}
+				if code < lo {
+					continue
+				}
+				if code > hi { // out of range, no need to read more
+					break
+				}
 
-					// file is sorted, so we can skip codes that are small than minCode
-					sorted = reader.IsSorted()
-
-					if !sorted {
-						if m1 == nil { // clone mc for this worker
-							m1 = make(map[uint64]uint32, len(mc))
-							for _, ct := range mc {
-								m1[ct.Code] = ct.Taxid
-							}
-							maps[i] = m1
-						}
-
-						for {
-							code, taxid, err = reader.ReadCodeWithTaxid()
-							if err != nil {
-								if err == io.EOF {
-									break
-								}
-								checkError(err)
-							}
-
-							// delete seen kmer
-							if qtaxid, ok = m1[code]; ok { // slowest part
-								if compareTaxid && (qtaxid == taxid ||
-									taxondb.LCA(taxid, qtaxid) == qtaxid) {
-									continue
-								}
-								delete(m1, code)
-							}
-						}
-
-						r.Close()
-
-						if opt.Verbose {
-							log.Infof("worker %02d: finished processing file (%d/%d): %s, %d k-mers remain", i, ifile.i+1, nfiles, file, len(m1))
-						}
-						if len(m1) == 0 {
-							hasDiff = false
-							toStop <- 1
-							return
-						}
-					} else {
-						mc2 := make([]unikmer.CodeTaxid, 0, len(mc1))
-						var qCode, code uint64
-						var qtaxid, taxid uint32
-						ii := 0
-
-						qCode = mc1[ii].Code
-						qtaxid = mc1[ii].Taxid
-						code, taxid, err = reader.ReadCodeWithTaxid()
-						if err != nil {
-							if err == io.EOF {
-								break
-							}
-							checkError(err)
-						}
-
-						for {
-							if qCode < code {
-								mc2 = append(mc2, mc1[ii])
-
-								ii++
-								if ii >= len(mc1) {
-									break
-								}
-								qCode = mc1[ii].Code
-								qtaxid = mc1[ii].Taxid
-							} else if qCode == code {
-								if compareTaxid && (qtaxid == taxid || // keep k-mer with same taxid
-									taxondb.LCA(taxid, qtaxid) == qtaxid) { // keep k-mer which is son of query
-									mc2 = append(mc2, mc1[ii])
-								}
-
-								ii++
-								if ii >= len(mc1) {
-									break
-								}
-								qCode = mc1[ii].Code
-								qtaxid = mc1[ii].Taxid
-
-								code, taxid, err = reader.ReadCodeWithTaxid()
-								if err != nil {
-									if err == io.EOF {
-										break
-									}
-									checkError(err)
-								}
-							} else {
-								code, taxid, err = reader.ReadCodeWithTaxid()
-								if err != nil {
-									if err == io.EOF {
-										break
-									}
-									checkError(err)
-								}
-							}
-						}
-						mc2 = append(mc2, mc1[ii:]...)
-
-						r.Close()
-
-						mc1 = mc2
-						if opt.Verbose {
-							log.Infof("worker %02d: finished processing file (%d/%d): %s, %d k-mers remain", i, ifile.i+1, nfiles, file, len(mc1))
-						}
-						if len(mc1) == 0 {
-							hasDiff = false
-							toStop <- 1
-							return
-						}
-
-						m1 = make(map[uint64]uint32, len(mc1))
-						for _, ct := range mc1 {
-							m1[ct.Code] = ct.Taxid
-						}
-						maps[i] = m1
+				for qCode < code {
+					part2 = append(part2, part[ii])
+					ii++
+					if ii >= len(part) {
+						break
 					}
-
+					qCode, qtaxid = part[ii].Code, part[ii].Taxid
+				}
+				if ii >= len(part) {
+					break
 				}
-			}(i)
-		}
 
-		// send file
-		go func() {
-		SENDFILE:
-			for i, file := range files[1:] {
-				if file == files[0] {
-					continue
+				for qCode == code {
+					if compareTaxid && (qtaxid == taxid || // keep k-mer with same taxid
+						taxondb.LCA(taxid, qtaxid) == qtaxid) { // keep k-mer which is son of query
+						part2 = append(part2, part[ii])
+					}
+					ii++
+					if ii >= len(part) {
+						break
+					}
+					qCode, qtaxid = part[ii].Code, part[ii].Taxid
 				}
-				select {
-				case <-done:
-					break SENDFILE
-				default:
+				if ii >= len(part) {
+					break
 				}
-
-				chFile <- iFile{i + 1, file}
 			}
-			close(chFile)
-
-			doneSendFile <- 1
-		}()
-
-		<-doneSendFile
-		wgWorkers.Wait()
-		toStop <- 1
-		<-doneDone
-
-		var m0 map[uint64]uint32
-		if !hasDiff {
-			if opt.Verbose {
-				log.Infof("no set difference found")
+			if ii < len(part) {
+				part2 = append(part2, part[ii:]...)
 			}
-			// return
 		} else {
-			if opt.Verbose {
-				log.Infof("merging results from workers")
+			if m == nil {
+				m = make(map[uint64]uint32, len(part))
+			}
+			for _, ct := range part {
+				m[ct.Code] = ct.Taxid
 			}
-			var code uint64
-			for _, m := range maps {
-				if len(m) == 0 {
-					m0 = m
-					break
-				}
 
-				if m0 == nil {
-					m0 = m
-					continue
-				}
-				for code = range m0 {
-					if _, ok = m[code]; !ok { // it's already been deleted in other m
-						delete(m0, code) // so it should be deleted
+			for {
+				code, taxid, err = reader.ReadCodeWithTaxid()
+				if err != nil {
+					if err == io.EOF {
+						break
 					}
+					checkError(err)
+				}
+				if code < lo || code > hi {
+					continue
 				}
 
-				if len(m0) == 0 {
-					break
+				// delete seen kmer
+				if qtaxid, ok = m[code]; ok {
+					if compareTaxid && (qtaxid == taxid ||
+						taxondb.LCA(taxid, qtaxid) == qtaxid) {
+						continue
+					}
+					delete(m, code)
 				}
 			}
 
-			if len(m0) == 0 {
-				if opt.Verbose {
-					log.Warningf("no set difference found")
+			for _, ct := range part {
+				if _, ok = m[ct.Code]; ok {
+					part2 = append(part2, ct)
 				}
-				// return
+			}
+			for code = range m {
+				delete(m, code)
 			}
 		}
+		r.Close()
 
-		// -----------------------------------------------------------------------
-
-		// output
+		part = part2
 
 		if opt.Verbose {
-			log.Infof("exporting k-mers")
+			log.Infof("worker %02d: finished processing file (%d/%d): %s, %d k-mers remain", worker, i+1, len(files), file, len(part))
 		}
+	}
 
-		if !isStdout(outFile) {
-			outFile += extDataFile
+	if opt.Verbose {
+		log.Infof("worker %02d: finished with %d k-mers", worker, len(part))
+	}
+	return part
+}
+
+// diffSorted computes set difference between k-mers of the first file
+// (reader0) and other files by streaming all sorted files simultaneously,
+// so memory occupation does not grow with the number of k-mers.
+// K-mers of reader0 not in the partition are skipped.
+func diffSorted(opt *Options, reader0 codeTaxidReader, readers []*unikmer.Reader,
+	compareTaxid bool, taxondb *unikmer.Taxonomy, writer codeTaxidWriter) (int, error) {
+	codes := make([]uint64, len(readers))
+	taxids := make([]uint32, len(readers))
+	eofs := make([]bool, len(readers))
+	var err error
+	for i, reader := range readers {
+		codes[i], taxids[i], err = reader.ReadCodeWithTaxid()
+		if err != nil {
+			if err != io.EOF {
+				return 0, err
+			}
+			eofs[i] = true
 		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
-		checkError(err)
-		defer func() {
-			outfh.Flush()
-			if gw != nil {
-				gw.Close()
+	}
+
+	var n, i int
+	var qCode uint64
+	var qtaxid uint32
+	var found bool
+	for {
+		qCode, qtaxid, err = opt.Partition.readCodeWithTaxid(reader0)
+		if err != nil {
+			if err == io.EOF {
+				return n, nil
 			}
-			w.Close()
-		}()
-
-		var mode uint32
-		if sortKmers {
-			mode |= unikmer.UNIK_SORTED
-		} else if opt.Compact {
-			mode |= unikmer.UNIK_COMPACT
+			return n, err
 		}
-		if canonical {
-			mode |= unikmer.UNIK_CANONICAL
+
+		found = false
+		for i = range readers {
+			for !eofs[i] && codes[i] < qCode {
+				codes[i], taxids[i], err = readers[i].ReadCodeWithTaxid()
+				if err != nil {
+					if err != io.EOF {
+						return n, err
+					}
+					eofs[i] = true
+				}
+			}
+			if eofs[i] || codes[i] != qCode {
+				continue
+			}
+			if compareTaxid && (qtaxid == taxids[i] || // keep k-mer with same taxid
+				taxondb.LCA(taxids[i], qtaxid) == qtaxid) { // keep k-mer which is son of query
+				continue
+			}
+			found = true
+			break
 		}
-		if hasTaxid {
-			mode |= unikmer.UNIK_INCLUDETAXID
+		if found {
+			continue
 		}
 
-		writer, err := unikmer.NewWriter(outfh, k, mode)
-		checkError(err)
-		writer.SetMaxTaxid(opt.MaxTaxid)
-
-		if sortKmers {
-			writer.Number = int64(len(m0))
+		if err = writer.WriteCodeWithTaxid(qCode, qtaxid); err != nil {
+			return n, err
 		}
+		n++
+	}
+}
 
-		if len(m0) == 0 {
-			writer.Number = 0
-			checkError(writer.WriteHeader())
-		} else {
-			if sortKmers {
-				codes := make([]unikmer.CodeTaxid, len(m0))
-				i := 0
-				for code, taxid := range m0 {
-					codes[i] = unikmer.CodeTaxid{Code: code, Taxid: taxid}
-					i++
-				}
-				if opt.Verbose {
-					log.Infof("sorting %d k-mers", len(codes))
-				}
-				sort.Sort(unikmer.CodeTaxidSlice(codes))
-				if opt.Verbose {
-					log.Infof("done sorting")
-				}
-				for _, codeT := range codes {
-					writer.WriteCodeWithTaxid(codeT.Code, codeT.Taxid)
-				}
-			} else {
-				for code, taxid := range m0 {
-					writer.WriteCodeWithTaxid(code, taxid)
-				}
+// sortKmerFile sorts k-mers of a binary file into outFile with a
+// SortingWriter, at most maxInMem k-mers are kept in RAM, and spilled
+// runs are saved in tmpDir. Taxids are kept only if withTaxid is true.
+func sortKmerFile(file string, outFile string, tmpDir string, maxInMem int, withTaxid bool) error {
+	infh, r, _, err := inStream(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	reader, err := unikmer.NewReader(infh)
+	if err != nil {
+		return err
+	}
+
+	w, err := os.Create(outFile)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	outfh := bufio.NewWriterSize(w, os.Getpagesize())
+
+	var mode uint32
+	if reader.IsCanonical() {
+		mode |= unikmer.UNIK_CANONICAL
+	}
+	withTaxid = withTaxid && reader.HasTaxidInfo()
+	if withTaxid {
+		mode |= unikmer.UNIK_INCLUDETAXID
+	}
+	writer, err := unikmer.NewSortingWriter(outfh, reader.K, mode, maxInMem)
+	if err != nil {
+		return err
+	}
+	writer.TmpDir = tmpDir
+	if withTaxid {
+		if err = writer.SetMaxTaxid(maxUint32N(reader.GetTaxidBytesLength())); err != nil {
+			return err
+		}
+	}
+
+	var code uint64
+	var taxid uint32
+	for {
+		code, taxid, err = reader.ReadCodeWithTaxid()
+		if err != nil {
+			if err == io.EOF {
+				break
 			}
+			return err
 		}
-		checkError(writer.Flush())
-		if opt.Verbose {
-			log.Infof("%d k-mers saved to %s", len(m0), outFile)
+		if err = writer.WriteCodeWithTaxid(code, taxid); err != nil {
+			return err
 		}
-	},
-}
-
-func init() {
-	RootCmd.AddCommand(diffCmd)
-
-	diffCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
-	diffCmd.Flags().BoolP("sort", "s", false, helpSort)
-	diffCmd.Flags().BoolP("compare-taxid", "t", false, `take taxid into consideration. type unikmer "diff -h" for detail`)
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	if err = outfh.Flush(); err != nil {
+		return err
+	}
+	return w.Close()
 }
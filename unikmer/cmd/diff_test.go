@@ -0,0 +1,353 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/shenwei356/unikmer"
+)
+
+// genDiffData writes nfiles binary files of random codes, the first one and
+// half of others are sorted, and returns sorted k-mers of the first file.
+func genDiffData(tb testing.TB, dir string, nfiles int, n int) ([]unikmer.CodeTaxid, []string) {
+	k := 15
+	rand.Seed(11)
+	files := make([]string, nfiles)
+	var mc []unikmer.CodeTaxid
+	for i := 0; i < nfiles; i++ {
+		m := make(map[uint64]struct{}, n)
+		for len(m) < n {
+			m[rand.Uint64()&unikmer.MaxCode[k]] = struct{}{}
+		}
+		codes := make([]uint64, 0, n)
+		for code := range m {
+			codes = append(codes, code)
+		}
+		sorted := i == 0 || i%2 == 1
+		if sorted {
+			sort.Sort(unikmer.CodeSlice(codes))
+		}
+		if i == 0 {
+			mc = make([]unikmer.CodeTaxid, len(codes))
+			for j, code := range codes {
+				mc[j] = unikmer.CodeTaxid{Code: code}
+			}
+		}
+
+		files[i] = filepath.Join(dir, fmt.Sprintf("%d.unik", i))
+		outfh, gw, w, err := outStream(files[i], true, flate.BestSpeed)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		var mode uint32
+		if sorted {
+			mode |= unikmer.UNIK_SORTED
+		}
+		writer, err := unikmer.NewWriter(outfh, k, mode)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		for _, code := range codes {
+			writer.WriteCode(code)
+		}
+		if err = writer.Flush(); err != nil {
+			tb.Fatal(err)
+		}
+		outfh.Flush()
+		gw.Close()
+		w.Close()
+	}
+	return mc, files
+}
+
+// diffByCloning is the previous strategy: every worker owns a full copy of
+// k-mers of the first file and handles a subset of other files,
+// results of all workers are intersected in the end.
+func diffByCloning(opt *Options, mc []unikmer.CodeTaxid, files []string, file0 string, threads int) []unikmer.CodeTaxid {
+	if threads > len(files) {
+		threads = len(files)
+	}
+	results := make([][]unikmer.CodeTaxid, threads)
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		mc1 := make([]unikmer.CodeTaxid, len(mc))
+		copy(mc1, mc)
+
+		_files := make([]string, 0, len(files)/threads+1)
+		for j := i; j < len(files); j += threads {
+			_files = append(_files, files[j])
+		}
+
+		wg.Add(1)
+		go func(i int, mc1 []unikmer.CodeTaxid, files []string) {
+			defer wg.Done()
+			results[i] = diffCodeRange(opt, i, mc1, files, file0, false, nil)
+		}(i, mc1, _files)
+	}
+	wg.Wait()
+
+	m := make(map[uint64]int, len(mc))
+	for _, result := range results {
+		for _, ct := range result {
+			m[ct.Code]++
+		}
+	}
+	mc2 := make([]unikmer.CodeTaxid, 0, len(results[0]))
+	for _, ct := range results[0] {
+		if m[ct.Code] == threads {
+			mc2 = append(mc2, ct)
+		}
+	}
+	return mc2
+}
+
+func TestDiffByCodeRange(t *testing.T) {
+	mc, files := genDiffData(t, t.TempDir(), 5, 20000)
+	opt := &Options{}
+
+	expected := diffByCodeRange(opt, mc, files[1:], files[0], 1, false, nil)
+	for _, threads := range []int{2, 3, 4, 8} {
+		result := diffByCodeRange(opt, mc, files[1:], files[0], threads, false, nil)
+		if len(result) != len(expected) {
+			t.Fatalf("threads %d: %d k-mers expected, %d returned", threads, len(expected), len(result))
+		}
+		for i := range result {
+			if result[i] != expected[i] {
+				t.Fatalf("threads %d: unexpected k-mer #%d: %d != %d", threads, i, result[i].Code, expected[i].Code)
+			}
+		}
+	}
+
+	result := diffByCloning(opt, mc, files[1:], files[0], 2)
+	if len(result) != len(expected) {
+		t.Errorf("cloning: %d k-mers expected, %d returned", len(expected), len(result))
+	}
+
+	// brute force
+	m := make(map[uint64]struct{}, len(mc))
+	for _, ct := range mc {
+		m[ct.Code] = struct{}{}
+	}
+	for _, file := range files[1:] {
+		codes, err := readCodes(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, code := range codes {
+			delete(m, code)
+		}
+	}
+	if len(m) != len(expected) {
+		t.Errorf("brute force: %d k-mers expected, %d returned", len(m), len(expected))
+	}
+}
+
+func TestDiffSorted(t *testing.T) {
+	mc, files := genDiffData(t, t.TempDir(), 5, 20000)
+	opt := &Options{}
+
+	// only sorted files
+	sortedFiles := []string{files[1], files[3]}
+	expected := diffByCodeRange(opt, mc, sortedFiles, files[0], 1, false, nil)
+
+	open := func(file string) *unikmer.Reader {
+		infh, r, _, err := inStream(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { r.Close() })
+		reader, err := unikmer.NewReader(infh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reader
+	}
+	readers := make([]*unikmer.Reader, len(sortedFiles))
+	for i, file := range sortedFiles {
+		readers[i] = open(file)
+	}
+
+	var buf bytes.Buffer
+	writer, err := unikmer.NewWriter(&buf, 15, unikmer.UNIK_SORTED)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := diffSorted(opt, open(files[0]), readers, false, nil, writer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n != len(expected) {
+		t.Fatalf("%d k-mers expected, %d returned", len(expected), n)
+	}
+
+	reader, err := unikmer.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range expected {
+		code, err := reader.ReadCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != expected[i].Code {
+			t.Fatalf("unexpected k-mer #%d: %d != %d", i, code, expected[i].Code)
+		}
+	}
+}
+
+func TestDiffSortedOnDisk(t *testing.T) {
+	dir := t.TempDir()
+	mc, files := genDiffData(t, dir, 5, 20000)
+	opt := &Options{}
+
+	expected := diffByCodeRange(opt, mc, files[1:], files[0], 1, false, nil)
+
+	// unsorted files are sorted with tiny chunks to force spilling
+	inputs := make([]string, len(files)-1)
+	for i, file := range files[1:] {
+		inputs[i] = file
+		if i%2 == 0 {
+			continue
+		}
+		inputs[i] = filepath.Join(dir, fmt.Sprintf("sorted_%d.unik", i))
+		if err := sortKmerFile(file, inputs[i], dir, 1000, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	open := func(file string) *unikmer.Reader {
+		infh, r, _, err := inStream(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { r.Close() })
+		reader, err := unikmer.NewReader(infh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reader
+	}
+	readers := make([]*unikmer.Reader, len(inputs))
+	for i, file := range inputs {
+		readers[i] = open(file)
+		if !readers[i].IsSorted() {
+			t.Fatalf("file not sorted: %s", file)
+		}
+	}
+
+	var buf bytes.Buffer
+	writer, err := unikmer.NewWriter(&buf, 15, unikmer.UNIK_SORTED)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := diffSorted(opt, open(files[0]), readers, false, nil, writer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(expected) {
+		t.Fatalf("%d k-mers expected, %d returned", len(expected), n)
+	}
+}
+
+func TestDiffSortedNumber(t *testing.T) {
+	dir := t.TempDir()
+	_, files := genDiffData(t, dir, 4, 2000)
+	sortedFiles := []string{files[0], files[1], files[3]}
+
+	for _, maxMem := range []string{"100M", "1K"} {
+		outPrefix := filepath.Join(dir, "diff")
+		if err := executeRootCmd(t, append([]string{"diff", "--max-memory", maxMem, "-o", outPrefix}, sortedFiles...)...); err != nil {
+			t.Fatal(err)
+		}
+
+		codes, err := readCodes(outPrefix + extDataFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		infh, r, _, err := inStream(outPrefix + extDataFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, err := unikmer.NewReader(infh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Close()
+
+		expected := int64(len(codes))
+		if maxMem == "1K" { // too many k-mers to buffer
+			expected = -1
+		}
+		if reader.Number != expected {
+			t.Errorf("max-memory %s: number in header %d, expected %d", maxMem, reader.Number, expected)
+		}
+	}
+}
+
+func readCodes(file string) ([]uint64, error) {
+	infh, r, _, err := inStream(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	reader, err := unikmer.NewReader(infh)
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]uint64, 0, 1024)
+	for {
+		code, err := reader.ReadCode()
+		if err != nil {
+			break
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func BenchmarkDiffByCodeRange(b *testing.B) {
+	mc, files := genDiffData(b, b.TempDir(), 9, 200000)
+	opt := &Options{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		diffByCodeRange(opt, mc, files[1:], files[0], 4, false, nil)
+	}
+}
+
+func BenchmarkDiffByCloning(b *testing.B) {
+	mc, files := genDiffData(b, b.TempDir(), 9, 200000)
+	opt := &Options{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		diffByCloning(opt, mc, files[1:], files[0], 4)
+	}
+}
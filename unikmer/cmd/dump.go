@@ -33,15 +33,23 @@ import (
 
 // dumpCmd represents
 var dumpCmd = &cobra.Command{
-	Use:   "dump",
-	Short: "Convert plain k-mer text to binary format",
+	Use:     "dump",
+	Aliases: []string{"import"},
+	Short:   "Convert plain k-mer text to binary format",
 	Long: `Convert plain k-mer text to binary format
 
-
 Attentions:
   1. Input should be one k-mer per line, or tab-delimited two columns
      with a k-mer and it's taxid.
   2. You can also assign a global taxid with flag -t/--taxid.
+  3. K is decided by the first k-mer, or flag --kmer-len.
+  4. Lines with k-mers of different length or bases other than ACGT
+     are invalid, which are fatal unless --skip-invalid is given.
+
+Tips:
+  1. Use --codes to read codes (e.g., output of "unikmer encode") instead
+     of k-mers, hexadecimal codes should have the prefix "0x".
+     Flag --kmer-len is needed then.
 
 `,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -68,11 +76,21 @@ Attentions:
 		canonicalOnly := getFlagBool(cmd, "canonical-only")
 		sortedKmers := getFlagBool(cmd, "sorted")
 		taxid := getFlagUint32(cmd, "taxid")
+		kmerLen := getFlagNonNegativeInt(cmd, "kmer-len")
+		isCode := getFlagBool(cmd, "codes")
+		skipInvalid := getFlagBool(cmd, "skip-invalid")
+
+		if kmerLen > 32 {
+			checkError(fmt.Errorf("k > 32 not supported"))
+		}
+		if isCode && kmerLen == 0 {
+			checkError(fmt.Errorf("flag --kmer-len needed when given --codes"))
+		}
 
 		if !isStdout(outFile) {
 			outFile += extDataFile
 		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -90,101 +108,140 @@ Attentions:
 		}
 
 		var k int = -1
+		if kmerLen > 0 {
+			k = kmerLen
+		}
 		var l int
 		var reader *breader.BufferedReader
 		var chunk breader.Chunk
 		var data interface{}
 		var line string
 		var kcode, kcodeC unikmer.KmerCode
+		var code uint64
 		var ok bool
-		var n int64
+		var n, nInvalid int64
 
 		var includeTaxid bool
 		var items []string
 		var tmp uint64
 		var _taxid uint32
-		var once bool = true
+		var first bool = true
 		hasGlobalTaxid := taxid > 0
+		if hasGlobalTaxid {
+			_taxid = taxid
+		}
 
-		for _, file := range files {
-			reader, err = breader.NewDefaultBufferedReader(file)
+		newWriter := func() {
+			var mode uint32
+			if sortedKmers {
+				mode |= unikmer.UNIK_SORTED
+			} else if opt.Compact {
+				mode |= unikmer.UNIK_COMPACT
+			}
+			if canonical || canonicalOnly {
+				mode |= unikmer.UNIK_CANONICAL
+			}
+			if includeTaxid {
+				mode |= unikmer.UNIK_INCLUDETAXID
+			}
+			writer, err = unikmer.NewWriter(outfh, k, mode)
 			checkError(err)
+			writer.SetMaxTaxid(opt.MaxTaxid)
+			if !includeTaxid && hasGlobalTaxid {
+				checkError(writer.SetGlobalTaxid(taxid))
+			}
+		}
 
+		var file string
+		var lineNum int
+		// invalid lines are fatal unless --skip-invalid given
+		reportInvalid := func(reason string) {
+			if !skipInvalid {
+				checkError(fmt.Errorf("invalid line %d in %s: %s", lineNum, file, reason))
+			}
+			log.Warningf("invalid line %d in %s skipped: %s", lineNum, file, reason)
+			nInvalid++
+		}
+
+		// empty lines are kept for counting line numbers
+		parseFunc := func(line string) (interface{}, bool, error) {
+			return strings.TrimRight(line, "\r\n"), true, nil
+		}
+
+		for _, file = range files {
+			reader, err = breader.NewBufferedReader(file, opt.NumCPUs, 100, parseFunc)
+			checkError(err)
+
+			lineNum = 0
 			for chunk = range reader.Ch {
 				checkError(chunk.Err)
 				for _, data = range chunk.Data {
 					line = data.(string)
-					l = len(line)
+					lineNum++
 
-					if l == 0 {
+					if len(line) == 0 {
 						continue
 					}
 
-					if k == -1 {
+					if first {
 						if strings.Index(line, "\t") > 0 {
 							includeTaxid = true
 							if hasGlobalTaxid {
 								log.Warningf("since input has more than one column, global taxid is ignored")
 							}
-						} else if hasGlobalTaxid {
-							_taxid = taxid
-							k = l
-						} else {
-							k = l
 						}
-					} else if !includeTaxid && l != k {
-						checkError(fmt.Errorf("K-mer length mismatch, previous: %d, current: %d. %s", k, l, line))
+						first = false
 					}
 
 					if includeTaxid {
 						items = strings.Split(line, "\t")
 						if len(items) < 2 {
-							checkError(fmt.Errorf("inconsistant two column tabular format"))
+							reportInvalid("inconsistant two column tabular format")
+							continue
 						}
 						line = items[0]
-						l = len(line)
-
-						if once {
-							k = len(line)
-							once = false
-						} else {
-							if l != k {
-								checkError(fmt.Errorf("K-mer length mismatch, previous: %d, current: %d. %s", k, l, line))
-							}
-						}
 
 						tmp, err = strconv.ParseUint(items[1], 10, 32)
 						if err != nil {
-							checkError(fmt.Errorf("query taxid (2nd column) should be positive integer in range of [1, %d]: %s", maxUint32, items[1]))
+							reportInvalid(fmt.Sprintf("taxid (2nd column) should be positive integer in range of [1, %d]: %s", maxUint32, items[1]))
+							continue
 						}
-
 						_taxid = uint32(tmp)
 					}
 
-					if writer == nil {
-						var mode uint32
-						if sortedKmers {
-							mode |= unikmer.UNIK_SORTED
-						} else if opt.Compact {
-							mode |= unikmer.UNIK_COMPACT
+					if isCode {
+						code, err = parseCode(line)
+						if err != nil {
+							reportInvalid(fmt.Sprintf("code should be non-negative integer: %s", line))
+							continue
 						}
-						if canonical || canonicalOnly {
-							mode |= unikmer.UNIK_CANONICAL
+						if code > unikmer.MaxCode[k] {
+							reportInvalid(fmt.Sprintf("code overflows for k=%d (max: %d): %s", k, unikmer.MaxCode[k], line))
+							continue
 						}
-						if includeTaxid {
-							mode |= unikmer.UNIK_INCLUDETAXID
+						kcode = unikmer.KmerCode{Code: code, K: k}
+					} else {
+						l = len(line)
+						if k == -1 {
+							k = l
+						} else if l != k {
+							reportInvalid(fmt.Sprintf("K-mer length mismatch, expected: %d, current: %d. %s", k, l, line))
+							continue
 						}
-						writer, err = unikmer.NewWriter(outfh, l, mode)
-						writer.SetMaxTaxid(opt.MaxTaxid)
-						checkError(err)
-						if !includeTaxid && hasGlobalTaxid {
-							checkError(writer.SetGlobalTaxid(taxid))
+						if !onlyACGT([]byte(line)) {
+							reportInvalid(fmt.Sprintf("bases other than ACGT found: %s", line))
+							continue
+						}
+
+						kcode, err = unikmer.NewKmerCode([]byte(line))
+						if err != nil {
+							reportInvalid(fmt.Sprintf("fail to encode '%s': %s", line, err))
+							continue
 						}
 					}
 
-					kcode, err = unikmer.NewKmerCode([]byte(line))
-					if err != nil {
-						checkError(fmt.Errorf("fail to encode '%s': %s", line, err))
+					if writer == nil {
+						newWriter()
 					}
 
 					if canonicalOnly {
@@ -217,8 +274,17 @@ Attentions:
 			}
 		}
 
+		if writer == nil {
+			if k <= 0 {
+				checkError(fmt.Errorf("no valid k-mers found, K can not be decided, please use --kmer-len"))
+			}
+			newWriter()
+		}
 		checkError(writer.Flush())
 		if opt.Verbose {
+			if nInvalid > 0 {
+				log.Infof("%d invalid lines skipped", nInvalid)
+			}
 			log.Infof("%d unique k-mers saved to %s", n, outFile)
 		}
 	},
@@ -233,4 +299,7 @@ func init() {
 	dumpCmd.Flags().BoolP("canonical-only", "k", false, "only save the canonical k-mers. This flag overides -K/--canonical")
 	dumpCmd.Flags().BoolP("sorted", "s", false, "input k-mers are sorted")
 	dumpCmd.Flags().Uint32P("taxid", "t", 0, "taxid")
+	dumpCmd.Flags().IntP("kmer-len", "", 0, "k-mer length, 0 for deciding from the first k-mer")
+	dumpCmd.Flags().BoolP("codes", "", false, "input are codes instead of k-mers")
+	dumpCmd.Flags().BoolP("skip-invalid", "", false, "skip invalid lines instead of exiting")
 }
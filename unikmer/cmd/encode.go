@@ -36,6 +36,15 @@ var encodeCmd = &cobra.Command{
 	Short: "Encode plain k-mer text to integer",
 	Long: `Encode plain k-mer text to integer
 
+K-mers are read from files (one k-mer per line, stdin by default),
+or given by -q/--query. Codes are the same as those in binary files.
+
+Tips:
+  1. Use -x/--hex to output codes in hexadecimal, which can be decoded
+     by "unikmer decode" too.
+  2. Use -a/--all to output the parsed k-mer (the canonical one with -K),
+     code and bits along with the original k-mer.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		opt := getOptions(cmd)
@@ -43,21 +52,26 @@ var encodeCmd = &cobra.Command{
 
 		var err error
 
-		if opt.Verbose {
-			log.Info("checking input files ...")
-		}
-		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
-		if opt.Verbose {
-			if len(files) == 1 && isStdin(files[0]) {
-				log.Info("no files given, reading from stdin")
-			} else {
-				log.Infof("%d input file(s) given", len(files))
-			}
-		}
-
 		outFile := getFlagString(cmd, "out-file")
 		all := getFlagBool(cmd, "all")
 		canonical := getFlagBool(cmd, "canonical")
+		queries := getFlagStringSlice(cmd, "query")
+		hex := getFlagBool(cmd, "hex")
+
+		var files []string
+		if len(queries) == 0 || len(args) > 0 || getFlagString(cmd, "infile-list") != "" {
+			if opt.Verbose {
+				log.Info("checking input files ...")
+			}
+			files = getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+			if opt.Verbose {
+				if len(files) == 1 && isStdin(files[0]) {
+					log.Info("no files given, reading from stdin")
+				} else {
+					log.Infof("%d input file(s) given", len(files))
+				}
+			}
+		}
 
 		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
 		checkError(err)
@@ -74,9 +88,43 @@ var encodeCmd = &cobra.Command{
 		var reader *breader.BufferedReader
 		var chunk breader.Chunk
 		var data interface{}
-		var line string
 		var kcode unikmer.KmerCode
 
+		format := "%d"
+		if hex {
+			format = "0x%x"
+		}
+
+		encode := func(line string) {
+			l = len(line)
+
+			if l == 0 {
+				return
+			} else if k == -1 {
+				k = l
+			} else if l != k {
+				checkError(fmt.Errorf("K-mer length mismatch, previous: %d, current: %d. %s", k, l, line))
+			}
+
+			kcode, err = unikmer.NewKmerCode([]byte(line))
+			if err != nil {
+				checkError(fmt.Errorf("fail to encode '%s': %s", line, err))
+			}
+			if canonical {
+				kcode = kcode.Canonical()
+			}
+
+			if all {
+				outfh.WriteString(fmt.Sprintf("%s\t%s\t"+format+"\t%s\n", line, kcode.String(), kcode.Code, kcode.BitsString()))
+			} else {
+				outfh.WriteString(fmt.Sprintf(format+"\n", kcode.Code))
+			}
+		}
+
+		for _, query := range queries {
+			encode(query)
+		}
+
 		for _, file := range files {
 			reader, err = breader.NewDefaultBufferedReader(file)
 			checkError(err)
@@ -84,30 +132,7 @@ var encodeCmd = &cobra.Command{
 			for chunk = range reader.Ch {
 				checkError(chunk.Err)
 				for _, data = range chunk.Data {
-					line = data.(string)
-					l = len(line)
-
-					if l == 0 {
-						continue
-					} else if k == -1 {
-						k = l
-					} else if l != k {
-						checkError(fmt.Errorf("K-mer length mismatch, previous: %d, current: %d. %s", k, l, line))
-					}
-
-					kcode, err = unikmer.NewKmerCode([]byte(line))
-					if err != nil {
-						checkError(fmt.Errorf("fail to encode '%s': %s", line, err))
-					}
-					if canonical {
-						kcode = kcode.Canonical()
-					}
-
-					if all {
-						outfh.WriteString(fmt.Sprintf("%s\t%s\t%d\t%s\n", line, kcode.String(), kcode.Code, kcode.BitsString()))
-					} else {
-						outfh.WriteString(fmt.Sprintf("%d\n", kcode.Code))
-					}
+					encode(data.(string))
 				}
 			}
 		}
@@ -121,4 +146,6 @@ func init() {
 	encodeCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
 	encodeCmd.Flags().BoolP("all", "a", false, `output all data: orginial k-mer, parsed k-mer, encoded integer, encode bits`)
 	encodeCmd.Flags().BoolP("canonical", "K", false, "keep the canonical k-mers")
+	encodeCmd.Flags().StringSliceP("query", "q", []string{""}, `k-mers to encode (multiple values delimted by comma supported), files are not read if no files given`)
+	encodeCmd.Flags().BoolP("hex", "x", false, "output codes in hexadecimal")
 }
@@ -0,0 +1,142 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// evalCmd represents
+var evalCmd = &cobra.Command{
+	Use:   "eval",
+	Short: "Evaluate k-mers of binary files against a truth set",
+	Long: `Evaluate k-mers of binary files against a truth set
+
+Every test file is compared with the truth file by merging the two sorted
+files in streaming, and precision, recall and F1 score are reported.
+
+Output format (tab-delimited):
+  1. file, test file
+  2. tp, true positives, number of k-mers in both files
+  3. fp, false positives, number of k-mers only in test file
+  4. fn, false negatives, number of k-mers only in truth file
+  5. precision, tp / (tp + fp)
+  6. recall, tp / (tp + fn)
+  7. f1, 2 * precision * recall / (precision + recall)
+
+Attentions:
+  1. All input files should be sorted, you can use 'unikmer sort -u -m 100M'.
+  2. K and 'canonical' flags of all files should be consistent.
+  3. Taxids are ignored.
+  4. Reading from stdin is not supported.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		truthFile := getFlagString(cmd, "truth")
+		if truthFile == "" {
+			checkError(fmt.Errorf("flag -t/--truth needed"))
+		}
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			log.Infof("%d input file(s) given", len(files))
+		}
+
+		checkFileSuffix(extDataFile, append([]string{truthFile}, files...)...)
+
+		outFile := getFlagString(cmd, "out-file")
+
+		// checking all files before computing
+		checkSortedFiles(append([]string{truthFile}, files...))
+
+		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		ratio := func(a, b int64) float64 {
+			if b == 0 {
+				return 0
+			}
+			return float64(a) / float64(b)
+		}
+
+		outfh.WriteString("file\ttp\tfp\tfn\tprecision\trecall\tf1\n")
+		var tp, fp, fn int64
+		var precision, recall, f1 float64
+		for i, file := range files {
+			if opt.Verbose {
+				log.Infof("processing file (%d/%d): %s", i+1, len(files), file)
+			}
+
+			func() {
+				readers := make([]*unikmer.Reader, 2)
+				for x, _file := range []string{truthFile, file} {
+					infh, r, _, err := inStream(_file)
+					checkError(err)
+					defer r.Close()
+
+					readers[x], err = unikmer.NewReader(infh)
+					checkError(err)
+				}
+
+				fn, tp, fp, err = unikmer.OverlapSize(readers[0], readers[1])
+				if err != nil {
+					checkError(fmt.Errorf("%s: %s", file, err))
+				}
+			}()
+
+			precision = ratio(tp, tp+fp)
+			recall = ratio(tp, tp+fn)
+			f1 = 0
+			if precision+recall > 0 {
+				f1 = 2 * precision * recall / (precision + recall)
+			}
+			outfh.WriteString(fmt.Sprintf("%s\t%d\t%d\t%d\t%.6f\t%.6f\t%.6f\n",
+				file, tp, fp, fn, precision, recall, f1))
+			outfh.Flush()
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(evalCmd)
+
+	evalCmd.Flags().StringP("truth", "t", "", "truth binary file (sorted)")
+	evalCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+}
@@ -0,0 +1,177 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// filterTaxidCmd represents
+var filterTaxidCmd = &cobra.Command{
+	Use:   "filter-taxid",
+	Short: "Filter k-mers by taxids in given clades",
+	Long: `Filter k-mers by taxids in given clades
+
+K-mers with taxids in the subtrees of any given taxids (including the
+taxids themselves) are kept, or dropped with -v/--invert.
+
+Attentions:
+  1. Taxids should be included in the input file.
+  2. Merged taxids are replaced by new ones before checking.
+  3. K-mers with taxids not found in the taxonomy are not in any clade.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			if len(files) == 1 && isStdin(files[0]) {
+				log.Info("no files given, reading from stdin")
+			} else {
+				log.Infof("%d input file(s) given", len(files))
+			}
+		}
+
+		if len(files) > 1 {
+			checkError(fmt.Errorf("no more than one file should be given"))
+		}
+
+		checkFileSuffix(extDataFile, files...)
+		setProvenance(opt, cmd, files)
+
+		outFile := getFlagString(cmd, "out-prefix")
+		invert := getFlagBool(cmd, "invert")
+
+		clades := make([]uint32, 0, 8)
+		for _, s := range getFlagCommaSeparatedStrings(cmd, "taxids") {
+			if s == "" {
+				continue
+			}
+			taxid, err := strconv.ParseUint(s, 10, 32)
+			if err != nil {
+				checkError(fmt.Errorf("invalid taxid: %s", s))
+			}
+			clades = append(clades, uint32(taxid))
+		}
+		if len(clades) == 0 {
+			checkError(fmt.Errorf("flag -t/--taxids needed"))
+		}
+
+		file := files[0]
+		infh, r, _, err := inStream(file)
+		checkError(err)
+		defer r.Close()
+
+		reader, err := unikmer.NewReader(infh)
+		checkError(err)
+
+		if !reader.HasTaxidInfo() {
+			checkError(fmt.Errorf("no taxids found in file: %s", file))
+		}
+
+		taxondb := loadTaxonomy(opt, false)
+		for _, clade := range clades {
+			if !taxondb.IsDescendantOf(clade, clade) {
+				checkError(fmt.Errorf("taxid not found in taxonomy: %d", clade))
+			}
+		}
+
+		if !isStdout(outFile) {
+			outFile += extDataFile
+		}
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		writer, err := unikmer.NewWriter(outfh, reader.K, reader.Flag)
+		checkError(err)
+		writer.Description = opt.Provenance
+		writer.SetMaxTaxid(maxUint32N(reader.GetTaxidBytesLength())) // follow reader
+		if reader.HasGlobalTaxid() {
+			checkError(writer.SetGlobalTaxid(reader.GetGlobalTaxid()))
+		}
+
+		// taxid -> in any clade, k-mers often share taxids
+		inClades := make(map[uint32]bool, 1024)
+		var code uint64
+		var taxid uint32
+		var rc bool
+		var count uint32
+		var in, ok bool
+		var n int64
+		for {
+			code, rc, count, taxid, err = reader.ReadRecord()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+			}
+
+			if in, ok = inClades[taxid]; !ok {
+				for _, clade := range clades {
+					if in = taxondb.IsDescendantOf(taxid, clade); in {
+						break
+					}
+				}
+				inClades[taxid] = in
+			}
+
+			if in == invert {
+				continue
+			}
+
+			n++
+			writer.WriteRecord(code, rc, count, taxid) // not need to check err
+		}
+
+		checkError(writer.Flush())
+		if opt.Verbose {
+			log.Infof("%d k-mers saved to %s", n, outFile)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(filterTaxidCmd)
+
+	filterTaxidCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
+	filterTaxidCmd.Flags().StringP("taxids", "t", "", `taxids of clades, comma-separated`)
+	filterTaxidCmd.Flags().BoolP("invert", "v", false, `invert result, i.e., drop k-mers in the clades`)
+}
@@ -24,6 +24,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"runtime"
 
@@ -38,7 +39,24 @@ var filterCmd = &cobra.Command{
 	Long: `Filter low-complexity k-mers (experimental)
 
 Attentions:
-  1. This command only detects single base repeat now.
+  1. This command detects single base repeats and short tandem repeats,
+     e.g., (AT)n and (CAG)n, with periods given by -p/--period.
+  2. For every period p, every base is scored by comparing with the base
+     p positions before it, bases same as those score --match-score,
+     and others score --mismatch-score. The first base scores 1,
+     and the next p-1 bases with nothing to compare with score 0.
+     K-mers with total score of any window >= threshold for any period
+     are filtered.
+  3. With --circular, a k-mer is treated as a circle, i.e., the first base
+     is compared with the last one, and windows wrap around the end.
+     For emitting k-mers spanning the origin of circular genomes,
+     use 'unikmer count --circular'.
+  4. With --max-run L, k-mers are filtered simply by the longest single-base
+     run, i.e., k-mers with any run longer than L are filtered,
+     and options of scoring are ignored.
+  5. With --entropy, k-mers are filtered by Shannon entropy of base
+     composition, i.e., k-mers with entropy < --min-entropy (in bits,
+     0 to 2) are filtered, and options of scoring are ignored.
 
 `,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -64,16 +82,33 @@ Attentions:
 		}
 
 		checkFileSuffix(extDataFile, files...)
+		setProvenance(opt, cmd, files)
 
 		outFile := getFlagString(cmd, "out-prefix")
 		threshold := getFlagNonNegativeInt(cmd, "threshold")
 		invert := getFlagBool(cmd, "invert")
 		window := getFlagPositiveInt(cmd, "window")
+		matchScore := getFlagInt(cmd, "match-score")
+		mismatchScore := getFlagInt(cmd, "mismatch-score")
+		circular := getFlagBool(cmd, "circular")
+		maxRun := getFlagNonNegativeInt(cmd, "max-run")
+		periods := getFlagCommaSeparatedInts(cmd, "period")
+		for _, p := range periods {
+			if p <= 0 {
+				checkError(fmt.Errorf("value of flag -p/--period should be positive integers"))
+			}
+		}
+		periods = uniqInts(periods)
+		byEntropy := getFlagBool(cmd, "entropy")
+		minEntropy := getFlagNonNegativeFloat64(cmd, "min-entropy")
+		if byEntropy && maxRun > 0 {
+			checkError(fmt.Errorf("flag --entropy and -r/--max-run are incompatible"))
+		}
 
 		if !isStdout(outFile) {
 			outFile += extDataFile
 		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -90,6 +125,8 @@ Attentions:
 		var reader *unikmer.Reader
 		var code uint64
 		var taxid uint32
+		var rc bool
+		var count uint32
 		var k int = -1
 		var canonical bool
 		var flag int
@@ -122,6 +159,7 @@ Attentions:
 
 					writer, err = unikmer.NewWriter(outfh, k, reader.Flag)
 					checkError(err)
+					writer.Description = opt.Provenance
 					writer.SetMaxTaxid(maxUint32N(reader.GetTaxidBytesLength())) // follow reader
 				} else {
 					if k != reader.K {
@@ -133,7 +171,7 @@ Attentions:
 				}
 
 				for {
-					code, taxid, err = reader.ReadCodeWithTaxid()
+					code, rc, count, taxid, err = reader.ReadRecord()
 					if err != nil {
 						if err == io.EOF {
 							break
@@ -141,7 +179,13 @@ Attentions:
 						checkError(err)
 					}
 
-					hit = filterCode(code, k, threshold, window, scores)
+					if byEntropy {
+						hit = entropyCode(code, k) < minEntropy
+					} else if maxRun > 0 {
+						hit = longestRun(code, k) > maxRun || circular && circularRun(code, k) > maxRun
+					} else {
+						hit = filterCode(code, k, threshold, window, matchScore, mismatchScore, circular, periods, scores)
+					}
 
 					if invert {
 						if !hit {
@@ -152,7 +196,7 @@ Attentions:
 					}
 
 					n++
-					writer.WriteCodeWithTaxid(code, taxid) // not need to check err
+					writer.WriteRecord(code, rc, count, taxid) // not need to check err
 				}
 
 				return flagContinue
@@ -179,46 +223,137 @@ func init() {
 	filterCmd.Flags().IntP("threshold", "t", 14, `score threshold for filter`)
 	filterCmd.Flags().IntP("window", "w", 10, `window size for checking score`)
 	filterCmd.Flags().BoolP("invert", "v", false, `invert result, i.e., output low-complexity k-mers`)
+	filterCmd.Flags().IntP("match-score", "M", 2, `score for a base same as the one a period before`)
+	filterCmd.Flags().IntP("mismatch-score", "N", -1, `score for a base different from the one a period before`)
+	filterCmd.Flags().StringP("period", "p", "1,2,3", `periods of repeats to detect, comma-separated, 1 for single base repeats`)
+	filterCmd.Flags().BoolP("circular", "", false, `treat k-mers as circles, i.e., windows wrap around the end`)
+	filterCmd.Flags().IntP("max-run", "r", 0, `filter k-mers with any single-base run longer than this, instead of scoring. 0 for disable`)
+	filterCmd.Flags().BoolP("entropy", "", false, `filter k-mers by Shannon entropy of base composition, instead of scoring`)
+	filterCmd.Flags().Float64P("min-entropy", "", 1.5, `minimum entropy (bits) of k-mers to keep, for --entropy`)
+}
+
+// firstBaseScore is the score of the first scored base, which has no
+// preceding base to compare with.
+const firstBaseScore = 1
+
+// filterCode tells whether a k-mer is of low complexity, i.e.,
+// the total score of any window reaches the threshold for any period.
+// For circular k-mers, windows wrap around the end.
+// scores should have a length of k.
+func filterCode(code uint64, k int, threshold int, window int, matchScore int, mismatchScore int, circular bool, periods []int, scores []int) bool {
+	if window > k {
+		window = k
+	}
+	end := k
+	if circular && window < k {
+		end = k + window - 1 // windows ending at k, ..., k+window-2 wrap around
+	}
+
+	var s int
+	for _, period := range periods {
+		scoreBases(code, k, period, matchScore, mismatchScore, circular, scores)
+
+		// check score in sliding window
+		s = 0
+		for j := 0; j < window; j++ {
+			s += scores[j]
+		}
+		if s >= threshold {
+			return true
+		}
+		for i := window; i < end; i++ { // slide to the window ending at i
+			s = s - scores[i-window] + scores[i%k]
+			if s >= threshold {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-func filterCode(code uint64, k int, threshold int, window int, scores []int) bool {
-	// code0 := code
-	// compute scores
-	var last, c uint64
-	last = 356
+// scoreBases scores every base of a k-mer by comparing with the base
+// period positions before it, period 1 for single base repeats.
+// Bases are scored from the lowest 2 bits, i.e., the last base of the k-mer,
+// so scores[0] is for the last base and equals firstBaseScore,
+// and scores[1:period] are 0, unless the k-mer is circular and longer than
+// the period, where the bases are compared with ones wrapping around the end.
+func scoreBases(code uint64, k int, period int, matchScore int, mismatchScore int, circular bool, scores []int) {
+	wrap := circular && k > period
+	var j int
 	for i := 0; i < k; i++ {
-		c = code & 3
-		if i > 0 {
-			if c == last {
-				scores[i] = 2
-			} else {
-				scores[i] = -1
+		j = i - period
+		if j < 0 {
+			if !wrap {
+				if i == 0 {
+					scores[i] = firstBaseScore
+				} else {
+					scores[i] = 0
+				}
+				continue
 			}
+			j += k
+		}
+		if (code>>uint(i<<1))&3 == (code>>uint(j<<1))&3 {
+			scores[i] = matchScore
 		} else {
-			scores[i] = 1
+			scores[i] = mismatchScore
 		}
-		last = c
+	}
+}
+
+// longestRun returns the length of the longest single-base run of a k-mer.
+func longestRun(code uint64, k int) int {
+	var prev, c uint64
+	var run, longest int
+	for i := 0; i < k; i++ {
+		c = code & 3
+		if i > 0 && c == prev {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+		prev = c
 		code >>= 2
 	}
-	// check score in sliding window
-	var s, pre int
-	iLast := k - window - 1
-	if iLast < 0 {
-		iLast = 0
+	return longest
+}
+
+// circularRun returns the length of the single-base run spanning
+// the end and the beginning of a circular k-mer, 0 for no such run.
+func circularRun(code uint64, k int) int {
+	first := (code >> uint((k-1)<<1)) & 3
+	if code&3 != first {
+		return 0
 	}
-	for i := 0; i <= iLast; i++ {
-		if i == 0 {
-			for j := 0; j < window; j++ {
-				s += scores[j]
-			}
-		} else { // update score
-			s = s - pre + scores[i+window-1]
-		}
-		pre = scores[i]
-		// fmt.Printf("%s, %d, %d\n", unikmer.KmerCode{code0, k}, i, s)
-		if s >= threshold {
-			return true
+	var i, j int
+	for i = 0; i < k && (code>>uint(i<<1))&3 == first; i++ { // from the last base
+	}
+	if i == k { // all bases are the same
+		return k
+	}
+	for j = k - 1; (code>>uint(j<<1))&3 == first; j-- { // from the first base
+	}
+	return i + k - 1 - j
+}
+
+// entropyCode returns the Shannon entropy (in bits) of base composition
+// of a k-mer, ranging from 0 for single base repeats to 2.
+func entropyCode(code uint64, k int) float64 {
+	var counts [4]int
+	for i := 0; i < k; i++ {
+		counts[code&3]++
+		code >>= 2
+	}
+	var e, p float64
+	for _, n := range counts {
+		if n == 0 {
+			continue
 		}
+		p = float64(n) / float64(k)
+		e -= p * math.Log2(p)
 	}
-	return false
+	return e
 }
@@ -0,0 +1,224 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/shenwei356/unikmer"
+)
+
+func TestFilterCode(t *testing.T) {
+	tests := []struct {
+		kmer          string
+		window        int
+		threshold     int
+		match         int
+		mismatch      int
+		circular      bool
+		lowComplexity bool
+	}{
+		// 1 + 9*2 = 19
+		{"AAAAAAAAAA", 10, 14, 2, -1, false, true},
+		{"AAAAAAAAAA", 10, 20, 2, -1, false, false},
+		// 1 - 9 = -8
+		{"ACGTACGTAC", 10, 14, 2, -1, false, false},
+		{"ACGTACGTAC", 10, -8, 2, -1, false, true},
+		// 1 + 4*2 - 1 + 4*2 = 16
+		{"AAAAACCCCC", 10, 16, 2, -1, false, true},
+		{"AAAAACCCCC", 10, 17, 2, -1, false, false},
+		// custom scores: 1 + 4*1 - 5 + 4*1 = 4
+		{"AAAAACCCCC", 10, 4, 1, -5, false, true},
+		{"AAAAACCCCC", 10, 5, 1, -5, false, false},
+		// the first window (last 5 bases): 1 + 4*2 = 9
+		{"ACGTAGGGGG", 5, 9, 2, -1, false, true},
+		// the last window (first 5 bases): -1 + 4*2 = 7
+		{"GGGGGACGTA", 5, 7, 2, -1, false, true},
+		{"GGGGGACGTA", 5, 8, 2, -1, false, false},
+		// window bigger than k
+		{"AAAAA", 10, 9, 2, -1, false, true},
+		// circular: the window wrapping around the end: -1 + 4*2 = 7
+		{"GGACGTAGGG", 5, 7, 2, -1, true, true},
+		{"GGACGTAGGG", 5, 7, 2, -1, false, false},
+		{"GGACGTAGGG", 5, 8, 2, -1, true, false},
+	}
+	for _, test := range tests {
+		code, err := unikmer.Encode([]byte(test.kmer))
+		if err != nil {
+			t.Fatal(err)
+		}
+		k := len(test.kmer)
+		scores := make([]int, k)
+		hit := filterCode(code, k, test.threshold, test.window, test.match, test.mismatch, test.circular, []int{1}, scores)
+		if hit != test.lowComplexity {
+			t.Errorf("%s (window: %d, threshold: %d, match: %d, mismatch: %d): expected %v, got %v",
+				test.kmer, test.window, test.threshold, test.match, test.mismatch, test.lowComplexity, hit)
+		}
+	}
+}
+
+func TestScoreBases(t *testing.T) {
+	tests := []struct {
+		kmer     string
+		circular bool
+		scores   []int // from the last base to the first one
+	}{
+		{"A", false, []int{1}},
+		{"AAAA", false, []int{1, 2, 2, 2}},
+		{"ACGT", false, []int{1, -1, -1, -1}},
+		{"AACC", false, []int{1, 2, -1, 2}},
+		{"GGGGGACGTA", false, []int{1, -1, -1, -1, -1, -1, 2, 2, 2, 2}},
+		{"A", true, []int{1}},
+		{"AAAA", true, []int{2, 2, 2, 2}},
+		{"GGGGGACGTA", true, []int{-1, -1, -1, -1, -1, -1, 2, 2, 2, 2}},
+		{"GGACGTAGGG", true, []int{2, 2, 2, -1, -1, -1, -1, -1, -1, 2}},
+	}
+	for _, test := range tests {
+		code, err := unikmer.Encode([]byte(test.kmer))
+		if err != nil {
+			t.Fatal(err)
+		}
+		scores := make([]int, len(test.kmer))
+		scoreBases(code, len(test.kmer), 1, 2, -1, test.circular, scores)
+		if !reflect.DeepEqual(scores, test.scores) {
+			t.Errorf("%s: expected %v, got %v", test.kmer, test.scores, scores)
+		}
+	}
+}
+
+func TestFilterCodePeriods(t *testing.T) {
+	tests := []struct {
+		kmer          string
+		periods       []int
+		circular      bool
+		lowComplexity bool
+	}{
+		// period 1: 1 - 9 = -8, period 2: 1 + 0 + 8*2 = 17
+		{"ATATATATAT", []int{1}, false, false},
+		{"ATATATATAT", []int{2}, false, true},
+		{"ATATATATAT", []int{1, 2, 3}, false, true},
+		// period 3: 1 + 0 + 0 + 7*2 = 15
+		{"CAGCAGCAGC", []int{1, 2}, false, false},
+		{"CAGCAGCAGC", []int{3}, false, true},
+		// period 4: 1 + 0 + 0 + 0 + 6*2 = 13
+		{"ACGTACGTAC", []int{1, 2, 3}, false, false},
+		{"ACGTACGTAC", []int{4}, false, false},
+		{"ACGTACGTAC", []int{4}, true, false}, // 10 isn't multiple of 4
+		{"ACGTACGTACGT", []int{4}, true, true},
+		{"AAAAAAAAAA", []int{1, 2, 3}, false, true},
+		{"AAAAAAAAAA", []int{2}, false, true},
+	}
+	for _, test := range tests {
+		code, err := unikmer.Encode([]byte(test.kmer))
+		if err != nil {
+			t.Fatal(err)
+		}
+		k := len(test.kmer)
+		scores := make([]int, k)
+		hit := filterCode(code, k, 14, k, 2, -1, test.circular, test.periods, scores)
+		if hit != test.lowComplexity {
+			t.Errorf("%s (periods: %v, circular: %v): expected %v, got %v",
+				test.kmer, test.periods, test.circular, test.lowComplexity, hit)
+		}
+	}
+}
+
+func TestScoreBasesPeriod(t *testing.T) {
+	tests := []struct {
+		kmer     string
+		period   int
+		circular bool
+		scores   []int // from the last base to the first one
+	}{
+		{"ATATAT", 2, false, []int{1, 0, 2, 2, 2, 2}},
+		{"ATATAC", 2, false, []int{1, 0, -1, 2, 2, 2}},
+		{"ATATAT", 2, true, []int{2, 2, 2, 2, 2, 2}},
+		{"CAGCAG", 3, false, []int{1, 0, 0, 2, 2, 2}},
+		{"CAGCAG", 3, true, []int{2, 2, 2, 2, 2, 2}},
+		{"CAG", 3, true, []int{1, 0, 0}},
+	}
+	for _, test := range tests {
+		code, err := unikmer.Encode([]byte(test.kmer))
+		if err != nil {
+			t.Fatal(err)
+		}
+		scores := make([]int, len(test.kmer))
+		scoreBases(code, len(test.kmer), test.period, 2, -1, test.circular, scores)
+		if !reflect.DeepEqual(scores, test.scores) {
+			t.Errorf("%s (period: %d, circular: %v): expected %v, got %v", test.kmer, test.period, test.circular, test.scores, scores)
+		}
+	}
+}
+
+func TestLongestRun(t *testing.T) {
+	tests := []struct {
+		kmer     string
+		run      int
+		circular int
+	}{
+		{"A", 1, 1},
+		{"AAAAAAAAAA", 10, 10},
+		{"ACGTACGTAC", 1, 0},
+		{"AAAAACCCCC", 5, 0},
+		{"ACCCCCCCGT", 7, 0},
+		{"GGACGTAGGG", 3, 5},
+		{"TTTTTTTTTA", 9, 0},
+		{"ATTTTTTTTT", 9, 0},
+		{"AATTTTTTTA", 7, 3},
+	}
+	for _, test := range tests {
+		code, err := unikmer.Encode([]byte(test.kmer))
+		if err != nil {
+			t.Fatal(err)
+		}
+		k := len(test.kmer)
+		if run := longestRun(code, k); run != test.run {
+			t.Errorf("%s: longest run expected %d, got %d", test.kmer, test.run, run)
+		}
+		if run := circularRun(code, k); run != test.circular {
+			t.Errorf("%s: circular run expected %d, got %d", test.kmer, test.circular, run)
+		}
+	}
+}
+
+func TestEntropyCode(t *testing.T) {
+	tests := []struct {
+		kmer    string
+		entropy float64
+	}{
+		{"AAAAAAAA", 0},
+		{"ATATATAT", 1},
+		{"AAAATTTT", 1},
+		{"ACGTACGT", 2},
+		{"AAAAAACG", 1.061278},
+	}
+	for _, test := range tests {
+		code, err := unikmer.Encode([]byte(test.kmer))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e := entropyCode(code, len(test.kmer)); math.Abs(e-test.entropy) > 1e-6 {
+			t.Errorf("%s: entropy expected %f, got %f", test.kmer, test.entropy, e)
+		}
+	}
+}
@@ -31,6 +31,7 @@ import (
 	"strconv"
 	"sync"
 
+	"github.com/shenwei356/bio/seqio/fastx"
 	"github.com/shenwei356/breader"
 	"github.com/shenwei356/unikmer"
 	"github.com/shenwei356/util/pathutil"
@@ -50,6 +51,15 @@ Attentions:
 Tips:
   1. Increase value of '-j' for better performance when dealing with
      lots of files, especially on SDD.
+  2. Queries can also be k-mer codes (unsigned integers), e.g., output of
+     "unikmer view --show-code". K is decided by binary files then.
+  3. For sorted canonical binary files, searching stops once k-mers exceed
+     the biggest query, which is fast for a few queries. For uncompressed
+     ones with index (created by "unikmer sort/merge --index -C"), queries
+     are located by seeking instead of scanning.
+  4. All k-mers of sequences in FASTA/Q files given by -Q/--seq-file are
+     used as queries, e.g., for probes. K-mers with bases other than ACGT
+     are skipped unless -D/--degenerate is given.
 
 `,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -76,6 +86,7 @@ Tips:
 		queries := getFlagStringSlice(cmd, "query")
 		queryFiles := getFlagStringSlice(cmd, "query-file")
 		queryUnikFiles := getFlagStringSlice(cmd, "query-unik-file")
+		seqFiles := getFlagStringSlice(cmd, "seq-file")
 		queryWithTaxids := getFlagBool(cmd, "query-is-taxid")
 
 		invertMatch := getFlagBool(cmd, "invert-match")
@@ -95,8 +106,12 @@ Tips:
 			sortKmers = true
 		}
 
-		if len(queries) == 0 && len(queryFiles) == 0 && len(queryUnikFiles) == 0 {
-			checkError(fmt.Errorf("one of flags -q/--query, -f/--query-file and -F/--query-unik-file needed"))
+		if len(queries) == 0 && len(queryFiles) == 0 && len(queryUnikFiles) == 0 && len(seqFiles) == 0 {
+			checkError(fmt.Errorf("one of flags -q/--query, -f/--query-file, -F/--query-unik-file and -Q/--seq-file needed"))
+		}
+
+		if queryWithTaxids && len(seqFiles) > 0 {
+			checkError(fmt.Errorf("flag -Q/--seq-file not allowed when given -t/--query-is-taxid"))
 		}
 
 		if mOutputs && !isStdin(outFile) {
@@ -119,7 +134,7 @@ Tips:
 			if query == "" {
 				continue
 			}
-			if !queryWithTaxids {
+			if !queryWithTaxids && !isCodeQuery(query) {
 				if k == -1 {
 					k = len(query)
 				} else if len(query) != k {
@@ -146,7 +161,7 @@ Tips:
 					checkError(chunk.Err)
 					for _, data = range chunk.Data {
 						query = data.(string)
-						if !queryWithTaxids {
+						if !queryWithTaxids && !isCodeQuery(query) {
 							if k == -1 {
 								k = len(query)
 							} else if len(query) != k {
@@ -165,6 +180,7 @@ Tips:
 		var _queries [][]byte
 		var q []byte
 		var val uint64
+		var queryCodes []uint64 // codes in queries, canonicalized after K is known
 		for _, query := range queryList {
 			if queryWithTaxids {
 				val, err = strconv.ParseUint(query, 10, 32)
@@ -175,6 +191,14 @@ Tips:
 				mt[uint32(val)] = struct{}{}
 				continue
 			}
+			if isCodeQuery(query) {
+				val, err = strconv.ParseUint(query, 10, 64)
+				if err != nil {
+					checkError(fmt.Errorf("invalid query code: %s", query))
+				}
+				queryCodes = append(queryCodes, val)
+				continue
+			}
 			if degenerate {
 				_queries, err = extendDegenerateSeq([]byte(query))
 				if err != nil {
@@ -258,6 +282,83 @@ Tips:
 			}
 		}
 
+		if len(queryCodes) > 0 {
+			if k == -1 { // only codes given, K is decided by the first binary file
+				if isStdin(files[0]) {
+					checkError(fmt.Errorf("K can not be decided from stdin when only query codes given"))
+				}
+				k = readKFromBinaryFile(files[0])
+			}
+			for _, code := range queryCodes {
+				if code > 1<<uint(k<<1)-1 {
+					checkError(fmt.Errorf("query code %d out of range for K (%d)", code, k))
+				}
+				m[unikmer.Canonical(code, k)] = struct{}{}
+			}
+		}
+
+		// load k-mers from sequence files
+		if len(seqFiles) > 0 {
+			if k == -1 { // K is decided by the first binary file
+				if isStdin(files[0]) {
+					checkError(fmt.Errorf("K can not be decided from stdin when only sequence files given"))
+				}
+				k = readKFromBinaryFile(files[0])
+			}
+
+			var fastxReader *fastx.Reader
+			var record *fastx.Record
+			var seq []byte
+			var nSkipped int
+			nfiles = len(seqFiles)
+			for i, file := range seqFiles {
+				if opt.Verbose {
+					log.Infof("loading queries from sequence file [%d/%d]: %s", i+1, nfiles, file)
+				}
+
+				fastxReader, err = fastx.NewDefaultReader(file)
+				checkError(err)
+				for {
+					record, err = fastxReader.Read()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+					}
+
+					seq = record.Seq.Seq
+					for j := 0; j+k <= len(seq); j++ {
+						mer = seq[j : j+k]
+						if degenerate {
+							_queries, err = extendDegenerateSeq(mer)
+							if err != nil {
+								nSkipped++
+								continue
+							}
+						} else if onlyACGT(mer) {
+							_queries = [][]byte{mer}
+						} else {
+							nSkipped++
+							continue
+						}
+
+						for _, q = range _queries {
+							kcode, err = unikmer.NewKmerCode(q)
+							if err != nil {
+								nSkipped++
+								continue
+							}
+							m[kcode.Canonical().Code] = struct{}{}
+						}
+					}
+				}
+			}
+			if nSkipped > 0 && opt.Verbose {
+				log.Warningf("%d k-mers with illegal bases skipped", nSkipped)
+			}
+		}
+
 		if opt.Verbose {
 			if queryWithTaxids {
 				if len(mt) == 0 {
@@ -279,6 +380,7 @@ Tips:
 		var singleTaxidQuery, singleCodeQuery bool
 		var theOneTaxid uint32
 		var theOneCode uint64
+		var maxCode uint64 // the biggest query code, for early stopping in sorted files
 
 		if queryWithTaxids {
 			singleTaxidQuery = len(mt) == 1
@@ -289,16 +391,31 @@ Tips:
 				}
 			}
 		} else {
-			singleCodeQuery = len(mt) == 1
+			singleCodeQuery = len(m) == 1
 			if singleCodeQuery {
 				for oc := range m {
 					theOneCode = oc
 					break
 				}
 			}
+			for oc := range m {
+				if oc > maxCode {
+					maxCode = oc
+				}
+			}
 
 		}
 
+		// sorted queries, for seeking in sorted files with index
+		var sortedQueries []uint64
+		if !queryWithTaxids && !invertMatch {
+			sortedQueries = make([]uint64, 0, len(m))
+			for code := range m {
+				sortedQueries = append(sortedQueries, code)
+			}
+			sort.Sort(unikmer.CodeSlice(sortedQueries))
+		}
+
 		////////////////////////////////////////////////////////////////////////////////
 
 		var outfh *bufio.Writer
@@ -404,12 +521,26 @@ Tips:
 					log.Infof("[file %d/%d] processing: %s", i+1, nfiles, file)
 				}
 
-				infh, r, _, err = inStream(file)
-				checkError(err)
-				defer r.Close()
+				// seeking is only available for uncompressed sorted canonical files with index
+				var seeking bool
+				if len(sortedQueries) > 0 && !isStdin(file) {
+					fh, err := os.Open(file)
+					checkError(err)
+					defer fh.Close()
 
-				reader, err = unikmer.NewReader(infh)
-				checkError(err)
+					reader, err = unikmer.NewReaderAt(fh)
+					seeking = err == nil && reader.IsIndexed() && reader.IsCanonical()
+				}
+				if !seeking {
+					infh, r, _, err = inStream(file)
+					checkError(err)
+					defer r.Close()
+
+					reader, err = unikmer.NewReader(infh)
+					checkError(err)
+				} else if opt.Verbose {
+					log.Infof("[file %d/%d] the file is indexed, locating queries by seeking", i+1, nfiles)
+				}
 
 				if !queryWithTaxids && k != reader.K {
 					checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to query K (%d)", reader.K, file, k))
@@ -431,7 +562,7 @@ Tips:
 							outFile += extDataFile
 						}
 						// the global writer
-						outfh, gw, w, err = outStream(outFile, opt.Compress, opt.CompressionLevel)
+						outfh, gw, w, err = outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
 						checkError(err)
 
 						var mode uint32
@@ -493,7 +624,7 @@ Tips:
 				if mOutputs {
 					// write to it's own output file
 					_outFile = filepath.Join(outdir, filepath.Base(file)+outSuffix+extDataFile)
-					_outfh, _gw, _w, _err := outStream(_outFile, opt.Compress, opt.CompressionLevel)
+					_outfh, _gw, _w, _err := outStreamWithCodec(_outFile, opt.CompressionCodec, opt.CompressionLevel)
 					checkError(_err)
 					defer func() {
 						_outfh.Flush()
@@ -534,10 +665,15 @@ Tips:
 					checkError(_writer.Flush())
 				}
 
+				next := reader.ReadWithTaxid
+				if seeking {
+					next = seekQueries(reader, sortedQueries)
+				}
+
 				var kcode unikmer.KmerCode
 				var taxid uint32
 				for {
-					kcode, taxid, err = reader.ReadWithTaxid()
+					kcode, taxid, err = next()
 					if err != nil {
 						if err == io.EOF {
 							break
@@ -552,15 +688,17 @@ Tips:
 							_, ok = mt[taxid]
 						}
 					} else {
+						// codes are canonical and sorted, no need compare later codes
+						if _sorted && _canonical && !invertMatch && kcode.Code > maxCode {
+							break
+						}
+
+						if !_canonical {
+							kcode = kcode.Canonical()
+						}
 						if singleCodeQuery {
 							ok = kcode.Code == theOneCode
-							if _sorted && kcode.Code > theOneCode { // no need compare later codes
-								break
-							}
 						} else {
-							if !_canonical {
-								kcode = kcode.Canonical()
-							}
 							_, ok = m[kcode.Code]
 						}
 					}
@@ -811,6 +949,7 @@ func init() {
 	grepCmd.Flags().StringSliceP("query", "q", []string{""}, `query k-mers/taxids (multiple values delimted by comma supported)`)
 	grepCmd.Flags().StringSliceP("query-file", "f", []string{""}, "query file (one k-mer/taxid per line)")
 	grepCmd.Flags().StringSliceP("query-unik-file", "F", []string{""}, "query file in .unik format")
+	grepCmd.Flags().StringSliceP("seq-file", "Q", []string{""}, "FASTA/Q file(s), all k-mers of sequences are used as queries")
 	grepCmd.Flags().BoolP("query-is-taxid", "t", false, "queries are taxids")
 
 	grepCmd.Flags().BoolP("degenerate", "D", false, "query k-mers contains degenerate base")
@@ -828,3 +967,54 @@ func init() {
 }
 
 var grepDefaultOutSuffix = ".grep"
+
+// isCodeQuery tells if the query is a k-mer code, i.e., an unsigned integer.
+func isCodeQuery(query string) bool {
+	if query == "" {
+		return false
+	}
+	for i := 0; i < len(query); i++ {
+		if query[i] < '0' || query[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// readKFromBinaryFile returns K of a binary file.
+func readKFromBinaryFile(file string) int {
+	infh, r, _, err := inStream(file)
+	checkError(err)
+	defer r.Close()
+
+	reader, err := unikmer.NewReader(infh)
+	checkError(err)
+	return reader.K
+}
+
+// seekQueries returns a function reading k-mers matching the sorted queries
+// from a sorted file with index, by seeking to each query.
+// Duplicated k-mers in the file are all returned.
+func seekQueries(reader *unikmer.Reader, queries []uint64) func() (unikmer.KmerCode, uint32, error) {
+	var i int
+	var located bool // the reader is positioned at queries[i]
+	return func() (unikmer.KmerCode, uint32, error) {
+		for i < len(queries) {
+			if !located {
+				if err := reader.Seek(queries[i]); err != nil {
+					return unikmer.KmerCode{}, 0, err
+				}
+				located = true
+			}
+
+			kcode, taxid, err := reader.ReadWithTaxid()
+			if err == io.EOF || (err == nil && kcode.Code != queries[i]) {
+				i++
+				located = false
+				continue
+			}
+			return kcode, taxid, err
+		}
+		return unikmer.KmerCode{}, 0, io.EOF
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/shenwei356/unikmer"
+)
+
+func TestSeekQueries(t *testing.T) {
+	codes := []uint64{1, 5, 8, 8, 8, 100, 101, 999}
+	queries := []uint64{0, 1, 6, 8, 101, 999, 1000}
+	expected := []uint64{1, 8, 8, 8, 101, 999}
+
+	for _, flag := range []uint32{unikmer.UNIK_SORTED, unikmer.UNIK_SORTED | unikmer.UNIK_INCLUDETAXID} {
+		var buf bytes.Buffer
+		writer, err := unikmer.NewWriterWithIndex(&buf, 11, flag, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, code := range codes {
+			writer.WriteCodeWithTaxid(code, 9606)
+		}
+		if err = writer.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		reader, err := unikmer.NewReaderAt(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		next := seekQueries(reader, queries)
+		found := make([]uint64, 0, len(expected))
+		for {
+			kcode, _, err := next()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatal(err)
+			}
+			found = append(found, kcode.Code)
+		}
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("flag: %d, expected %v, got %v", flag, expected, found)
+		}
+	}
+}
@@ -37,6 +37,9 @@ var headCmd = &cobra.Command{
 	Short: "Extract the first N k-mers",
 	Long: `Extract the first N k-mers
 
+Reading stops once N k-mers are extracted, and "-n 0" produces
+an empty file with only a header.
+
 Attentions:
   1. The 'canonical' flags of all files should be consistent.
   2. Input files should ALL have or don't have taxid information.
@@ -68,7 +71,7 @@ Attentions:
 		if !isStdout(outFile) {
 			outFile += extDataFile
 		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -85,6 +88,8 @@ Attentions:
 		var reader *unikmer.Reader
 		var code uint64
 		var taxid uint32
+		var rc bool
+		var count uint32
 		var k int = -1
 		var canonical bool
 		var hasTaxid bool
@@ -117,6 +122,13 @@ Attentions:
 					writer, err = unikmer.NewWriter(outfh, k, mode)
 					checkError(err)
 					writer.SetMaxTaxid(maxUint32N(reader.GetTaxidBytesLength())) // follow reader
+
+					// the number is known if the first file is big enough or the only one
+					if reader.Number >= int64(N) {
+						writer.Number = int64(N)
+					} else if reader.Number >= 0 && nfiles == 1 {
+						writer.Number = reader.Number
+					}
 				} else {
 					if k != reader.K {
 						checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
@@ -133,21 +145,21 @@ Attentions:
 					}
 				}
 
-				for {
-					code, taxid, err = reader.ReadCodeWithTaxid()
+				for n < N {
+					code, rc, count, taxid, err = reader.ReadRecord()
 					if err != nil {
 						if err == io.EOF {
 							break
 						}
 						checkError(err)
 					}
-					if n >= N {
-						return flagBreak
-					}
 					n++
-					writer.WriteCodeWithTaxid(code, taxid)
+					writer.WriteRecord(code, rc, count, taxid)
 				}
 
+				if n >= N { // stop reading the rest of the file and other files
+					return flagBreak
+				}
 				return flagContinue
 			}()
 
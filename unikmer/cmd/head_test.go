@@ -0,0 +1,130 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/shenwei356/unikmer"
+)
+
+// TestPassthroughPayloads checks that commands copying flags of the input
+// file keep strands, counts and taxids of k-mers.
+func TestPassthroughPayloads(t *testing.T) {
+	dir := t.TempDir()
+	k := 21
+	mode := uint32(unikmer.UNIK_SORTED | unikmer.UNIK_INCLUDECOUNT | unikmer.UNIK_INCLUDETAXID)
+
+	rand.Seed(11)
+	n := 1000
+	m := make(map[uint64]struct{}, n)
+	for len(m) < n {
+		m[rand.Uint64()&unikmer.MaxCode[k]] = struct{}{}
+	}
+	codes := make([]uint64, 0, n)
+	for code := range m {
+		codes = append(codes, code)
+	}
+	sort.Sort(unikmer.CodeSlice(codes))
+
+	type payload struct {
+		count uint32
+		taxid uint32
+	}
+	payloads := make(map[uint64]payload, n)
+
+	file := filepath.Join(dir, "in.unik")
+	outfh, gw, w, err := outStreamWithCodec(file, codecGzip, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := unikmer.NewWriter(outfh, k, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, code := range codes {
+		p := payload{count: uint32(i%7 + 2), taxid: uint32(i%3 + 1)}
+		payloads[code] = p
+		if err = writer.WriteRecord(code, false, p.count, p.taxid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	outfh.Flush()
+	gw.Close()
+	w.Close()
+
+	tests := [][]string{
+		{"head", "-n", "100"},
+		{"slice", "--from", "10", "--to", "500"},
+		{"sample", "-s", "2", "-w", "3"},
+		{"filter", "-t", "1000"},
+	}
+	for _, args := range tests {
+		outPrefix := filepath.Join(dir, args[0])
+		if err = executeRootCmd(t, append(args, "-o", outPrefix, file)...); err != nil {
+			t.Fatalf("%s: %s", args[0], err)
+		}
+
+		func() {
+			infh, r, _, err := inStream(outPrefix + extDataFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			reader, err := unikmer.NewReader(infh)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reader.IsIncludeCount() {
+				t.Fatalf("%s: UNIK_INCLUDECOUNT expected", args[0])
+			}
+
+			var nRecords int
+			for {
+				code, _, count, taxid, err := reader.ReadRecord()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					t.Fatalf("%s: %s", args[0], err)
+				}
+				nRecords++
+				if p := payloads[code]; p.count != count || p.taxid != taxid {
+					t.Errorf("%s: unexpected count/taxid of %d: %d/%d, expected: %d/%d",
+						args[0], code, count, taxid, p.count, p.taxid)
+				}
+			}
+			if nRecords == 0 {
+				t.Errorf("%s: no k-mers outputted", args[0])
+			}
+		}()
+		os.Remove(outPrefix + extDataFile)
+	}
+}
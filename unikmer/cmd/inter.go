@@ -48,6 +48,16 @@ Tips:
      you can use 'unikmer sort -u -m 100M' for each file,
 	 and then 'unikmer merge -' from them.
   2. Put the smallest file in the begining to reduce memory usage.
+  3. Use --hamming 1 to treat k-mers with a Hamming distance of 1 as matched,
+     i.e., a k-mer in the first file is kept if the k-mer or any of its
+     3k neighbors is found in each of other files. This is much slower,
+     as 3k+1 hash lookups are needed for each k-mer of the larger one of
+     the remaining k-mers and the next file, and the smaller one is
+     loaded into a hash table, which needs about 40 bytes per k-mer.
+  4. K-mers of the first file are loaded into memory by default. If they
+     would occupy more memory than --max-memory, all files are scanned
+     simultaneously instead, which only needs a little memory but keeps
+     all files open. Duplicated k-mers are removed in this case.
 
 `,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -69,9 +79,22 @@ Tips:
 		}
 
 		checkFileSuffix(extDataFile, files...)
+		setProvenance(opt, cmd, files)
 		var nfiles = len(files)
 
 		outFile := getFlagString(cmd, "out-prefix")
+		opt.Partition = getFlagPartition(cmd, "partition")
+		failIfEmpty := getFlagBool(cmd, "fail-if-empty")
+		hamming := getFlagNonNegativeInt(cmd, "hamming")
+		if hamming > 1 {
+			checkError(fmt.Errorf("only Hamming distance of 0 or 1 supported: %d", hamming))
+		}
+		maxMem, err := ParseByteSize(getFlagString(cmd, "max-memory"))
+		if err != nil {
+			checkError(fmt.Errorf("invalid value of flag --max-memory: %s", err))
+		}
+		var nResult int
+		defer exitIfEmpty(failIfEmpty, &nResult)
 
 		var taxondb *unikmer.Taxonomy
 
@@ -89,51 +112,107 @@ Tips:
 		var code uint64
 		var taxid uint32
 		var flag int
+		var nFirst int64 = -1 // number of k-mers in the first file
 
-		// checking files
-		for _, file := range files {
+		// checking files, only headers are read
+		for i, file := range files {
 			if isStdin(file) {
 				continue
 			}
-			func() {
+			info, err := unikmer.Stat(file)
+			checkError(err)
+
+			if !info.IsSorted() {
+				checkError(fmt.Errorf("input file should be sorted: %s", file))
+			}
+			if i == 0 {
+				nFirst = info.Number
+			}
+
+			if k == -1 {
+				k = info.K
+				canonical = info.IsCanonical()
+				hasTaxid = !opt.IgnoreTaxid && info.HasTaxidInfo()
+
+				if hasTaxid {
+					if opt.Verbose {
+						log.Infof("taxids found in file: %s", file)
+					}
+					taxondb = loadTaxonomy(opt, false)
+				}
+			} else {
+				if k != info.K {
+					checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", info.K, file, k))
+				}
+				if info.IsCanonical() != canonical {
+					checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+				}
+				if !opt.IgnoreTaxid && info.HasTaxidInfo() != hasTaxid {
+					if info.HasTaxidInfo() {
+						checkError(fmt.Errorf(`taxid information not found in previous files, but found in this: %s`, file))
+					} else {
+						checkError(fmt.Errorf(`taxid information found in previous files, but missing in this: %s`, file))
+					}
+				}
+			}
+		}
+
+		if !isStdout(outFile) {
+			outFile += extDataFile
+		}
+		newWriter := func() (*unikmer.Writer, func()) {
+			outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
+			checkError(err)
+
+			var mode uint32
+			mode |= unikmer.UNIK_SORTED
+			if canonical {
+				mode |= unikmer.UNIK_CANONICAL
+			}
+			if hasTaxid {
+				mode |= unikmer.UNIK_INCLUDETAXID
+			}
+
+			writer, err := unikmer.NewWriter(outfh, k, mode)
+			checkError(err)
+			writer.Description = opt.Provenance
+			writer.SetMaxTaxid(opt.MaxTaxid) // follow taxondb
+
+			return writer, func() {
+				checkError(writer.Flush())
+				outfh.Flush()
+				if gw != nil {
+					gw.Close()
+				}
+				w.Close()
+			}
+		}
+
+		if hamming == 0 && nfiles > 1 && maxMem > 0 && nFirst > 0 &&
+			nFirst*bytesPerCodeTaxid > int64(maxMem) {
+			if opt.Verbose {
+				log.Infof("%d k-mers in the first file exceed --max-memory, scanning all files simultaneously", nFirst)
+			}
+
+			readers := make([]*unikmer.Reader, nfiles)
+			for i, file := range files {
 				infh, r, _, err = inStream(file)
 				checkError(err)
 				defer r.Close()
 
-				reader, err = unikmer.NewReader(infh)
+				readers[i], err = unikmer.NewReader(infh)
 				checkError(err)
+			}
 
-				if !reader.IsSorted() {
-					checkError(fmt.Errorf("input file should be sorted: %s", file))
-				}
-
-				if k == -1 {
-					k = reader.K
-					canonical = reader.IsCanonical()
-					hasTaxid = !opt.IgnoreTaxid && reader.HasTaxidInfo()
+			writer, closeWriter := newWriter()
+			nResult, err = interKWay(readers, opt.Partition, hasTaxid, taxondb, writer)
+			checkError(err)
+			closeWriter()
 
-					if hasTaxid {
-						if opt.Verbose {
-							log.Infof("taxids found in file: %s", file)
-						}
-						taxondb = loadTaxonomy(opt, false)
-					}
-				} else {
-					if k != reader.K {
-						checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
-					}
-					if reader.IsCanonical() != canonical {
-						checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
-					}
-					if !opt.IgnoreTaxid && reader.HasTaxidInfo() != hasTaxid {
-						if reader.HasTaxidInfo() {
-							checkError(fmt.Errorf(`taxid information not found in previous files, but found in this: %s`, file))
-						} else {
-							checkError(fmt.Errorf(`taxid information found in previous files, but missing in this: %s`, file))
-						}
-					}
-				}
-			}()
+			if opt.Verbose {
+				log.Infof("%d k-mers saved to %s", nResult, outFile)
+			}
+			return
 		}
 
 		for i, file := range files {
@@ -149,9 +228,13 @@ Tips:
 				reader, err = unikmer.NewReader(infh)
 				checkError(err)
 
+				pr := newProgressReader(opt, file, reader)
+				defer pr.done()
+
 				if firstFile {
 					for {
-						code, taxid, err = reader.ReadCodeWithTaxid()
+						// intersection is a subset of the first file
+						code, taxid, err = opt.Partition.readCodeWithTaxid(pr)
 						if err != nil {
 							if err == io.EOF {
 								break
@@ -163,6 +246,24 @@ Tips:
 						m = append(m, false)
 					}
 					firstFile = false
+					if len(mc) == 0 {
+						hasInter = false
+						return flagBreak
+					}
+					return flagContinue
+				}
+
+				if hamming == 1 {
+					mc = interHamming1(mc, pr, k, canonical, hasTaxid, taxondb)
+					m = make([]bool, len(mc))
+
+					if opt.Verbose {
+						log.Infof("%d k-mers remain", len(mc))
+					}
+					if len(mc) == 0 {
+						hasInter = false
+						return flagBreak
+					}
 					return flagContinue
 				}
 
@@ -172,7 +273,7 @@ Tips:
 				qCode = mc[ii].Code
 				qtaxid = mc[ii].Taxid
 
-				code, taxid, err = reader.ReadCodeWithTaxid()
+				code, taxid, err = pr.ReadCodeWithTaxid()
 				if err != nil {
 					if err == io.EOF {
 						return flagBreak
@@ -203,7 +304,7 @@ Tips:
 						qCode = mc[ii].Code
 						qtaxid = mc[ii].Taxid
 
-						code, taxid, err = reader.ReadCodeWithTaxid()
+						code, taxid, err = pr.ReadCodeWithTaxid()
 						if err != nil {
 							if err == io.EOF {
 								break
@@ -211,7 +312,7 @@ Tips:
 							checkError(err)
 						}
 					} else {
-						code, taxid, err = reader.ReadCodeWithTaxid()
+						code, taxid, err = pr.ReadCodeWithTaxid()
 						if err != nil {
 							if err == io.EOF {
 								break
@@ -263,33 +364,9 @@ Tips:
 			log.Infof("exporting k-mers")
 		}
 
-		if !isStdout(outFile) {
-			outFile += extDataFile
-		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
-		checkError(err)
-		defer func() {
-			outfh.Flush()
-			if gw != nil {
-				gw.Close()
-			}
-			w.Close()
-		}()
-
-		var mode uint32
-		mode |= unikmer.UNIK_SORTED
-		if canonical {
-			mode |= unikmer.UNIK_CANONICAL
-		}
-		if hasTaxid {
-			mode |= unikmer.UNIK_INCLUDETAXID
-		}
-
-		writer, err := unikmer.NewWriter(outfh, k, mode)
-		checkError(err)
-		writer.SetMaxTaxid(opt.MaxTaxid) // follow taxondb
-
+		writer, closeWriter := newWriter()
 		writer.Number = int64(len(mc))
+		nResult = len(mc)
 
 		if hasTaxid {
 			for _, ct := range mc {
@@ -301,7 +378,7 @@ Tips:
 			}
 		}
 
-		checkError(writer.Flush())
+		closeWriter()
 		if opt.Verbose {
 			log.Infof("%d k-mers saved to %s", len(m), outFile)
 		}
@@ -312,4 +389,170 @@ func init() {
 	RootCmd.AddCommand(interCmd)
 
 	interCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
+	interCmd.Flags().StringP("partition", "", "", helpPartition)
+	interCmd.Flags().BoolP("fail-if-empty", "", false, helpFailIfEmpty)
+	interCmd.Flags().StringP("max-memory", "", "4G", `maximum memory for loading k-mers of the first file, all files are scanned simultaneously if exceeded, supports K/M/G suffix, 0 for no limit`)
+	interCmd.Flags().IntP("hamming", "", 0, `treat k-mers with Hamming distance no greater than this (0 or 1) as matched, much slower and the smaller set is hashed in memory`)
+}
+
+// interHamming1 keeps k-mers in mc, of which the k-mer itself or any of its
+// 3k neighbors with a Hamming distance of 1 exists in the reader.
+// For canonical k-mers, neighbors are also canonicalized.
+// Exact matches are preferred for computing LCA of taxids.
+//
+// K-mers of the reader are loaded into a hash table until they outnumber mc,
+// then mc is hashed instead and the rest of the reader is streamed,
+// so only the smaller set is hashed.
+func interHamming1(mc []unikmer.CodeTaxid, reader codeTaxidReader, k int, canonical bool,
+	hasTaxid bool, taxondb *unikmer.Taxonomy) []unikmer.CodeTaxid {
+	m := make(map[uint64]uint32, minInt(mapInitSize, len(mc)+1))
+	loaded := make([]unikmer.CodeTaxid, 0, minInt(mapInitSize, len(mc)+1))
+	var code uint64
+	var taxid uint32
+	var err error
+	for {
+		code, taxid, err = reader.ReadCodeWithTaxid()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			checkError(err)
+		}
+		m[code] = taxid
+		loaded = append(loaded, unikmer.CodeTaxid{Code: code, Taxid: taxid})
+		if len(m) > len(mc) {
+			return interHamming1Stream(mc, loaded, reader, k, canonical, hasTaxid, taxondb)
+		}
+	}
+
+	var ok bool
+	var i int
+	var b uint64
+	mc2 := mc[:0] // k-mers remain sorted
+	for _, ct := range mc {
+		taxid, ok = m[ct.Code]
+		for i = 0; !ok && i < k; i++ {
+			for b = 1; b < 4; b++ {
+				code = ct.Code ^ (b << uint(i<<1))
+				if canonical {
+					code = unikmer.Canonical(code, k)
+				}
+				if taxid, ok = m[code]; ok {
+					break
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		if hasTaxid {
+			ct.Taxid = taxondb.LCA(ct.Taxid, taxid)
+		}
+		mc2 = append(mc2, ct)
+	}
+	return mc2
+}
+
+// interHamming1Stream is the same as interHamming1, but hashes mc and checks
+// k-mers already loaded and the rest ones of the reader. Being at a Hamming
+// distance of 1 is symmetric, also for canonical k-mers.
+func interHamming1Stream(mc []unikmer.CodeTaxid, loaded []unikmer.CodeTaxid,
+	reader codeTaxidReader, k int, canonical bool,
+	hasTaxid bool, taxondb *unikmer.Taxonomy) []unikmer.CodeTaxid {
+	// index of the first one of duplicated k-mers, mc is sorted
+	idx := make(map[uint64]int, len(mc))
+	for i := len(mc) - 1; i >= 0; i-- {
+		idx[mc[i].Code] = i
+	}
+
+	const (
+		matchNone = iota
+		matchNeighbor
+		matchExact
+	)
+	matches := make([]uint8, len(mc))
+	taxids := make([]uint32, len(mc))
+
+	mark := func(code uint64, taxid uint32, match uint8) {
+		j, ok := idx[code]
+		if !ok {
+			return
+		}
+		for ; j < len(mc) && mc[j].Code == code; j++ {
+			// the first neighbor or the last exact match, like a hash table
+			if matches[j] > match || (match == matchNeighbor && matches[j] == matchNeighbor) {
+				continue
+			}
+			matches[j] = match
+			taxids[j] = taxid
+		}
+	}
+	check := func(code uint64, taxid uint32) {
+		mark(code, taxid, matchExact)
+		var c uint64
+		var b uint64
+		for i := 0; i < k; i++ {
+			for b = 1; b < 4; b++ {
+				c = code ^ (b << uint(i<<1))
+				if canonical {
+					c = unikmer.Canonical(c, k)
+				}
+				mark(c, taxid, matchNeighbor)
+			}
+		}
+	}
+
+	for _, ct := range loaded {
+		check(ct.Code, ct.Taxid)
+	}
+	var code uint64
+	var taxid uint32
+	var err error
+	for {
+		code, taxid, err = reader.ReadCodeWithTaxid()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			checkError(err)
+		}
+		check(code, taxid)
+	}
+
+	mc2 := mc[:0] // k-mers remain sorted
+	for j, ct := range mc {
+		if matches[j] == matchNone {
+			continue
+		}
+		if hasTaxid {
+			ct.Taxid = taxondb.LCA(ct.Taxid, taxids[j])
+		}
+		mc2 = append(mc2, ct)
+	}
+	return mc2
+}
+
+// bytesPerCode is the size of a code in a list.
+const bytesPerCode = 8
+
+// bytesPerCodeTaxid is the size of unikmer.CodeTaxid.
+const bytesPerCodeTaxid = 16
+
+// interKWay computes the intersection of sorted k-mers by scanning all
+// readers simultaneously, which only needs O(N) memory for N files.
+func interKWay(readers []*unikmer.Reader, p *codePartition, hasTaxid bool,
+	taxondb *unikmer.Taxonomy, writer *unikmer.Writer) (int, error) {
+	if !hasTaxid {
+		taxondb = nil
+	}
+	var n int
+	err := unikmer.IntersectFunc(readers, taxondb, func(code uint64, taxid uint32) error {
+		if p != nil && code%p.n != p.i {
+			return nil
+		}
+		n++
+		return writer.WriteCodeWithTaxid(code, taxid)
+	})
+	return n, err
 }
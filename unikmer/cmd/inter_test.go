@@ -0,0 +1,165 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/shenwei356/unikmer"
+)
+
+func TestInterKWay(t *testing.T) {
+	for _, c := range []struct {
+		files [][]uint64
+		inter []uint64
+	}{
+		{[][]uint64{{1, 2, 3}, {}}, []uint64{}},
+		{[][]uint64{{1, 3, 5, 7}, {2, 3, 4, 7}}, []uint64{3, 7}},
+		{[][]uint64{{1, 3, 3, 5, 7}, {3, 5, 5}, {0, 3, 5, 9}}, []uint64{3, 5}},
+		{[][]uint64{{1, 2}, {3, 4}}, []uint64{}},
+		{[][]uint64{{9}, {9}, {9}}, []uint64{9}},
+	} {
+		readers := make([]*unikmer.Reader, len(c.files))
+		for i, codes := range c.files {
+			var buf bytes.Buffer
+			writer, err := unikmer.NewWriter(&buf, 21, unikmer.UNIK_SORTED)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, code := range codes {
+				writer.WriteCode(code)
+			}
+			if err = writer.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if readers[i], err = unikmer.NewReader(&buf); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var buf bytes.Buffer
+		writer, err := unikmer.NewWriter(&buf, 21, unikmer.UNIK_SORTED)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := interKWay(readers, nil, false, nil, writer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = writer.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		reader, err := unikmer.NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		codes := make([]uint64, 0, n)
+		for {
+			code, err := reader.ReadCode()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatal(err)
+			}
+			codes = append(codes, code)
+		}
+		if n != len(codes) || !reflect.DeepEqual(codes, c.inter) {
+			t.Errorf("files %v: expected %v, returned %d, got %v", c.files, c.inter, n, codes)
+		}
+	}
+}
+
+type codeTaxidSlice struct {
+	codes []uint64
+	i     int
+}
+
+func (s *codeTaxidSlice) ReadCodeWithTaxid() (uint64, uint32, error) {
+	if s.i >= len(s.codes) {
+		return 0, 0, io.EOF
+	}
+	s.i++
+	return s.codes[s.i-1], 0, nil
+}
+
+func TestInterHamming1(t *testing.T) {
+	k := 5
+	hamming := func(a, b uint64) int {
+		var d int
+		for i := 0; i < k; i++ {
+			if (a>>uint(i<<1))&3 != (b>>uint(i<<1))&3 {
+				d++
+			}
+		}
+		return d
+	}
+	randCodes := func(r *rand.Rand, n int, canonical bool) []uint64 {
+		codes := make([]uint64, n)
+		for i := range codes {
+			codes[i] = uint64(r.Int63n(1 << uint(k<<1)))
+			if canonical {
+				codes[i] = unikmer.Canonical(codes[i], k)
+			}
+		}
+		sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+		return codes
+	}
+
+	r := rand.New(rand.NewSource(11))
+	for _, canonical := range []bool{false, true} {
+		// the reader has fewer and more k-mers than mc
+		for _, n := range []int{5, 50, 500} {
+			codes1 := randCodes(r, 50, canonical)
+			codes2 := randCodes(r, n, canonical)
+
+			expected := make([]uint64, 0, len(codes1))
+			for _, a := range codes1 {
+				for _, b := range codes2 {
+					if hamming(a, b) <= 1 ||
+						(canonical && hamming(a, unikmer.RevComp(b, k)) <= 1) {
+						expected = append(expected, a)
+						break
+					}
+				}
+			}
+
+			mc := make([]unikmer.CodeTaxid, len(codes1))
+			for i, code := range codes1 {
+				mc[i] = unikmer.CodeTaxid{Code: code}
+			}
+			mc = interHamming1(mc, &codeTaxidSlice{codes: codes2}, k, canonical, false, nil)
+			codes := make([]uint64, len(mc))
+			for i, ct := range mc {
+				codes[i] = ct.Code
+			}
+			if !reflect.DeepEqual(codes, expected) {
+				t.Errorf("canonical: %v, %d vs %d k-mers: expected %v, got %v",
+					canonical, len(codes1), n, expected, codes)
+			}
+		}
+	}
+}
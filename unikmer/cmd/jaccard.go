@@ -0,0 +1,180 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// jaccardCmd represents
+var jaccardCmd = &cobra.Command{
+	Use:   "jaccard",
+	Short: "Compute pairwise Jaccard similarity of multiple binary files",
+	Long: `Compute pairwise Jaccard similarity of multiple binary files
+
+For every pair of files, sizes of intersection and union are computed by
+merging the two sorted files in streaming, so k-mers are not loaded into RAM.
+
+Output format (tab-delimited):
+  Default: a square matrix of Jaccard index, with file names as
+           the first row and the first column.
+  --list:  1. file1
+           2. file2
+           3. intersection, number of k-mers in both files
+           4. union, number of k-mers in either file
+           5. jaccard, intersection / union
+
+Attentions:
+  1. All input files should be sorted, you can use 'unikmer sort -u -m 100M'.
+  2. K and 'canonical' flags of all files should be consistent.
+  3. Taxids are ignored.
+  4. Reading from stdin is not supported.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			log.Infof("%d input file(s) given", len(files))
+		}
+
+		checkFileSuffix(extDataFile, files...)
+
+		outFile := getFlagString(cmd, "out-file")
+		listFormat := getFlagBool(cmd, "list")
+
+		if len(files) < 2 {
+			checkError(fmt.Errorf("at least two files needed"))
+		}
+
+		// checking all files before computing
+		checkSortedFiles(files)
+
+		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		type pairResult struct {
+			inter, union int64
+		}
+		n := len(files)
+		results := make([][]pairResult, n) // only results[i][j] with i < j are used
+		for i := range results {
+			results[i] = make([]pairResult, n)
+		}
+		if opt.Verbose {
+			log.Infof("%d pairs of files to compare", n*(n-1)/2)
+		}
+
+		var wg sync.WaitGroup
+		tokens := make(chan int, opt.NumCPUs)
+		for i := 0; i < n-1; i++ {
+			for j := i + 1; j < n; j++ {
+				wg.Add(1)
+				tokens <- 1
+				go func(i, j int) {
+					defer func() {
+						wg.Done()
+						<-tokens
+					}()
+
+					readers := make([]*unikmer.Reader, 2)
+					for x, file := range []string{files[i], files[j]} {
+						infh, r, _, err := inStream(file)
+						checkError(err)
+						defer r.Close()
+
+						readers[x], err = unikmer.NewReader(infh)
+						checkError(err)
+					}
+
+					inter, union, err := unikmer.IntersectionUnionSize(readers)
+					if err != nil {
+						checkError(fmt.Errorf("%s and %s: %s", files[i], files[j], err))
+					}
+					results[i][j] = pairResult{inter: inter, union: union}
+				}(i, j)
+			}
+		}
+		wg.Wait()
+
+		jaccard := func(r pairResult) float64 {
+			if r.union == 0 {
+				return 0
+			}
+			return float64(r.inter) / float64(r.union)
+		}
+
+		if listFormat {
+			outfh.WriteString("file1\tfile2\tintersection\tunion\tjaccard\n")
+			var r pairResult
+			for i := 0; i < n-1; i++ {
+				for j := i + 1; j < n; j++ {
+					r = results[i][j]
+					outfh.WriteString(fmt.Sprintf("%s\t%s\t%d\t%d\t%.6f\n",
+						files[i], files[j], r.inter, r.union, jaccard(r)))
+				}
+			}
+			return
+		}
+
+		outfh.WriteString("\t" + strings.Join(files, "\t") + "\n")
+		for i := 0; i < n; i++ {
+			outfh.WriteString(files[i])
+			for j := 0; j < n; j++ {
+				switch {
+				case i == j:
+					outfh.WriteString(fmt.Sprintf("\t%.6f", 1.0))
+				case i < j:
+					outfh.WriteString(fmt.Sprintf("\t%.6f", jaccard(results[i][j])))
+				default:
+					outfh.WriteString(fmt.Sprintf("\t%.6f", jaccard(results[j][i])))
+				}
+			}
+			outfh.WriteString("\n")
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(jaccardCmd)
+
+	jaccardCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+	jaccardCmd.Flags().BoolP("list", "", false, "output in long format instead of a square matrix")
+}
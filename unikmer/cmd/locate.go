@@ -45,6 +45,17 @@ Attention:
   1. The 'canonical' flags of all files should be consistent.
   2. output location is 1-based.
 
+Output formats (--out-format):
+  text: k-mer and its 1-based locations delimited by comma.
+  bed:  6-column BED (chrom, start, end, name, score, strand) of hits along
+        each sequence. Overlapping hits on the same strand are merged,
+        the name is the first matched k-mer in binary files, and the score
+        is the number of k-mers
+        (1000 at most). The strand is "+" or "-" for the forward or
+        reverse-complement k-mer matched when the 'canonical' flag is off,
+        and "." otherwise. K-mers containing bases other than A, C, G, T
+        are skipped.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		opt := getOptions(cmd)
@@ -75,6 +86,12 @@ Attention:
 		circular := getFlagBool(cmd, "circular")
 
 		genomeFile := getFlagNonEmptyString(cmd, "genome")
+		outFormat := getFlagString(cmd, "out-format")
+		switch outFormat {
+		case "text", "bed":
+		default:
+			checkError(fmt.Errorf("invalid value of flag --out-format: %s, available: text, bed", outFormat))
+		}
 
 		// -----------------------------------------------------------------------
 
@@ -124,6 +141,21 @@ Attention:
 
 		// -----------------------------------------------------------------------
 
+		if outFormat == "bed" {
+			outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+			checkError(err)
+			defer func() {
+				outfh.Flush()
+				if gw != nil {
+					gw.Close()
+				}
+				w.Close()
+			}()
+
+			locateBED(opt, files, genomeFile, k, canonical, circular, outfh)
+			return
+		}
+
 		m := make(map[uint64][]int, mapInitSize)
 
 		var sequence, kmer, preKmer []byte
@@ -297,4 +329,151 @@ func init() {
 	locateCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
 	locateCmd.Flags().BoolP("circular", "", false, "circular genome")
 	locateCmd.Flags().StringP("genome", "g", "", "genome in (gzipped) fasta file")
+	locateCmd.Flags().StringP("out-format", "", "text", "output format: text or bed")
+}
+
+// bedHit is a 0-based half-open interval of k-mer hits on a sequence.
+type bedHit struct {
+	start, end int
+	strand     byte
+	kmer       string // the first k-mer
+	n          int    // number of k-mers
+}
+
+// locateBED loads k-mers of binary files, and writes hits along every
+// sequence of the genome file in BED format.
+func locateBED(opt *Options, files []string, genomeFile string, k int, canonical bool, circular bool, outfh *bufio.Writer) {
+	m := make(map[uint64]struct{}, mapInitSize)
+	for _, file := range files {
+		func() {
+			infh, r, _, err := inStream(file)
+			checkError(err)
+			defer r.Close()
+
+			reader, err := unikmer.NewReader(infh)
+			checkError(err)
+
+			var code uint64
+			for {
+				code, err = reader.ReadCode()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+				}
+				m[code] = struct{}{}
+			}
+		}()
+	}
+	if opt.Verbose {
+		log.Infof("%d k-mers loaded", len(m))
+		log.Infof("reading genome file: %s", genomeFile)
+	}
+
+	fastxReader, err := fastx.NewDefaultReader(genomeFile)
+	checkError(err)
+
+	var record *fastx.Record
+	var sequence, kmer []byte
+	var circKmer []byte // k-mer spanning the origin of circular genome
+	var kcode unikmer.KmerCode
+	var strand byte
+	var ok bool
+	var l, e, i int
+	hits := make([]bedHit, 0, 1024)
+	var nHits int
+	for {
+		record, err = fastxReader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			checkError(err)
+			break
+		}
+
+		sequence = record.Seq.Seq
+		l = len(sequence)
+		if l < k {
+			continue
+		}
+
+		hits = hits[:0]
+		for i = 0; i < l; i++ {
+			e = i + k
+			if e > l {
+				if !circular {
+					break
+				}
+				circKmer = append(append(circKmer[:0], sequence[i:]...), sequence[0:e-l]...)
+				kmer = circKmer
+			} else {
+				kmer = sequence[i:e]
+			}
+
+			kcode, err = unikmer.NewKmerCode(kmer)
+			if err != nil { // invalid bases
+				continue
+			}
+
+			// kcode is updated to the matched k-mer in the database
+			if canonical {
+				kcode = kcode.Canonical()
+				_, ok = m[kcode.Code]
+				strand = '.'
+			} else if _, ok = m[kcode.Code]; ok {
+				strand = '+'
+			} else if _, ok = m[kcode.RevComp().Code]; ok {
+				kcode = kcode.RevComp()
+				strand = '-'
+			}
+			if !ok {
+				continue
+			}
+
+			if e > l { // spanning the origin of circular genome
+				hits = append(hits, bedHit{start: i, end: l, strand: strand, kmer: kcode.String(), n: 1})
+				hits = append(hits, bedHit{start: 0, end: e - l, strand: strand, kmer: kcode.String(), n: 1})
+			} else {
+				hits = append(hits, bedHit{start: i, end: e, strand: strand, kmer: kcode.String(), n: 1})
+			}
+		}
+
+		for _, hit := range mergeBedHits(hits) {
+			score := hit.n
+			if score > 1000 {
+				score = 1000
+			}
+			outfh.WriteString(fmt.Sprintf("%s\t%d\t%d\t%s\t%d\t%c\n",
+				record.ID, hit.start, hit.end, hit.kmer, score, hit.strand))
+			nHits++
+		}
+	}
+	if opt.Verbose {
+		log.Infof("%d intervals saved", nHits)
+	}
+}
+
+// mergeBedHits merges overlapping hits on the same strand,
+// and returns intervals sorted by start positions.
+func mergeBedHits(hits []bedHit) []bedHit {
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].start < hits[j].start })
+
+	merged := make([]bedHit, 0, len(hits))
+	last := make(map[byte]int, 3) // strand -> index of the last interval in merged
+	var j int
+	var ok bool
+	for _, hit := range hits {
+		if j, ok = last[hit.strand]; ok && hit.start <= merged[j].end {
+			if hit.end > merged[j].end {
+				merged[j].end = hit.end
+			}
+			merged[j].n += hit.n
+			continue
+		}
+		last[hit.strand] = len(merged)
+		merged = append(merged, hit)
+	}
+	return merged
 }
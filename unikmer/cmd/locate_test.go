@@ -0,0 +1,46 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeBedHits(t *testing.T) {
+	hits := []bedHit{
+		{start: 5, end: 10, strand: '+', kmer: "b", n: 1},
+		{start: 0, end: 3, strand: '+', kmer: "a", n: 1}, // from circular genome
+		{start: 6, end: 11, strand: '-', kmer: "c", n: 1},
+		{start: 8, end: 13, strand: '+', kmer: "d", n: 1},
+		{start: 13, end: 18, strand: '+', kmer: "e", n: 1}, // adjacent
+		{start: 20, end: 25, strand: '-', kmer: "f", n: 1},
+	}
+	expected := []bedHit{
+		{start: 0, end: 3, strand: '+', kmer: "a", n: 1},
+		{start: 5, end: 18, strand: '+', kmer: "b", n: 3},
+		{start: 6, end: 11, strand: '-', kmer: "c", n: 1},
+		{start: 20, end: 25, strand: '-', kmer: "f", n: 1},
+	}
+	if merged := mergeBedHits(hits); !reflect.DeepEqual(merged, expected) {
+		t.Errorf("unexpected result: %v", merged)
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/shenwei356/breader"
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// lookupCmd represents
+var lookupCmd = &cobra.Command{
+	Use:   "lookup",
+	Short: "Check whether given k-mers exist in a binary file",
+	Long: `Check whether given k-mers exist in a binary file
+
+Output format (tab-delimited):
+  1. query, query k-mer
+  2. kmer, k-mer searched, i.e., canonical k-mer if the file is canonical
+  3. found, "yes" or "no"
+
+Attentions:
+  1. Only one binary file is accepted.
+  2. Length of queries should be equal to K of the file.
+
+Tips:
+  1. For uncompressed sorted files written with an index, e.g., via
+     unikmer.NewWriterWithIndex, every query is located by seeking,
+     instead of scanning the whole file.
+  2. For other sorted files, scanning stops once k-mers exceed the biggest
+     query.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if len(files) > 1 {
+			checkError(fmt.Errorf("no more than one file should be given"))
+		}
+		checkFileSuffix(extDataFile, files...)
+		file := files[0]
+
+		outFile := getFlagString(cmd, "out-file")
+		queries := getFlagStringSlice(cmd, "query")
+		queryFiles := getFlagStringSlice(cmd, "query-file")
+
+		queryList := make([]string, 0, 8)
+		for _, query := range queries {
+			if query == "" {
+				continue
+			}
+			queryList = append(queryList, query)
+		}
+		for _, queryFile := range queryFiles {
+			if queryFile == "" {
+				continue
+			}
+			brdr, err := breader.NewDefaultBufferedReader(queryFile)
+			checkError(err)
+			for chunk := range brdr.Ch {
+				checkError(chunk.Err)
+				for _, data := range chunk.Data {
+					queryList = append(queryList, data.(string))
+				}
+			}
+		}
+		if len(queryList) == 0 {
+			checkError(fmt.Errorf("one of flags -q/--query and -f/--query-file needed"))
+		}
+
+		// seeking is only available for uncompressed sorted files with index
+		var reader *unikmer.Reader
+		var seeking bool
+		if !isStdin(file) {
+			fh, err := os.Open(file)
+			checkError(err)
+			defer fh.Close()
+
+			reader, err = unikmer.NewReaderAt(fh)
+			seeking = err == nil && reader.IsIndexed()
+		}
+		if !seeking {
+			infh, r, _, err := inStream(file)
+			checkError(err)
+			defer r.Close()
+
+			reader, err = unikmer.NewReader(infh)
+			checkError(err)
+		}
+		if opt.Verbose {
+			if seeking {
+				log.Infof("the file is indexed, locating queries by seeking")
+			} else {
+				log.Infof("scanning the file")
+			}
+		}
+
+		// encode queries
+		codes := make([]uint64, len(queryList))
+		for i, query := range queryList {
+			if len(query) != reader.K {
+				checkError(fmt.Errorf("length of query (%d) not equal to K (%d): %s", len(query), reader.K, query))
+			}
+			kcode, err := unikmer.NewKmerCode([]byte(query))
+			if err != nil {
+				checkError(fmt.Errorf("fail to encode query '%s': %s", query, err))
+			}
+			if reader.IsCanonical() {
+				kcode = kcode.Canonical()
+			}
+			codes[i] = kcode.Code
+		}
+
+		found, err := lookupCodes(reader, seeking, codes)
+		checkError(err)
+
+		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		outfh.WriteString("query\tkmer\tfound\n")
+		var status string
+		for i, query := range queryList {
+			status = "no"
+			if found[codes[i]] {
+				status = "yes"
+			}
+			outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\n", query,
+				unikmer.KmerCode{Code: codes[i], K: reader.K}.String(), status))
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(lookupCmd)
+
+	lookupCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+	lookupCmd.Flags().StringSliceP("query", "q", []string{""}, `query k-mers (multiple values delimted by comma supported)`)
+	lookupCmd.Flags().StringSliceP("query-file", "f", []string{""}, "query file (one k-mer per line)")
+}
+
+// lookupCodes checks whether codes exist in a binary file, by seeking
+// for sorted file with index, or by scanning.
+func lookupCodes(reader *unikmer.Reader, seeking bool, codes []uint64) (map[uint64]bool, error) {
+	found := make(map[uint64]bool, len(codes))
+	if len(codes) == 0 {
+		return found, nil
+	}
+	for _, code := range codes {
+		found[code] = false
+	}
+
+	var code, c uint64
+	var err error
+	if seeking {
+		for code = range found {
+			if err = reader.Seek(code); err != nil {
+				return nil, err
+			}
+			c, err = reader.ReadCode()
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			found[code] = err == nil && c == code
+		}
+		return found, nil
+	}
+
+	var maxCode uint64
+	for code = range found {
+		if code > maxCode {
+			maxCode = code
+		}
+	}
+	var ok bool
+	for {
+		code, _, err = reader.ReadCodeWithTaxid()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if _, ok = found[code]; ok {
+			found[code] = true
+		}
+		if reader.IsSorted() && code >= maxCode {
+			break
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,78 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/shenwei356/unikmer"
+)
+
+func TestLookupCodes(t *testing.T) {
+	codes := []uint64{1, 5, 8, 100, 101, 999}
+	queries := []uint64{0, 1, 6, 8, 101, 999, 1000}
+	expected := map[uint64]bool{0: false, 1: true, 6: false, 8: true, 101: true, 999: true, 1000: false}
+
+	for _, flag := range []uint32{0, unikmer.UNIK_SORTED, unikmer.UNIK_SORTED | unikmer.UNIK_INCLUDETAXID} {
+		for _, seeking := range []bool{false, true} {
+			if seeking && flag&unikmer.UNIK_SORTED == 0 {
+				continue
+			}
+
+			var buf bytes.Buffer
+			var writer *unikmer.Writer
+			var err error
+			if seeking {
+				writer, err = unikmer.NewWriterWithIndex(&buf, 11, flag, 2)
+			} else {
+				writer, err = unikmer.NewWriter(&buf, 11, flag)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, code := range codes {
+				writer.WriteCodeWithTaxid(code, 9606)
+			}
+			if err = writer.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			var reader *unikmer.Reader
+			if seeking {
+				reader, err = unikmer.NewReaderAt(bytes.NewReader(buf.Bytes()))
+			} else {
+				reader, err = unikmer.NewReader(&buf)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			found, err := lookupCodes(reader, seeking, queries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(found, expected) {
+				t.Errorf("flag: %d, seeking: %v, expected %v, got %v", flag, seeking, expected, found)
+			}
+		}
+	}
+}
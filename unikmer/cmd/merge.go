@@ -23,6 +23,7 @@ package cmd
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -49,6 +50,9 @@ Attentions:
 Tips:
   1. If you don't need to compute unique or repeated k-mers, 
      use 'unikmer concat -s', which is faster.
+  2. By default (--quick-check), the first 1000 k-mers of each file are
+     checked to catch unsorted files cheaply. Use --full-check to check
+     all k-mers, which doubles the I/O.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		opt := getOptions(cmd)
@@ -60,6 +64,15 @@ Tips:
 		maxOpenFiles := getFlagPositiveInt(cmd, "max-open-files")
 		keepTmpDir := getFlagBool(cmd, "keep-tmp-dir")
 		force := getFlagBool(cmd, "force")
+		opt.IndexInterval = getFlagIndexInterval(cmd, opt)
+		opt.Partition = getFlagPartition(cmd, "partition")
+
+		var nCheck int // number of k-mers to check sortedness, -1 for all
+		if getFlagBool(cmd, "full-check") {
+			nCheck = -1
+		} else if getFlagBool(cmd, "quick-check") {
+			nCheck = mergeQuickCheckSize
+		}
 
 		var err error
 
@@ -165,6 +178,11 @@ Tips:
 				if !reader.IsSorted() {
 					checkError(fmt.Errorf("input files should be sorted"))
 				}
+				if nCheck != 0 {
+					if err = checkSortedCodes(reader, nCheck); err != nil {
+						checkError(fmt.Errorf("%s: %s", file, err))
+					}
+				}
 
 				if k == -1 { // first file
 					k = reader.K
@@ -213,6 +231,8 @@ Tips:
 			log.Warningf("no valid chunk files given")
 			return
 		}
+
+		setProvenance(opt, cmd, files)
 		if opt.Verbose {
 			log.Infof("checking passed")
 		}
@@ -355,4 +375,38 @@ func init() {
 	mergeCmd.Flags().StringP("tmp-dir", "t", "./", `directory for intermediate files`)
 	mergeCmd.Flags().BoolP("keep-tmp-dir", "k", false, `keep tmp dir`)
 	mergeCmd.Flags().BoolP("force", "", false, "overwrite tmp dir")
+	mergeCmd.Flags().BoolP("index", "", false, helpIndex)
+	mergeCmd.Flags().IntP("index-interval", "", 1024, "interval of index for --index")
+	mergeCmd.Flags().StringP("partition", "", "", helpPartition)
+	mergeCmd.Flags().BoolP("quick-check", "", true, "check if the first k-mers of input files are sorted")
+	mergeCmd.Flags().BoolP("full-check", "", false, "check if all k-mers of input files are sorted, overrides --quick-check")
+}
+
+// mergeQuickCheckSize is the number of k-mers to check in quick check.
+const mergeQuickCheckSize = 1000
+
+// checkSortedCodes checks if the first n codes (all if n < 0) are
+// in ascending order.
+func checkSortedCodes(reader *unikmer.Reader, n int) error {
+	var code, prev uint64
+	var err error
+	for i := 0; n < 0 || i < n; i++ {
+		code, err = reader.ReadCode()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if reader.IsIncludeTaxid() {
+			if _, err = reader.ReadTaxid(); err != nil {
+				return err
+			}
+		}
+		if i > 0 && code < prev {
+			return fmt.Errorf("k-mers not sorted: k-mer #%d (%d) < k-mer #%d (%d)", i+1, code, i, prev)
+		}
+		prev = code
+	}
+	return nil
 }
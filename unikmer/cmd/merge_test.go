@@ -0,0 +1,64 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/shenwei356/unikmer"
+)
+
+func TestCheckSortedCodes(t *testing.T) {
+	for _, c := range []struct {
+		codes []uint64
+		n     int
+		ok    bool
+	}{
+		{[]uint64{}, -1, true},
+		{[]uint64{1, 2, 2, 10, 100}, -1, true},
+		{[]uint64{1, 2, 10, 5, 100}, -1, false},
+		{[]uint64{1, 2, 10, 5, 100}, 3, true}, // not checked
+		{[]uint64{1, 2, 10, 5, 100}, 4, false},
+	} {
+		for _, flag := range []uint32{unikmer.UNIK_SORTED, unikmer.UNIK_SORTED | unikmer.UNIK_INCLUDETAXID} {
+			var buf bytes.Buffer
+			writer, err := unikmer.NewWriter(&buf, 21, flag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, code := range c.codes {
+				writer.WriteCodeWithTaxid(code, 9606)
+			}
+			if err = writer.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			reader, err := unikmer.NewReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = checkSortedCodes(reader, c.n); (err == nil) != c.ok {
+				t.Errorf("codes %v, n %d, flag %d: unexpected result: %v", c.codes, c.n, flag, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// rawCmd represents
+var rawCmd = &cobra.Command{
+	Use:   "raw",
+	Short: "Output k-mer codes as headerless raw binary integers",
+	Long: `Output k-mer codes as headerless raw binary integers
+
+Codes are written as 8-byte little-endian unsigned integers without header
+and taxids, for feeding k-mers to external tools.
+
+With --bits, every code occupies 2*k bits, and codes are packed
+continuously, least significant bits first. The last byte is padded
+with zero bits.
+
+Attentions:
+  1. K of all files should be consistent for --bits.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			if len(files) == 1 && isStdin(files[0]) {
+				log.Info("no files given, reading from stdin")
+			} else {
+				log.Infof("%d input file(s) given", len(files))
+			}
+		}
+
+		checkFileSuffix(extDataFile, files...)
+
+		outFile := getFlagString(cmd, "out-file")
+		bits := getFlagBool(cmd, "bits")
+
+		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		var packer *bitPacker
+		if bits {
+			packer = &bitPacker{w: outfh}
+		}
+		buf := make([]byte, 8)
+
+		var infh *bufio.Reader
+		var r *os.File
+		var reader *unikmer.Reader
+		var code uint64
+		var k int = -1
+		var n int64
+		var nfiles = len(files)
+		for i, file := range files {
+			if opt.Verbose {
+				log.Infof("processing file (%d/%d): %s", i+1, nfiles, file)
+			}
+
+			func() {
+				infh, r, _, err = inStream(file)
+				checkError(err)
+				defer r.Close()
+
+				reader, err = unikmer.NewReader(infh)
+				checkError(err)
+
+				if k == -1 {
+					k = reader.K
+				} else if bits && k != reader.K {
+					checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
+				}
+
+				for {
+					code, err = reader.ReadCode()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+					}
+
+					if bits {
+						checkError(packer.write(code, uint(k<<1)))
+					} else {
+						binary.LittleEndian.PutUint64(buf, code)
+						_, err = outfh.Write(buf)
+						checkError(err)
+					}
+					n++
+				}
+			}()
+		}
+		if bits {
+			checkError(packer.flush())
+		}
+
+		if opt.Verbose {
+			log.Infof("%d k-mers saved to %s", n, outFile)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(rawCmd)
+
+	rawCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+	rawCmd.Flags().BoolP("bits", "", false, "pack codes compactly, 2*k bits per code")
+}
+
+// bitPacker packs values of given bits continuously,
+// least significant bits first.
+type bitPacker struct {
+	w   io.Writer
+	acc uint64 // buffered bits
+	n   uint   // number of buffered bits
+	buf [8]byte
+}
+
+// write appends the lowest bits (1-64) of v.
+func (p *bitPacker) write(v uint64, bits uint) error {
+	if bits < 64 {
+		v &= 1<<bits - 1
+	}
+	p.acc |= v << p.n
+	if p.n+bits < 64 {
+		p.n += bits
+		return nil
+	}
+
+	binary.LittleEndian.PutUint64(p.buf[:], p.acc)
+	if _, err := p.w.Write(p.buf[:]); err != nil {
+		return err
+	}
+	if p.n > 0 {
+		p.acc = v >> (64 - p.n) // bits not written yet
+	} else {
+		p.acc = 0
+	}
+	p.n = p.n + bits - 64
+	return nil
+}
+
+// flush writes the buffered bits, the last byte is padded with zero bits.
+func (p *bitPacker) flush() error {
+	if p.n == 0 {
+		return nil
+	}
+	binary.LittleEndian.PutUint64(p.buf[:], p.acc)
+	_, err := p.w.Write(p.buf[:(p.n+7)>>3])
+	p.acc, p.n = 0, 0
+	return err
+}
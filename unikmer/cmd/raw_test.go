@@ -0,0 +1,72 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBitPacker(t *testing.T) {
+	for _, k := range []int{1, 5, 16, 21, 31, 32} {
+		bits := uint(k << 1)
+		var mask uint64 = 1<<bits - 1
+		if bits == 64 {
+			mask = ^uint64(0)
+		}
+
+		values := make([]uint64, 0, 100)
+		for i := uint64(0); i < 100; i++ {
+			values = append(values, (i*0x9E3779B97F4A7C15)&mask)
+		}
+
+		var buf bytes.Buffer
+		p := &bitPacker{w: &buf}
+		for _, v := range values {
+			if err := p.write(v, bits); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := p.flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := (uint(len(values))*bits + 7) / 8; uint(buf.Len()) != expected {
+			t.Errorf("k=%d: unexpected size: %d != %d", k, buf.Len(), expected)
+		}
+
+		// unpack bit by bit
+		data := buf.Bytes()
+		var pos uint
+		for i, expected := range values {
+			var v uint64
+			for j := uint(0); j < bits; j++ {
+				if data[pos>>3]&(1<<(pos&7)) > 0 {
+					v |= 1 << j
+				}
+				pos++
+			}
+			if v != expected {
+				t.Errorf("k=%d: unexpected value #%d: %d != %d", k, i, v, expected)
+			}
+		}
+	}
+}
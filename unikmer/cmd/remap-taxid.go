@@ -0,0 +1,259 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/shenwei356/breader"
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// remapTaxidCmd represents
+var remapTaxidCmd = &cobra.Command{
+	Use:   "remap-taxid",
+	Short: "Remap taxids of k-mers according to a mapping file",
+	Long: `Remap taxids of k-mers according to a mapping file
+
+Attentions:
+  1. The mapping file contains two tab-delimited columns: old taxid and new taxid.
+  2. K-mers are outputted in the same order, and flags are kept.
+  3. Only one input file is allowed.
+  4. Unmapped taxids are handled according to -u/--unmapped:
+       error: report an error (default)
+       drop:  drop k-mers with unmapped taxids
+       keep:  keep the original taxids
+
+Tips:
+  1. It's useful for translating taxids between taxonomies, e.g., GTDB and NCBI.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			if len(files) == 1 && isStdin(files[0]) {
+				log.Info("no files given, reading from stdin")
+			} else {
+				log.Infof("%d input file(s) given", len(files))
+			}
+		}
+
+		if len(files) > 1 {
+			checkError(fmt.Errorf("no more than one file should be given"))
+		}
+
+		checkFileSuffix(extDataFile, files...)
+		setProvenance(opt, cmd, files)
+
+		outFile := getFlagString(cmd, "out-prefix")
+		mapFile := getFlagNonEmptyString(cmd, "map")
+		unmapped := getFlagString(cmd, "unmapped")
+		var dropUnmapped, keepUnmapped bool
+		switch unmapped {
+		case "error":
+		case "drop":
+			dropUnmapped = true
+		case "keep":
+			keepUnmapped = true
+		default:
+			checkError(fmt.Errorf("invalid value of flag -u/--unmapped: %s, available: error, drop, keep", unmapped))
+		}
+
+		if opt.Verbose {
+			log.Infof("loading taxid mapping file: %s", mapFile)
+		}
+		taxidMap, maxTaxid, err := loadTaxidMap(mapFile, opt.NumCPUs)
+		checkError(err)
+		if opt.Verbose {
+			log.Infof("%d taxid pairs loaded", len(taxidMap))
+		}
+
+		file := files[0]
+		infh, r, _, err := inStream(file)
+		checkError(err)
+		defer r.Close()
+
+		reader, err := unikmer.NewReader(infh)
+		checkError(err)
+
+		if !reader.HasTaxidInfo() {
+			checkError(fmt.Errorf("no taxids found in file: %s", file))
+		}
+
+		var ok bool
+		remap := func(taxid uint32) (uint32, bool) {
+			if newTaxid, found := taxidMap[taxid]; found {
+				return newTaxid, true
+			}
+			if keepUnmapped {
+				return taxid, true
+			}
+			if dropUnmapped {
+				return 0, false
+			}
+			checkError(fmt.Errorf("taxid not found in mapping file: %d", taxid))
+			return 0, false
+		}
+
+		if !isStdout(outFile) {
+			outFile += extDataFile
+		}
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		writer, err := unikmer.NewWriter(outfh, reader.K, reader.Flag)
+		checkError(err)
+		writer.Description = opt.Provenance
+
+		var n int64
+		if !reader.IsIncludeTaxid() { // only global taxid
+			var taxid uint32
+			taxid, ok = remap(reader.GetGlobalTaxid())
+			if !ok {
+				log.Warningf("global taxid %d unmapped, all k-mers dropped", reader.GetGlobalTaxid())
+				checkError(writer.Flush())
+				return
+			}
+			checkError(writer.SetGlobalTaxid(taxid))
+			writer.Number = reader.Number
+		} else {
+			if keepUnmapped && maxUint32N(reader.GetTaxidBytesLength()) > maxTaxid {
+				maxTaxid = maxUint32N(reader.GetTaxidBytesLength())
+			}
+			checkError(writer.SetMaxTaxid(maxTaxid))
+			if !dropUnmapped {
+				writer.Number = reader.Number
+			}
+		}
+
+		var code uint64
+		var taxid uint32
+		var rc bool
+		var count uint32
+		var nDropped int64
+		for {
+			code, rc, count, taxid, err = reader.ReadRecord()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+			}
+
+			if reader.IsIncludeTaxid() {
+				taxid, ok = remap(taxid)
+				if !ok {
+					nDropped++
+					continue
+				}
+			}
+
+			checkError(writer.WriteRecord(code, rc, count, taxid))
+			n++
+		}
+
+		checkError(writer.Flush())
+		if opt.Verbose {
+			if nDropped > 0 {
+				log.Infof("%d k-mers with unmapped taxids dropped", nDropped)
+			}
+			log.Infof("%d k-mers saved to %s", n, outFile)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(remapTaxidCmd)
+
+	remapTaxidCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
+	remapTaxidCmd.Flags().StringP("map", "m", "", `tab-delimited mapping file of old and new taxids`)
+	remapTaxidCmd.Flags().StringP("unmapped", "u", "error", `how to handle unmapped taxids: error, drop, or keep`)
+}
+
+// loadTaxidMap loads a two-column tab-delimited mapping file of taxids,
+// the maximum new taxid is also returned.
+func loadTaxidMap(file string, threads int) (map[uint32]uint32, uint32, error) {
+	parseFunc := func(line string) (interface{}, bool, error) {
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" || line[0] == '#' {
+			return nil, false, nil
+		}
+		items := strings.Split(line, "\t")
+		if len(items) < 2 {
+			return nil, false, fmt.Errorf("two columns needed: %s", line)
+		}
+		old, err := strconv.ParseUint(items[0], 10, 32)
+		if err != nil || old == 0 {
+			return nil, false, fmt.Errorf("invalid taxid: %s", items[0])
+		}
+		new, err := strconv.ParseUint(items[1], 10, 32)
+		if err != nil || new == 0 {
+			return nil, false, fmt.Errorf("invalid taxid: %s", items[1])
+		}
+		return [2]uint32{uint32(old), uint32(new)}, true, nil
+	}
+
+	reader, err := breader.NewBufferedReader(file, threads, 100, parseFunc)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	m := make(map[uint32]uint32, 1024)
+	var maxTaxid uint32
+	var p [2]uint32
+	var data interface{}
+	for chunk := range reader.Ch {
+		if chunk.Err != nil {
+			return nil, 0, chunk.Err
+		}
+		for _, data = range chunk.Data {
+			p = data.([2]uint32)
+			if v, ok := m[p[0]]; ok && v != p[1] {
+				return nil, 0, fmt.Errorf("taxid %d mapped to different taxids: %d, %d", p[0], v, p[1])
+			}
+			m[p[0]] = p[1]
+			if p[1] > maxTaxid {
+				maxTaxid = p[1]
+			}
+		}
+	}
+	return m, maxTaxid, nil
+}
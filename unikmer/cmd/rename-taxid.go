@@ -0,0 +1,233 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/shenwei356/util/pathutil"
+	"github.com/spf13/cobra"
+)
+
+// renameTaxidCmd represents
+var renameTaxidCmd = &cobra.Command{
+	Use:   "rename-taxid",
+	Short: "Update stale taxids of k-mers with merged.dmp and delnodes.dmp",
+	Long: `Update stale taxids of k-mers with merged.dmp and delnodes.dmp
+
+Taxids merged by NCBI are replaced with their current ones according to
+merged.dmp, and k-mers with taxids in delnodes.dmp are handled according
+to -d/--deleted:
+    drop:  drop these k-mers (default)
+    keep:  keep the original taxids
+    error: report an error
+
+Attentions:
+  1. Input file should have taxid information.
+  2. K-mers are outputted in the same order, and flags are kept.
+  3. Only one input file is allowed.
+  4. merged.dmp and delnodes.dmp are read from --data-dir.
+
+Tips:
+  1. Use "unikmer remap-taxid" for translating taxids with a custom
+     mapping file.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			if len(files) == 1 && isStdin(files[0]) {
+				log.Info("no files given, reading from stdin")
+			} else {
+				log.Infof("%d input file(s) given", len(files))
+			}
+		}
+
+		if len(files) > 1 {
+			checkError(fmt.Errorf("no more than one file should be given"))
+		}
+
+		checkFileSuffix(extDataFile, files...)
+		setProvenance(opt, cmd, files)
+
+		outFile := getFlagString(cmd, "out-prefix")
+		deleted := getFlagString(cmd, "deleted")
+		var dropDeleted, keepDeleted bool
+		switch deleted {
+		case "error":
+		case "drop":
+			dropDeleted = true
+		case "keep":
+			keepDeleted = true
+		default:
+			checkError(fmt.Errorf("invalid value of flag -d/--deleted: %s, available: drop, keep, error", deleted))
+		}
+
+		file := files[0]
+		infh, r, _, err := inStream(file)
+		checkError(err)
+		defer r.Close()
+
+		reader, err := unikmer.NewReader(infh)
+		checkError(err)
+
+		if !reader.HasTaxidInfo() {
+			checkError(fmt.Errorf("no taxids found in file: %s", file))
+		}
+
+		taxondb := loadTaxonomy(opt, false)
+
+		delFile := filepath.Join(opt.DataDir, "delnodes.dmp")
+		existed, err := pathutil.Exists(delFile)
+		if err != nil {
+			checkError(fmt.Errorf("err on checking file delnodes.dmp: %s", err))
+		}
+		if existed {
+			checkError(taxondb.LoadDeletedNodesFromNCBI(delFile))
+		}
+		if opt.Verbose {
+			log.Infof("%d deleted nodes loaded", len(taxondb.DelNodes))
+		}
+
+		var nRenamed, nDropped int64
+
+		// rename returns the current taxid, and false if the k-mer should be dropped
+		rename := func(taxid uint32) (uint32, bool) {
+			if taxid == 0 {
+				return taxid, true
+			}
+			if _, ok := taxondb.DelNodes[taxid]; ok {
+				if keepDeleted {
+					return taxid, true
+				}
+				if dropDeleted {
+					nDropped++
+					return 0, false
+				}
+				checkError(fmt.Errorf("deleted taxid found: %d", taxid))
+			}
+
+			// merged taxids might be merged again
+			newTaxid := taxid
+			for i := 0; i < len(taxondb.MergeNodes); i++ {
+				next, ok := taxondb.MergeNodes[newTaxid]
+				if !ok || next == newTaxid {
+					break
+				}
+				newTaxid = next
+			}
+			if newTaxid != taxid {
+				nRenamed++
+			}
+			return newTaxid, true
+		}
+
+		if !isStdout(outFile) {
+			outFile += extDataFile
+		}
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		writer, err := unikmer.NewWriter(outfh, reader.K, reader.Flag)
+		checkError(err)
+		writer.Description = opt.Provenance
+
+		var ok bool
+		var n int64
+		if !reader.IsIncludeTaxid() { // only global taxid
+			var taxid uint32
+			taxid, ok = rename(reader.GetGlobalTaxid())
+			if !ok {
+				log.Warningf("global taxid %d deleted, all k-mers dropped", reader.GetGlobalTaxid())
+				checkError(writer.Flush())
+				return
+			}
+			checkError(writer.SetGlobalTaxid(taxid))
+			writer.Number = reader.Number
+		} else {
+			checkError(writer.SetMaxTaxid(opt.MaxTaxid)) // follow taxondb
+			if !dropDeleted {
+				writer.Number = reader.Number
+			}
+		}
+
+		var code uint64
+		var taxid uint32
+		var rc bool
+		var count uint32
+		for {
+			code, rc, count, taxid, err = reader.ReadRecord()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+			}
+
+			if reader.IsIncludeTaxid() {
+				taxid, ok = rename(taxid)
+				if !ok {
+					continue
+				}
+			}
+
+			checkError(writer.WriteRecord(code, rc, count, taxid))
+			n++
+		}
+
+		checkError(writer.Flush())
+		if opt.Verbose {
+			if reader.IsIncludeTaxid() {
+				log.Infof("%d k-mers with merged taxids renamed", nRenamed)
+				log.Infof("%d k-mers with deleted taxids dropped", nDropped)
+			} else if nRenamed > 0 {
+				log.Infof("global taxid renamed")
+			}
+			log.Infof("%d k-mers saved to %s", n, outFile)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(renameTaxidCmd)
+
+	renameTaxidCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
+	renameTaxidCmd.Flags().StringP("deleted", "d", "drop", `how to handle deleted taxids: drop, keep, or error`)
+}
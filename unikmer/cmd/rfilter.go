@@ -132,7 +132,7 @@ Attentions:
 		if !isStdout(outFile) {
 			outFile += extDataFile
 		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -149,6 +149,8 @@ Attentions:
 		var reader *unikmer.Reader
 		var code uint64
 		var taxid uint32
+		var rc bool
+		var count uint32
 		var k int = -1
 		var canonical bool
 		var hasTaxid bool
@@ -197,7 +199,7 @@ Attentions:
 				}
 
 				for {
-					code, taxid, err = reader.ReadCodeWithTaxid()
+					code, rc, count, taxid, err = reader.ReadRecord()
 					if err != nil {
 						if err == io.EOF {
 							break
@@ -221,7 +223,7 @@ Attentions:
 					}
 
 					n++
-					writer.WriteCodeWithTaxid(code, taxid) // not need to check err
+					writer.WriteRecord(code, rc, count, taxid) // not need to check err
 					// fmt.Printf("%d\t%s\n", taxid, rank)
 				}
 
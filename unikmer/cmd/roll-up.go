@@ -0,0 +1,266 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/shenwei356/util/pathutil"
+	"github.com/spf13/cobra"
+)
+
+// rollUpCmd represents
+var rollUpCmd = &cobra.Command{
+	Use:   "roll-up",
+	Short: "Build per-rank databases by mapping taxids up to given ranks",
+	Long: `Build per-rank databases by mapping taxids up to given ranks
+
+For every rank in -r/--ranks, taxids of k-mers are mapped to their ancestors
+at the rank, and k-mers are saved to <out-dir>/<rank>.unik, in a single pass
+over input files.
+
+Attentions:
+  1. The 'canonical' flags of all files should be consistent.
+  2. Input files should ALL have taxid information.
+  3. For a rank, k-mers with taxids above the rank or without ancestors at
+     the rank are discarded, so are k-mers shared by different taxa at the rank.
+  4. All k-mers will be loaded into RAM, for every rank.
+  5. Output files are sorted.
+
+Tips:
+  1. It's useful for building databases in multiple resolutions from one source,
+     e.g., -r species,genus,family.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		ranks := getFlagCommaSeparatedStrings(cmd, "ranks")
+		if len(ranks) == 0 {
+			checkError(fmt.Errorf("flag -r/--ranks needed"))
+		}
+		ranksMap := make(map[string]struct{}, len(ranks))
+		for _, rank := range ranks {
+			if _, ok := ranksMap[rank]; ok {
+				checkError(fmt.Errorf("duplicated rank: %s", rank))
+			}
+			ranksMap[rank] = struct{}{}
+		}
+
+		outdir := getFlagString(cmd, "out-dir")
+		force := getFlagBool(cmd, "force")
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			if len(files) == 1 && isStdin(files[0]) {
+				log.Info("no files given, reading from stdin")
+			} else {
+				log.Infof("%d input file(s) given", len(files))
+			}
+		}
+
+		checkFileSuffix(extDataFile, files...)
+		setProvenance(opt, cmd, files)
+
+		if opt.IgnoreTaxid {
+			checkError(fmt.Errorf("flag -I/--ignore-taxid not allowed"))
+		}
+
+		taxondb := loadTaxonomy(opt, true)
+		for _, rank := range ranks {
+			if _, ok := taxondb.Ranks[rank]; !ok {
+				checkError(fmt.Errorf("rank not found in taxonomy: %s", rank))
+			}
+		}
+
+		if outdir == "" {
+			if isStdin(files[0]) {
+				outdir = "stdin.roll-up"
+			} else {
+				outdir = files[0] + ".roll-up"
+			}
+		}
+		pwd, _ := os.Getwd()
+		if outdir != "./" && outdir != "." && pwd != filepath.Clean(outdir) {
+			existed, err := pathutil.DirExists(outdir)
+			checkError(err)
+			if existed {
+				empty, err := pathutil.IsEmpty(outdir)
+				checkError(err)
+				if !empty {
+					if force {
+						checkError(os.RemoveAll(outdir))
+						checkError(os.MkdirAll(outdir, 0777))
+					} else {
+						log.Warningf("outdir not empty: %s, you can use --force to overwrite", outdir)
+					}
+				}
+			} else {
+				checkError(os.MkdirAll(outdir, 0777))
+			}
+		}
+
+		// rank -> code -> taxid at the rank, 0 for discarded k-mers
+		mts := make([]map[uint64]uint32, len(ranks))
+		// rank -> taxid -> taxid at the rank
+		caches := make([]map[uint32]uint32, len(ranks))
+		for i := range ranks {
+			mts[i] = make(map[uint64]uint32, mapInitSize)
+			caches[i] = make(map[uint32]uint32, 1024)
+		}
+
+		var infh *bufio.Reader
+		var r *os.File
+		var reader *unikmer.Reader
+		var code uint64
+		var taxid, taxidR, prev uint32
+		var k int = -1
+		var canonical bool
+		var ok bool
+		var i int
+		var mt map[uint64]uint32
+		var cache map[uint32]uint32
+		var nfiles = len(files)
+		for j, file := range files {
+			if opt.Verbose {
+				log.Infof("processing file (%d/%d): %s", j+1, nfiles, file)
+			}
+
+			func() {
+				infh, r, _, err = inStream(file)
+				checkError(err)
+				defer r.Close()
+
+				reader, err = unikmer.NewReader(infh)
+				checkError(err)
+
+				if !reader.HasTaxidInfo() {
+					checkError(fmt.Errorf("taxid information not found in file: %s", file))
+				}
+
+				if k == -1 {
+					k = reader.K
+					canonical = reader.IsCanonical()
+				} else {
+					if k != reader.K {
+						checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
+					}
+					if reader.IsCanonical() != canonical {
+						checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+					}
+				}
+
+				for {
+					code, taxid, err = reader.ReadCodeWithTaxid()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+					}
+
+					for i, cache = range caches {
+						if taxidR, ok = cache[taxid]; !ok {
+							taxidR = taxondb.AtRank(taxid, ranks[i])
+							cache[taxid] = taxidR
+						}
+
+						mt = mts[i]
+						if prev, ok = mt[code]; !ok {
+							mt[code] = taxidR
+						} else if prev != taxidR {
+							mt[code] = 0 // shared by different taxa at the rank
+						}
+					}
+				}
+			}()
+		}
+
+		var mode uint32
+		mode |= unikmer.UNIK_SORTED | unikmer.UNIK_INCLUDETAXID
+		if canonical {
+			mode |= unikmer.UNIK_CANONICAL
+		}
+
+		var codesTaxids []unikmer.CodeTaxid
+		for i, rank := range ranks {
+			mt = mts[i]
+			codesTaxids = codesTaxids[:0]
+			for code, taxid = range mt {
+				if taxid > 0 {
+					codesTaxids = append(codesTaxids, unikmer.CodeTaxid{Code: code, Taxid: taxid})
+				}
+			}
+			mts[i] = nil
+			sort.Sort(unikmer.CodeTaxidSlice(codesTaxids))
+
+			outFile := filepath.Join(outdir, strings.Replace(rank, " ", "_", -1)+extDataFile)
+			func() {
+				outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
+				checkError(err)
+				defer func() {
+					outfh.Flush()
+					if gw != nil {
+						gw.Close()
+					}
+					w.Close()
+				}()
+
+				writer, err := unikmer.NewWriter(outfh, k, mode)
+				checkError(err)
+				writer.Description = opt.Provenance
+				writer.SetMaxTaxid(opt.MaxTaxid) // follow taxondb
+				writer.Number = int64(len(codesTaxids))
+
+				for _, ct := range codesTaxids {
+					checkError(writer.WriteCodeWithTaxid(ct.Code, ct.Taxid))
+				}
+				checkError(writer.Flush())
+			}()
+
+			if opt.Verbose {
+				log.Infof("%d k-mers at rank %s saved to %s, %d discarded",
+					len(codesTaxids), rank, outFile, len(mt)-len(codesTaxids))
+			}
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(rollUpCmd)
+
+	rollUpCmd.Flags().StringP("ranks", "r", "species,genus,family", `comma-separated ranks to map taxids to`)
+	rollUpCmd.Flags().StringP("out-dir", "O", "", `output directory`)
+	rollUpCmd.Flags().BoolP("force", "", false, `overwrite output directory`)
+}
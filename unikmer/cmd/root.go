@@ -71,6 +71,15 @@ Dataset (optional):
   all taxids should be in range of [1, %d]
 
 `, VERSION, maxUint32),
+
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openLogFile(cmd, args)
+		startMemReport(cmd, args)
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		stopMemReport(cmd, args)
+		closeLogFile(cmd, args)
+	},
 }
 
 // Execute adds all child commands to the root command sets flags appropriately.
@@ -97,14 +106,27 @@ func init() {
 	RootCmd.PersistentFlags().IntP("threads", "j", defaultThreads, "number of CPUs to use. (default value: 1 for single-CPU PC, 2 for others)")
 	RootCmd.PersistentFlags().BoolP("verbose", "", false, "print verbose information")
 	RootCmd.PersistentFlags().BoolP("no-compress", "C", false, "do not compress binary file (not recommended)")
-	RootCmd.PersistentFlags().IntP("compression-level", "", flate.DefaultCompression, "compression level")
+	RootCmd.PersistentFlags().IntP("compression-level", "", flate.DefaultCompression, "compression level, -2 to 9 for gzip, 1 to 22 for zstd, -1 for the default level. gzipped text outputs use the default level if it is not valid for gzip")
+	RootCmd.PersistentFlags().StringP("compress", "", "gzip", `compression codec of output binary files, available: "gzip", "zstd". the codec of input files is detected automatically`)
 	RootCmd.PersistentFlags().BoolP("compact", "c", false, "write compact binary file with little loss of speed")
 	RootCmd.PersistentFlags().StringP("infile-list", "i", "", "file of input files list (one file per line), if given, they are appended to files from cli arguments")
+	RootCmd.PersistentFlags().BoolP("report-mem", "", false, "report peak memory usage at the end")
+	RootCmd.PersistentFlags().BoolP("overwrite-input", "", false, "allow output files to overwrite input files, only show warning message")
+	RootCmd.PersistentFlags().StringP("log-file", "", "", "write logs (information and warnings) into this file, only errors are still written to stderr")
+	RootCmd.PersistentFlags().BoolP("no-provenance", "", false, "do not stamp provenance (command, version, input files and time) into description of output binary file, for byte-reproducible outputs")
+	RootCmd.PersistentFlags().BoolP("progress", "", false, "report progress (k-mers processed, percentage, rate and ETA) of reading input files, for commands like diff and inter")
 
 	RootCmd.PersistentFlags().Uint32P("max-taxid", "", 1<<32-1, "for smaller taxids, we can use less space to store taxids. default value is 1<<32-1, that's enough for NCBI Taxonomy taxids")
 	RootCmd.PersistentFlags().BoolP("ignore-taxid", "I", false, "ignore taxonomy information")
 	RootCmd.PersistentFlags().StringP("data-dir", "", defaultDataDir, "directory containing NCBI Taxonomy files, including nodes.dmp, names.dmp, merged.dmp and delnodes.dmp")
+	RootCmd.PersistentFlags().BoolP("validate-taxonomy", "", false, "check cycles and broken lineages in taxonomy after loading it")
+	RootCmd.PersistentFlags().BoolP("strict-open", "", false, "sample the first k-mers of binary files on opening, and warn if they contradict the 'canonical' flag")
 	// RootCmd.PersistentFlags().BoolP("cache-lca", "", false, "cache LCA queries")
+
+	RootCmd.PersistentFlags().IntP("map-init-size", "", 1<<20, "initial size of maps and lists for storing k-mers, for tuning memory and speed")
+	RootCmd.PersistentFlags().MarkHidden("map-init-size")
 }
 
 const helpSort = "sort k-mers, this significantly reduce file size for k<=25. This flag overwrites global flag -c/--compact"
+
+const helpFailIfEmpty = "exit with code 2 if the result is empty, for branching in scripts"
@@ -24,6 +24,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"runtime"
 
@@ -37,7 +38,13 @@ var sampleCmd = &cobra.Command{
 	Short: "Sample k-mers from binary files",
 	Long: `Sample k-mers from binary files.
 
-The Sampling type is fixed sampling.
+Two sampling types are supported:
+  1. Fixed sampling (default): k-mers at positions of start, start+window,
+     start+2*window, ... are outputted.
+  2. Hash sampling (-p/--proportion): every k-mer is outputted if its hash
+     value is below proportion * (2^64-1), so the result is deterministic,
+     independent of k-mer order, and roughly a proportion of all k-mers.
+     Different seeds (--seed) produce different subsets.
 
 Attentions:
   1. The 'canonical' flags of all files should be consistent.
@@ -68,11 +75,21 @@ Attentions:
 
 		start := getFlagPositiveInt(cmd, "start")
 		window := getFlagPositiveInt(cmd, "window")
+		proportion := getFlagNonNegativeFloat64(cmd, "proportion")
+		if proportion > 1 {
+			checkError(fmt.Errorf("value of flag -p/--proportion should be in range of (0, 1]"))
+		}
+		byHash := proportion > 0
+		seed := unikmer.Hash64(getFlagUint64(cmd, "seed"))
+		var maxHash uint64 = math.MaxUint64
+		if proportion < 1 {
+			maxHash = uint64(proportion * float64(math.MaxUint64))
+		}
 
 		if !isStdout(outFile) {
 			outFile += extDataFile
 		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -89,6 +106,8 @@ Attentions:
 		var reader *unikmer.Reader
 		var code uint64
 		var taxid uint32
+		var rc bool
+		var count uint32
 		var k int = -1
 		var canonical bool
 		var hasTaxid bool
@@ -134,7 +153,7 @@ Attentions:
 
 				j = 0
 				for {
-					code, taxid, err = reader.ReadCodeWithTaxid()
+					code, rc, count, taxid, err = reader.ReadRecord()
 					if err != nil {
 						if err == io.EOF {
 							break
@@ -142,10 +161,18 @@ Attentions:
 						checkError(err)
 					}
 
+					if byHash {
+						if unikmer.Hash64(code^seed) <= maxHash {
+							n++
+							writer.WriteRecord(code, rc, count, taxid)
+						}
+						continue
+					}
+
 					j++
 					if j >= start && (j-start)%window == 0 {
 						n++
-						writer.WriteCodeWithTaxid(code, taxid)
+						writer.WriteRecord(code, rc, count, taxid)
 					}
 				}
 
@@ -171,6 +198,8 @@ func init() {
 
 	sampleCmd.Flags().IntP("start", "s", 1, `start location`)
 	sampleCmd.Flags().IntP("window", "w", 1, `window size`)
+	sampleCmd.Flags().Float64P("proportion", "p", 0, `sample k-mers by hash values with this proportion, instead of fixed sampling. 0 for disable`)
+	sampleCmd.Flags().Uint64P("seed", "", 1, `seed for hash sampling`)
 
 	sampleCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
 }
@@ -0,0 +1,141 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// sharedCmd represents
+var sharedCmd = &cobra.Command{
+	Use:     "shared",
+	Aliases: []string{"shared-count"},
+	Short:   "Count the number of files every k-mer appears in",
+	Long: `Count the number of files every k-mer appears in
+
+Sorted input files are k-way merged in streaming, and every distinct k-mer
+is outputted with the number of files containing it, in ascending order
+of k-mer codes.
+
+Attentions:
+  1. The 'canonical' flags of all files should be consistent.
+  2. All input files should be sorted, and stdin is not supported.
+  3. Duplicated k-mers in a file are counted once.
+
+Tips:
+  1. Use --min-files to only output k-mers shared by at least N files,
+     i.e., a soft intersection, which is useful for finding core k-mers
+     of a group of genomes. '--min-files' equal to the number of files
+     gives the intersection.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			if len(files) == 1 && isStdin(files[0]) {
+				log.Info("no files given, reading from stdin")
+			} else {
+				log.Infof("%d input file(s) given", len(files))
+			}
+		}
+
+		checkFileSuffix(extDataFile, files...)
+
+		outFile := getFlagString(cmd, "out-file")
+		minFiles := getFlagPositiveInt(cmd, "min-files")
+		if minFiles > len(files) {
+			checkError(fmt.Errorf("value of --min-files (%d) should not be greater than the number of files (%d)", minFiles, len(files)))
+		}
+
+		k, _ := checkSortedFiles(files)
+
+		readers := make([]*unikmer.Reader, len(files))
+		for i, file := range files {
+			infh, r, _, err := inStream(file)
+			checkError(err)
+			defer r.Close()
+
+			readers[i], err = unikmer.NewReader(infh)
+			checkError(err)
+		}
+
+		merger, err := unikmer.NewCodeMerger(readers, nil)
+		checkError(err)
+
+		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		outfh.WriteString("kmer\tfiles\n")
+
+		var code uint64
+		var presentIn []int
+		var n, nAll int64
+		for {
+			code, _, presentIn, err = merger.Next()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+			}
+			nAll++
+
+			if len(presentIn) < minFiles {
+				continue
+			}
+			outfh.WriteString(unikmer.KmerCode{Code: code, K: k}.String())
+			outfh.WriteString("\t" + strconv.Itoa(len(presentIn)) + "\n")
+			n++
+		}
+
+		if opt.Verbose {
+			log.Infof("%d of %d distinct k-mers shared by at least %d file(s)", n, nAll, minFiles)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(sharedCmd)
+
+	sharedCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+	sharedCmd.Flags().IntP("min-files", "m", 1, `only output k-mers shared by at least this number of files`)
+}
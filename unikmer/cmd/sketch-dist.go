@@ -0,0 +1,120 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// sketchDistCmd represents
+var sketchDistCmd = &cobra.Command{
+	Use:   "sketch-dist",
+	Short: "Compute pairwise Mash distances of MinHash sketches",
+	Long: `Compute pairwise Mash distances of MinHash sketches
+
+Jaccard index of every pair of sketches created by "unikmer sketch" is
+estimated with the s smallest hash values of the union of the two sketches,
+where s is the smaller sketch size, and converted to the Mash distance:
+  D = -1/k * ln(2j/(1+j)), or 1 for j = 0.
+
+Output format (tab-delimited):
+  1. file1
+  2. file2
+  3. shared, number of shared hash values / s
+  4. jaccard, estimated Jaccard index
+  5. distance, Mash distance
+
+Attentions:
+  1. K and 'canonical' flags of all sketches should be consistent.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			log.Infof("%d input file(s) given", len(files))
+		}
+
+		if len(files) < 2 {
+			checkError(fmt.Errorf("at least two files needed"))
+		}
+
+		outFile := getFlagString(cmd, "out-file")
+
+		sketches := make([]*unikmer.MinHash, len(files))
+		for i, file := range files {
+			func() {
+				infh, r, _, err := inStream(file)
+				checkError(err)
+				defer r.Close()
+
+				sketches[i], err = unikmer.ReadMinHash(infh)
+				if err != nil {
+					checkError(fmt.Errorf("%s: %s", file, err))
+				}
+			}()
+			if sketches[i].K != sketches[0].K || sketches[i].Canonical != sketches[0].Canonical {
+				checkError(fmt.Errorf("K or 'canonical' flag of sketch '%s' not consistent with previous ones", file))
+			}
+		}
+
+		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		k := sketches[0].K
+		var jaccard float64
+		var shared, total int
+		outfh.WriteString("file1\tfile2\tshared\tjaccard\tdistance\n")
+		for i := 0; i < len(files)-1; i++ {
+			for j := i + 1; j < len(files); j++ {
+				jaccard, shared, total, err = sketches[i].Jaccard(sketches[j])
+				checkError(err)
+				outfh.WriteString(fmt.Sprintf("%s\t%s\t%d/%d\t%.6f\t%.6f\n",
+					files[i], files[j], shared, total, jaccard, unikmer.MashDistance(jaccard, k)))
+			}
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(sketchDistCmd)
+
+	sketchDistCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+}
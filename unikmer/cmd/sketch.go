@@ -0,0 +1,162 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// sketchCmd represents
+var sketchCmd = &cobra.Command{
+	Use:   "sketch",
+	Short: "Create MinHash sketches of binary files",
+	Long: `Create MinHash sketches of binary files
+
+The s smallest hash values of k-mers are kept as a bottom-k MinHash sketch,
+like Mash, which is small and cheap to compare with "unikmer sketch-dist".
+
+Output:
+  For one input file, the sketch is written to -o/--out-file.
+  For multiple input files, sketches are saved to files with the suffix
+  ".sketch" in -O/--out-dir, or the directories of input files by default.
+
+Attentions:
+  1. Sketches can only be compared with ones of the same K and 'canonical' flag,
+     canonical k-mers are recommended.
+  2. Taxids are ignored.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			if len(files) == 1 && isStdin(files[0]) {
+				log.Info("no files given, reading from stdin")
+			} else {
+				log.Infof("%d input file(s) given", len(files))
+			}
+		}
+
+		checkFileSuffix(extDataFile, files...)
+
+		outFile := getFlagString(cmd, "out-file")
+		outDir := getFlagString(cmd, "out-dir")
+		size := getFlagPositiveInt(cmd, "size")
+
+		outFiles := make([]string, len(files))
+		if len(files) == 1 {
+			outFiles[0] = outFile
+		} else {
+			if !isStdout(outFile) {
+				log.Warningf("flag -o/--out-file ignored for multiple input files")
+			}
+			if outDir != "" {
+				checkError(os.MkdirAll(outDir, 0777))
+			}
+			for i, file := range files {
+				if isStdin(file) {
+					checkError(fmt.Errorf("stdin not supported for multiple input files"))
+				}
+				if outDir == "" {
+					outFiles[i] = file + extSketchFile
+				} else {
+					outFiles[i] = filepath.Join(outDir, filepath.Base(file)+extSketchFile)
+				}
+			}
+		}
+
+		var wg sync.WaitGroup
+		tokens := make(chan int, opt.NumCPUs)
+		for i, file := range files {
+			wg.Add(1)
+			tokens <- 1
+			go func(file, outFile string) {
+				defer func() {
+					wg.Done()
+					<-tokens
+				}()
+
+				infh, r, _, err := inStream(file)
+				checkError(err)
+				defer r.Close()
+
+				reader, err := unikmer.NewReader(infh)
+				checkError(err)
+
+				mh, err := unikmer.NewMinHash(reader.K, reader.IsCanonical(), size)
+				checkError(err)
+
+				var code uint64
+				for {
+					code, _, err = reader.ReadCodeWithTaxid()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(fmt.Errorf("%s: %s", file, err))
+					}
+					mh.Push(code)
+				}
+
+				outfh, gw, w, err := outStream(outFile, false, opt.CompressionLevel)
+				checkError(err)
+				defer func() {
+					outfh.Flush()
+					if gw != nil {
+						gw.Close()
+					}
+					w.Close()
+				}()
+				_, err = mh.WriteTo(outfh)
+				checkError(err)
+
+				if opt.Verbose {
+					log.Infof("%d hash values of %s saved to %s", len(mh.Values()), file, outFile)
+				}
+			}(file, outFiles[i])
+		}
+		wg.Wait()
+	},
+}
+
+// extSketchFile is the suffix of MinHash sketch files.
+const extSketchFile = ".sketch"
+
+func init() {
+	RootCmd.AddCommand(sketchCmd)
+
+	sketchCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout), for one input file`)
+	sketchCmd.Flags().StringP("out-dir", "O", "", `output directory for multiple input files, default: directories of input files`)
+	sketchCmd.Flags().IntP("size", "s", 1000, "sketch size, i.e., number of the smallest hash values to keep")
+}
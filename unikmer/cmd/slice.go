@@ -0,0 +1,195 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// sliceCmd represents
+var sliceCmd = &cobra.Command{
+	Use:   "slice",
+	Short: "Extract k-mers in a range of record ranks",
+	Long: `Extract k-mers in a range of record ranks
+
+K-mers with 0-based ranks in the half-open range [--from, --to) are copied
+to the output, with flags of the input file preserved.
+
+For uncompressed, unsorted binary files (e.g., produced with global flag
+-C/--no-compress), records are of fixed width, so we seek to --from
+directly. Otherwise, the first --from records are read and skipped.
+
+Attentions:
+  1. Only one input file is accepted.
+  2. The number of k-mers in the output header is set if the number of
+     the input file is known.
+
+Tips:
+  1. Combine with "unikmer num" for manually sharding a file.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if len(files) > 1 {
+			checkError(fmt.Errorf("only one input file accepted"))
+		}
+
+		checkFileSuffix(extDataFile, files...)
+		setProvenance(opt, cmd, files)
+
+		file := files[0]
+
+		outFile := getFlagString(cmd, "out-prefix")
+		from := getFlagInt64(cmd, "from")
+		to := getFlagInt64(cmd, "to")
+		if from < 0 {
+			checkError(fmt.Errorf("value of flag --from should not be negative"))
+		}
+		if to >= 0 && to < from {
+			checkError(fmt.Errorf("value of flag --to (%d) should not be smaller than --from (%d)", to, from))
+		}
+
+		// try random access first
+		var reader *unikmer.Reader
+		var seekable bool
+		if !isStdin(file) {
+			var fh *os.File
+			fh, err = os.Open(file)
+			checkError(err)
+			defer fh.Close()
+
+			reader, err = unikmer.NewReaderAt(fh)
+			// strands and counts are not returned by ReadAt
+			seekable = err == nil && !reader.IsIncludeStrand() && !reader.IsIncludeCount()
+		}
+		if !seekable {
+			infh, r, _, err := inStream(file)
+			checkError(err)
+			defer r.Close()
+
+			reader, err = unikmer.NewReader(infh)
+			checkError(err)
+		}
+		if opt.Verbose {
+			if seekable {
+				log.Infof("seeking to record %d in file: %s", from, file)
+			} else {
+				log.Infof("scanning file: %s", file)
+			}
+		}
+
+		if !isStdout(outFile) {
+			outFile += extDataFile
+		}
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		writer, err := unikmer.NewWriter(outfh, reader.K, reader.Flag)
+		checkError(err)
+		writer.Description = opt.Provenance
+		writer.SetMaxTaxid(maxUint32N(reader.GetTaxidBytesLength())) // follow reader
+		if reader.HasGlobalTaxid() {
+			checkError(writer.SetGlobalTaxid(reader.GetGlobalTaxid()))
+		}
+
+		if reader.Number >= 0 {
+			end := reader.Number
+			if to >= 0 && to < end {
+				end = to
+			}
+			if end > from {
+				writer.Number = end - from
+			} else {
+				writer.Number = 0
+			}
+		}
+
+		var n int64
+		if seekable {
+			var ct unikmer.CodeTaxid
+			for i := from; to < 0 || i < to; i++ {
+				ct, err = reader.ReadAt(i)
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+				}
+				checkError(writer.WriteCodeWithTaxid(ct.Code, ct.Taxid))
+				n++
+			}
+		} else {
+			var code uint64
+			var taxid, count uint32
+			var rc bool
+			for i := int64(0); to < 0 || i < to; i++ {
+				code, rc, count, taxid, err = reader.ReadRecord()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+				}
+
+				if i < from {
+					continue
+				}
+
+				checkError(writer.WriteRecord(code, rc, count, taxid))
+				n++
+			}
+		}
+
+		checkError(writer.Flush())
+		if opt.Verbose {
+			log.Infof("%d k-mers saved to %s", n, outFile)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(sliceCmd)
+
+	sliceCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
+	sliceCmd.Flags().Int64P("from", "", 0, "0-based rank of the first k-mer to extract")
+	sliceCmd.Flags().Int64P("to", "", -1, "0-based rank of the k-mer to stop at (exclusive), -1 for the end")
+}
@@ -52,6 +52,9 @@ Notes:
 Tips:
   1. You can use '-m/--chunk-size' to limit memory usage, and chunk file size
      depends on k-mers and file save mode (sorted/compact/normal).
+     Or use '--max-memory' to give a memory budget directly, the chunk size
+     is computed from it. Sorted chunks are saved in '-t/--tmp-dir' and
+     k-way merged in the end, with duplicates removed if -u/--unique given.
   2. Increasing value of -j/--threads can accelerates splitting stage,
      in cost of more memory occupation.
   3. For sorted input files, the memory usage is very low and speed is fast.
@@ -68,6 +71,7 @@ Tips:
 		maxOpenFiles := getFlagPositiveInt(cmd, "max-open-files")
 		keepTmpDir := getFlagBool(cmd, "keep-tmp-dir")
 		force := getFlagBool(cmd, "force")
+		opt.IndexInterval = getFlagIndexInterval(cmd, opt)
 
 		if unique && repeated {
 			checkError(fmt.Errorf("flag -u/--unique overides -d/--repeated, don't provide both"))
@@ -77,10 +81,18 @@ Tips:
 		if err != nil {
 			checkError(fmt.Errorf("parsing byte size: %s", err))
 		}
-		limitMem := maxElem > 0
+		maxMem, err := ParseByteSize(getFlagString(cmd, "max-memory"))
+		if err != nil {
+			checkError(fmt.Errorf("invalid value of flag --max-memory: %s", err))
+		}
+		if maxMem > 0 && maxElem > 0 {
+			checkError(fmt.Errorf("flag -m/--chunk-size and --max-memory are exclusive"))
+		}
+		// with --max-memory, the chunk size is decided after checking taxids
+		limitMem := maxElem > 0 || maxMem > 0
 
 		var listInitSize int
-		if limitMem {
+		if maxElem > 0 {
 			listInitSize = maxElem
 		} else {
 			listInitSize = mapInitSize
@@ -99,6 +111,7 @@ Tips:
 		}
 
 		checkFileSuffix(extDataFile, files...)
+		setProvenance(opt, cmd, files)
 
 		var m []uint64
 		var taxondb *unikmer.Taxonomy
@@ -184,6 +197,21 @@ Tips:
 					canonical = reader.IsCanonical()
 					hasTaxid = !opt.IgnoreTaxid && reader.HasTaxidInfo()
 
+					if maxMem > 0 {
+						if hasTaxid {
+							maxElem = maxMem / bytesPerCodeTaxid
+						} else {
+							maxElem = maxMem / bytesPerCode
+						}
+						if maxElem < 1 {
+							maxElem = 1
+						}
+						listInitSize = maxElem
+						if opt.Verbose {
+							log.Infof("chunk size: %d k-mers for --max-memory %s", maxElem, getFlagString(cmd, "max-memory"))
+						}
+					}
+
 					if hasTaxid {
 						if opt.Verbose {
 							log.Infof("taxids found in file: %s", file)
@@ -456,7 +484,7 @@ Tips:
 			log.Infof("done sorting")
 		}
 
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -465,8 +493,9 @@ Tips:
 			}
 			w.Close()
 		}()
-		writer, err = unikmer.NewWriter(outfh, k, mode)
+		writer, err = newSortedWriter(opt, outfh, k, mode)
 		checkError(err)
+		writer.Description = opt.Provenance
 		writer.SetMaxTaxid(opt.MaxTaxid) // follow taxondb
 
 		var n int
@@ -576,8 +605,11 @@ func init() {
 	sortCmd.Flags().BoolP("unique", "u", false, `remove duplicated k-mers`)
 	sortCmd.Flags().BoolP("repeated", "d", false, `only print duplicate k-mers`)
 	sortCmd.Flags().StringP("chunk-size", "m", "", `split input into chunks of N k-mers, supports K/M/G suffix, type "unikmer sort -h" for detail`)
+	sortCmd.Flags().StringP("max-memory", "", "", `maximum memory for storing k-mers, exceeded k-mers are sorted and dumped into chunk files, supports K/M/G suffix, exclusive with -m/--chunk-size`)
 	sortCmd.Flags().StringP("tmp-dir", "t", "./", `directory for intermediate files`)
 	sortCmd.Flags().IntP("max-open-files", "M", 400, `max number of open files`)
 	sortCmd.Flags().BoolP("keep-tmp-dir", "k", false, `keep tmp dir`)
 	sortCmd.Flags().BoolP("force", "", false, "overwrite tmp dir")
+	sortCmd.Flags().BoolP("index", "", false, helpIndex)
+	sortCmd.Flags().IntP("index-interval", "", 1024, "interval of index for --index")
 }
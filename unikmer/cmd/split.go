@@ -89,6 +89,7 @@ Tips:
 		}
 
 		checkFileSuffix(extDataFile, files...)
+		setProvenance(opt, cmd, files)
 
 		var m []uint64
 		var taxondb *unikmer.Taxonomy
@@ -206,11 +207,12 @@ Tips:
 					if doNotNeedSorting {
 						iTmpFile++
 						outFile2 = chunkFileName(outDir, iTmpFile)
-						outfh, gw, w, err = outStream(outFile2, opt.Compress, opt.CompressionLevel)
+						outfh, gw, w, err = outStreamWithCodec(outFile2, opt.CompressionCodec, opt.CompressionLevel)
 						checkError(err)
 
 						writer, err = unikmer.NewWriter(outfh, k, mode)
 						checkError(err)
+						writer.Description = opt.Provenance
 						writer.SetMaxTaxid(maxUint32N(reader.GetTaxidBytesLength())) // follow reader
 						if opt.Verbose {
 							log.Infof("[chunk %d] begin writing k-mers to: %s", iTmpFile, outFile2)
@@ -260,11 +262,12 @@ Tips:
 
 							iTmpFile++
 							outFile2 = chunkFileName(outDir, iTmpFile)
-							outfh, gw, w, err = outStream(outFile2, opt.Compress, opt.CompressionLevel)
+							outfh, gw, w, err = outStreamWithCodec(outFile2, opt.CompressionCodec, opt.CompressionLevel)
 							checkError(err)
 
 							writer, err = unikmer.NewWriter(outfh, k, mode)
 							checkError(err)
+							writer.Description = opt.Provenance
 							writer.SetMaxTaxid(maxUint32N(reader.GetTaxidBytesLength())) // follow reader
 
 							if opt.Verbose {
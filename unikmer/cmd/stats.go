@@ -24,6 +24,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -48,6 +49,21 @@ var statCmd = &cobra.Command{
 Tips:
   1. For lots of small files (especially on SDD), use big value of '-j' to
      parallelize counting.
+  2. Use -d/--density to show the fraction of the code space (4^k, or the
+     number of canonical k-mers for canonical files) occupied by k-mers.
+  3. Use -p/--palindromes to count k-mers equal to their reverse complements,
+     which only exist for even k.
+  4. Use -z/--check-size to compare the size of uncompressed and unsorted
+     files with the expected one (header + number of k-mers * record size),
+     a mismatch indicates a broken file. If the number of k-mers in the
+     header is unknown, the data should consist of whole records, so
+     truncations at record boundaries can not be detected.
+     Read errors of these files are reported as warnings instead of
+     exiting. "-" is shown for other files.
+  5. Use -E/--estimate to estimate the number of distinct k-mers with a
+     HyperLogLog sketch, which needs little memory for big unsorted files
+     with duplicates. The standard error is about 1.04/sqrt(2^precision),
+     i.e., 0.8% for the default precision of 14.
 
 `,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -77,6 +93,17 @@ Tips:
 		sTrue := getFlagString(cmd, "symbol-true")
 		sFalse := getFlagString(cmd, "symbol-false")
 		basename := getFlagBool(cmd, "basename")
+		density := getFlagBool(cmd, "density")
+		palindromes := getFlagBool(cmd, "palindromes")
+		checkSize := getFlagBool(cmd, "check-size")
+		estimate := getFlagBool(cmd, "estimate")
+		precision := getFlagPositiveInt(cmd, "sketch-precision")
+		if estimate {
+			_, err = unikmer.NewSketch(precision)
+			if err != nil {
+				checkError(fmt.Errorf("invalid value of --sketch-precision: %d", precision))
+			}
+		}
 
 		if sTrue == sFalse {
 			checkError(fmt.Errorf("values of -/--symbol-true and -F/--symbol--false should be different"))
@@ -105,12 +132,66 @@ Tips:
 				"global-taxid",
 			}
 			if all {
-				colnames = append(colnames, []string{"number"}...)
+				colnames = append(colnames, []string{"number", "description"}...)
+			}
+			if density {
+				colnames = append(colnames, "density")
+			}
+			if palindromes {
+				colnames = append(colnames, "palindromes")
+			}
+			if checkSize {
+				colnames = append(colnames, []string{"expected-size", "size"}...)
+			}
+			if estimate {
+				colnames = append(colnames, "estimated-number")
 			}
 			outfh.WriteString(strings.Join(colnames, "\t") + "\n")
 			outfh.Flush()
 		}
 
+		writeTabular := func(info statInfo) {
+			if !all {
+				outfh.WriteString(fmt.Sprintf("%s\t%v\t%v\t%v\t%v\t%v\t%v\t%s",
+					info.file,
+					info.k,
+					boolStr(sTrue, sFalse, info.gzipped),
+					boolStr(sTrue, sFalse, info.compact),
+					boolStr(sTrue, sFalse, info.canonical),
+					boolStr(sTrue, sFalse, info.sorted),
+					boolStr(sTrue, sFalse, info.includeTaxid),
+					info.globalTaxid,
+				))
+			} else {
+				outfh.WriteString(fmt.Sprintf("%s\t%v\t%v\t%v\t%v\t%v\t%v\t%s\t%d\t%s",
+					info.file,
+					info.k,
+					boolStr(sTrue, sFalse, info.gzipped),
+					boolStr(sTrue, sFalse, info.compact),
+					boolStr(sTrue, sFalse, info.canonical),
+					boolStr(sTrue, sFalse, info.sorted),
+					boolStr(sTrue, sFalse, info.includeTaxid),
+					info.globalTaxid,
+					info.number,
+					info.description,
+				))
+			}
+			if density {
+				outfh.WriteString("\t" + densityStr(info))
+			}
+			if palindromes {
+				outfh.WriteString(fmt.Sprintf("\t%d", info.palindromes))
+			}
+			if checkSize {
+				outfh.WriteString(fmt.Sprintf("\t%s\t%s", sizeStr(info.expectedSize, false), sizeStr(info.size, false)))
+			}
+			if estimate {
+				outfh.WriteString(fmt.Sprintf("\t%d", info.estimated))
+			}
+			outfh.WriteString("\n")
+			outfh.Flush()
+		}
+
 		ch := make(chan statInfo, opt.NumCPUs)
 		statInfos := make([]statInfo, 0, 256)
 
@@ -138,31 +219,7 @@ Tips:
 					if !tabular {
 						statInfos = append(statInfos, info)
 					} else {
-						if !all {
-							outfh.WriteString(fmt.Sprintf("%s\t%v\t%v\t%v\t%v\t%v\t%v\t%s\n",
-								info.file,
-								info.k,
-								boolStr(sTrue, sFalse, info.gzipped),
-								boolStr(sTrue, sFalse, info.compact),
-								boolStr(sTrue, sFalse, info.canonical),
-								boolStr(sTrue, sFalse, info.sorted),
-								boolStr(sTrue, sFalse, info.includeTaxid),
-								info.globalTaxid,
-							))
-						} else {
-							outfh.WriteString(fmt.Sprintf("%s\t%v\t%v\t%v\t%v\t%v\t%v\t%s\t%d\n",
-								info.file,
-								info.k,
-								boolStr(sTrue, sFalse, info.gzipped),
-								boolStr(sTrue, sFalse, info.compact),
-								boolStr(sTrue, sFalse, info.canonical),
-								boolStr(sTrue, sFalse, info.sorted),
-								boolStr(sTrue, sFalse, info.includeTaxid),
-								info.globalTaxid,
-								info.number,
-							))
-						}
-						outfh.Flush()
+						writeTabular(info)
 					}
 					id++
 				} else { // check bufferd result
@@ -171,31 +228,7 @@ Tips:
 							if !tabular {
 								statInfos = append(statInfos, info1)
 							} else {
-								if !all {
-									outfh.WriteString(fmt.Sprintf("%s\t%v\t%v\t%v\t%v\t%v\t%v\t%s\n",
-										info.file,
-										info.k,
-										boolStr(sTrue, sFalse, info.gzipped),
-										boolStr(sTrue, sFalse, info.compact),
-										boolStr(sTrue, sFalse, info.canonical),
-										boolStr(sTrue, sFalse, info.sorted),
-										boolStr(sTrue, sFalse, info.includeTaxid),
-										info.globalTaxid,
-									))
-								} else {
-									outfh.WriteString(fmt.Sprintf("%s\t%v\t%v\t%v\t%v\t%v\t%v\t%s\t%d\n",
-										info.file,
-										info.k,
-										boolStr(sTrue, sFalse, info.gzipped),
-										boolStr(sTrue, sFalse, info.compact),
-										boolStr(sTrue, sFalse, info.canonical),
-										boolStr(sTrue, sFalse, info.sorted),
-										boolStr(sTrue, sFalse, info.includeTaxid),
-										info.globalTaxid,
-										info.number,
-									))
-								}
-								outfh.Flush()
+								writeTabular(info1)
 							}
 
 							delete(buf, info1.id)
@@ -221,31 +254,7 @@ Tips:
 					if !tabular {
 						statInfos = append(statInfos, info)
 					} else {
-						if !all {
-							outfh.WriteString(fmt.Sprintf("%s\t%v\t%v\t%v\t%v\t%v\t%v\t%s\n",
-								info.file,
-								info.k,
-								boolStr(sTrue, sFalse, info.gzipped),
-								boolStr(sTrue, sFalse, info.compact),
-								boolStr(sTrue, sFalse, info.canonical),
-								boolStr(sTrue, sFalse, info.sorted),
-								boolStr(sTrue, sFalse, info.includeTaxid),
-								info.globalTaxid,
-							))
-						} else {
-							outfh.WriteString(fmt.Sprintf("%s\t%v\t%v\t%v\t%v\t%v\t%v\t%s\t%d\n",
-								info.file,
-								info.k,
-								boolStr(sTrue, sFalse, info.gzipped),
-								boolStr(sTrue, sFalse, info.compact),
-								boolStr(sTrue, sFalse, info.canonical),
-								boolStr(sTrue, sFalse, info.sorted),
-								boolStr(sTrue, sFalse, info.includeTaxid),
-								info.globalTaxid,
-								info.number,
-							))
-						}
-						outfh.Flush()
+						writeTabular(info)
 					}
 				}
 			}
@@ -292,8 +301,14 @@ Tips:
 				var r *os.File
 				var reader *unikmer.Reader
 				var gzipped bool
-				var n int64
+				var n, np int64
+				var code uint64
 				var globalTaxid string
+				var expectedSize, size int64 = -1, -1
+				var sketch *unikmer.Sketch
+				if estimate {
+					sketch, _ = unikmer.NewSketch(precision)
+				}
 
 				infh, r, gzipped, err = inStream(file)
 				if err != nil {
@@ -326,24 +341,66 @@ Tips:
 					return
 				}
 
+				recordSize := reader.RecordSize()
+				checkingSize := checkSize && !gzipped && !isStdin(file) && recordSize > 0
+
 				n = 0
-				if all {
-					if reader.IsSorted() && reader.Number >= 0 {
+				if all || density || palindromes || estimate {
+					if reader.IsSorted() && reader.Number >= 0 && !(palindromes && reader.K&1 == 0) && !estimate {
 						n = reader.Number
 					} else {
 						for {
-							_, _, err = reader.ReadCodeWithTaxid()
+							code, _, err = reader.ReadCodeWithTaxid()
 							if err != nil {
 								if err == io.EOF {
 									break
 								}
-								checkError(err)
+								if checkingSize { // reported as a size mismatch below
+									log.Warningf("%s: %s", file, err)
+									break
+								}
+								select {
+								case <-cancel:
+									return
+								default:
+								}
+								if basename {
+									file = filepath.Base(file)
+								}
+								ch <- statInfo{file: file, err: err, id: id}
+								return
 							}
 
 							n++
+							if palindromes && code == unikmer.RevComp(code, reader.K) {
+								np++
+							}
+							if estimate {
+								sketch.Add(code)
+							}
 						}
 					}
 				}
+				if checkingSize {
+					fi, err := r.Stat()
+					checkError(err)
+					size = fi.Size()
+					if reader.Number >= 0 { // trust the header
+						expectedSize = unikmer.HeaderSize + reader.Number*int64(recordSize)
+					} else if size < unikmer.HeaderSize {
+						expectedSize = unikmer.HeaderSize
+					} else { // the data should consist of whole records
+						expectedSize = size - (size-unikmer.HeaderSize)%int64(recordSize)
+					}
+					if size != expectedSize {
+						log.Warningf("%s: file size (%d) does not match the expected one (%d), the file might be broken",
+							file, size, expectedSize)
+					}
+				}
+				var estimated uint64
+				if estimate {
+					estimated = sketch.Count()
+				}
 				if basename {
 					file = filepath.Base(file)
 				}
@@ -362,6 +419,11 @@ Tips:
 					includeTaxid: reader.IsIncludeTaxid(),
 					globalTaxid:  globalTaxid,
 					number:       n,
+					description:  string(reader.Description),
+					palindromes:  np,
+					expectedSize: expectedSize,
+					size:         size,
+					estimated:    estimated,
 
 					err: nil,
 					id:  id,
@@ -399,16 +461,33 @@ Tips:
 		if all {
 			columns = append(columns, []prettytable.Column{
 				{Header: "number", AlignRight: true},
+				{Header: "description"},
+			}...)
+		}
+		if density {
+			columns = append(columns, prettytable.Column{Header: "density", AlignRight: true})
+		}
+		if palindromes {
+			columns = append(columns, prettytable.Column{Header: "palindromes", AlignRight: true})
+		}
+		if checkSize {
+			columns = append(columns, []prettytable.Column{
+				{Header: "expected-size", AlignRight: true},
+				{Header: "size", AlignRight: true},
 			}...)
 		}
+		if estimate {
+			columns = append(columns, prettytable.Column{Header: "estimated-number", AlignRight: true})
+		}
 		tbl, err := prettytable.NewTable(columns...)
 
 		checkError(err)
 		tbl.Separator = "  "
 
+		var row []interface{}
 		for _, info := range statInfos {
 			if !all {
-				tbl.AddRow(
+				row = append(row[:0],
 					info.file,
 					info.k,
 					boolStr(sTrue, sFalse, info.gzipped),
@@ -419,7 +498,7 @@ Tips:
 					info.globalTaxid,
 				)
 			} else {
-				tbl.AddRow(
+				row = append(row[:0],
 					info.file,
 					info.k,
 					boolStr(sTrue, sFalse, info.gzipped),
@@ -429,8 +508,22 @@ Tips:
 					boolStr(sTrue, sFalse, info.includeTaxid),
 					info.globalTaxid,
 					humanize.Comma(info.number),
+					info.description,
 				)
 			}
+			if density {
+				row = append(row, densityStr(info))
+			}
+			if palindromes {
+				row = append(row, humanize.Comma(info.palindromes))
+			}
+			if checkSize {
+				row = append(row, sizeStr(info.expectedSize, true), sizeStr(info.size, true))
+			}
+			if estimate {
+				row = append(row, humanize.Comma(int64(info.estimated)))
+			}
+			tbl.AddRow(row...)
 		}
 		outfh.Write(tbl.Bytes())
 	},
@@ -446,6 +539,11 @@ type statInfo struct {
 	includeTaxid bool
 	globalTaxid  string
 	number       int64
+	description  string
+	palindromes  int64
+	expectedSize int64 // -1 for not checked
+	size         int64
+	estimated    uint64 // estimated number of distinct k-mers
 
 	err error
 	id  uint64
@@ -455,12 +553,35 @@ func init() {
 	RootCmd.AddCommand(statCmd)
 
 	statCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
-	statCmd.Flags().BoolP("all", "a", false, "all information, including number of k-mers")
+	statCmd.Flags().BoolP("all", "a", false, "all information, including number of k-mers and description (provenance)")
 	statCmd.Flags().BoolP("tabular", "T", false, "output in machine-friendly tabular format")
 	statCmd.Flags().BoolP("skip-err", "e", false, "skip error, only show warning message")
 	statCmd.Flags().StringP("symbol-true", "", "✓", "smybol for true")
 	statCmd.Flags().StringP("symbol-false", "", "✕", "smybol for false")
 	statCmd.Flags().BoolP("basename", "b", false, "only output basename of files")
+	statCmd.Flags().BoolP("density", "d", false, "show the percentage of the code space occupied by k-mers")
+	statCmd.Flags().BoolP("palindromes", "p", false, "count k-mers equal to their reverse complements")
+	statCmd.Flags().BoolP("check-size", "z", false, "compare file size with the expected one, only for uncompressed and unsorted files")
+	statCmd.Flags().BoolP("estimate", "E", false, "estimate the number of distinct k-mers with HyperLogLog")
+	statCmd.Flags().IntP("sketch-precision", "", unikmer.DefaultSketchPrecision, "precision of HyperLogLog sketch for -E/--estimate, in range of [4, 18]")
+}
+
+// codeSpaceSize returns the number of all possible k-mers,
+// or canonical k-mers if canonical is true.
+// float64 is used to avoid overflow of 4^32.
+func codeSpaceSize(k int, canonical bool) float64 {
+	n := math.Pow(4, float64(k))
+	if !canonical {
+		return n
+	}
+	if k&1 == 1 { // no palindromic k-mers
+		return n / 2
+	}
+	return (n + math.Pow(4, float64(k/2))) / 2
+}
+
+func densityStr(info statInfo) string {
+	return fmt.Sprintf("%.6g%%", float64(info.number)/codeSpaceSize(info.k, info.canonical)*100)
 }
 
 func boolStr(sTrue, sFalse string, v bool) string {
@@ -469,3 +590,13 @@ func boolStr(sTrue, sFalse string, v bool) string {
 	}
 	return sFalse
 }
+
+func sizeStr(size int64, comma bool) string {
+	if size < 0 {
+		return "-"
+	}
+	if comma {
+		return humanize.Comma(size)
+	}
+	return strconv.FormatInt(size, 10)
+}
@@ -0,0 +1,95 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shenwei356/unikmer"
+)
+
+// TestStatsCheckSize checks that truncated files without the number of
+// k-mers in the header are reported, instead of matching or exiting.
+func TestStatsCheckSize(t *testing.T) {
+	dir := t.TempDir()
+	k := 21
+	n := 100
+
+	file := filepath.Join(dir, "plain.unik")
+	w, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := unikmer.NewWriter(w, k, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if err = writer.WriteCode(uint64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	size := int64(unikmer.HeaderSize + n*unikmer.RecordSize(0, k))
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != size {
+		t.Fatalf("unexpected file size: %d != %d", fi.Size(), size)
+	}
+
+	truncated := filepath.Join(dir, "truncated.unik")
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(truncated, data[:size-3], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outFile := filepath.Join(dir, "stats.tsv")
+	if err = executeRootCmd(t, "stats", "-a", "-T", "-z", "-b", "-o", outFile, file, truncated); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ioutil.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	for i, expected := range []string{"990\t990", "982\t987"} {
+		if !strings.HasSuffix(lines[i+1], expected) {
+			t.Errorf("unexpected sizes: %s, expected: %s", lines[i+1], expected)
+		}
+	}
+}
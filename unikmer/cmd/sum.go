@@ -0,0 +1,143 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// sumCmd represents
+var sumCmd = &cobra.Command{
+	Use:   "sum",
+	Short: "Sum up counts of k-mers in multiple binary files",
+	Long: `Sum up counts of k-mers in multiple binary files
+
+Sorted input files with counts of k-mers (created by
+"unikmer count --with-count -s") are k-way merged in streaming,
+and counts of the same k-mers are added up, which only needs
+little memory. Output is sorted and also contains counts.
+
+Attentions:
+  1. All input files should be sorted and contain counts.
+  2. K and 'canonical' flags of all files should be consistent.
+  3. Taxids are ignored.
+  4. Sums exceeding 4294967295 are saturated.
+
+Tips:
+  1. Use -m/--min-count to remove rare k-mers, e.g., sequencing errors.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			if len(files) == 1 && isStdin(files[0]) {
+				log.Info("no files given, reading from stdin")
+			} else {
+				log.Infof("%d input file(s) given", len(files))
+			}
+		}
+
+		checkFileSuffix(extDataFile, files...)
+		setProvenance(opt, cmd, files)
+
+		outFile := getFlagString(cmd, "out-prefix")
+		minCount := getFlagUint32(cmd, "min-count")
+
+		readers := make([]*unikmer.Reader, len(files))
+		var k int = -1
+		var canonical bool
+		for i, file := range files {
+			infh, r, _, err := inStream(file)
+			checkError(err)
+			defer r.Close()
+
+			reader, err := unikmer.NewReader(infh)
+			checkError(err)
+
+			if !reader.IsSorted() {
+				checkError(fmt.Errorf("input file should be sorted: %s", file))
+			}
+			if !reader.IsIncludeCount() {
+				checkError(fmt.Errorf(`no counts found in file, please create it with "unikmer count --with-count": %s`, file))
+			}
+			if k == -1 {
+				k = reader.K
+				canonical = reader.IsCanonical()
+			} else {
+				if k != reader.K {
+					checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
+				}
+				if reader.IsCanonical() != canonical {
+					checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+				}
+			}
+			readers[i] = reader
+		}
+
+		if !isStdout(outFile) {
+			outFile += extDataFile
+		}
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		mode := uint32(unikmer.UNIK_SORTED | unikmer.UNIK_INCLUDECOUNT)
+		if canonical {
+			mode |= unikmer.UNIK_CANONICAL
+		}
+		writer, err := unikmer.NewWriter(outfh, k, mode)
+		checkError(err)
+		writer.Description = opt.Provenance
+
+		n, err := unikmer.Sum(readers, writer, minCount)
+		checkError(err)
+
+		checkError(writer.Flush())
+		if opt.Verbose {
+			log.Infof("%d k-mers saved to %s", n, outFile)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(sumCmd)
+
+	sumCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
+	sumCmd.Flags().Uint32P("min-count", "m", 1, "minimum count of k-mers to output")
+}
@@ -0,0 +1,287 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// symdiffCmd represents
+var symdiffCmd = &cobra.Command{
+	Use:   "symdiff",
+	Short: "Symmetric difference of multiple binary files",
+	Long: `Symmetric difference of multiple binary files
+
+K-mers present in an odd number of input files are outputted,
+for two files, that's k-mers present in exactly one of them.
+
+Attentions:
+  1. The 'canonical' flags of all files should be consistent.
+  2. Input files should ALL have or don't have taxid information.
+  3. Duplicated k-mers in a file are counted once.
+
+Tips:
+  1. Use --exactly-one to only output k-mers present in exactly one file.
+  2. If all input files are sorted, they are k-way merged in streaming
+     instead of being loaded into RAM, and output is sorted. The output
+     is buffered in --max-memory to record the number of k-mers in the
+     header, which is unknown (-1) for bigger outputs.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			if len(files) == 1 && isStdin(files[0]) {
+				log.Info("no files given, reading from stdin")
+			} else {
+				log.Infof("%d input file(s) given", len(files))
+			}
+		}
+
+		checkFileSuffix(extDataFile, files...)
+		setProvenance(opt, cmd, files)
+
+		outFile := getFlagString(cmd, "out-prefix")
+		sortKmers := getFlagBool(cmd, "sort")
+		exactlyOne := getFlagBool(cmd, "exactly-one")
+		failIfEmpty := getFlagBool(cmd, "fail-if-empty")
+		var nResult int
+		defer exitIfEmpty(failIfEmpty, &nResult)
+
+		var taxondb *unikmer.Taxonomy
+
+		if !isStdout(outFile) {
+			outFile += extDataFile
+		}
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		var writer *unikmer.Writer
+
+		if sorted, _k, _canonical, _hasTaxid := checkMergeableFiles(opt, files); sorted {
+			if opt.Verbose {
+				log.Infof("all input files are sorted, merging them in streaming")
+			}
+
+			readers := make([]*unikmer.Reader, len(files))
+			for i, file := range files {
+				infh, r, _, err := inStream(file)
+				checkError(err)
+				defer r.Close()
+
+				readers[i], err = unikmer.NewReader(infh)
+				checkError(err)
+			}
+
+			mode := uint32(unikmer.UNIK_SORTED)
+			if _canonical {
+				mode |= unikmer.UNIK_CANONICAL
+			}
+			if _hasTaxid {
+				mode |= unikmer.UNIK_INCLUDETAXID
+				taxondb = loadTaxonomy(opt, false)
+			}
+			writer, err = unikmer.NewWriter(outfh, _k, mode)
+			checkError(err)
+			writer.Description = opt.Provenance
+			writer.SetMaxTaxid(opt.MaxTaxid)
+
+			nw := newNumberedWriter(writer, getFlagMaxBuffered(cmd, "max-memory"))
+			var n int64
+			err = unikmer.SymmetricDifferenceFunc(readers, taxondb, exactlyOne, func(code uint64, taxid uint32) error {
+				n++
+				return nw.WriteCodeWithTaxid(code, taxid)
+			})
+			checkError(err)
+			checkError(nw.Close())
+			nResult = int(n)
+
+			checkError(writer.Flush())
+			if opt.Verbose {
+				log.Infof("%d k-mers saved to %s", n, outFile)
+			}
+			return
+		}
+
+		// code -> taxid and number of files containing it
+		m := make(map[uint64]symdiffEntry, mapInitSize)
+
+		var infh *bufio.Reader
+		var r *os.File
+		var reader *unikmer.Reader
+		var code uint64
+		var taxid uint32
+		var k int = -1
+		var canonical bool
+		var hasTaxid bool
+		var e symdiffEntry
+		var ok bool
+		var nfiles = len(files)
+		for i, file := range files {
+			if opt.Verbose {
+				log.Infof("processing file (%d/%d): %s", i+1, nfiles, file)
+			}
+
+			func() {
+				infh, r, _, err = inStream(file)
+				checkError(err)
+				defer r.Close()
+
+				reader, err = unikmer.NewReader(infh)
+				checkError(err)
+
+				if k == -1 {
+					k = reader.K
+					canonical = reader.IsCanonical()
+					hasTaxid = !opt.IgnoreTaxid && reader.HasTaxidInfo()
+					if hasTaxid {
+						if opt.Verbose {
+							log.Infof("taxids found in file: %s", file)
+						}
+						taxondb = loadTaxonomy(opt, false)
+					}
+				} else {
+					if k != reader.K {
+						checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
+					}
+					if reader.IsCanonical() != canonical {
+						checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+					}
+					if !opt.IgnoreTaxid && reader.HasTaxidInfo() != hasTaxid {
+						if reader.HasTaxidInfo() {
+							checkError(fmt.Errorf(`taxid information not found in previous files, but found in this: %s`, file))
+						} else {
+							checkError(fmt.Errorf(`taxid information found in previous files, but missing in this: %s`, file))
+						}
+					}
+				}
+
+				for {
+					code, taxid, err = reader.ReadCodeWithTaxid()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+					}
+
+					if e, ok = m[code]; !ok {
+						m[code] = symdiffEntry{taxid: taxid, n: 1, last: int32(i)}
+						continue
+					}
+					if hasTaxid {
+						e.taxid = taxondb.LCA(e.taxid, taxid)
+					}
+					if e.last != int32(i) {
+						e.n++
+						e.last = int32(i)
+					}
+					m[code] = e
+				}
+			}()
+		}
+
+		codesTaxids := make([]unikmer.CodeTaxid, 0, mapInitSize)
+		for code, e = range m {
+			if exactlyOne {
+				if e.n != 1 {
+					continue
+				}
+			} else if e.n&1 == 0 {
+				continue
+			}
+			codesTaxids = append(codesTaxids, unikmer.CodeTaxid{Code: code, Taxid: e.taxid})
+		}
+		m = nil
+
+		if sortKmers {
+			if opt.Verbose {
+				log.Infof("sorting %d k-mers", len(codesTaxids))
+			}
+			sort.Sort(unikmer.CodeTaxidSlice(codesTaxids))
+		}
+
+		var mode uint32
+		if sortKmers {
+			mode |= unikmer.UNIK_SORTED
+		} else if opt.Compact {
+			mode |= unikmer.UNIK_COMPACT
+		}
+		if canonical {
+			mode |= unikmer.UNIK_CANONICAL
+		}
+		if hasTaxid {
+			mode |= unikmer.UNIK_INCLUDETAXID
+		}
+		writer, err = unikmer.NewWriter(outfh, k, mode)
+		checkError(err)
+		writer.Description = opt.Provenance
+		writer.SetMaxTaxid(opt.MaxTaxid)
+		writer.Number = int64(len(codesTaxids))
+		nResult = len(codesTaxids)
+
+		for _, ct := range codesTaxids {
+			writer.WriteCodeWithTaxid(ct.Code, ct.Taxid)
+		}
+		checkError(writer.Flush())
+		if opt.Verbose {
+			log.Infof("%d k-mers saved to %s", len(codesTaxids), outFile)
+		}
+	},
+}
+
+type symdiffEntry struct {
+	taxid uint32
+	n     int32 // number of files containing the k-mer
+	last  int32 // index of the last file containing the k-mer
+}
+
+func init() {
+	RootCmd.AddCommand(symdiffCmd)
+
+	symdiffCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
+	symdiffCmd.Flags().BoolP("sort", "s", false, helpSort)
+	symdiffCmd.Flags().StringP("max-memory", "", "100M", helpMaxMemoryNumber)
+	symdiffCmd.Flags().BoolP("exactly-one", "", false, "only output k-mers present in exactly one input file")
+	symdiffCmd.Flags().BoolP("fail-if-empty", "", false, helpFailIfEmpty)
+}
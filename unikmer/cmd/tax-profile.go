@@ -0,0 +1,209 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/shenwei356/util/pathutil"
+	"github.com/spf13/cobra"
+)
+
+// taxProfileCmd represents
+var taxProfileCmd = &cobra.Command{
+	Use:   "tax-profile",
+	Short: "Summarize taxonomic composition of k-mers at a rank",
+	Long: `Summarize taxonomic composition of k-mers at a rank
+
+K-mers are tallied per taxid, and taxids are mapped to their ancestors
+at the rank given by -r/--rank. A table of taxid, rank, name and number
+of k-mers is outputted in descending order of counts.
+
+Attentions:
+  1. Input files should ALL have taxid information of every k-mer.
+  2. K-mers with taxids above the rank or without ancestors at the rank
+     are reported in the row with taxid 0.
+  3. Names are read from names.dmp in the taxonomy data directory,
+     the name column is empty if the file does not exist.
+
+Tips:
+  1. It's useful for summarizing the result of intersecting a sample
+     against a reference database, e.g.,
+       unikmer inter sample.unik ref.unik | unikmer tax-profile -r species
+  2. Use --percent to output fractions of the total number of k-mers
+     instead of counts.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		rank := getFlagString(cmd, "rank")
+		if rank == "" {
+			checkError(fmt.Errorf("flag -r/--rank needed"))
+		}
+		outFile := getFlagString(cmd, "out-file")
+		percent := getFlagBool(cmd, "percent")
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			if len(files) == 1 && isStdin(files[0]) {
+				log.Info("no files given, reading from stdin")
+			} else {
+				log.Infof("%d input file(s) given", len(files))
+			}
+		}
+
+		checkFileSuffix(extDataFile, files...)
+
+		if opt.IgnoreTaxid {
+			checkError(fmt.Errorf("flag -I/--ignore-taxid not allowed"))
+		}
+
+		taxondb := loadTaxonomy(opt, true)
+		if _, ok := taxondb.Ranks[rank]; !ok {
+			checkError(fmt.Errorf("rank not found in taxonomy: %s", rank))
+		}
+
+		namesFile := filepath.Join(opt.DataDir, "names.dmp")
+		existed, err := pathutil.Exists(namesFile)
+		if err != nil {
+			checkError(fmt.Errorf("err on checking file names.dmp: %s", err))
+		}
+		if existed {
+			checkError(taxondb.LoadNamesFromNCBI(namesFile))
+			if opt.Verbose {
+				log.Infof("%d names loaded", len(taxondb.Names))
+			}
+		}
+
+		counts := make(map[uint32]int64, 1024)
+
+		var infh *bufio.Reader
+		var r *os.File
+		var reader *unikmer.Reader
+		var taxid uint32
+		var nfiles = len(files)
+		for i, file := range files {
+			if opt.Verbose {
+				log.Infof("processing file (%d/%d): %s", i+1, nfiles, file)
+			}
+
+			func() {
+				infh, r, _, err = inStream(file)
+				checkError(err)
+				defer r.Close()
+
+				reader, err = unikmer.NewReader(infh)
+				checkError(err)
+
+				if !reader.IsIncludeTaxid() {
+					checkError(fmt.Errorf("taxids of k-mers not found in file: %s", file))
+				}
+
+				for {
+					_, taxid, err = reader.ReadCodeWithTaxid()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+					}
+					counts[taxid]++
+				}
+			}()
+		}
+
+		// roll up to the rank
+		profile := make(map[uint32]int64, len(counts))
+		var total int64
+		for taxid, n := range counts {
+			profile[taxondb.AtRank(taxid, rank)] += n
+			total += n
+		}
+
+		taxids := make([]uint32, 0, len(profile))
+		for taxid = range profile {
+			taxids = append(taxids, taxid)
+		}
+		sort.Slice(taxids, func(i, j int) bool {
+			if profile[taxids[i]] == profile[taxids[j]] {
+				return taxids[i] < taxids[j]
+			}
+			return profile[taxids[i]] > profile[taxids[j]]
+		})
+
+		if opt.Verbose {
+			log.Infof("%d k-mers of %d taxids mapped to %d taxids at rank %s",
+				total, len(counts), len(taxids), rank)
+		}
+
+		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		if percent {
+			outfh.WriteString("taxid\trank\tname\tfraction\n")
+		} else {
+			outfh.WriteString("taxid\trank\tname\tcount\n")
+		}
+		var _rank string
+		for _, taxid = range taxids {
+			if taxid > 0 {
+				_rank = rank
+			} else {
+				_rank = ""
+			}
+			if percent {
+				outfh.WriteString(fmt.Sprintf("%d\t%s\t%s\t%.6f\n", taxid, _rank, taxondb.Name(taxid),
+					float64(profile[taxid])/float64(total)))
+			} else {
+				outfh.WriteString(fmt.Sprintf("%d\t%s\t%s\t%d\n", taxid, _rank, taxondb.Name(taxid), profile[taxid]))
+			}
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(taxProfileCmd)
+
+	taxProfileCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+	taxProfileCmd.Flags().StringP("rank", "r", "", `rank to map taxids to, e.g., species`)
+	taxProfileCmd.Flags().BoolP("percent", "", false, `output fractions of the total number of k-mers instead of counts`)
+}
@@ -0,0 +1,214 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/shenwei356/util/pathutil"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// taxidsCmd represents
+var taxidsCmd = &cobra.Command{
+	Use:   "taxids",
+	Short: "List distinct taxids in binary files",
+	Long: `List distinct taxids in binary files
+
+Attentions:
+  1. Taxids are outputted in ascending order, one per line.
+  2. Files without taxid information are skipped.
+
+Tips:
+  1. Use -n/--count to also output the number of k-mers of every taxid.
+  2. Use --check-taxonomy to cross-reference taxids against a taxonomy
+     directory containing nodes.dmp, and optional merged.dmp and delnodes.dmp,
+     an extra column of status is appended:
+       valid, deleted, unknown, or merged:<new taxid>.
+     It tells whether you need to update taxids before using the files.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opt := getOptions(cmd)
+		runtime.GOMAXPROCS(opt.NumCPUs)
+
+		var err error
+
+		if opt.Verbose {
+			log.Info("checking input files ...")
+		}
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		if opt.Verbose {
+			if len(files) == 1 && isStdin(files[0]) {
+				log.Info("no files given, reading from stdin")
+			} else {
+				log.Infof("%d input file(s) given", len(files))
+			}
+		}
+
+		checkFileSuffix(extDataFile, files...)
+
+		outFile := getFlagString(cmd, "out-file")
+		showCount := getFlagBool(cmd, "count")
+		taxDir := getFlagString(cmd, "check-taxonomy")
+
+		counts := make(map[uint32]int64, 1024)
+
+		var infh *bufio.Reader
+		var r *os.File
+		var reader *unikmer.Reader
+		var taxid uint32
+		var nfiles = len(files)
+		for i, file := range files {
+			if opt.Verbose {
+				log.Infof("processing file (%d/%d): %s", i+1, nfiles, file)
+			}
+
+			func() {
+				infh, r, _, err = inStream(file)
+				checkError(err)
+				defer r.Close()
+
+				reader, err = unikmer.NewReader(infh)
+				checkError(err)
+
+				if !reader.HasTaxidInfo() {
+					log.Warningf("no taxids found in file: %s, skipped", file)
+					return
+				}
+
+				if !reader.IsIncludeTaxid() { // only global taxid
+					if !showCount {
+						counts[reader.GetGlobalTaxid()] += 0
+						return
+					}
+					if reader.Number >= 0 {
+						counts[reader.GetGlobalTaxid()] += reader.Number
+						return
+					}
+				}
+
+				for {
+					_, taxid, err = reader.ReadCodeWithTaxid()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+					}
+					counts[taxid]++
+				}
+			}()
+		}
+
+		taxids := make([]uint32, 0, len(counts))
+		for taxid = range counts {
+			taxids = append(taxids, taxid)
+		}
+		sort.Slice(taxids, func(i, j int) bool { return taxids[i] < taxids[j] })
+
+		if opt.Verbose {
+			log.Infof("%d distinct taxids found", len(taxids))
+		}
+
+		var taxondb *unikmer.Taxonomy
+		if taxDir != "" {
+			opt.DataDir = taxDir
+			taxondb = loadTaxonomy(opt, false)
+
+			delFile := filepath.Join(taxDir, "delnodes.dmp")
+			existed, err := pathutil.Exists(delFile)
+			if err != nil {
+				checkError(fmt.Errorf("err on checking file delnodes.dmp: %s", err))
+			}
+			if existed {
+				checkError(taxondb.LoadDeletedNodesFromNCBI(delFile))
+			}
+			if opt.Verbose {
+				log.Infof("%d deleted nodes loaded", len(taxondb.DelNodes))
+			}
+		}
+		var status string
+		var nInvalid int
+
+		outfh, gw, w, err := outStream(outFile, strings.HasSuffix(strings.ToLower(outFile), ".gz"), opt.CompressionLevel)
+		checkError(err)
+		defer func() {
+			outfh.Flush()
+			if gw != nil {
+				gw.Close()
+			}
+			w.Close()
+		}()
+
+		for _, taxid = range taxids {
+			if showCount {
+				outfh.WriteString(fmt.Sprintf("%d\t%d", taxid, counts[taxid]))
+			} else {
+				outfh.WriteString(fmt.Sprintf("%d", taxid))
+			}
+			if taxondb != nil {
+				status = taxidStatus(taxondb, taxid)
+				if status != "valid" {
+					nInvalid++
+				}
+				outfh.WriteString("\t" + status)
+			}
+			outfh.WriteString("\n")
+		}
+
+		if taxondb != nil && nInvalid > 0 {
+			log.Warningf("%d of %d taxids not valid in the taxonomy", nInvalid, len(taxids))
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(taxidsCmd)
+
+	taxidsCmd.Flags().StringP("out-file", "o", "-", `out file ("-" for stdout, suffix .gz for gzipped out)`)
+	taxidsCmd.Flags().BoolP("count", "n", false, `output number of k-mers of every taxid in the second column`)
+	taxidsCmd.Flags().StringP("check-taxonomy", "", "", `check taxids against taxonomy files in this directory`)
+}
+
+// taxidStatus returns status of a taxid in a taxonomy:
+// valid, deleted, unknown, or merged:<new taxid>.
+func taxidStatus(t *unikmer.Taxonomy, taxid uint32) string {
+	if _, ok := t.Nodes[taxid]; ok {
+		return "valid"
+	}
+	if newTaxid, ok := t.MergeNodes[taxid]; ok {
+		return fmt.Sprintf("merged:%d", newTaxid)
+	}
+	if _, ok := t.DelNodes[taxid]; ok {
+		return "deleted"
+	}
+	return "unknown"
+}
@@ -22,6 +22,7 @@ package cmd
 
 import (
 	"bufio"
+	"container/list"
 	"fmt"
 	"io"
 	"os"
@@ -49,10 +50,19 @@ Attentions:
   4. All k-mers will loaded into RAM, for big input files,
      you can 'split' them first, 'tsplit' and then 'concat'
      for every taxid.
+  5. With -r/--rank, k-mers are split by their ancestors at the rank,
+     and only one input file is allowed, which is streamed once
+     and needs not to be sorted. Outputs keep flags of the input,
+     including taxids, strands and counts of k-mers. K-mers with no
+     ancestor at the rank are dropped. At most -M/--max-open-files
+     output files are kept open, k-mers of other taxids are written into
+     chunk files, which are concatenated in the end.
   
 Tips:
   1. Increasing value of -j/--threads can accelerates splitting stage,
      in cost of more memory occupation.
+  2. Use -r/--rank to create per-genus or per-species databases, e.g.,
+     'unikmer tsplit -r genus -O outdir in.unik'.
 
 `,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -62,6 +72,8 @@ Tips:
 		outdir := getFlagString(cmd, "out-dir")
 		force := getFlagBool(cmd, "force")
 		outPrefix := getFlagString(cmd, "out-prefix")
+		rank := strings.ToLower(getFlagString(cmd, "rank"))
+		maxOpenFiles := getFlagPositiveInt(cmd, "max-open-files")
 
 		if outPrefix == "" || strings.HasPrefix(outPrefix, ".") {
 			checkError(fmt.Errorf(`-o/--out-prefix should not be empty or starting with "."`))
@@ -80,9 +92,21 @@ Tips:
 		}
 
 		checkFileSuffix(extDataFile, files...)
+		setProvenance(opt, cmd, files)
 
 		var err error
 
+		var taxondb *unikmer.Taxonomy
+		if rank != "" {
+			if len(files) > 1 {
+				checkError(fmt.Errorf("only one input file allowed for -r/--rank"))
+			}
+			taxondb = loadTaxonomy(opt, true)
+			if _, ok := taxondb.Ranks[rank]; !ok {
+				checkError(fmt.Errorf("rank not found in taxonomy: %s", rank))
+			}
+		}
+
 		if outdir == "" {
 			if isStdin(files[0]) {
 				outdir = "stdin.tsplit"
@@ -109,6 +133,11 @@ Tips:
 			}
 		}
 
+		if rank != "" {
+			tsplitByRank(opt, files[0], taxondb, rank, outdir, outPrefix, maxOpenFiles)
+			return
+		}
+
 		m := make(map[uint32]*[]uint64, 1024) // taxid -> kmers
 
 		var infh *bufio.Reader
@@ -145,6 +174,10 @@ Tips:
 					canonical = reader.IsCanonical()
 					hasTaxid = !opt.IgnoreTaxid && reader.HasTaxidInfo()
 					mode = reader.Flag
+					if mode&(unikmer.UNIK_INCLUDESTRAND|unikmer.UNIK_INCLUDECOUNT) > 0 {
+						log.Warningf("strands and counts of k-mers are discarded, use -r/--rank to keep them")
+						mode &^= unikmer.UNIK_INCLUDESTRAND | unikmer.UNIK_INCLUDECOUNT
+					}
 					if !reader.IsSorted() {
 						checkError(fmt.Errorf("input should be sorted: %s", file))
 					}
@@ -247,7 +280,7 @@ Tips:
 				}()
 
 				_outFile := filepath.Join(outdir, fmt.Sprintf("%s.taxid-%d.k%d%s", outPrefix, taxid, k, extDataFile))
-				_outfh, _gw, _w, _err := outStream(_outFile, opt.Compress, opt.CompressionLevel)
+				_outfh, _gw, _w, _err := outStreamWithCodec(_outFile, opt.CompressionCodec, opt.CompressionLevel)
 				checkError(_err)
 				defer func() {
 					_outfh.Flush()
@@ -259,6 +292,7 @@ Tips:
 
 				_writer, err := unikmer.NewWriter(_outfh, k, mode)
 				checkError(err)
+				_writer.Description = opt.Provenance
 
 				_writer.Number = int64(len(*codes))
 				_writer.SetMaxTaxid(maxTaxid) // follow reader
@@ -296,4 +330,181 @@ func init() {
 	tsplitCmd.Flags().StringP("out-prefix", "o", "tsplit", `out file prefix`)
 	tsplitCmd.Flags().StringP("out-dir", "O", "", `output directory`)
 	tsplitCmd.Flags().BoolP("force", "", false, `overwrite output directory`)
+	tsplitCmd.Flags().StringP("rank", "r", "", `split k-mers by their ancestors at this rank, e.g., genus, in streaming`)
+	tsplitCmd.Flags().IntP("max-open-files", "M", 400, `max number of open files for -r/--rank`)
+}
+
+// tsplitByRank streams k-mers of a file into files of their ancestors
+// at the rank, writers are keyed by the ancestor taxids.
+// At most maxOpenFiles writers are kept open, the least recently used one
+// is closed when more are needed, and reopened as a new chunk file
+// later. Chunk files of a taxid are concatenated in the end, in the order
+// of writing, so sorted k-mers remain sorted.
+func tsplitByRank(opt *Options, file string, taxondb *unikmer.Taxonomy, rank string, outdir string, outPrefix string, maxOpenFiles int) {
+	infh, r, _, err := inStream(file)
+	checkError(err)
+	defer r.Close()
+
+	reader, err := unikmer.NewReader(infh)
+	checkError(err)
+
+	if opt.IgnoreTaxid || !reader.HasTaxidInfo() {
+		checkError(fmt.Errorf("no taxids found in file: %s", file))
+	}
+
+	type rankWriter struct {
+		file   string   // the output file
+		chunks []string // all files written, the first one is the output file
+		writer *unikmer.Writer
+		outfh  *bufio.Writer
+		gw     io.WriteCloser
+		w      *os.File
+		elem   *list.Element // position in the list of open writers
+		n      int64
+	}
+	writers := make(map[uint32]*rankWriter, 1024)
+	opened := list.New() // open writers, the most recently used one at front
+
+	maxTaxid := maxUint32N(reader.GetTaxidBytesLength()) // follow reader
+	newWriter := func(outFile string) (*unikmer.Writer, *bufio.Writer, io.WriteCloser, *os.File) {
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
+		checkError(err)
+
+		writer, err := unikmer.NewWriter(outfh, reader.K, reader.Flag)
+		checkError(err)
+		writer.Description = opt.Provenance
+		writer.SetMaxTaxid(maxTaxid)
+		if !reader.IsIncludeTaxid() {
+			checkError(writer.SetGlobalTaxid(reader.GetGlobalTaxid()))
+		}
+		return writer, outfh, gw, w
+	}
+	closeWriter := func(rw *rankWriter) {
+		checkError(rw.writer.Flush())
+		rw.outfh.Flush()
+		if rw.gw != nil {
+			rw.gw.Close()
+		}
+		rw.w.Close()
+		rw.writer = nil
+		opened.Remove(rw.elem)
+		rw.elem = nil
+	}
+	openWriter := func(rw *rankWriter) {
+		if opened.Len() >= maxOpenFiles {
+			closeWriter(opened.Back().Value.(*rankWriter))
+		}
+		outFile := rw.file
+		if len(rw.chunks) > 0 {
+			outFile = fmt.Sprintf("%s.chunk_%03d", rw.file, len(rw.chunks))
+		}
+		rw.chunks = append(rw.chunks, outFile)
+		rw.writer, rw.outfh, rw.gw, rw.w = newWriter(outFile)
+		rw.elem = opened.PushFront(rw)
+	}
+
+	var code uint64
+	var taxid, ancestor uint32
+	var rc bool
+	var count uint32
+	var ok bool
+	var rw *rankWriter
+	var nDropped int64
+	ancestors := make(map[uint32]uint32, 1024) // k-mers often share taxids
+	for {
+		code, rc, count, taxid, err = reader.ReadRecord()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			checkError(err)
+		}
+
+		if ancestor, ok = ancestors[taxid]; !ok {
+			ancestor = taxondb.AtRank(taxid, rank)
+			ancestors[taxid] = ancestor
+		}
+		if ancestor == 0 {
+			nDropped++
+			continue
+		}
+
+		if rw, ok = writers[ancestor]; !ok {
+			rw = &rankWriter{file: filepath.Join(outdir, fmt.Sprintf("%s.%s-%d.k%d%s", outPrefix, rank, ancestor, reader.K, extDataFile))}
+			writers[ancestor] = rw
+		}
+		if rw.writer == nil {
+			openWriter(rw)
+		} else {
+			opened.MoveToFront(rw.elem)
+		}
+		checkError(rw.writer.WriteRecord(code, rc, count, taxid))
+		rw.n++
+	}
+
+	var N int64
+	var nChunked int
+	for _, rw = range writers {
+		if rw.writer != nil {
+			closeWriter(rw)
+		}
+		if len(rw.chunks) > 1 {
+			concatRankChunks(rw.file, rw.chunks, newWriter)
+			nChunked++
+		}
+		N += rw.n
+		if opt.Verbose {
+			log.Infof("%d k-mers saved to %s", rw.n, rw.file)
+		}
+	}
+
+	if opt.Verbose {
+		if nChunked > 0 {
+			log.Infof("k-mers of %d taxids were written in chunks due to -M/--max-open-files, and concatenated", nChunked)
+		}
+		if nDropped > 0 {
+			log.Infof("%d k-mers with no ancestor at rank '%s' dropped", nDropped, rank)
+		}
+		log.Infof("%d k-mers belonging to %d taxids at rank '%s' saved to dir: %s", N, len(writers), rank, outdir)
+	}
+}
+
+// concatRankChunks concatenates chunk files into file, the first chunk file
+// is the file itself. All chunk files are removed.
+func concatRankChunks(file string, chunks []string,
+	newWriter func(string) (*unikmer.Writer, *bufio.Writer, io.WriteCloser, *os.File)) {
+	chunks[0] = file + ".chunk_000"
+	checkError(os.Rename(file, chunks[0]))
+
+	writer, outfh, gw, w := newWriter(file)
+
+	var code uint64
+	var taxid, count uint32
+	var rc bool
+	for _, chunk := range chunks {
+		infh, r, _, err := inStream(chunk)
+		checkError(err)
+
+		reader, err := unikmer.NewReader(infh)
+		checkError(err)
+		for {
+			code, rc, count, taxid, err = reader.ReadRecord()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+			}
+			checkError(writer.WriteRecord(code, rc, count, taxid))
+		}
+		r.Close()
+		checkError(os.Remove(chunk))
+	}
+
+	checkError(writer.Flush())
+	outfh.Flush()
+	if gw != nil {
+		gw.Close()
+	}
+	w.Close()
 }
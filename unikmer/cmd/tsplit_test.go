@@ -0,0 +1,160 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/shenwei356/unikmer"
+)
+
+// TestTsplitByRankMaxOpenFiles checks that outputs are the same when
+// writers are closed and reopened as chunk files.
+func TestTsplitByRankMaxOpenFiles(t *testing.T) {
+	dir := t.TempDir()
+	k := 21
+	mode := uint32(unikmer.UNIK_SORTED | unikmer.UNIK_INCLUDECOUNT | unikmer.UNIK_INCLUDETAXID)
+
+	nodes := filepath.Join(dir, "nodes.dmp")
+	err := ioutil.WriteFile(nodes, []byte("1\t|\t1\t|\tno rank\t|\n"+
+		"10\t|\t1\t|\tgenus\t|\n"+
+		"11\t|\t10\t|\tspecies\t|\n"+
+		"12\t|\t10\t|\tspecies\t|\n"+
+		"20\t|\t1\t|\tgenus\t|\n"+
+		"21\t|\t20\t|\tspecies\t|\n"+
+		"30\t|\t1\t|\tgenus\t|\n"+
+		"31\t|\t30\t|\tspecies\t|\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	taxondb, err := unikmer.NewTaxonomyWithRankFromNCBI(nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rand.Seed(11)
+	n := 1000
+	m := make(map[uint64]struct{}, n)
+	for len(m) < n {
+		m[rand.Uint64()&unikmer.MaxCode[k]] = struct{}{}
+	}
+	codes := make([]uint64, 0, n)
+	for code := range m {
+		codes = append(codes, code)
+	}
+	sort.Sort(unikmer.CodeSlice(codes))
+
+	file := filepath.Join(dir, "in.unik")
+	outfh, gw, w, err := outStreamWithCodec(file, codecGzip, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := unikmer.NewWriter(outfh, k, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	taxids := []uint32{11, 12, 21, 31, 1}
+	for i, code := range codes {
+		if err = writer.WriteRecord(code, false, uint32(i%7+1), taxids[rand.Intn(len(taxids))]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	outfh.Flush()
+	gw.Close()
+	w.Close()
+
+	type record struct {
+		code         uint64
+		count, taxid uint32
+	}
+	readRecords := func(file string) []record {
+		infh, r, _, err := inStream(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		reader, err := unikmer.NewReader(infh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reader.Flag != mode {
+			t.Errorf("flags not kept in %s: %d != %d", file, reader.Flag, mode)
+		}
+		records := make([]record, 0, n)
+		for {
+			code, _, count, taxid, err := reader.ReadRecord()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatal(err)
+			}
+			records = append(records, record{code: code, count: count, taxid: taxid})
+		}
+		return records
+	}
+
+	opt := &Options{CompressionCodec: codecGzip, CompressionLevel: -1}
+	results := make([]map[string][]record, 0, 2)
+	for _, maxOpenFiles := range []int{400, 1} {
+		outdir := filepath.Join(dir, "out")
+		if err = os.RemoveAll(outdir); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.MkdirAll(outdir, 0777); err != nil {
+			t.Fatal(err)
+		}
+		tsplitByRank(opt, file, taxondb, "genus", outdir, "tsplit", maxOpenFiles)
+
+		outFiles, err := filepath.Glob(filepath.Join(outdir, "*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := make(map[string][]record, len(outFiles))
+		for _, outFile := range outFiles {
+			records := readRecords(outFile)
+			for i := 1; i < len(records); i++ {
+				if records[i].code < records[i-1].code {
+					t.Fatalf("max-open-files %d: k-mers not sorted in %s", maxOpenFiles, outFile)
+				}
+			}
+			result[filepath.Base(outFile)] = records
+		}
+		if len(result) != 3 {
+			t.Errorf("max-open-files %d: 3 output files expected, %d found: %v", maxOpenFiles, len(result), outFiles)
+		}
+		results = append(results, result)
+	}
+
+	if !reflect.DeepEqual(results[0], results[1]) {
+		t.Errorf("outputs differ when writers are reopened")
+	}
+}
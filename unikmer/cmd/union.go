@@ -46,6 +46,10 @@ Tips:
   1. 'unikmer sort -u' is slightly faster in cost of more memory usage.
   2. For really huge number of k-mers, you can use 'unikmer sort -m 100M -u'.
   3. For large number of sorted .unik files, you can use 'unikmer merge'.
+  4. If all input files are sorted, they are k-way merged in streaming
+     instead of being loaded into RAM, and output is sorted. The output
+     is buffered in --max-memory to record the number of k-mers in the
+     header, which is unknown (-1) for bigger outputs.
 
 `,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -67,6 +71,7 @@ Tips:
 		}
 
 		checkFileSuffix(extDataFile, files...)
+		setProvenance(opt, cmd, files)
 
 		outFile := getFlagString(cmd, "out-prefix")
 		sortKmers := getFlagBool(cmd, "sort")
@@ -78,7 +83,7 @@ Tips:
 		if !isStdout(outFile) {
 			outFile += extDataFile
 		}
-		outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+		outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
 		checkError(err)
 		defer func() {
 			outfh.Flush()
@@ -90,6 +95,50 @@ Tips:
 
 		var writer *unikmer.Writer
 
+		if sorted, _k, _canonical, _hasTaxid := checkMergeableFiles(opt, files); sorted {
+			if opt.Verbose {
+				log.Infof("all input files are sorted, merging them in streaming")
+			}
+
+			readers := make([]*unikmer.Reader, len(files))
+			for i, file := range files {
+				infh, r, _, err := inStream(file)
+				checkError(err)
+				defer r.Close()
+
+				readers[i], err = unikmer.NewReader(infh)
+				checkError(err)
+			}
+
+			mode := uint32(unikmer.UNIK_SORTED)
+			if _canonical {
+				mode |= unikmer.UNIK_CANONICAL
+			}
+			if _hasTaxid {
+				mode |= unikmer.UNIK_INCLUDETAXID
+				taxondb = loadTaxonomy(opt, false)
+			}
+			writer, err = unikmer.NewWriter(outfh, _k, mode)
+			checkError(err)
+			writer.Description = opt.Provenance
+			writer.SetMaxTaxid(opt.MaxTaxid)
+
+			nw := newNumberedWriter(writer, getFlagMaxBuffered(cmd, "max-memory"))
+			var n int64
+			err = unikmer.UnionFunc(readers, taxondb, func(code uint64, taxid uint32) error {
+				n++
+				return nw.WriteCodeWithTaxid(code, taxid)
+			})
+			checkError(err)
+			checkError(nw.Close())
+
+			checkError(writer.Flush())
+			if opt.Verbose {
+				log.Infof("%d k-mers saved to %s", n, outFile)
+			}
+			return
+		}
+
 		var infh *bufio.Reader
 		var r *os.File
 		var reader *unikmer.Reader
@@ -143,6 +192,7 @@ Tips:
 						}
 						writer, err = unikmer.NewWriter(outfh, k, mode)
 						checkError(err)
+						writer.Description = opt.Provenance
 						writer.SetMaxTaxid(opt.MaxTaxid)
 					}
 				} else {
@@ -211,6 +261,7 @@ Tips:
 			}
 			writer, err = unikmer.NewWriter(outfh, k, mode)
 			checkError(err)
+			writer.Description = opt.Provenance
 			writer.SetMaxTaxid(opt.MaxTaxid)
 
 			if hasTaxid {
@@ -291,4 +342,52 @@ func init() {
 
 	unionCmd.Flags().StringP("out-prefix", "o", "-", `out file prefix ("-" for stdout)`)
 	unionCmd.Flags().BoolP("sort", "s", false, helpSort)
+	unionCmd.Flags().StringP("max-memory", "", "100M", helpMaxMemoryNumber)
+}
+
+// checkMergeableFiles checks if all input files are sorted and consistent,
+// so they can be merged in streaming. Stdin is not supported.
+func checkMergeableFiles(opt *Options, files []string) (sorted bool, k int, canonical bool, hasTaxid bool) {
+	k = -1
+	for _, file := range files {
+		if isStdin(file) {
+			return false, k, canonical, hasTaxid
+		}
+	}
+	sorted = true
+	for _, file := range files {
+		func() {
+			infh, r, _, err := inStream(file)
+			checkError(err)
+			defer r.Close()
+
+			reader, err := unikmer.NewReader(infh)
+			checkError(err)
+
+			if !reader.IsSorted() {
+				sorted = false
+			}
+
+			if k == -1 {
+				k = reader.K
+				canonical = reader.IsCanonical()
+				hasTaxid = !opt.IgnoreTaxid && reader.HasTaxidInfo()
+				return
+			}
+			if k != reader.K {
+				checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", reader.K, file, k))
+			}
+			if reader.IsCanonical() != canonical {
+				checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+			}
+			if !opt.IgnoreTaxid && reader.HasTaxidInfo() != hasTaxid {
+				if reader.HasTaxidInfo() {
+					checkError(fmt.Errorf(`taxid information not found in previous files, but found in this: %s`, file))
+				} else {
+					checkError(fmt.Errorf(`taxid information found in previous files, but missing in this: %s`, file))
+				}
+			}
+		}()
+	}
+	return sorted, k, canonical, hasTaxid
 }
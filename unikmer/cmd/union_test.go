@@ -0,0 +1,72 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/shenwei356/unikmer"
+)
+
+// headerNumber returns the number of k-mers in the header of a binary
+// file, and the number of k-mers actually read.
+func headerNumber(t *testing.T, file string) (int64, int64) {
+	infh, r, _, err := inStream(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	reader, err := unikmer.NewReader(infh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	for {
+		if _, _, err = reader.ReadCodeWithTaxid(); err != nil {
+			break
+		}
+		n++
+	}
+	return reader.Number, n
+}
+
+func TestUnionSortedNumber(t *testing.T) {
+	dir := t.TempDir()
+	_, files := genDiffData(t, dir, 4, 2000)
+	sortedFiles := []string{files[0], files[1], files[3]}
+
+	for _, maxMem := range []string{"100M", "1K"} {
+		outPrefix := filepath.Join(dir, "union")
+		if err := executeRootCmd(t, append([]string{"union", "--max-memory", maxMem, "-o", outPrefix}, sortedFiles...)...); err != nil {
+			t.Fatal(err)
+		}
+
+		number, n := headerNumber(t, outPrefix+extDataFile)
+		if maxMem == "1K" { // too many k-mers to buffer
+			n = -1
+		}
+		if number != n {
+			t.Errorf("max-memory %s: number in header %d, expected %d", maxMem, number, n)
+		}
+	}
+}
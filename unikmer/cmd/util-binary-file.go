@@ -20,4 +20,41 @@
 
 package cmd
 
+import (
+	"fmt"
+
+	"github.com/shenwei356/unikmer"
+)
+
 const extDataFile = ".unik"
+
+// checkSortedFiles checks if all input files are sorted and have
+// consistent K and 'canonical' flags, and returns K and the flag.
+// Stdin is not supported, as files are read more than once.
+func checkSortedFiles(files []string) (k int, canonical bool) {
+	k = -1
+	for _, file := range files {
+		if isStdin(file) {
+			checkError(fmt.Errorf("stdin not supported"))
+		}
+
+		info, err := unikmer.Stat(file)
+		checkError(err)
+
+		if !info.IsSorted() {
+			checkError(fmt.Errorf("input file should be sorted: %s", file))
+		}
+		if k == -1 {
+			k = info.K
+			canonical = info.IsCanonical()
+			continue
+		}
+		if k != info.K {
+			checkError(fmt.Errorf("K (%d) of binary file '%s' not equal to previous K (%d)", info.K, file, k))
+		}
+		if info.IsCanonical() != canonical {
+			checkError(fmt.Errorf(`'canonical' flags not consistent, please check with "unikmer stats"`))
+		}
+	}
+	return k, canonical
+}
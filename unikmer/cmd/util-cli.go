@@ -61,6 +61,12 @@ func getFlagUint32(cmd *cobra.Command, flag string) uint32 {
 	return value
 }
 
+func getFlagUint64(cmd *cobra.Command, flag string) uint64 {
+	value, err := cmd.Flags().GetUint64(flag)
+	checkError(err)
+	return value
+}
+
 func getFlagPositiveInt(cmd *cobra.Command, flag string) int {
 	value, err := cmd.Flags().GetInt(flag)
 	checkError(err)
@@ -236,9 +242,11 @@ func getFileListFromArgsAndFile(cmd *cobra.Command, args []string, checkFileFrom
 		}
 
 		if len(files) == 1 && isStdin(files[0]) {
+			inputFiles = append(inputFiles, _files...)
 			return _files
 		}
 		files = append(files, _files...)
 	}
+	inputFiles = append(inputFiles, files...)
 	return files
 }
@@ -28,17 +28,93 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/klauspost/compress/zstd"
 	gzip "github.com/klauspost/pgzip"
 )
 
 // BufferSize is size of buffer
 var BufferSize = 65536 //os.Getpagesize()
 
+// inputFiles are input files of the current command, which are not allowed
+// to be overwritten by outStream, unless overwriteInput is true.
+var inputFiles []string
+var overwriteInput bool
+
+// checkOverwritingInput returns an error if file is one of inputFiles.
+func checkOverwritingInput(file string) error {
+	if isStdout(file) {
+		return nil
+	}
+	fo, err := os.Stat(file)
+	if err != nil { // not existed
+		return nil
+	}
+	for _, in := range inputFiles {
+		if isStdin(in) {
+			continue
+		}
+		fi, err := os.Stat(in)
+		if err != nil || !os.SameFile(fi, fo) {
+			continue
+		}
+		if overwriteInput {
+			log.Warningf("input file will be overwritten: %s", in)
+			return nil
+		}
+		return fmt.Errorf("output file would overwrite input file: %s, use --overwrite-input to force", in)
+	}
+	return nil
+}
+
+// compression codecs of output files
+const (
+	codecNone = ""
+	codecGzip = "gzip"
+	codecZstd = "zstd"
+)
+
+// checkCompressionCodec checks the codec and its compression level.
+// Level -1 means the default level of the codec.
+func checkCompressionCodec(codec string, level int) error {
+	switch codec {
+	case codecGzip:
+		if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+			return fmt.Errorf("gzip: invalid compression level: %d", level)
+		}
+	case codecZstd:
+		if level != -1 && (level < 1 || level > 22) {
+			return fmt.Errorf("zstd: invalid compression level: %d, valid range: [1, 22]", level)
+		}
+	default:
+		return fmt.Errorf("unsupported compression codec: %s, available: %s, %s", codec, codecGzip, codecZstd)
+	}
+	return nil
+}
+
+// outStream is used for text outputs, which are gzipped if gzipped is true.
+// The level is shared with binary outputs via --compression-level, so the
+// default gzip level is used if it's not valid for gzip, e.g., a zstd level.
 func outStream(file string, gzipped bool, level int) (*bufio.Writer, io.WriteCloser, *os.File, error) {
+	if gzipped {
+		if checkCompressionCodec(codecGzip, level) != nil {
+			level = gzip.DefaultCompression
+		}
+		return outStreamWithCodec(file, codecGzip, level)
+	}
+	return outStreamWithCodec(file, codecNone, level)
+}
+
+// outStreamWithCodec is like outStream, but the output is compressed
+// with the given codec, which could be codecNone, codecGzip or codecZstd.
+func outStreamWithCodec(file string, codec string, level int) (*bufio.Writer, io.WriteCloser, *os.File, error) {
 	var w *os.File
 	if file == "-" {
 		w = os.Stdout
 	} else {
+		if err := checkOverwritingInput(file); err != nil {
+			return nil, nil, nil, err
+		}
+
 		dir := filepath.Dir(file)
 		fi, err := os.Stat(dir)
 		if err == nil && !fi.IsDir() {
@@ -54,17 +130,31 @@ func outStream(file string, gzipped bool, level int) (*bufio.Writer, io.WriteClo
 		}
 	}
 
-	if gzipped {
+	switch codec {
+	case codecGzip:
 		// gw := gzip.NewWriter(w)
 		gw, err := gzip.NewWriterLevel(w, level)
 		if err != nil {
 			return nil, nil, nil, fmt.Errorf("fail to write %s: %s", file, err)
 		}
 		return bufio.NewWriterSize(gw, BufferSize), gw, w, nil
+	case codecZstd:
+		encLevel := zstd.SpeedDefault
+		if level > 0 {
+			encLevel = zstd.EncoderLevelFromZstd(level)
+		}
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(encLevel))
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("fail to write %s: %s", file, err)
+		}
+		return bufio.NewWriterSize(zw, BufferSize), zw, w, nil
 	}
 	return bufio.NewWriterSize(w, BufferSize), nil, w, nil
 }
 
+// inStream opens a plain, gzipped or zstd-compressed file,
+// the format is detected by magic number.
+// The returned bool reports whether the file is compressed.
 func inStream(file string) (*bufio.Reader, *os.File, bool, error) {
 	var err error
 	var r *os.File
@@ -92,6 +182,15 @@ func inStream(file string) (*bufio.Reader, *os.File, bool, error) {
 			return nil, r, gzipped, fmt.Errorf("fail to create gzip reader for %s: %s", file, err)
 		}
 		br = bufio.NewReaderSize(gr, BufferSize)
+	} else if gzipped, err = isZstd(br); err != nil {
+		return nil, nil, gzipped, fmt.Errorf("fail to check is file (%s) zstd-compressed: %s", file, err)
+	} else if gzipped {
+		// synchronous decoding, so no goroutines are left after reading
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, r, gzipped, fmt.Errorf("fail to create zstd reader for %s: %s", file, err)
+		}
+		br = bufio.NewReaderSize(zr, BufferSize)
 	}
 	return br, r, gzipped, nil
 }
@@ -100,6 +199,13 @@ func isGzip(b *bufio.Reader) (bool, error) {
 	return checkBytes(b, []byte{0x1f, 0x8b})
 }
 
+func isZstd(b *bufio.Reader) (bool, error) {
+	if _, err := b.Peek(4); err != nil { // too short to be zstd-compressed
+		return false, nil
+	}
+	return checkBytes(b, []byte{0x28, 0xb5, 0x2f, 0xfd})
+}
+
 func checkBytes(b *bufio.Reader, buf []byte) (bool, error) {
 	m, err := b.Peek(len(buf))
 	if err != nil {
@@ -0,0 +1,182 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/shenwei356/unikmer"
+)
+
+// TestInStreamMixedCompression tests reading gzipped, zstd-compressed and
+// plain binary files in one run, the compression format should be detected
+// by magic number per file.
+func TestInStreamMixedCompression(t *testing.T) {
+	dir := t.TempDir()
+	k := 21
+	codes := []uint64{1, 5, 9, 1 << 20, 1 << 40}
+
+	// file name does not tell if the file is gzipped or not
+	files := []string{
+		filepath.Join(dir, "a.unik"),
+		filepath.Join(dir, "b.unik"),
+		filepath.Join(dir, "c.unik.gz"),
+		filepath.Join(dir, "d.unik.gz"),
+		filepath.Join(dir, "e.unik"),
+		filepath.Join(dir, "f.unik"),
+	}
+	codecs := []string{codecGzip, codecNone, codecGzip, codecNone, codecZstd, codecZstd}
+	levels := []int{flate.DefaultCompression, flate.DefaultCompression, flate.DefaultCompression,
+		flate.DefaultCompression, -1, 19}
+
+	for i, file := range files {
+		outfh, gw, w, err := outStreamWithCodec(file, codecs[i], levels[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer, err := unikmer.NewWriter(outfh, k, unikmer.UNIK_SORTED)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, code := range codes {
+			if err = writer.WriteCode(code); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = writer.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		outfh.Flush()
+		if gw != nil {
+			gw.Close()
+		}
+		w.Close()
+	}
+
+	checkFileSuffix(extDataFile, files...)
+
+	for i, file := range files {
+		infh, r, compressed, err := inStream(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compressed != (codecs[i] != codecNone) {
+			t.Errorf("%s: compressed should be %v, got %v", file, codecs[i] != codecNone, compressed)
+		}
+
+		reader, err := unikmer.NewReader(infh)
+		if err != nil {
+			t.Fatalf("%s: %s", file, err)
+		}
+		if reader.K != k {
+			t.Errorf("%s: K mismatch: %d != %d", file, reader.K, k)
+		}
+		var j int
+		for {
+			code, err := reader.ReadCode()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatalf("%s: %s", file, err)
+			}
+			if j >= len(codes) || code != codes[j] {
+				t.Errorf("%s: unexpected code #%d: %d", file, j+1, code)
+			}
+			j++
+		}
+		if j != len(codes) {
+			t.Errorf("%s: %d codes expected, %d read", file, len(codes), j)
+		}
+		r.Close()
+	}
+}
+
+func TestCheckOverwritingInput(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "a.unik")
+	other := filepath.Join(dir, "b.unik")
+	for _, file := range []string{in, other} {
+		if err := ioutil.WriteFile(file, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := filepath.Join(dir, "c.unik")
+	if err := os.Symlink(in, link); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		inputFiles = nil
+		overwriteInput = false
+	}()
+	inputFiles = []string{"-", in}
+
+	for _, c := range []struct {
+		file string
+		ok   bool
+	}{
+		{"-", true},
+		{other, true},
+		{filepath.Join(dir, "new.unik"), true},
+		{in, false},
+		{filepath.Join(dir, ".", "a.unik"), false},
+		{link, false},
+	} {
+		if err := checkOverwritingInput(c.file); (err == nil) != c.ok {
+			t.Errorf("%s: unexpected result: %v", c.file, err)
+		}
+	}
+
+	overwriteInput = true
+	if err := checkOverwritingInput(in); err != nil {
+		t.Errorf("no error expected with overwriteInput: %s", err)
+	}
+}
+
+func TestOutStreamZstdLevel(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "out.txt.gz")
+	outfh, gw, w, err := outStream(file, true, 15) // a zstd level
+	if err != nil {
+		t.Fatal(err)
+	}
+	outfh.WriteString("ACGTA\n")
+	outfh.Flush()
+	gw.Close()
+	w.Close()
+
+	br, r, gzipped, err := inStream(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if !gzipped {
+		t.Errorf("gzipped output expected")
+	}
+	if line, _ := br.ReadString('\n'); line != "ACGTA\n" {
+		t.Errorf("unexpected content: %q", line)
+	}
+}
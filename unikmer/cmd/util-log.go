@@ -0,0 +1,69 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/shenwei356/go-logging"
+	"github.com/spf13/cobra"
+)
+
+// LogBackend is the backend writing logs to stderr, which is set in the main package.
+var LogBackend logging.Backend
+
+var logFileFormat = logging.MustStringFormatter(
+	`%{time:2006-01-02 15:04:05.000} [%{level:.4s}] %{message}`,
+)
+
+var logFile *os.File
+
+// openLogFile redirects logs to the file given by global flag --log-file,
+// only errors are still written to stderr.
+func openLogFile(cmd *cobra.Command, args []string) {
+	file := getFlagString(cmd, "log-file")
+	if file == "" {
+		return
+	}
+
+	var err error
+	logFile, err = os.Create(file)
+	if err != nil {
+		checkError(fmt.Errorf("fail to create log file: %s", err))
+	}
+
+	stderrBackend := LogBackend
+	if stderrBackend == nil {
+		stderrBackend = logging.NewBackendFormatter(logging.NewLogBackend(os.Stderr, "", 0), logFileFormat)
+	}
+	leveled := logging.AddModuleLevel(stderrBackend)
+	leveled.SetLevel(logging.ERROR, "")
+
+	fileBackend := logging.NewBackendFormatter(logging.NewLogBackend(logFile, "", 0), logFileFormat)
+	logging.SetBackend(leveled, fileBackend)
+}
+
+func closeLogFile(cmd *cobra.Command, args []string) {
+	if logFile != nil {
+		logFile.Close()
+		logFile = nil
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+// memReporter samples runtime.MemStats periodically to record the peak
+// memory usage, which is logged at the end of a run with --report-mem.
+type memReporter struct {
+	interval time.Duration
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	peakHeapAlloc uint64
+	peakSys       uint64
+}
+
+var memRpt *memReporter
+
+func startMemReporter(interval time.Duration) *memReporter {
+	m := &memReporter{interval: interval, done: make(chan struct{})}
+	m.sample()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.sample()
+			case <-m.done:
+				return
+			}
+		}
+	}()
+	return m
+}
+
+func (m *memReporter) sample() {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	if ms.HeapAlloc > m.peakHeapAlloc {
+		m.peakHeapAlloc = ms.HeapAlloc
+	}
+	if ms.Sys > m.peakSys {
+		m.peakSys = ms.Sys
+	}
+}
+
+// stop stops sampling and logs the peak values.
+func (m *memReporter) stop() {
+	close(m.done)
+	m.wg.Wait()
+	m.sample()
+
+	if rss, ok := maxRSS(); ok {
+		log.Infof("peak memory: RSS: %s, HeapAlloc: %s, Sys: %s",
+			humanize.IBytes(rss), humanize.IBytes(m.peakHeapAlloc), humanize.IBytes(m.peakSys))
+	} else {
+		log.Infof("peak memory: HeapAlloc: %s, Sys: %s",
+			humanize.IBytes(m.peakHeapAlloc), humanize.IBytes(m.peakSys))
+	}
+}
+
+func startMemReport(cmd *cobra.Command, args []string) {
+	if getFlagBool(cmd, "report-mem") {
+		memRpt = startMemReporter(100 * time.Millisecond)
+	}
+}
+
+func stopMemReport(cmd *cobra.Command, args []string) {
+	if memRpt != nil {
+		memRpt.stop()
+		memRpt = nil
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// codePartition selects codes with code % n == i, so a job can be split
+// into n ones handling disjoint sets of k-mers.
+// A nil *codePartition selects all codes.
+type codePartition struct {
+	i, n uint64
+}
+
+func (p *codePartition) String() string {
+	return fmt.Sprintf("%d/%d", p.i, p.n)
+}
+
+func parsePartition(s string) (*codePartition, error) {
+	items := strings.Split(s, "/")
+	if len(items) != 2 {
+		return nil, fmt.Errorf("invalid partition: %s, format: i/n", s)
+	}
+	i, err := strconv.ParseUint(items[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid partition: %s, format: i/n", s)
+	}
+	n, err := strconv.ParseUint(items[1], 10, 64)
+	if err != nil || n == 0 {
+		return nil, fmt.Errorf("invalid partition: %s, n should be a positive integer", s)
+	}
+	if i >= n {
+		return nil, fmt.Errorf("invalid partition: %s, i should be in range of [0, n)", s)
+	}
+	return &codePartition{i: i, n: n}, nil
+}
+
+// getFlagPartition returns nil if the flag is not given.
+func getFlagPartition(cmd *cobra.Command, flag string) *codePartition {
+	value := getFlagString(cmd, flag)
+	if value == "" {
+		return nil
+	}
+	p, err := parsePartition(value)
+	checkError(err)
+	return p
+}
+
+// readCodeWithTaxid reads the next code in the partition.
+func (p *codePartition) readCodeWithTaxid(reader codeTaxidReader) (code uint64, taxid uint32, err error) {
+	for {
+		code, taxid, err = reader.ReadCodeWithTaxid()
+		if err != nil || p == nil || code%p.n == p.i {
+			return
+		}
+	}
+}
+
+const helpPartition = `only handle codes in a partition: code % n == i, format: i/n. ` +
+	`It's useful for splitting a job into n ones, of which outputs can be merged with "unikmer merge"`
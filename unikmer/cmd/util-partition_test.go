@@ -0,0 +1,41 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import "testing"
+
+func TestParsePartition(t *testing.T) {
+	for _, s := range []string{"0/1", "3/8", "7/8"} {
+		p, err := parsePartition(s)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", s, err)
+			continue
+		}
+		if p.String() != s {
+			t.Errorf("%s: unexpected result: %s", s, p)
+		}
+	}
+	for _, s := range []string{"", "3", "8/8", "1/0", "-1/8", "a/8", "1/2/3"} {
+		if _, err := parsePartition(s); err == nil {
+			t.Errorf("%s: error expected", s)
+		}
+	}
+}
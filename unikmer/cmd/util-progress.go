@@ -0,0 +1,133 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"time"
+
+	"github.com/shenwei356/unikmer"
+)
+
+// progressInterval is the minimum interval between two progress reports.
+var progressInterval = time.Second
+
+// progressCheckMask controls how often the clock is checked,
+// i.e., every 1<<16 k-mers.
+const progressCheckMask = 1<<16 - 1
+
+// progress reports the number of processed k-mers, percentage, rate
+// and ETA of reading a file. Reports are written as log lines,
+// so they do not corrupt other logs.
+// A nil *progress reports nothing.
+type progress struct {
+	name  string
+	total int64 // -1 for unknown
+	n     int64
+
+	start time.Time
+	last  time.Time
+}
+
+// newProgress returns nil if global flag --progress is not given.
+func newProgress(opt *Options, name string, total int64) *progress {
+	if !opt.Progress {
+		return nil
+	}
+	now := time.Now()
+	return &progress{name: name, total: total, start: now, last: now}
+}
+
+// add records n processed k-mers, and reports progress if it's time.
+func (p *progress) add(n int64) {
+	if p == nil {
+		return
+	}
+	old := p.n
+	p.n += n
+	if old|progressCheckMask == p.n|progressCheckMask {
+		return
+	}
+	now := time.Now()
+	if now.Sub(p.last) < progressInterval {
+		return
+	}
+	p.last = now
+	p.report(now)
+}
+
+// done reports the final progress.
+func (p *progress) done() {
+	if p == nil {
+		return
+	}
+	p.report(time.Now())
+}
+
+func (p *progress) report(now time.Time) {
+	elapsed := now.Sub(p.start)
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(p.n) / elapsed.Seconds()
+	}
+	if p.total <= 0 {
+		log.Infof("progress of %s: %d k-mers, %.2f M k-mers/s, elapsed: %s",
+			p.name, p.n, rate/1000000, elapsed.Round(time.Second))
+		return
+	}
+	var eta time.Duration
+	if rate > 0 && p.n < p.total {
+		eta = time.Duration(float64(p.total-p.n) / rate * float64(time.Second))
+	}
+	log.Infof("progress of %s: %d/%d k-mers (%.1f%%), %.2f M k-mers/s, ETA: %s",
+		p.name, p.n, p.total, float64(p.n)/float64(p.total)*100, rate/1000000, eta.Round(time.Second))
+}
+
+// codeTaxidReader reads codes along with taxids.
+type codeTaxidReader interface {
+	ReadCodeWithTaxid() (code uint64, taxid uint32, err error)
+}
+
+// progressReader wraps a unikmer.Reader, and ticks a progress
+// for every read code.
+type progressReader struct {
+	*unikmer.Reader
+	p *progress
+}
+
+// newProgressReader creates a progressReader with the total number of
+// k-mers from the header of the reader.
+func newProgressReader(opt *Options, file string, reader *unikmer.Reader) *progressReader {
+	return &progressReader{Reader: reader, p: newProgress(opt, file, reader.Number)}
+}
+
+// ReadCodeWithTaxid reads a code and its taxid, and ticks the progress.
+func (r *progressReader) ReadCodeWithTaxid() (code uint64, taxid uint32, err error) {
+	code, taxid, err = r.Reader.ReadCodeWithTaxid()
+	if err == nil {
+		r.p.add(1)
+	}
+	return
+}
+
+// done reports the final progress.
+func (r *progressReader) done() {
+	r.p.done()
+}
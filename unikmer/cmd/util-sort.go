@@ -28,10 +28,11 @@ import (
 	"path/filepath"
 
 	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
 )
 
 func dumpCodes2File(m []uint64, k int, mode uint32, outFile string, opt *Options, unique bool, repeated bool) int64 {
-	outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+	outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
 	checkError(err)
 	defer func() {
 		outfh.Flush()
@@ -43,6 +44,7 @@ func dumpCodes2File(m []uint64, k int, mode uint32, outFile string, opt *Options
 
 	writer, err := unikmer.NewWriter(outfh, k, mode)
 	checkError(err)
+	writer.Description = opt.Provenance
 	writer.SetMaxTaxid(opt.MaxTaxid)
 
 	var n int64
@@ -80,7 +82,7 @@ func dumpCodes2File(m []uint64, k int, mode uint32, outFile string, opt *Options
 }
 
 func dumpCodesTaxids2File(mt []unikmer.CodeTaxid, taxondb *unikmer.Taxonomy, k int, mode uint32, outFile string, opt *Options, unique bool, repeated bool) int64 {
-	outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+	outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
 	checkError(err)
 	defer func() {
 		outfh.Flush()
@@ -92,6 +94,7 @@ func dumpCodesTaxids2File(mt []unikmer.CodeTaxid, taxondb *unikmer.Taxonomy, k i
 
 	writer, err := unikmer.NewWriter(outfh, k, mode)
 	checkError(err)
+	writer.Description = opt.Provenance
 	writer.SetMaxTaxid(opt.MaxTaxid)
 
 	var n int64
@@ -156,6 +159,29 @@ func dumpCodesTaxids2File(mt []unikmer.CodeTaxid, taxondb *unikmer.Taxonomy, k i
 	return n
 }
 
+// getFlagIndexInterval returns the interval of index for sorted output,
+// 0 if flag --index is not given.
+func getFlagIndexInterval(cmd *cobra.Command, opt *Options) int {
+	if !getFlagBool(cmd, "index") {
+		return 0
+	}
+	if opt.Compress {
+		log.Warningf("flag --index only helps seeking in uncompressed files, please add -C/--no-compress")
+	}
+	return getFlagPositiveInt(cmd, "index-interval")
+}
+
+const helpIndex = `write an index of every --index-interval k-mers after data, for locating k-mers by seeking, e.g., in "unikmer grep" and "unikmer lookup"`
+
+// newSortedWriter creates a Writer for the final sorted output,
+// with an index of every opt.IndexInterval codes if it's positive.
+func newSortedWriter(opt *Options, w io.Writer, k int, mode uint32) (*unikmer.Writer, error) {
+	if opt.IndexInterval > 0 {
+		return unikmer.NewWriterWithIndex(w, k, mode, opt.IndexInterval)
+	}
+	return unikmer.NewWriter(w, k, mode)
+}
+
 func chunkFileName(outDir string, i int) string {
 	return filepath.Join(outDir, fmt.Sprintf("chunk_%03d", i)) + extDataFile
 }
@@ -192,7 +218,7 @@ func (h codeEntryHeap) Pop() interface{} {
 }
 
 func mergeChunksFile(opt *Options, taxondb *unikmer.Taxonomy, files []string, outFile string, k int, mode uint32, unique bool, repeated bool, finalRound bool) (int64, string) {
-	outfh, gw, w, err := outStream(outFile, opt.Compress, opt.CompressionLevel)
+	outfh, gw, w, err := outStreamWithCodec(outFile, opt.CompressionCodec, opt.CompressionLevel)
 	checkError(err)
 	defer func() {
 		outfh.Flush()
@@ -208,8 +234,13 @@ func mergeChunksFile(opt *Options, taxondb *unikmer.Taxonomy, files []string, ou
 		checkError(fmt.Errorf("taxon information is need when UNIK_INCLUDETAXID is one"))
 	}
 
-	writer, err = unikmer.NewWriter(outfh, k, mode)
+	if finalRound {
+		writer, err = newSortedWriter(opt, outfh, k, mode)
+	} else {
+		writer, err = unikmer.NewWriter(outfh, k, mode)
+	}
 	checkError(err)
+	writer.Description = opt.Provenance
 	writer.SetMaxTaxid(opt.MaxTaxid)
 
 	readers := make(map[int]*unikmer.Reader, len(files))
@@ -244,7 +275,7 @@ func mergeChunksFile(opt *Options, taxondb *unikmer.Taxonomy, files []string, ou
 			reader = readers[i]
 			n := 0
 			for {
-				code, taxid, err = reader.ReadCodeWithTaxid()
+				code, taxid, err = opt.Partition.readCodeWithTaxid(reader)
 				if err != nil {
 					if err == io.EOF {
 						delete(readers, i)
@@ -353,7 +384,7 @@ func mergeChunksFile(opt *Options, taxondb *unikmer.Taxonomy, files []string, ou
 
 		reader = readers[e.idx]
 		if reader != nil {
-			code, taxid, err = reader.ReadCodeWithTaxid()
+			code, taxid, err = opt.Partition.readCodeWithTaxid(reader)
 			if err != nil {
 				if err == io.EOF {
 					delete(readers, e.idx)
@@ -0,0 +1,105 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+)
+
+// codeTaxidWriter is the interface for writing codes with taxids,
+// implemented by *unikmer.Writer and *numberedWriter.
+type codeTaxidWriter interface {
+	WriteCodeWithTaxid(code uint64, taxid uint32) error
+}
+
+// bytesPerBufferedKmer is the size of an unikmer.CodeTaxid.
+const bytesPerBufferedKmer = 16
+
+const helpMaxMemoryNumber = `maximum memory for buffering k-mers of streamed sorted output, ` +
+	`so that the number of k-mers could be recorded in the header, supports K/M/G suffix. ` +
+	`For larger outputs, the number is unknown (-1) in the header`
+
+// getFlagMaxBuffered returns the maximum number of k-mers to buffer,
+// according to the flag of memory size.
+func getFlagMaxBuffered(cmd *cobra.Command, flag string) int {
+	maxMem, err := ParseByteSize(getFlagString(cmd, flag))
+	if err != nil {
+		checkError(fmt.Errorf("invalid value of flag --%s: %s", flag, err))
+	}
+	return maxMem / bytesPerBufferedKmer
+}
+
+// numberedWriter buffers at most maxBuffered k-mers before writing them
+// to the Writer, so the number of k-mers in the header is correct when
+// all k-mers fit in the buffer. Otherwise, buffered k-mers are written
+// and the number remains unknown (-1), as the header is written before
+// k-mers.
+type numberedWriter struct {
+	writer      *unikmer.Writer
+	maxBuffered int
+	buf         []unikmer.CodeTaxid
+	spilled     bool
+}
+
+// newNumberedWriter creates a numberedWriter.
+func newNumberedWriter(writer *unikmer.Writer, maxBuffered int) *numberedWriter {
+	return &numberedWriter{
+		writer:      writer,
+		maxBuffered: maxBuffered,
+		buf:         make([]unikmer.CodeTaxid, 0, minInt(maxBuffered, mapInitSize)),
+	}
+}
+
+// WriteCodeWithTaxid buffers or writes a code and its taxid.
+func (w *numberedWriter) WriteCodeWithTaxid(code uint64, taxid uint32) error {
+	if w.spilled {
+		return w.writer.WriteCodeWithTaxid(code, taxid)
+	}
+	w.buf = append(w.buf, unikmer.CodeTaxid{Code: code, Taxid: taxid})
+	if len(w.buf) < w.maxBuffered {
+		return nil
+	}
+	w.spilled = true
+	return w.writeBuffered()
+}
+
+// Close writes buffered k-mers, with the number of k-mers set in the
+// header if they are all buffered. The Writer is not flushed.
+func (w *numberedWriter) Close() error {
+	if w.spilled {
+		return nil
+	}
+	w.writer.Number = int64(len(w.buf))
+	return w.writeBuffered()
+}
+
+func (w *numberedWriter) writeBuffered() error {
+	for _, ct := range w.buf {
+		if err := w.writer.WriteCodeWithTaxid(ct.Code, ct.Taxid); err != nil {
+			return err
+		}
+	}
+	w.buf = nil
+	return nil
+}
@@ -21,12 +21,13 @@
 package cmd
 
 import (
-	"compress/flate"
 	"fmt"
+	"hash/fnv"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/shenwei356/unikmer"
 	"github.com/shenwei356/util/pathutil"
@@ -46,6 +47,7 @@ type Options struct {
 	NumCPUs          int
 	Verbose          bool
 	Compress         bool
+	CompressionCodec string // codecNone if Compress is false
 	Compact          bool
 	CompressionLevel int
 	MaxTaxid         uint32
@@ -53,13 +55,23 @@ type Options struct {
 	DataDir          string
 	NodesFile        string
 	CacheLCA         bool
+
+	ValidateTaxonomy bool
+
+	NoProvenance bool
+	Provenance   []byte // description stamped into output binary files
+
+	Progress bool // report progress of reading input files
+
+	Partition *codePartition // only handle codes in a partition, nil for all
+
+	IndexInterval int // index every N codes of sorted output, 0 for no index
 }
 
 func getOptions(cmd *cobra.Command) *Options {
 	level := getFlagInt(cmd, "compression-level")
-	if level < flate.HuffmanOnly || level > flate.BestCompression {
-		checkError(fmt.Errorf("gzip: invalid compression level: %d", level))
-	}
+	codec := strings.ToLower(getFlagString(cmd, "compress"))
+	checkError(checkCompressionCodec(codec, level))
 
 	var val, dataDir string
 	if val = os.Getenv("UNIKMER_DB"); val != "" {
@@ -73,10 +85,21 @@ func getOptions(cmd *cobra.Command) *Options {
 		checkError(fmt.Errorf("are your seriously? %d threads? It will exhaust your RAM", threads))
 	}
 
+	mapInitSize = getFlagPositiveInt(cmd, "map-init-size")
+	unikmer.StrictOpen = getFlagBool(cmd, "strict-open")
+	unikmer.CanonicalMismatchHandler = warnCanonicalMismatch
+	overwriteInput = getFlagBool(cmd, "overwrite-input")
+
+	compress := !getFlagBool(cmd, "no-compress")
+	if !compress {
+		codec = codecNone
+	}
+
 	return &Options{
 		NumCPUs:          threads,
 		Verbose:          getFlagBool(cmd, "verbose"),
-		Compress:         !getFlagBool(cmd, "no-compress"),
+		Compress:         compress,
+		CompressionCodec: codec,
 		Compact:          getFlagBool(cmd, "compact"),
 		CompressionLevel: level,
 
@@ -85,7 +108,52 @@ func getOptions(cmd *cobra.Command) *Options {
 
 		DataDir:  dataDir,
 		CacheLCA: true, // getFlagBool(cmd, "cache-lca"),
+
+		ValidateTaxonomy: getFlagBool(cmd, "validate-taxonomy"),
+
+		NoProvenance: getFlagBool(cmd, "no-provenance"),
+
+		Progress: getFlagBool(cmd, "progress"),
+	}
+}
+
+// setProvenance records how the output file is produced, including the
+// subcommand, version, a hash of input file names and the time.
+// It will be written as the description of output binary file.
+func setProvenance(opt *Options, cmd *cobra.Command, files []string) {
+	if opt.NoProvenance {
+		opt.Provenance = nil
+		return
 	}
+	h := fnv.New64a()
+	for _, file := range files {
+		h.Write([]byte(file))
+		h.Write([]byte{'\n'})
+	}
+	opt.Provenance = []byte(fmt.Sprintf("unikmer %s v%s; %d input(s): %016x; %s",
+		cmd.Name(), VERSION, len(files), h.Sum64(), time.Now().UTC().Format(time.RFC3339)))
+}
+
+// exitCodeEmptyResult is the exit code for empty result with flag --fail-if-empty.
+const exitCodeEmptyResult = 2
+
+// exitIfEmpty exits with exitCodeEmptyResult if failIfEmpty is true and n is 0.
+// It should be deferred before deferring closing output file,
+// so the output is completely written before exiting.
+func exitIfEmpty(failIfEmpty bool, n *int) {
+	if failIfEmpty && *n == 0 {
+		log.Warningf("exit with code %d for empty result", exitCodeEmptyResult)
+		exit(exitCodeEmptyResult)
+	}
+}
+
+// exit runs the hooks of RootCmd.PersistentPostRun, which are skipped
+// by os.Exit, i.e., reporting memory usage and closing the log file,
+// and then exits with the code.
+func exit(code int) {
+	stopMemReport(nil, nil)
+	closeLogFile(nil, nil)
+	os.Exit(code)
 }
 
 func checkDataDir(opt *Options) {
@@ -142,6 +210,13 @@ func loadTaxonomy(opt *Options, withRank bool) *unikmer.Taxonomy {
 		log.Infof("%d merged nodes loaded", len(t.MergeNodes))
 	}
 
+	if opt.ValidateTaxonomy {
+		if opt.Verbose {
+			log.Infof("validating Taxonomy")
+		}
+		checkError(t.Validate())
+	}
+
 	if opt.CacheLCA {
 		t.CacheLCA()
 	}
@@ -224,13 +299,43 @@ func extendDegenerateSeq(s []byte) (dseqs [][]byte, err error) {
 	return dseqs, nil
 }
 
+// isACGT marks bases of A, C, G, T and their lower cases,
+// other bases (e.g., N) are encoded as one of ACGT by the k-mer encoder.
+var isACGT [256]bool
+
+func init() {
+	for _, b := range []byte("ACGTacgt") {
+		isACGT[b] = true
+	}
+}
+
+// onlyACGT tells if the sequence contains only A, C, G and T.
+func onlyACGT(s []byte) bool {
+	for _, b := range s {
+		if !isACGT[b] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseCode parses a code in decimal, or hexadecimal with the prefix "0x".
+func parseCode(s string) (uint64, error) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return strconv.ParseUint(s[2:], 16, 64)
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
 func checkFileSuffix(suffix string, files ...string) {
 	for _, file := range files {
 		if isStdin(file) {
 			continue
 		}
 
-		if suffix != "" && !strings.HasSuffix(file, suffix) {
+		// gzip-compressed or not is detected by magic number, not file extension,
+		// so files with extra suffix of ".gz" are also accepted.
+		if suffix != "" && !strings.HasSuffix(file, suffix) && !strings.HasSuffix(file, suffix+".gz") {
 			checkError(fmt.Errorf("input should be stdin or %s file: %s", suffix, file))
 		}
 	}
@@ -316,3 +421,11 @@ var maxUint32 = uint64(^uint32(0))
 func maxUint32N(n int) uint32 {
 	return (1 << (n << 3)) - 1
 }
+
+// warnCanonicalMismatch logs a warning for a binary file of which sampled
+// k-mers contradict the 'canonical' flag, instead of exiting.
+func warnCanonicalMismatch(reader *unikmer.Reader) error {
+	log.Warningf(`k-mers contradict the 'canonical' flag (%v) of a binary file (K=%d), the file might be mislabeled, please check with "unikmer stats"`,
+		reader.IsCanonical(), reader.K)
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright © 2018-2020 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shenwei356/unikmer"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// executeRootCmd runs RootCmd with args. Flags of all commands are reset
+// to their defaults before running, as cobra keeps the values of previous
+// runs, and global states set by getOptions and getFileList are reset
+// after the test.
+func executeRootCmd(tb testing.TB, args ...string) error {
+	resetFlags(RootCmd)
+	resetGlobals()
+	tb.Cleanup(resetGlobals)
+
+	RootCmd.SetArgs(args)
+	return RootCmd.Execute()
+}
+
+// resetFlags resets changed flags of cmd and its subcommands to defaults.
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		if v, ok := f.Value.(pflag.SliceValue); ok {
+			var vals []string
+			if def := strings.Trim(f.DefValue, "[]"); def != "" {
+				vals = strings.Split(def, ",")
+			}
+			v.Replace(vals)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.PersistentFlags().VisitAll(reset)
+	cmd.Flags().VisitAll(reset)
+	for _, c := range cmd.Commands() {
+		resetFlags(c)
+	}
+}
+
+// resetGlobals resets global variables to the values of default flags.
+func resetGlobals() {
+	mapInitSize = 1 << 20
+	unikmer.StrictOpen = false
+	unikmer.CanonicalMismatchHandler = nil
+	overwriteInput = false
+	inputFiles = nil
+}
+
+func TestExecuteRootCmdResetsStates(t *testing.T) {
+	dir := t.TempDir()
+	_, files := genDiffData(t, dir, 1, 100)
+	if err := executeRootCmd(t, "head", "--strict-open", "--overwrite-input",
+		"-n", "1", "-o", dir+"/a", files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if !unikmer.StrictOpen || !overwriteInput || len(inputFiles) == 0 {
+		t.Fatalf("global states not set by flags")
+	}
+
+	resetFlags(RootCmd)
+	resetGlobals()
+	for _, name := range []string{"strict-open", "overwrite-input"} {
+		if f := RootCmd.PersistentFlags().Lookup(name); f.Changed || f.Value.String() != f.DefValue {
+			t.Errorf("flag --%s not reset: %s", name, f.Value)
+		}
+	}
+	if unikmer.StrictOpen || overwriteInput || len(inputFiles) != 0 {
+		t.Errorf("global states not reset")
+	}
+}
@@ -38,10 +38,17 @@ var viewCmd = &cobra.Command{
 	Short: "Read and output binary format to plain text",
 	Long: `Read and output binary format to plain text
 
+Output format:
+  Default: k-mer, followed by the code (-n/--show-code), taxid
+           (-t/--show-taxid) and count (--show-count) if given.
+  FASTA/Q: the code as the header, followed by the taxid and count
+           if given.
+
 Attentions:
   1. The 'canonical' flags of all files should be consistent.
   2. Input files should ALL have or don't have taxid information.
-  
+  3. Counts are 1 for files without counts.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		opt := getOptions(cmd)
@@ -69,6 +76,7 @@ Attentions:
 		outFastq := getFlagBool(cmd, "fastq")
 		showCodeOnly := getFlagBool(cmd, "show-code-only")
 		showTaxidOnly := getFlagBool(cmd, "show-taxid-only")
+		showCount := getFlagBool(cmd, "show-count")
 
 		showTaxid := getFlagBool(cmd, "show-taxid")
 		if opt.IgnoreTaxid {
@@ -89,7 +97,9 @@ Attentions:
 		var r *os.File
 		var reader *unikmer.Reader
 		var kcode unikmer.KmerCode
-		var taxid uint32
+		var taxid, count uint32
+		var includeTaxid bool
+		var extra string // code, taxid and count
 
 		var k int = -1
 		var hasTaxid bool
@@ -130,36 +140,53 @@ Attentions:
 					quality = strings.Repeat("g", reader.K)
 				}
 
+				includeTaxid = reader.IsIncludeTaxid()
+				kcode.K = reader.K
 				for {
-					kcode, taxid, err = reader.ReadWithTaxid()
+					kcode.Code, count, err = reader.ReadCodeWithCount()
 					if err != nil {
 						if err == io.EOF {
 							break
 						}
 						checkError(err)
 					}
+					if includeTaxid {
+						taxid, err = reader.ReadTaxid()
+						checkError(err)
+					} else {
+						taxid = reader.GetGlobalTaxid()
+					}
 
 					// outfh.WriteString(fmt.Sprintf("%s\n", kcode.Bytes())) // slower
-					if outFasta {
+					if outFasta || outFastq {
+						extra = ""
 						if showTaxid {
-							outfh.WriteString(fmt.Sprintf(">%d %d\n%s\n", kcode.Code, taxid, kcode.String()))
-						} else {
-							outfh.WriteString(fmt.Sprintf(">%d\n%s\n", kcode.Code, kcode.String()))
+							extra += fmt.Sprintf(" %d", taxid)
 						}
-					} else if outFastq {
-						if showTaxid {
-							outfh.WriteString(fmt.Sprintf("@%d %d\n%s\n+\n%s\n", kcode.Code, taxid, kcode.String(), quality))
+						if showCount {
+							extra += fmt.Sprintf(" %d", count)
+						}
+						if outFasta {
+							outfh.WriteString(fmt.Sprintf(">%d%s\n%s\n", kcode.Code, extra, kcode.String()))
 						} else {
-							outfh.WriteString(fmt.Sprintf("@%d\n%s\n+\n%s\n", kcode.Code, kcode.String(), quality))
+							outfh.WriteString(fmt.Sprintf("@%d%s\n%s\n+\n%s\n", kcode.Code, extra, kcode.String(), quality))
 						}
-					} else if showTaxid {
-						outfh.WriteString(fmt.Sprintf("%s\t%d\n", kcode.String(), taxid))
 					} else if showTaxidOnly {
 						outfh.WriteString(fmt.Sprintf("%d\n", taxid))
 					} else if showCodeOnly {
 						outfh.WriteString(fmt.Sprintf("%d\n", kcode.Code))
-					} else if showCode {
-						outfh.WriteString(fmt.Sprintf("%s\t%d\n", kcode.String(), kcode.Code))
+					} else if showCode || showTaxid || showCount {
+						extra = ""
+						if showCode {
+							extra += fmt.Sprintf("\t%d", kcode.Code)
+						}
+						if showTaxid {
+							extra += fmt.Sprintf("\t%d", taxid)
+						}
+						if showCount {
+							extra += fmt.Sprintf("\t%d", count)
+						}
+						outfh.WriteString(kcode.String() + extra + "\n")
 					} else {
 						outfh.WriteString(kcode.String() + "\n")
 					}
@@ -180,4 +207,5 @@ func init() {
 	viewCmd.Flags().BoolP("fastq", "q", false, `output in FASTQ format, with encoded integer as FASTQ header`)
 	viewCmd.Flags().BoolP("show-taxid", "t", false, "show taxid")
 	viewCmd.Flags().BoolP("show-taxid-only", "T", false, "show taxid only")
+	viewCmd.Flags().BoolP("show-count", "", false, "show count of k-mers")
 }
@@ -42,6 +42,7 @@ func init() {
 	backend := logging.NewLogBackend(stderr, "", 0)
 	backendFormatter := logging.NewBackendFormatter(backend, logFormat)
 	logging.SetBackend(backendFormatter)
+	cmd.LogBackend = backendFormatter
 }
 
 func main() {